@@ -1,46 +0,0 @@
-package vers42
-
-/******
- * YAML data representations
- ******/
-
-//type nodeYaml struct {
-type NodeYaml struct { // <-Needs to be exported
-	NodeProfiles map[string]*NodeConf
-	Nodes        map[string]*NodeConf
-}
-
-type NodeConf struct {
-	Comment        string              `yaml:"comment,omitempty"`
-	ClusterName    string              `yaml:"cluster name,omitempty"`
-	ContainerName  string              `yaml:"container name,omitempty"`
-	Ipxe           string              `yaml:"ipxe template,omitempty"`
-	KernelVersion  string              `yaml:"kernel version,omitempty"`
-	KernelArgs     string              `yaml:"kernel args,omitempty"`
-	IpmiUserName   string              `yaml:"ipmi username,omitempty"`
-	IpmiPassword   string              `yaml:"ipmi password,omitempty"`
-	IpmiIpaddr     string              `yaml:"ipmi ipaddr,omitempty"`
-	IpmiNetmask    string              `yaml:"ipmi netmask,omitempty"`
-	IpmiPort       string              `yaml:"ipmi port,omitempty"`
-	IpmiGateway    string              `yaml:"ipmi gateway,omitempty"`
-	IpmiInterface  string              `yaml:"ipmi interface,omitempty"`
-	RuntimeOverlay string              `yaml:"runtime overlay,omitempty"`
-	SystemOverlay  string              `yaml:"system overlay,omitempty"`
-	Init           string              `yaml:"init,omitempty"`
-	Root           string              `yaml:"root,omitempty"`
-	Discoverable   bool                `yaml:"discoverable,omitempty"`
-	Profiles       []string            `yaml:"profiles,omitempty"`
-	NetDevs        map[string]*NetDevs `yaml:"network devices,omitempty"`
-	Keys           map[string]string   `yaml:"keys,omitempty"`
-}
-
-type NetDevs struct {
-	Type    string `yaml:"type,omitempty"`
-	Default bool   `yaml:"default"`
-	Hwaddr  string
-	Ipaddr  string
-	IpCIDR  string
-	Prefix  string
-	Netmask string
-	Gateway string `yaml:"gateway,omitempty"`
-}
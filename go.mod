@@ -8,9 +8,12 @@ require (
 	github.com/containers/storage v1.30.0
 	github.com/coreos/go-systemd v0.0.0-20190321100706-95778dfbb74e
 	github.com/creasty/defaults v1.5.2
+	github.com/docker/go-units v0.4.0
 	github.com/fatih/color v1.13.0
 	github.com/google/uuid v1.1.2
 	github.com/manifoldco/promptui v0.8.0
+	github.com/mattn/go-sqlite3 v1.14.15
+	github.com/opencontainers/go-digest v1.0.0
 	github.com/opencontainers/image-spec v1.0.2-0.20190823105129-775207bd45b6
 	github.com/opencontainers/umoci v0.4.6
 	github.com/pkg/errors v0.9.1
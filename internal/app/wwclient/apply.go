@@ -0,0 +1,416 @@
+package wwclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/hpcng/warewulf/internal/pkg/cpio"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+const (
+	// files of the runtime overlay which were applied last
+	manifestFile = "var/lib/warewulf/wwclient.manifest"
+	// commands which are run after files of the runtime overlay changed
+	hooksFile = "etc/warewulf/wwclient.hooks"
+)
+
+/*
+The runtime overlay which was applied last, Files are the regular files and
+symlinks relative to the root
+*/
+type manifest struct {
+	Digest string   `json:"digest"`
+	Files  []string `json:"files"`
+}
+
+func loadManifest(root string) manifest {
+	var ret manifest
+	data, err := ioutil.ReadFile(filepath.Join(root, manifestFile))
+	if err != nil {
+		return ret
+	}
+	if err := json.Unmarshal(data, &ret); err != nil {
+		wwlog.Warn("Ignoring invalid manifest %s: %s", manifestFile, err)
+		return manifest{}
+	}
+	return ret
+}
+
+func saveManifest(root string, m manifest) error {
+	sort.Strings(m.Files)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	fileName := filepath.Join(root, manifestFile)
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(fileName, data, 0644)
+}
+
+/*
+Name of a member relative to the root, members outside of the root are
+rejected. Returns an empty name for the root itself.
+*/
+func memberPath(name string) (string, error) {
+	name = strings.TrimPrefix(name, "./")
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("absolute path in overlay: %s", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("path outside of the root in overlay: %s", name)
+		}
+	}
+	name = path.Clean(name)
+	if name == "." {
+		return "", nil
+	}
+	return name, nil
+}
+
+// owners are only set when running as root, like cpio does
+var setOwner = os.Geteuid() == 0
+
+func sameMode(fi os.FileInfo, hdr *cpio.Header) bool {
+	if fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) != hdr.Perm() {
+		return false
+	}
+	if !setOwner {
+		return true
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == hdr.Uid && int(stat.Gid) == hdr.Gid
+}
+
+/*
+Creates a directory, an existing directory or a symlink to one is kept
+*/
+func applyDir(target string, hdr *cpio.Header) error {
+	fi, err := os.Stat(target)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%s exists and is not a directory", target)
+	}
+	fi, err = os.Lstat(target)
+	if err != nil || !fi.IsDir() || sameMode(fi, hdr) {
+		return err
+	}
+	if setOwner {
+		if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(target, hdr.Perm())
+}
+
+/*
+Writes a file to a temporary file next to it and renames it, so that the
+file is never seen partially written. Returns false if the file was
+unchanged.
+*/
+func applyFile(target string, hdr *cpio.Header, content []byte) (bool, error) {
+	fi, err := os.Lstat(target)
+	if err == nil && fi.Mode().IsRegular() && sameMode(fi, hdr) && fi.Size() == int64(len(content)) {
+		current, err := ioutil.ReadFile(target)
+		if err == nil && bytes.Equal(current, content) {
+			return false, nil
+		}
+	} else if err == nil && fi.IsDir() {
+		return false, fmt.Errorf("%s is a directory", target)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(target), "."+filepath.Base(target)+".wwclient")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	// chown clears setuid and setgid, so it comes first
+	if setOwner {
+		if err := os.Chown(tmp.Name(), hdr.Uid, hdr.Gid); err != nil {
+			return false, err
+		}
+	}
+	if err := os.Chmod(tmp.Name(), hdr.Perm()); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp.Name(), target)
+}
+
+/*
+Replaces a symlink atomically, returns false if it was unchanged
+*/
+func applySymlink(target string, hdr *cpio.Header, linkTarget string) (bool, error) {
+	fi, err := os.Lstat(target)
+	if err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if current, err := os.Readlink(target); err == nil && current == linkTarget {
+			return false, nil
+		}
+	} else if err == nil && fi.IsDir() {
+		return false, fmt.Errorf("%s is a directory", target)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	tmp := filepath.Join(filepath.Dir(target), fmt.Sprintf(".%s.wwclient%d", filepath.Base(target), os.Getpid()))
+	_ = os.Remove(tmp)
+	if err := os.Symlink(linkTarget, tmp); err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+	if setOwner {
+		if err := os.Lchown(tmp, hdr.Uid, hdr.Gid); err != nil {
+			return false, err
+		}
+	}
+	return true, os.Rename(tmp, target)
+}
+
+/*
+Removes a file which is no longer part of the overlay, directories are kept
+*/
+func removeStale(target string) (bool, error) {
+	fi, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if fi.IsDir() {
+		return false, nil
+	}
+	return true, os.Remove(target)
+}
+
+/*
+Applies the gzip compressed overlay in r below root. Files of the previously
+applied overlay which are no longer part of it are removed. Returns the
+digest of the overlay, the same as warewulfd uses for its ETag, and the
+files which were changed, added or removed. The digest is empty if the
+overlay could not be applied completely.
+*/
+func applyOverlay(root string, r io.Reader) (string, []string, error) {
+	old := loadManifest(root)
+	var changed []string
+	files := make(map[string]bool)
+	failed := 0
+
+	digester := cpio.NewDigester()
+	gz, readErr := gzip.NewReader(r)
+	if readErr == nil {
+		defer gz.Close()
+		cr := cpio.NewReader(gz)
+		for {
+			hdr, err := cr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				readErr = err
+				break
+			}
+			content, err := ioutil.ReadAll(io.TeeReader(cr, digester.Add(hdr)))
+			if err != nil {
+				readErr = err
+				break
+			}
+			name, err := memberPath(hdr.Name)
+			if err != nil {
+				wwlog.Warn("%s", err)
+				failed++
+				continue
+			}
+			if name == "" {
+				continue
+			}
+			target := filepath.Join(root, name)
+			var ok bool
+			switch {
+			case hdr.IsDir():
+				err = applyDir(target, hdr)
+			case hdr.IsRegular():
+				files[name] = true
+				ok, err = applyFile(target, hdr, content)
+			case hdr.IsSymlink():
+				files[name] = true
+				ok, err = applySymlink(target, hdr, string(content))
+			default:
+				wwlog.Warn("Skipping %s, unsupported file type: %o", name, hdr.Mode&cpio.ModeType)
+				continue
+			}
+			if err != nil {
+				wwlog.Warn("Could not apply %s: %s", name, err)
+				failed++
+				continue
+			}
+			if ok {
+				wwlog.Verbose("Updated %s", name)
+				changed = append(changed, name)
+			}
+		}
+	}
+
+	if readErr != nil || failed > 0 {
+		// keep track of the old files, they are removed after the next
+		// complete update
+		for _, name := range old.Files {
+			files[name] = true
+		}
+		err := saveManifest(root, manifest{Files: mapKeys(files)})
+		if readErr != nil {
+			return "", changed, util.FirstError(errors.Wrap(readErr, "could not read overlay"), err)
+		}
+		return "", changed, util.FirstError(fmt.Errorf("%d files of the overlay could not be applied", failed), err)
+	}
+
+	for _, name := range old.Files {
+		if files[name] {
+			continue
+		}
+		removed, err := removeStale(filepath.Join(root, name))
+		if err != nil {
+			wwlog.Warn("Could not remove %s: %s", name, err)
+			files[name] = true
+			continue
+		}
+		if removed {
+			wwlog.Verbose("Removed %s", name)
+			changed = append(changed, name)
+		}
+	}
+	digest := digester.Sum()
+	return digest, changed, saveManifest(root, manifest{Digest: digest, Files: mapKeys(files)})
+}
+
+func mapKeys(m map[string]bool) []string {
+	ret := make([]string, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+/*
+A command which is run when a file matching pattern was changed
+*/
+type hook struct {
+	pattern string
+	command string
+}
+
+/*
+Reads the hooks of the overlay, every line has a pattern of absolute paths
+(see path.Match) and the command separated by whitespace, e.g.
+
+	/etc/ssh/sshd_config systemctl try-reload-or-restart sshd
+*/
+func loadHooks(root string) []hook {
+	var ret []hook
+	f, err := os.Open(filepath.Join(root, hooksFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			wwlog.Warn("Could not read hooks: %s", err)
+		}
+		return ret
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			wwlog.Warn("Ignoring hook without command: %s", line)
+			continue
+		}
+		h := hook{pattern: line[:i], command: strings.TrimSpace(line[i:])}
+		if _, err := path.Match(h.pattern, ""); err != nil {
+			wwlog.Warn("Ignoring hook with invalid pattern: %s", line)
+			continue
+		}
+		ret = append(ret, h)
+	}
+	return ret
+}
+
+/*
+Returns the commands of the hooks which match one of the changed files, in
+the order of the hooks file and every command once
+*/
+func matchHooks(hooks []hook, changed []string) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, h := range hooks {
+		if seen[h.command] {
+			continue
+		}
+		for _, name := range changed {
+			if ok, _ := path.Match(h.pattern, "/"+name); ok {
+				seen[h.command] = true
+				ret = append(ret, h.command)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+/*
+Runs the hooks of the overlay for the changed files, if dryRun is set they
+are only logged
+*/
+func runHooks(root string, changed []string, dryRun bool) {
+	if len(changed) == 0 {
+		return
+	}
+	for _, command := range matchHooks(loadHooks(root), changed) {
+		if dryRun {
+			wwlog.Info("Not running hook: %s", command)
+			continue
+		}
+		wwlog.Info("Running hook: %s", command)
+		cmd := exec.Command("/bin/sh", "-c", command)
+		cmd.Dir = root
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			wwlog.Warn("Hook failed: %s: %s: %s", command, err, strings.TrimSpace(string(out)))
+		}
+	}
+}
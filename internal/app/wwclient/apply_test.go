@@ -0,0 +1,182 @@
+package wwclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hpcng/warewulf/internal/pkg/cpio"
+)
+
+type member struct {
+	name string
+	mode uint32
+	data string
+}
+
+func writeArchive(members []member) []byte {
+	var buf bytes.Buffer
+	write := func(m member) {
+		fmt.Fprintf(&buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			1, m.mode, 0, 0, 1, 100, len(m.data), 0, 0, 0, 0, len(m.name)+1, 0)
+		buf.WriteString(m.name + "\x00")
+		buf.Write(make([]byte, (4-(110+len(m.name)+1)%4)%4))
+		buf.WriteString(m.data)
+		buf.Write(make([]byte, (4-len(m.data)%4)%4))
+	}
+	for _, m := range members {
+		write(m)
+	}
+	write(member{name: "TRAILER!!!"})
+	return buf.Bytes()
+}
+
+func gzipArchive(t *testing.T, members []member) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(writeArchive(members))
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+const testHooks = `# restart sshd
+/etc/ssh/sshd_config systemctl try-restart sshd
+/etc/ssh/*	touch hooked
+/etc/hosts systemctl try-restart sshd
+`
+
+var overlayV1 = []member{
+	{name: ".", mode: cpio.ModeDir | 0755},
+	{name: "./etc", mode: cpio.ModeDir | 0755},
+	{name: "./etc/hosts", mode: cpio.ModeRegular | 0644, data: "127.0.0.1 localhost\n"},
+	{name: "./etc/localtime", mode: cpio.ModeSymlink | 0777, data: "/usr/share/zoneinfo/UTC"},
+	{name: "./etc/ssh/sshd_config", mode: cpio.ModeRegular | 0600, data: "PermitRootLogin no\n"},
+	{name: "./etc/warewulf/wwclient.hooks", mode: cpio.ModeRegular | 0644, data: testHooks},
+}
+
+var overlayV2 = []member{
+	{name: "./etc", mode: cpio.ModeDir | 0755},
+	{name: "./etc/localtime", mode: cpio.ModeSymlink | 0777, data: "/usr/share/zoneinfo/UTC"},
+	{name: "./etc/ssh/sshd_config", mode: cpio.ModeRegular | 0600, data: "PermitRootLogin yes\n"},
+	{name: "./etc/motd", mode: cpio.ModeRegular | 0644, data: "welcome\n"},
+	{name: "./etc/warewulf/wwclient.hooks", mode: cpio.ModeRegular | 0644, data: testHooks},
+}
+
+func Test_applyOverlay(t *testing.T) {
+	root := t.TempDir()
+
+	digest, changed, err := applyOverlay(root, bytes.NewReader(gzipArchive(t, overlayV1)))
+	assert.NoError(t, err)
+	expected, err := cpio.Digest(bytes.NewReader(writeArchive(overlayV1)))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, digest)
+	assert.Equal(t, []string{"etc/hosts", "etc/localtime", "etc/ssh/sshd_config", "etc/warewulf/wwclient.hooks"}, changed)
+	data, err := ioutil.ReadFile(filepath.Join(root, "etc/ssh/sshd_config"))
+	assert.NoError(t, err)
+	assert.Equal(t, "PermitRootLogin no\n", string(data))
+	fi, err := os.Stat(filepath.Join(root, "etc/ssh/sshd_config"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	link, err := os.Readlink(filepath.Join(root, "etc/localtime"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/usr/share/zoneinfo/UTC", link)
+
+	// nothing changed
+	_, changed, err = applyOverlay(root, bytes.NewReader(gzipArchive(t, overlayV1)))
+	assert.NoError(t, err)
+	assert.Empty(t, changed)
+
+	// a modified file is replaced
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "etc/hosts"), []byte("modified\n"), 0644))
+	_, changed, err = applyOverlay(root, bytes.NewReader(gzipArchive(t, overlayV1)))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"etc/hosts"}, changed)
+
+	// files which were dropped from the overlay are removed
+	digest, changed, err = applyOverlay(root, bytes.NewReader(gzipArchive(t, overlayV2)))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"etc/ssh/sshd_config", "etc/motd", "etc/hosts"}, changed)
+	assert.NoFileExists(t, filepath.Join(root, "etc/hosts"))
+	assert.FileExists(t, filepath.Join(root, "etc/motd"))
+	m := loadManifest(root)
+	assert.Equal(t, digest, m.Digest)
+	assert.Equal(t, []string{"etc/localtime", "etc/motd", "etc/ssh/sshd_config", "etc/warewulf/wwclient.hooks"}, m.Files)
+
+	// no temporary files are left behind
+	entries, err := ioutil.ReadDir(filepath.Join(root, "etc"))
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotEqual(t, '.', entry.Name()[0], entry.Name())
+	}
+}
+
+func Test_applyOverlayIncomplete(t *testing.T) {
+	root := t.TempDir()
+	_, _, err := applyOverlay(root, bytes.NewReader(gzipArchive(t, overlayV1)))
+	assert.NoError(t, err)
+
+	members := append([]member{{name: "../evil", mode: cpio.ModeRegular | 0644, data: "x"}}, overlayV2...)
+	digest, _, err := applyOverlay(root, bytes.NewReader(gzipArchive(t, members)))
+	assert.Error(t, err)
+	assert.Empty(t, digest)
+	assert.NoFileExists(t, filepath.Join(root, "../evil"))
+	// stale files are kept until the overlay was applied completely
+	assert.FileExists(t, filepath.Join(root, "etc/hosts"))
+	assert.Contains(t, loadManifest(root).Files, "etc/hosts")
+
+	_, changed, err := applyOverlay(root, bytes.NewReader(gzipArchive(t, overlayV2)))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"etc/hosts"}, changed)
+
+	_, _, err = applyOverlay(root, bytes.NewReader([]byte("not gzip")))
+	assert.Error(t, err)
+}
+
+func Test_memberPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		err      bool
+	}{
+		{name: ".", expected: ""},
+		{name: "./etc/hosts", expected: "etc/hosts"},
+		{name: "etc//hosts", expected: "etc/hosts"},
+		{name: "/etc/hosts", err: true},
+		{name: "etc/../../hosts", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := memberPath(tt.name)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+}
+
+func Test_hooks(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "etc/warewulf"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, hooksFile), []byte(testHooks+"invalid\n[ bad pattern\n"), 0644))
+
+	hooks := loadHooks(root)
+	assert.Len(t, hooks, 3)
+	assert.Equal(t, []string{"systemctl try-restart sshd", "touch hooked"}, matchHooks(hooks, []string{"etc/hosts", "etc/ssh/sshd_config"}))
+	assert.Equal(t, []string{"systemctl try-restart sshd"}, matchHooks(hooks, []string{"etc/hosts"}))
+	assert.Empty(t, matchHooks(hooks, []string{"etc/motd", "etc/ssh/keys/key"}))
+
+	runHooks(root, []string{"etc/ssh/ssh_config"}, true)
+	assert.NoFileExists(t, filepath.Join(root, "hooked"))
+	runHooks(root, []string{"etc/ssh/ssh_config"}, false)
+	assert.FileExists(t, filepath.Join(root, "hooked"))
+}
@@ -0,0 +1,90 @@
+package wwclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hpcng/warewulf/internal/pkg/remoteexec"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+// seconds a request waits for a command
+const commandWait = 30
+
+/*
+Waits for commands which warewulfd queued for the node and runs them one
+after the other, if the allowlist of the runtime overlay allows them
+*/
+func runCommands(client *http.Client, ipaddr string, port int, wwid string, tag string, localUUID uuid.UUID) {
+	execURL := fmt.Sprintf("http://%s:%d/exec/%s?assetkey=%s&uuid=%s", ipaddr, port, wwid, tag, localUUID)
+	for {
+		job, err := pollCommand(client, execURL)
+		if err != nil {
+			wwlog.Printf(wwlog.DEBUG, "Could not get command: %s\n", err)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+		if job == nil {
+			continue
+		}
+		allow, err := remoteexec.LoadAllowlist(remoteexec.AllowlistFile)
+		if err != nil {
+			log.Printf("Could not read allowlist of commands: %s\n", err)
+		}
+		log.Printf("Running command %s: %s\n", job.ID, strings.Join(job.Command, " "))
+		remoteexec.Run(*job, allow, time.Second, func(report remoteexec.Report) {
+			err := postReport(client, execURL+"&job="+job.ID, report)
+			if err != nil {
+				log.Printf("Could not send output of command %s: %s\n", job.ID, err)
+			}
+		})
+	}
+}
+
+/*
+Returns the next command for the node, nil if there was none within the
+wait time
+*/
+func pollCommand(client *http.Client, execURL string) (*remoteexec.Job, error) {
+	getString := fmt.Sprintf("%s&wait=%d", execURL, commandWait)
+	wwlog.Printf(wwlog.DEBUG, "Making request: %s\n", getString)
+	resp, err := client.Get(getString)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got status code: %d", resp.StatusCode)
+	}
+	var job remoteexec.Job
+	err = json.NewDecoder(resp.Body).Decode(&job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func postReport(client *http.Client, postString string, report remoteexec.Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(postString, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("got status code: %d", resp.StatusCode)
+	}
+	return nil
+}
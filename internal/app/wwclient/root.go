@@ -1,6 +1,9 @@
 package wwclient
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -8,16 +11,18 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/coreos/go-systemd/daemon"
 	"github.com/google/uuid"
 	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/heartbeat"
+	"github.com/hpcng/warewulf/internal/pkg/inventory"
 	"github.com/hpcng/warewulf/internal/pkg/pidfile"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
@@ -36,6 +41,8 @@ var (
 	DebugFlag bool
 	PIDFile   string
 	Webclient *http.Client
+	// the overlay is put into /warewulf/wwclient-test, hooks are not run
+	testRoot bool
 )
 
 func init() {
@@ -76,6 +83,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Called via: %s\n", os.Args[0])
 		fmt.Printf("Runtime overlay is being put in '/warewulf/wwclient-test' rather than '/'\n")
 		fmt.Printf("For full functionality call with: %s\n", path.Join(buildconfig.WWCLIENTDIR(), "wwclient"))
+		testRoot = true
 		err := os.MkdirAll("/warewulf/wwclient-test", 0755)
 		if err != nil {
 			wwlog.Printf(wwlog.ERROR, "failed to create dir: %s", err)
@@ -91,27 +99,14 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	localTCPAddr := net.TCPAddr{}
-	if conf.Warewulf.Secure {
-		// Setup local port to something privileged (<1024)
-		localTCPAddr.Port = 987
-		wwlog.Printf(wwlog.INFO, "Running from trusted port\n")
-	}
+	appliedDigest = loadManifest(".").Digest
+
+	Webclient = newWebclient(conf.Warewulf.Secure, 987)
+	// the long polls of the runtime overlay and of commands keep the port
+	// of their client busy
+	heartbeatClient := newWebclient(conf.Warewulf.Secure, 986)
+	execClient := newWebclient(conf.Warewulf.Secure, 985)
 
-	Webclient = &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				LocalAddr: &localTCPAddr,
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		},
-	}
 	smbiosDump, err := smbios.New()
 	if err != nil {
 		wwlog.Printf(wwlog.ERROR, "Could not get SMBIOS info: %s\n", err)
@@ -150,6 +145,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			switch sig {
 			case syscall.SIGHUP:
 				log.Printf("Received SIGNAL: %s\n", sig)
+				forceUpdate()
 				stopTimer.Stop()
 				stopTimer.Reset(0)
 			case syscall.SIGTERM, syscall.SIGINT:
@@ -159,31 +155,149 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			}
 		}
 	}()
+	if conf.Warewulf.HeartbeatInterval > 0 {
+		go sendHeartbeats(heartbeatClient, conf.Ipaddr, conf.Warewulf.Port, wwid, tag, localUUID, conf.Warewulf.HeartbeatInterval)
+	}
+	go runCommands(execClient, conf.Ipaddr, conf.Warewulf.Port, wwid, tag, localUUID)
+
 	var finishedInitialSync bool = false
+	var lastInventory []byte
 	for {
-		updateSystem(conf.Ipaddr, conf.Warewulf.Port, wwid, tag, localUUID)
+		longPoll := updateSystem(conf.Ipaddr, conf.Warewulf.Port, wwid, tag, localUUID, duration)
+		lastInventory = postInventory(conf.Ipaddr, conf.Warewulf.Port, wwid, tag, localUUID, lastInventory)
 		if !finishedInitialSync {
 			// ignore error and status here, as this wouldn't change anything
 			_, _ = daemon.SdNotify(false, daemon.SdNotifyReady)
 			finishedInitialSync = true
 		}
 
+		// a server which supports conditional requests waits in the next
+		// request until the overlay changes, older ones are polled
+		if longPoll {
+			continue
+		}
 		<-stopTimer.C
 		stopTimer.Reset(time.Duration(duration) * time.Second)
 	}
 }
 
-func updateSystem(ipaddr string, port int, wwid string, tag string, localUUID uuid.UUID) {
+/*
+Returns a client for requests to warewulfd. In secure mode the requests are
+made from the given privileged port, which shows warewulfd that they come
+from root.
+*/
+func newWebclient(secure bool, port int) *http.Client {
+	localTCPAddr := net.TCPAddr{}
+	if secure {
+		// Setup local port to something privileged (<1024)
+		localTCPAddr.Port = port
+		wwlog.Printf(wwlog.INFO, "Running from trusted port %d\n", port)
+	}
+
+	dialer := &net.Dialer{
+		LocalAddr: &localTCPAddr,
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if secure {
+		// all connections use the same port, closing them with a reset
+		// avoids TIME_WAIT, which would block the port for the next
+		// connection when a long poll is canceled
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			var err error
+			cerr := c.Control(func(fd uintptr) {
+				err = syscall.SetsockoptLinger(int(fd), syscall.SOL_SOCKET, syscall.SO_LINGER, &syscall.Linger{Onoff: 1, Linger: 0})
+			})
+			if cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
+/*
+ETag of the runtime overlay which was applied last, the next request waits
+until the overlay differs from it. cancelPoll cancels the waiting request.
+appliedDigest is the digest of the overlay on the disk, which is reported to
+warewulfd.
+*/
+var (
+	pollLock      sync.Mutex
+	overlayEtag   string
+	appliedDigest string
+	cancelPoll    context.CancelFunc
+)
+
+/*
+Gets the runtime overlay unconditionally and immediately
+*/
+func forceUpdate() {
+	pollLock.Lock()
+	defer pollLock.Unlock()
+	overlayEtag = ""
+	if cancelPoll != nil {
+		cancelPoll()
+	}
+}
+
+/*
+Gets and applies the runtime overlay if it differs from the one applied last.
+Returns true if the server supports conditional requests, the request then
+waits up to wait seconds for a change of the overlay.
+*/
+func updateSystem(ipaddr string, port int, wwid string, tag string, localUUID uuid.UUID, wait int) bool {
 	var resp *http.Response
 	counter := 0
 	for {
 		var err error
 		getString := fmt.Sprintf("http://%s:%d/provision/%s?assetkey=%s&uuid=%s&stage=runtime&compress=gz", ipaddr, port, wwid, tag, localUUID)
+		pollLock.Lock()
+		etag := overlayEtag
+		if etag != "" {
+			getString += fmt.Sprintf("&wait=%d", wait)
+		}
+		if appliedDigest != "" {
+			getString += "&applied=" + appliedDigest
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelPoll = cancel
+		pollLock.Unlock()
+
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, getString, nil)
+		if err != nil {
+			cancel()
+			log.Println(err)
+			return false
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
 		wwlog.Printf(wwlog.DEBUG, "Making request: %s\n", getString)
-		resp, err = Webclient.Get(getString)
+		resp, err = Webclient.Do(req)
+		pollLock.Lock()
+		cancelPoll = nil
+		pollLock.Unlock()
 		if err == nil {
+			defer cancel()
 			break
+		} else if ctx.Err() != nil {
+			// canceled by SIGHUP, get the overlay again
+			cancel()
+			continue
 		} else {
+			cancel()
 			if counter > 60 {
 				counter = 0
 			}
@@ -194,18 +308,100 @@ func updateSystem(ipaddr string, port int, wwid string, tag string, localUUID uu
 		}
 		time.Sleep(1000 * time.Millisecond)
 	}
+	defer resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode == http.StatusNotModified {
+		wwlog.Printf(wwlog.DEBUG, "Runtime overlay not changed\n")
+		return etag != ""
+	}
 	if resp.StatusCode != 200 {
 		log.Printf("Not updating runtime overlay, got status code: %d\n", resp.StatusCode)
 		time.Sleep(60000 * time.Millisecond)
-		return
+		return false
 	}
 	log.Printf("Updating system\n")
-	command := exec.Command("/bin/sh", "-c", "gzip -dc | cpio -iu")
-	command.Stdin = resp.Body
-	err := command.Run()
+	digest, changed, err := applyOverlay(".", resp.Body)
+	runHooks(".", changed, testRoot)
+	if err != nil {
+		log.Printf("ERROR: Failed applying runtime overlay: %s\n", err)
+		return false
+	}
+	pollLock.Lock()
+	overlayEtag = etag
+	appliedDigest = digest
+	pollLock.Unlock()
+	return etag != ""
+}
+
+/*
+Posts the hardware inventory to warewulfd if it differs from the one posted
+last time, returns the inventory which warewulfd has now
+*/
+func postInventory(ipaddr string, port int, wwid string, tag string, localUUID uuid.UUID, last []byte) []byte {
+	inv, err := inventory.Collect()
+	if err != nil {
+		log.Printf("Could not collect inventory: %s\n", err)
+		return last
+	}
+	collected := inv.Collected
+	inv.Collected = 0
+	current, err := json.Marshal(inv)
+	if err != nil || bytes.Equal(current, last) {
+		return last
+	}
+	inv.Collected = collected
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return last
+	}
+
+	postString := fmt.Sprintf("http://%s:%d/inventory/%s?assetkey=%s&uuid=%s", ipaddr, port, wwid, tag, localUUID)
+	wwlog.Printf(wwlog.DEBUG, "Making request: %s\n", postString)
+	resp, err := Webclient.Post(postString, "application/json", bytes.NewReader(data))
 	if err != nil {
-		log.Printf("ERROR: Failed running CPIO: %s\n", err)
+		log.Printf("Could not post inventory: %s\n", err)
+		return last
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		log.Printf("Inventory not accepted, got status code: %d\n", resp.StatusCode)
+		return last
+	}
+	return current
+}
+
+/*
+Posts the heartbeat of the node to warewulfd every interval seconds. A failure
+is only logged when the previous heartbeat was accepted.
+*/
+func sendHeartbeats(client *http.Client, ipaddr string, port int, wwid string, tag string, localUUID uuid.UUID, interval int) {
+	failed := false
+	for {
+		err := postHeartbeat(client, ipaddr, port, wwid, tag, localUUID)
+		if err != nil && !failed {
+			log.Printf("Could not post heartbeat: %s\n", err)
+		}
+		failed = err != nil
+		time.Sleep(time.Duration(interval) * time.Second)
+	}
+}
+
+func postHeartbeat(client *http.Client, ipaddr string, port int, wwid string, tag string, localUUID uuid.UUID) error {
+	data, err := json.Marshal(heartbeat.Collect())
+	if err != nil {
+		return err
+	}
+	postString := fmt.Sprintf("http://%s:%d/heartbeat/%s?assetkey=%s&uuid=%s", ipaddr, port, wwid, tag, localUUID)
+	wwlog.Printf(wwlog.DEBUG, "Making request: %s\n", postString)
+	resp, err := client.Post(postString, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("got status code: %d", resp.StatusCode)
 	}
+	return nil
 }
 
 func cleanUp() {
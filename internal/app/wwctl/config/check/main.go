@@ -0,0 +1,87 @@
+package check
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/configcheck"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	conf, err := warewulfconf.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read %s: %s\n", warewulfconf.ConfigFile, err)
+		os.Exit(1)
+	}
+	problems := configcheck.CheckController(conf, warewulfconf.ConfigFile)
+
+	nodeDB, err := node.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
+		os.Exit(1)
+	}
+	nodes, err := nodeDB.FindAllNodes()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not get node list: %s\n", err)
+		os.Exit(1)
+	}
+	profiles, err := nodeDB.FindAllProfiles()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not get profile list: %s\n", err)
+		os.Exit(1)
+	}
+	var profileNames []string
+	for _, p := range profiles {
+		profileNames = append(profileNames, p.Id.Get())
+	}
+
+	inv, err := configcheck.LoadInventory(profileNames)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+	for _, p := range profiles {
+		if _, err := nodeDB.ResolveProfiles([]string{p.Id.Get()}); err != nil {
+			problems = append(problems, configcheck.Problem{
+				Level:   configcheck.LevelError,
+				Object:  "profile " + p.Id.Get(),
+				Field:   "profiles",
+				Message: err.Error(),
+			})
+		}
+	}
+	problems = append(problems, configcheck.CheckNodes("profile", profiles, inv)...)
+	problems = append(problems, configcheck.CheckNodes("node", nodes, inv)...)
+
+	ipProblems, err := nodeDB.CheckIpaddrs()
+	if err != nil {
+		wwlog.Printf(wwlog.WARN, "Could not check the node addresses: %s\n", err)
+	}
+	problems = append(problems, configcheck.FromIpaddrProblems(ipProblems)...)
+
+	var errCount, warnCount int
+	for _, p := range problems {
+		if p.Level == configcheck.LevelError {
+			errCount++
+		} else {
+			warnCount++
+			if SetQuiet {
+				continue
+			}
+		}
+		fmt.Println(p.String())
+	}
+	fmt.Printf("%d nodes, %d profiles: %d errors, %d warnings\n", len(nodes), len(profiles), errCount, warnCount)
+
+	if errCount > 0 {
+		os.Exit(1)
+	}
+	if warnCount > 0 {
+		os.Exit(2)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package check
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "check [OPTIONS]",
+		Short:                 "Check warewulf.conf and the node configuration",
+		Long: "This command validates warewulf.conf and every node and profile. It checks\n" +
+			"addresses, netmasks and MAC addresses, looks for duplicate addresses and\n" +
+			"verifies that the referenced containers, kernels, overlays, iPXE templates\n" +
+			"and profiles exist. The exit code is 1 if errors and 2 if only warnings\n" +
+			"were found.",
+		RunE: CobraRunE,
+		Args: cobra.NoArgs,
+	}
+	SetQuiet bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().BoolVarP(&SetQuiet, "quiet", "q", false, "Only show errors")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
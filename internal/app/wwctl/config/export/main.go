@@ -0,0 +1,49 @@
+package export
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	nodeDB, err := node.New()
+	if SetBackend != "" {
+		db, err := node.OpenBackend(SetBackend, SetPath)
+		if err != nil {
+			wwlog.Printf(wwlog.ERROR, "%s\n", err)
+			os.Exit(1)
+		}
+		nodeDB, err = node.NewFromBackend(db)
+	}
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node database: %s\n", err)
+		os.Exit(1)
+	}
+
+	out, err := yaml.Marshal(&nodeDB)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+
+	if len(args) == 0 || args[0] == "-" {
+		fmt.Print(string(out))
+		return nil
+	}
+
+	err = util.WriteFileAtomic(args[0], out, 0644)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not write %s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+	wwlog.Printf(wwlog.INFO, "Exported %d nodes and %d profiles to %s\n",
+		len(nodeDB.Nodes), len(nodeDB.NodeProfiles), args[0])
+
+	return nil
+}
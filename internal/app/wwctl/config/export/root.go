@@ -0,0 +1,28 @@
+package export
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "export [OPTIONS] [FILE]",
+		Short:                 "Export the node database to a yaml file",
+		Long: "This command writes all nodes and profiles of the node database in the\n" +
+			"format of nodes.conf to FILE or to stdout. With --backend another database\n" +
+			"than the one configured in warewulf.conf is exported.",
+		RunE: CobraRunE,
+		Args: cobra.MaximumNArgs(1),
+	}
+	SetBackend string
+	SetPath    string
+)
+
+func init() {
+	baseCmd.PersistentFlags().StringVarP(&SetBackend, "backend", "b", "", "Backend to export from (yaml or sqlite)")
+	baseCmd.PersistentFlags().StringVarP(&SetPath, "path", "p", "", "File of the backend, the default location if not set")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
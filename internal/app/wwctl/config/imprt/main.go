@@ -0,0 +1,68 @@
+package imprt
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	src, err := node.OpenBackend(node.BackendYaml, args[0])
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+	srcDB, err := node.NewFromBackend(src)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read %s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+
+	nodeDB, err := node.New()
+	if SetBackend != "" {
+		db, err := node.OpenBackend(SetBackend, SetPath)
+		if err != nil {
+			wwlog.Printf(wwlog.ERROR, "%s\n", err)
+			os.Exit(1)
+		}
+		nodeDB, err = node.NewFromBackend(db)
+	}
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node database: %s\n", err)
+		os.Exit(1)
+	}
+
+	if !SetYes && (len(nodeDB.Nodes) > 0 || len(nodeDB.NodeProfiles) > 0) {
+		prompt := promptui.Prompt{
+			Label: fmt.Sprintf("Are you sure you want to replace %d nodes and %d profiles",
+				len(nodeDB.Nodes), len(nodeDB.NodeProfiles)),
+			IsConfirm: true,
+		}
+		result, _ := prompt.Run()
+		if result != "y" && result != "yes" {
+			return nil
+		}
+	}
+
+	nodeDB.Replace(srcDB)
+	err = nodeDB.Persist()
+	if err != nil {
+		return errors.Wrap(err, "failed to persist nodedb")
+	}
+	wwlog.Printf(wwlog.INFO, "Imported %d nodes and %d profiles\n", len(srcDB.Nodes), len(srcDB.NodeProfiles))
+
+	if SetBackend == "" {
+		err = warewulfd.DaemonReload()
+		if err != nil {
+			return errors.Wrap(err, "failed to reload warewulf daemon")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package imprt
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "import [OPTIONS] FILE",
+		Short:                 "Import a yaml file into the node database",
+		Long: "This command replaces all nodes and profiles of the node database with the\n" +
+			"ones of FILE, which has the format of nodes.conf. To move to another backend,\n" +
+			"export the database, change 'nodedb: backend:' in warewulf.conf and import the\n" +
+			"file again, or import directly with --backend.",
+		RunE: CobraRunE,
+		Args: cobra.ExactArgs(1),
+	}
+	SetBackend string
+	SetPath    string
+	SetYes     bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().StringVarP(&SetBackend, "backend", "b", "", "Backend to import into (yaml or sqlite)")
+	baseCmd.PersistentFlags().StringVarP(&SetPath, "path", "p", "", "File of the backend, the default location if not set")
+	baseCmd.PersistentFlags().BoolVarP(&SetYes, "yes", "y", false, "Set 'yes' to all questions asked")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	changes, err := node.ReadAuditLog()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read audit log: %s\n", err)
+		os.Exit(1)
+	}
+
+	var selected []node.Change
+	for _, c := range changes {
+		if SetNode != "" && !c.Touches("node", SetNode) {
+			continue
+		}
+		if SetProfile != "" && !c.Touches("profile", SetProfile) {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	if SetLimit > 0 && len(selected) > SetLimit {
+		selected = selected[len(selected)-SetLimit:]
+	}
+
+	for _, c := range selected {
+		fmt.Printf("Change %d, %s by %s\n", c.Id, c.Time.Format("2006-01-02 15:04:05"), c.User)
+		fmt.Printf("  %s\n", c.Command)
+		for _, o := range c.Objects {
+			if SetNode != "" && !(o.Kind == "node" && o.Name == SetNode) {
+				continue
+			}
+			if SetProfile != "" && !(o.Kind == "profile" && o.Name == SetProfile) {
+				continue
+			}
+			switch {
+			case o.Before == "":
+				fmt.Printf("  %s %s: added\n", o.Kind, o.Name)
+			case o.After == "":
+				fmt.Printf("  %s %s: deleted\n", o.Kind, o.Name)
+			default:
+				fmt.Printf("  %s %s:\n", o.Kind, o.Name)
+			}
+			for _, f := range o.Fields {
+				fmt.Printf("    %-30s %s -> %s\n", f.Field, value(f.Old), value(f.New))
+			}
+		}
+		fmt.Printf("\n")
+	}
+
+	return nil
+}
+
+func value(v string) string {
+	if v == "" {
+		return "--"
+	}
+	return v
+}
@@ -0,0 +1,29 @@
+package log
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "log [OPTIONS]",
+		Short:                 "Show the changes of the node configuration",
+		Long: "This command shows the recorded changes of nodes and profiles with the user,\n" +
+			"the command line and the changed fields, the most recent change last.",
+		RunE: CobraRunE,
+		Args: cobra.NoArgs,
+	}
+	SetNode    string
+	SetProfile string
+	SetLimit   int
+)
+
+func init() {
+	baseCmd.PersistentFlags().StringVarP(&SetNode, "node", "n", "", "Only show changes of this node")
+	baseCmd.PersistentFlags().StringVarP(&SetProfile, "profile", "p", "", "Only show changes of this profile")
+	baseCmd.PersistentFlags().IntVarP(&SetLimit, "limit", "l", 0, "Only show the last N changes")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
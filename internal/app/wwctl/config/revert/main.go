@@ -0,0 +1,66 @@
+package revert
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Invalid change id: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	change, err := node.GetChange(id)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+
+	nodeDB, err := node.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = nodeDB.Revert(change, SetForce)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not revert change %d: %s\n", id, err)
+		os.Exit(1)
+	}
+
+	if !SetYes {
+		for _, o := range change.Objects {
+			fmt.Printf("%s %s\n", o.Kind, o.Name)
+		}
+		prompt := promptui.Prompt{
+			Label:     fmt.Sprintf("Are you sure you want to revert change %d", id),
+			IsConfirm: true,
+		}
+		result, _ := prompt.Run()
+		if result != "y" && result != "yes" {
+			return nil
+		}
+	}
+
+	err = nodeDB.Persist()
+	if err != nil {
+		return errors.Wrap(err, "failed to persist nodedb")
+	}
+
+	err = warewulfd.DaemonReload()
+	if err != nil {
+		return errors.Wrap(err, "failed to reload warewulf daemon")
+	}
+
+	return nil
+}
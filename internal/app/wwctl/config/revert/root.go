@@ -0,0 +1,28 @@
+package revert
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "revert [OPTIONS] CHANGE_ID",
+		Short:                 "Revert a change of the node configuration",
+		Long: "This command restores the nodes and profiles of the given change (see\n" +
+			"'wwctl config log') to the state before the change. Nodes and profiles\n" +
+			"which were modified again since are only restored with --force.",
+		RunE: CobraRunE,
+		Args: cobra.ExactArgs(1),
+	}
+	SetForce bool
+	SetYes   bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().BoolVarP(&SetForce, "force", "f", false, "Revert also later modified nodes and profiles")
+	baseCmd.PersistentFlags().BoolVarP(&SetYes, "yes", "y", false, "Set 'yes' to all questions asked")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"github.com/hpcng/warewulf/internal/app/wwctl/config/check"
+	"github.com/hpcng/warewulf/internal/app/wwctl/config/export"
+	"github.com/hpcng/warewulf/internal/app/wwctl/config/imprt"
+	"github.com/hpcng/warewulf/internal/app/wwctl/config/log"
+	"github.com/hpcng/warewulf/internal/app/wwctl/config/revert"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "config COMMAND [OPTIONS]",
+		Short:                 "Node configuration management",
+		Long: "Every change of the node and profile configuration is recorded in an audit\n" +
+			"log. These commands show the recorded changes and revert them, import and\n" +
+			"export the node database and check the configuration for errors.",
+	}
+)
+
+func init() {
+	baseCmd.AddCommand(check.GetCommand())
+	baseCmd.AddCommand(export.GetCommand())
+	baseCmd.AddCommand(imprt.GetCommand())
+	baseCmd.AddCommand(log.GetCommand())
+	baseCmd.AddCommand(revert.GetCommand())
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
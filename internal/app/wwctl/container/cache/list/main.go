@@ -0,0 +1,51 @@
+package list
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/go-units"
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/hpcng/warewulf/internal/pkg/oci"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	cache := oci.NewBlobCache(container.OciBlobCacheDir())
+
+	entries, err := cache.List()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read OCI cache: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%-20s %-10s %-17s %s\n", "IMAGE ID", "SIZE", "LAST USED", "URI")
+	for _, e := range entries {
+		lastUsed := "--"
+		if !e.LastUsed.IsZero() {
+			lastUsed = e.LastUsed.Format("2006-01-02 15:04")
+		}
+		uri := e.Uri
+		if uri == "" {
+			uri = "--"
+		}
+		fmt.Printf("%-20s %-10s %-17s %s\n", shortId(e.Id), units.BytesSize(float64(e.Size)), lastUsed, uri)
+	}
+
+	size, err := cache.Size()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not get size of OCI cache: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nTotal cache size: %s\n", units.BytesSize(float64(size)))
+
+	return nil
+}
+
+func shortId(id string) string {
+	if len(id) > 19 {
+		return id[:19]
+	}
+	return id
+}
@@ -0,0 +1,20 @@
+package list
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "list [OPTIONS]",
+		Short:                 "List cached OCI images",
+		Long:                  "This command shows the images in the OCI cache, the least recently used first.",
+		RunE:                  CobraRunE,
+		Args:                  cobra.NoArgs,
+		Aliases:               []string{"ls"},
+	}
+)
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,72 @@
+package prune
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/hpcng/warewulf/internal/pkg/oci"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	var maxAge time.Duration
+	var maxSize int64
+	var err error
+
+	if SetMaxAge != "" {
+		maxAge, err = parseAge(SetMaxAge)
+		if err != nil {
+			wwlog.Printf(wwlog.ERROR, "Invalid --max-age: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if SetMaxSize != "" {
+		maxSize, err = units.RAMInBytes(SetMaxSize)
+		if err != nil || maxSize <= 0 {
+			wwlog.Printf(wwlog.ERROR, "Invalid --max-size: %s\n", SetMaxSize)
+			os.Exit(1)
+		}
+	}
+	if SetAll {
+		// a budget of one byte can't be met by any image
+		maxSize = 1
+	}
+
+	cache := oci.NewBlobCache(container.OciBlobCacheDir())
+	removed, freed, err := cache.Prune(maxAge, maxSize, SetDryRun)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not prune OCI cache: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, e := range removed {
+		if SetDryRun {
+			fmt.Printf("Would remove: %s %s\n", e.Id, e.Uri)
+		} else {
+			fmt.Printf("Removed: %s %s\n", e.Id, e.Uri)
+		}
+	}
+	fmt.Printf("Freed space: %s\n", units.BytesSize(float64(freed)))
+
+	return nil
+}
+
+/*
+Like time.ParseDuration, but also accepts days with the suffix 'd'
+*/
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(age)
+}
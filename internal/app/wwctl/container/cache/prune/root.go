@@ -0,0 +1,34 @@
+package prune
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "prune [OPTIONS]",
+		Short:                 "Remove images from the OCI cache",
+		Long: "This command removes the images from the OCI cache which were not used\n" +
+			"within --max-age. Afterwards the least recently used images are removed until\n" +
+			"the cache is smaller than --max-size. Blobs which aren't used by any cached\n" +
+			"image are always removed.",
+		Example: "wwctl container cache prune --max-age 30d --max-size 50G",
+		RunE:    CobraRunE,
+		Args:    cobra.NoArgs,
+	}
+	SetMaxAge  string
+	SetMaxSize string
+	SetAll     bool
+	SetDryRun  bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().StringVar(&SetMaxAge, "max-age", "", "Remove images not used within this time (e.g. 12h, 30d)")
+	baseCmd.PersistentFlags().StringVar(&SetMaxSize, "max-size", "", "Size budget of the cache (e.g. 500M, 50G)")
+	baseCmd.PersistentFlags().BoolVarP(&SetAll, "all", "a", false, "Remove all images")
+	baseCmd.PersistentFlags().BoolVarP(&SetDryRun, "dry-run", "n", false, "Only show what would be removed")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
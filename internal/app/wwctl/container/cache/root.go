@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"github.com/hpcng/warewulf/internal/app/wwctl/container/cache/list"
+	"github.com/hpcng/warewulf/internal/app/wwctl/container/cache/prune"
+	"github.com/hpcng/warewulf/internal/app/wwctl/container/cache/verify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "cache COMMAND [OPTIONS]",
+		Short:                 "OCI image cache management",
+		Long: "All OCI images which are imported into Warewulf are cached locally, so that\n" +
+			"shared layers are only downloaded once and images can be imported again\n" +
+			"with 'wwctl container import --offline'. These commands list, prune and\n" +
+			"verify the cached images.",
+	}
+)
+
+func init() {
+	baseCmd.AddCommand(list.GetCommand())
+	baseCmd.AddCommand(prune.GetCommand())
+	baseCmd.AddCommand(verify.GetCommand())
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,36 @@
+package verify
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/hpcng/warewulf/internal/pkg/oci"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	cache := oci.NewBlobCache(container.OciBlobCacheDir())
+
+	bad, err := cache.Verify(SetRemove)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not verify OCI cache: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(bad) == 0 {
+		fmt.Printf("All blobs are valid\n")
+		return nil
+	}
+	for _, b := range bad {
+		fmt.Printf("Corrupted or missing blob: %s\n", b)
+	}
+	if SetRemove {
+		fmt.Printf("Removed the affected images from the cache\n")
+		return nil
+	}
+	os.Exit(1)
+
+	return nil
+}
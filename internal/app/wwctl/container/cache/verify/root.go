@@ -0,0 +1,26 @@
+package verify
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "verify [OPTIONS]",
+		Short:                 "Verify the blobs of the OCI cache",
+		Long: "This command checks the sha256 digest of every blob in the OCI cache.\n" +
+			"With --remove, images with corrupted or missing blobs are removed from\n" +
+			"the cache, so that they are downloaded again on the next import.",
+		RunE: CobraRunE,
+		Args: cobra.NoArgs,
+	}
+	SetRemove bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().BoolVar(&SetRemove, "remove", false, "Remove images with corrupted blobs")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,66 @@
+package delegate
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+
+	if !container.ValidSource(containerName) {
+		wwlog.Printf(wwlog.ERROR, "Unknown Warewulf container: %s\n", containerName)
+		os.Exit(1)
+	}
+
+	if !SetRemove && len(args) == 1 {
+		delegation, err := container.GetDelegation(containerName)
+		if err != nil {
+			wwlog.Printf(wwlog.ERROR, "Could not read the delegation of container %s: %s\n", containerName, err)
+			os.Exit(1)
+		}
+		if delegation == nil {
+			fmt.Printf("%s is not delegated\n", containerName)
+		} else {
+			fmt.Printf("%s is delegated to %s\n", containerName, delegation.User)
+		}
+		return nil
+	}
+
+	if os.Geteuid() != 0 {
+		wwlog.Printf(wwlog.ERROR, "Only root can change the delegation of a container\n")
+		os.Exit(1)
+	}
+
+	if SetRemove {
+		err := container.Undelegate(containerName)
+		if err != nil {
+			wwlog.Printf(wwlog.ERROR, "%s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed the delegation of %s\n", containerName)
+		return nil
+	}
+
+	u, err := user.Lookup(args[1])
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Unknown user: %s\n", args[1])
+		os.Exit(1)
+	}
+	if !container.MayModify(containerName, u) {
+		wwlog.Printf(wwlog.ERROR, "User %s is not allowed to modify container %s in warewulf.conf\n", u.Username, containerName)
+		os.Exit(1)
+	}
+	err = container.Delegate(containerName, u)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Delegated %s to %s\n", containerName, u.Username)
+	return nil
+}
@@ -0,0 +1,39 @@
+package delegate
+
+import (
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "delegate [OPTIONS] CONTAINER [USER]",
+		Short:                 "Delegate a container to an unprivileged user",
+		Long: "Delegate the CONTAINER to the USER, who must be allowed to modify it in\n" +
+			"warewulf.conf under container: delegates:. The owners of the files of the\n" +
+			"container are changed to the ids which the user namespace of the user maps,\n" +
+			"so that the user can run 'wwctl container exec' as root of the container.\n" +
+			"A container is delegated to one user at a time. Without USER the current\n" +
+			"delegation is shown.",
+		RunE: CobraRunE,
+		Args: cobra.RangeArgs(1, 2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			list, _ := container.ListSources()
+			return list, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	SetRemove bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().BoolVarP(&SetRemove, "remove", "r", false, "Remove the delegation and change the owners back")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -23,6 +23,28 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
+	if waitSync {
+		// the parent writes a single byte once the id mappings are in place,
+		// capabilities are only gained by an exec as the mapped root
+		sync := os.NewFile(3, "sync")
+		buf := make([]byte, 1)
+		n, _ := sync.Read(buf)
+		sync.Close()
+		if n != 1 {
+			wwlog.Printf(wwlog.ERROR, "User namespace was not set up\n")
+			os.Exit(1)
+		}
+		var argv []string
+		for _, arg := range os.Args {
+			if arg != "--sync" {
+				argv = append(argv, arg)
+			}
+		}
+		err := syscall.Exec("/proc/self/exe", argv, os.Environ())
+		wwlog.Printf(wwlog.ERROR, "Could not execute in the user namespace: %s\n", err)
+		os.Exit(1)
+	}
+
 	containerName := args[0]
 
 	if !container.ValidSource(containerName) {
@@ -44,7 +66,12 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		return errors.Wrap(err, "failed to mount")
 	}
 
-	err = syscall.Mount("/dev", path.Join(containerPath, "/dev"), "", syscall.MS_BIND, "")
+	// submounts of /dev are locked in a user namespace, so they must come along
+	var bindFlags uintptr = syscall.MS_BIND
+	if userNs {
+		bindFlags |= syscall.MS_REC
+	}
+	err = syscall.Mount("/dev", path.Join(containerPath, "/dev"), "", bindFlags, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to mount /dev")
 	}
@@ -62,7 +89,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			dest = bind[1]
 		}
 
-		err := syscall.Mount(source, path.Join(containerPath, dest), "", syscall.MS_BIND, "")
+		err := syscall.Mount(source, path.Join(containerPath, dest), "", bindFlags, "")
 		if err != nil {
 			fmt.Printf("BIND ERROR: %s\n", err)
 			os.Exit(1)
@@ -11,11 +11,15 @@ var (
 		Args:               cobra.MinimumNArgs(1),
 		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
 	}
-	binds []string
+	binds    []string
+	userNs   bool
+	waitSync bool
 )
 
 func init() {
 	baseCmd.PersistentFlags().StringArrayVarP(&binds, "bind", "b", []string{}, "bind points")
+	baseCmd.PersistentFlags().BoolVar(&userNs, "userns", false, "run in a user namespace")
+	baseCmd.PersistentFlags().BoolVar(&waitSync, "sync", false, "wait for user namespace mappings")
 }
 
 // GetRootCommand returns the root cobra.Command for the application.
@@ -7,18 +7,29 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
 	"path"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/hpcng/warewulf/internal/pkg/container"
 	"github.com/hpcng/warewulf/internal/pkg/util"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
-func runContainedCmd(args []string) error {
+func runContainedCmd(args []string, delegation *container.Delegation) error {
 	wwlog.Printf(wwlog.VERBOSE, "Running contained command: %s\n", args[1:])
+	if delegation != nil {
+		if err := runUserNsCmd(args, delegation); err != nil {
+			wwlog.Printf(wwlog.DEBUG, "Contained command failed: %s\n", err)
+			fmt.Printf("Command exited non-zero, not rebuilding/updating VNFS image\n")
+			os.Exit(0)
+		}
+		return nil
+	}
 	c := exec.Command("/proc/self/exe", append([]string{"container", "exec", "__child"}, args...)...)
 
 	c.SysProcAttr = &syscall.SysProcAttr{
@@ -35,6 +46,80 @@ func runContainedCmd(args []string) error {
 	return nil
 }
 
+/*
+Run the command in a new user namespace with the mappings of the delegation
+of the container, its root file system is owned by these ids. Root and a
+user which only maps itself write the mappings directly, otherwise they are
+written with newuidmap/newgidmap. In that case the child waits on fd 3 until
+the mappings are written and executes itself again to gain its capabilities.
+*/
+func runUserNsCmd(args []string, delegation *container.Delegation) error {
+	direct := os.Geteuid() == 0 ||
+		(len(delegation.UidMap) == 1 && delegation.UidMap[0].HostID == os.Getuid() &&
+			len(delegation.GidMap) == 1 && delegation.GidMap[0].HostID == os.Getgid())
+	childArgs := []string{"container", "exec", "__child", "--userns"}
+	if !direct {
+		childArgs = append(childArgs, "--sync")
+	}
+	c := exec.Command("/proc/self/exe", append(childArgs, args...)...)
+	c.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS,
+	}
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	if direct {
+		c.SysProcAttr.UidMappings = container.SysProcIDMaps(delegation.UidMap)
+		c.SysProcAttr.GidMappings = container.SysProcIDMaps(delegation.GidMap)
+		c.SysProcAttr.GidMappingsEnableSetgroups = os.Geteuid() == 0
+		c.SysProcAttr.Credential = &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: os.Geteuid() != 0}
+		return c.Run()
+	}
+
+	newuidmap, err := exec.LookPath("newuidmap")
+	if err != nil {
+		return errors.Wrap(err, "the subordinate ids of the delegation need newuidmap")
+	}
+	newgidmap, err := exec.LookPath("newgidmap")
+	if err != nil {
+		return errors.Wrap(err, "the subordinate ids of the delegation need newgidmap")
+	}
+	syncR, syncW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	c.ExtraFiles = []*os.File{syncR}
+	if err := c.Start(); err != nil {
+		syncR.Close()
+		syncW.Close()
+		return err
+	}
+	syncR.Close()
+
+	pid := strconv.Itoa(c.Process.Pid)
+	uidArgs := append([]string{pid}, container.MapArgs(delegation.UidMap)...)
+	gidArgs := append([]string{pid}, container.MapArgs(delegation.GidMap)...)
+	wwlog.Printf(wwlog.DEBUG, "newuidmap %v\n", uidArgs)
+	err = exec.Command(newuidmap, uidArgs...).Run()
+	if err == nil {
+		wwlog.Printf(wwlog.DEBUG, "newgidmap %v\n", gidArgs)
+		err = exec.Command(newgidmap, gidArgs...).Run()
+	}
+	if err != nil {
+		syncW.Close()
+		_ = c.Wait()
+		return errors.Wrap(err, "failed to write id mappings")
+	}
+	_, err = syncW.Write([]byte{1})
+	syncW.Close()
+	if err != nil {
+		_ = c.Wait()
+		return err
+	}
+	return c.Wait()
+}
+
 func CobraRunE(cmd *cobra.Command, args []string) error {
 
 	containerName := args[0]
@@ -45,6 +130,28 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
+	delegation, err := container.GetDelegation(containerName)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read the delegation of container %s: %s\n", containerName, err)
+		os.Exit(1)
+	}
+	rootless := os.Geteuid() != 0
+	if rootless {
+		u, err := user.Current()
+		if err != nil {
+			wwlog.Printf(wwlog.ERROR, "Could not get current user: %s\n", err)
+			os.Exit(1)
+		}
+		if !container.MayModify(containerName, u) {
+			wwlog.Printf(wwlog.ERROR, "User %s is not allowed to modify container: %s\n", u.Username, containerName)
+			os.Exit(1)
+		}
+		if delegation == nil || delegation.User != u.Username {
+			wwlog.Printf(wwlog.ERROR, "Container %s is not delegated to %s, root must run: wwctl container delegate %s %s\n", containerName, u.Username, containerName, u.Username)
+			os.Exit(1)
+		}
+	}
+
 	for _, b := range binds {
 		allargs = append(allargs, "--bind", b)
 	}
@@ -60,7 +167,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 	wwlog.Printf(wwlog.DEBUG, "passwd: %v\n", passwdTime)
 	wwlog.Printf(wwlog.DEBUG, "group: %v\n", groupTime)
 
-	err := runContainedCmd(allargs)
+	err = runContainedCmd(allargs, delegation)
 	if err != nil {
 		wwlog.Printf(wwlog.ERROR, "Failed executing container command: %s\n", err)
 		os.Exit(1)
@@ -68,7 +175,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 
 	if util.IsFile(path.Join(container.RootFsDir(allargs[0]), "/etc/warewulf/container_exit.sh")) {
 		wwlog.Printf(wwlog.VERBOSE, "Found clean script: /etc/warewulf/container_exit.sh\n")
-		err = runContainedCmd([]string{allargs[0], "/bin/sh", "/etc/warewulf/container_exit.sh"})
+		err = runContainedCmd([]string{allargs[0], "/bin/sh", "/etc/warewulf/container_exit.sh"}, delegation)
 		if err != nil {
 			wwlog.Printf(wwlog.ERROR, "Failed executing exit script: %s\n", err)
 			os.Exit(1)
@@ -93,7 +200,9 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		syncuids = true
 	}
 	wwlog.Printf(wwlog.DEBUG, "group: %v\n", time.Unix(int64(unixStat.Ctim.Sec), int64(unixStat.Ctim.Nsec)))
-	if syncuids && SyncUser {
+	if syncuids && SyncUser && rootless {
+		wwlog.Printf(wwlog.WARN, "Can't synchronize users as unprivileged user, run 'syncuser' as root\n")
+	} else if syncuids && SyncUser {
 		err = container.SyncUids(containerName, true)
 		if err != nil {
 			wwlog.Printf(wwlog.ERROR, "Error in user sync, fix error and run 'syncuser' manually, but trying to build container: %s\n", err)
@@ -102,7 +211,9 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Rebuilding container...\n")
 	err = container.Build(containerName, false)
-	if err != nil {
+	if err != nil && rootless {
+		wwlog.Printf(wwlog.WARN, "Could not build container %s, rebuild it as root: %s\n", containerName, err)
+	} else if err != nil {
 		wwlog.Printf(wwlog.ERROR, "Could not build container %s: %s\n", containerName, err)
 		os.Exit(1)
 	}
@@ -1,15 +1,13 @@
 package imprt
 
 import (
-	"fmt"
 	"os"
 	"path"
-	"strconv"
 	"strings"
 
-	"github.com/containers/image/v5/types"
 	"github.com/hpcng/warewulf/internal/pkg/container"
 	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/oci"
 	"github.com/hpcng/warewulf/internal/pkg/util"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
@@ -17,56 +15,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func setOCICredentials(sCtx *types.SystemContext) error {
-	username, userSet := os.LookupEnv("WAREWULF_OCI_USERNAME")
-	password, passSet := os.LookupEnv("WAREWULF_OCI_PASSWORD")
-	if userSet || passSet {
-		if userSet && passSet {
-			sCtx.DockerAuthConfig = &types.DockerAuthConfig{
-				Username: username,
-				Password: password,
-			}
-		} else {
-			return fmt.Errorf("oci username and password env vars must be specified together")
-		}
-	}
-	return nil
-}
-
-func setNoHTTPSOpts(sCtx *types.SystemContext) error {
-	val, ok := os.LookupEnv("WAREWULF_OCI_NOHTTPS")
-	if !ok {
-		return nil
-	}
-
-	noHTTPS, err := strconv.ParseBool(val)
-	if err != nil {
-		return fmt.Errorf("while parsing insecure http option: %v", err)
-	}
-
-	// only set this if we want to disable, otherwise leave as undefined
-	if noHTTPS {
-		sCtx.DockerInsecureSkipTLSVerify = types.NewOptionalBool(true)
-	}
-	sCtx.OCIInsecureSkipTLSVerify = noHTTPS
-
-	return nil
-}
-
-func getSystemContext() (sCtx *types.SystemContext, err error) {
-	sCtx = &types.SystemContext{}
-
-	if err := setOCICredentials(sCtx); err != nil {
-		return nil, err
-	}
-
-	if err := setNoHTTPSOpts(sCtx); err != nil {
-		return nil, err
-	}
-
-	return sCtx, nil
-}
-
 func CobraRunE(cmd *cobra.Command, args []string) error {
 	var name string
 	uri := args[0]
@@ -101,12 +49,13 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		}
 	} else if strings.HasPrefix(uri, "docker://") || strings.HasPrefix(uri, "docker-daemon://") ||
 		strings.HasPrefix(uri, "file://") || util.IsFile(uri) {
-		sCtx, err := getSystemContext()
+		sCtx, err := oci.NewSystemContext(SetPlatform)
 		if err != nil {
 			wwlog.ErrorExc(err, "")
+			os.Exit(1)
 		}
 
-		err = container.ImportDocker(uri, name, sCtx)
+		err = container.ImportDocker(uri, name, sCtx, SetOffline)
 		if err != nil {
 			wwlog.Error("Could not import image: %s", err)
 			_ = container.DeleteSource(name)
@@ -124,6 +73,13 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
+	if arch := container.Arch(name); arch != "" {
+		wwlog.Info("Container architecture: %s", arch)
+		if want := oci.PlatformArch(SetPlatform); want != "" && want != arch {
+			wwlog.Warn("Requested platform %s, but container has architecture %s", SetPlatform, arch)
+		}
+	}
+
 	wwlog.Info("Updating the container's /etc/resolv.conf")
 	err := util.CopyFile("/etc/resolv.conf", path.Join(container.RootFsDir(name), "/etc/resolv.conf"))
 	if err != nil {
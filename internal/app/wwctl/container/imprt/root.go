@@ -15,16 +15,20 @@ are:
  * file://path/to/archive/tar/ball
  * /path/to/archive/tar/ball
  * /path/to/chroot/
-Imported containers are used to create bootable VNFS images.`,
+Imported containers are used to create bootable VNFS images. The architecture
+of the container is detected and recorded. For multi-arch images the platform
+can be selected with --platform, it defaults to the platform of this host.`,
 		Example: "wwctl container import docker://warewulf/centos-8 my_container",
 		RunE:    CobraRunE,
 		Args:    cobra.MinimumNArgs(1),
 	}
-	SetForce   bool
-	SetUpdate  bool
-	SetBuild   bool
-	SetDefault bool
-	SyncUser   bool
+	SetForce    bool
+	SetUpdate   bool
+	SetBuild    bool
+	SetDefault  bool
+	SyncUser    bool
+	SetPlatform string
+	SetOffline  bool
 )
 
 func init() {
@@ -33,6 +37,8 @@ func init() {
 	baseCmd.PersistentFlags().BoolVarP(&SetBuild, "build", "b", false, "Build container when after pulling")
 	baseCmd.PersistentFlags().BoolVar(&SetDefault, "setdefault", false, "Set this container for the default profile")
 	baseCmd.PersistentFlags().BoolVar(&SyncUser, "syncuser", false, "Synchronize uis/gods from host to container")
+	baseCmd.PersistentFlags().StringVar(&SetPlatform, "platform", "", "Platform to import from a multi-arch image (e.g. linux/arm64 or aarch64)")
+	baseCmd.PersistentFlags().BoolVar(&SetOffline, "offline", false, "Import the image from the local OCI cache only")
 }
 
 // GetRootCommand returns the root cobra.Command for the application.
@@ -26,7 +26,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		nodemap[n.ContainerName.Get()]++
 	}
 
-	fmt.Printf("%-25s %-6s %-6s\n", "CONTAINER NAME", "NODES", "KERNEL VERSION")
+	fmt.Printf("%-25s %-6s %-8s %-6s\n", "CONTAINER NAME", "NODES", "ARCH", "KERNEL VERSION")
 	for _, source := range sources {
 		if nodemap[source] == 0 {
 			nodemap[source] = 0
@@ -34,7 +34,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 
 		wwlog.Printf(wwlog.DEBUG, "Finding kernel version for: %s\n", source)
 		kernelVersion := container.KernelVersion(source)
-		fmt.Printf("%-25s %-6d %s\n", source, nodemap[source], kernelVersion)
+		fmt.Printf("%-25s %-6d %-8s %s\n", source, nodemap[source], container.Arch(source), kernelVersion)
 
 	}
 	return nil
@@ -2,6 +2,8 @@ package container
 
 import (
 	"github.com/hpcng/warewulf/internal/app/wwctl/container/build"
+	"github.com/hpcng/warewulf/internal/app/wwctl/container/cache"
+	"github.com/hpcng/warewulf/internal/app/wwctl/container/delegate"
 	"github.com/hpcng/warewulf/internal/app/wwctl/container/delete"
 	"github.com/hpcng/warewulf/internal/app/wwctl/container/exec"
 	"github.com/hpcng/warewulf/internal/app/wwctl/container/imprt"
@@ -33,6 +35,8 @@ func init() {
 	baseCmd.AddCommand(delete.GetCommand())
 	baseCmd.AddCommand(show.GetCommand())
 	baseCmd.AddCommand(syncuser.GetCommand())
+	baseCmd.AddCommand(cache.GetCommand())
+	baseCmd.AddCommand(delegate.GetCommand())
 
 }
 
@@ -33,8 +33,12 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 	c := exec.Command("/proc/self/exe", append([]string{"container", "exec"}, allargs...)...)
 
 	//c := exec.Command("/bin/sh")
-	c.SysProcAttr = &syscall.SysProcAttr{
-		Cloneflags: syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS,
+	// unprivileged users can't create these namespaces, exec will set up a
+	// user namespace for them instead
+	if os.Geteuid() == 0 {
+		c.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags: syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS,
+		}
 	}
 	c.Stdin = os.Stdin
 	c.Stdout = os.Stdout
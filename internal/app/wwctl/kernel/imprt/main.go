@@ -2,11 +2,14 @@ package imprt
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 
+	"github.com/containers/image/v5/types"
 	"github.com/hpcng/warewulf/internal/pkg/container"
 	"github.com/hpcng/warewulf/internal/pkg/kernel"
 	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/oci"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 	"github.com/pkg/errors"
@@ -14,21 +17,49 @@ import (
 )
 
 func CobraRunE(cmd *cobra.Command, args []string) error {
+	if OptPackage != "" && OptOci != "" {
+		return errors.New("the '--package' and '--oci' flags can't be used together")
+	}
+	if OptPackage != "" || OptOci != "" {
+		if OptContainer != "" {
+			return errors.New("the '--container' flag can't be used with '--package' or '--oci'")
+		}
+		tmpRoot, err := ioutil.TempDir("", "wwkernel-")
+		if err != nil {
+			return errors.Wrap(err, "could not create temporary directory")
+		}
+		defer os.RemoveAll(tmpRoot)
+		if OptPackage != "" {
+			err = kernel.ExtractPackage(OptPackage, tmpRoot)
+		} else {
+			var sCtx *types.SystemContext
+			sCtx, err = oci.NewSystemContext(OptPlatform)
+			if err != nil {
+				return err
+			}
+			err = os.MkdirAll(container.OciBlobCacheDir(), 0755)
+			if err == nil {
+				err = kernel.ExtractOci(OptOci, tmpRoot, container.OciBlobCacheDir(), sCtx)
+			}
+		}
+		if err != nil {
+			return errors.Wrap(err, "could not extract kernel")
+		}
+		OptRoot = tmpRoot
+		OptDetect = true
+	}
 	if len(args) == 0 && !OptDetect {
-		wwlog.Printf(wwlog.ERROR, "the '--detect' flag is needed, if no kernel version is suppiled")
-		os.Exit(1)
+		return errors.New("the '--detect' flag is needed, if no kernel version is suppiled")
 	}
-	if OptDetect && (OptRoot == "" || OptContainer == "") {
-		wwlog.Printf(wwlog.ERROR, "the '--detect flag needs the '--container' or '--root' flag")
-		os.Exit(1)
+	if OptDetect && (OptRoot == "" && OptContainer == "") {
+		return errors.New("the '--detect flag needs the '--container' or '--root' flag")
 	}
 	// Checking if container flag was set, then overwriting OptRoot
 	if OptContainer != "" {
 		if container.ValidSource(OptContainer) {
 			OptRoot = container.RootFsDir(OptContainer)
 		} else {
-			wwlog.Printf(wwlog.ERROR, " %s is not a valid container", OptContainer)
-			os.Exit(1)
+			return errors.Errorf("%s is not a valid container", OptContainer)
 		}
 	}
 
@@ -39,8 +70,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 	} else {
 		kernelVersion, err = kernel.FindKernelVersion(OptRoot)
 		if err != nil {
-			wwlog.Printf(wwlog.ERROR, "could not detect kernel under %s\n", OptRoot)
-			os.Exit(1)
+			return errors.Errorf("could not detect kernel under %s", OptRoot)
 		}
 	}
 	kernelName := kernelVersion
@@ -49,28 +79,46 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 	} else if OptDetect && (OptContainer != "") {
 		kernelName = OptContainer
 	}
-	output, err := kernel.Build(kernelVersion, kernelName, OptRoot)
+	var policy *kernel.ModulePolicy
+	if OptModules != "" {
+		policy, err = kernel.ReadModulePolicy(OptModules)
+		if err != nil {
+			return err
+		}
+	}
+	if len(OptIncludeModules) > 0 || OptHwProfile != "" {
+		if policy == nil {
+			policy = new(kernel.ModulePolicy)
+		}
+		policy.Include = append(policy.Include, OptIncludeModules...)
+	}
+	if OptHwProfile != "" {
+		modaliases, err := kernel.ReadHardwareProfile(OptHwProfile)
+		if err != nil {
+			return errors.Wrap(err, "could not read hardware profile")
+		}
+		policy.Modaliases = append(policy.Modaliases, modaliases...)
+	}
+
+	output, err := kernel.Build(kernelVersion, kernelName, OptRoot, policy)
 	if err != nil {
-		wwlog.Printf(wwlog.ERROR, "Failed building kernel: %s\n", err)
-		os.Exit(1)
-	} else {
-		fmt.Printf("%s: %s\n", kernelName, output)
+		return errors.Wrap(err, "failed building kernel")
 	}
+	fmt.Printf("%s: %s\n", kernelName, output)
 
 	if SetDefault {
 
 		nodeDB, err := node.New()
 		if err != nil {
-			wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
-			os.Exit(1)
+			return errors.Wrap(err, "could not open node configuration")
 		}
 		//TODO: Don't loop through profiles, instead have a nodeDB function that goes directly to the map
 		profiles, _ := nodeDB.FindAllProfiles()
 		for _, profile := range profiles {
 			wwlog.Printf(wwlog.DEBUG, "Looking for profile default: %s\n", profile.Id.Get())
 			if profile.Id.Get() == "default" {
-				wwlog.Printf(wwlog.DEBUG, "Found profile default, setting kernel version to: %s\n", args[0])
-				profile.Kernel.Override.Set(args[0])
+				wwlog.Printf(wwlog.DEBUG, "Found profile default, setting kernel version to: %s\n", kernelName)
+				profile.Kernel.Override.Set(kernelName)
 				err := nodeDB.ProfileUpdate(profile)
 				if err != nil {
 					return errors.Wrap(err, "failed to update node profile")
@@ -81,7 +129,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return errors.Wrap(err, "failed to persist nodedb")
 		}
-		fmt.Printf("Set default kernel version to: %s\n", args[0])
+		fmt.Printf("Set default kernel version to: %s\n", kernelName)
 		err = warewulfd.DaemonReload()
 		if err != nil {
 			return errors.Wrap(err, "failed to reload warewulf daemon")
@@ -10,11 +10,20 @@ import (
 var (
 	baseCmd = &cobra.Command{
 		DisableFlagsInUseLine: true,
-		Use:                   "import [OPTIONS] KERNEL",
+		Use:                   "import [OPTIONS] [KERNEL [NAME]]",
 		Short:                 "Import Kernel version into Warewulf",
-		Long:                  "This will import a boot KERNEL version from the control node into Warewulf",
-		RunE:                  CobraRunE,
-		Args:                  cobra.MinimumNArgs(0),
+		Long: "This will import a boot KERNEL version from the control node into Warewulf.\n" +
+			"With --package the kernel is imported from a rpm or deb package, with --oci\n" +
+			"from an OCI image, of which only /boot, the modules and the firmware are\n" +
+			"extracted. The version is detected if KERNEL is not given.\n\n" +
+			"By default all modules and firmware are packed into the kmods image. With\n" +
+			"--modules, --include-modules or --hwprofile only the selected modules, their\n" +
+			"dependencies and the firmware they need are packed. Import the kernel under\n" +
+			"different names to use different selections for different nodes.",
+		Example: "wwctl kernel import --package kernel-core-5.14.0-70.el9.x86_64.rpm\n" +
+			"wwctl kernel import --oci docker://registry.example.org/kernels:5.15 vendor-5.15",
+		RunE: CobraRunE,
+		Args: cobra.MinimumNArgs(0),
 	}
 	BuildAll     bool
 	ByNode       bool
@@ -22,6 +31,13 @@ var (
 	OptRoot      string
 	OptContainer string
 	OptDetect    bool
+	OptPackage   string
+	OptOci       string
+	OptPlatform  string
+	OptModules   string
+	OptHwProfile string
+
+	OptIncludeModules []string
 )
 
 func init() {
@@ -38,6 +54,12 @@ func init() {
 		log.Println(err)
 	}
 	baseCmd.PersistentFlags().BoolVarP(&OptDetect, "detect", "D", false, "Try to detect the kernel version in an automated way, needs the -C or -r option")
+	baseCmd.PersistentFlags().StringVar(&OptPackage, "package", "", "Import kernel from a rpm or deb package file")
+	baseCmd.PersistentFlags().StringVar(&OptOci, "oci", "", "Import kernel from an OCI image (e.g. docker://...)")
+	baseCmd.PersistentFlags().StringVar(&OptPlatform, "platform", "", "Platform of the OCI image (e.g. linux/arm64)")
+	baseCmd.PersistentFlags().StringVar(&OptModules, "modules", "", "Module selection policy (yaml file with include, classes, modaliases, exclude, firmware)")
+	baseCmd.PersistentFlags().StringSliceVar(&OptIncludeModules, "include-modules", []string{}, "Only pack these modules (and their dependencies)")
+	baseCmd.PersistentFlags().StringVar(&OptHwProfile, "hwprofile", "", "Only pack the modules for the modaliases listed in this file")
 }
 
 // GetRootCommand returns the root cobra.Command for the application.
@@ -73,6 +73,22 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 
 		}
 
+		if SetNetwork != "" {
+			if SetNetName == "" {
+				return errors.New("you must include the '--netname' option")
+			}
+
+			if _, ok := n.NetDevs[SetNetName]; !ok {
+				var netdev node.NetDevEntry
+				n.NetDevs[SetNetName] = &netdev
+			}
+
+			wwlog.Printf(wwlog.VERBOSE, "Node: %s:%s, Setting network to: %s\n", n.Id.Get(), SetNetName, SetNetwork)
+
+			n.NetDevs[SetNetName].Network.Set(SetNetwork)
+			n.NetDevs[SetNetName].OnBoot.SetB(true)
+		}
+
 		if SetNetmask != "" {
 			if SetNetName == "" {
 				return errors.New("you must include the '--netname' option")
@@ -152,6 +168,18 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		count++
 	}
 
+	_, err = nodeDB.AllocateIpaddrs(node_args)
+	if err != nil {
+		return errors.Wrap(err, "failed to allocate addresses")
+	}
+	problems, err := nodeDB.CheckIpaddrs()
+	if err != nil {
+		return errors.Wrap(err, "failed to check addresses")
+	}
+	if err := node.IpaddrError(problems, node_args); err != nil {
+		return err
+	}
+
 	err = nodeDB.Persist()
 	if err != nil {
 		return errors.Wrap(err, "failed to persist new node")
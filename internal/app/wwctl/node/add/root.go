@@ -16,6 +16,7 @@ var (
 	SetNetDev       string
 	SetIpaddr       string
 	SetIpaddr6      string
+	SetNetwork      string
 	SetNetmask      string
 	SetGateway      string
 	SetHwaddr       string
@@ -29,6 +30,7 @@ func init() {
 	baseCmd.PersistentFlags().StringVarP(&SetNetDev, "netdev", "N", "", "Define the network device to configure")
 	baseCmd.PersistentFlags().StringVarP(&SetIpaddr, "ipaddr", "I", "", "Set the node's network device IP address")
 	baseCmd.PersistentFlags().StringVarP(&SetIpaddr6, "ipaddr6", "6", "", "Set the node's network device IPv6 address")
+	baseCmd.PersistentFlags().StringVar(&SetNetwork, "network", "", "Allocate the node's IP address from this network of warewulf.conf")
 	baseCmd.PersistentFlags().StringVarP(&SetNetmask, "netmask", "M", "", "Set the node's network device netmask")
 	baseCmd.PersistentFlags().StringVarP(&SetGateway, "gateway", "G", "", "Set the node's network device gateway")
 	baseCmd.PersistentFlags().StringVarP(&SetHwaddr, "hwaddr", "H", "", "Set the node's network device HW address")
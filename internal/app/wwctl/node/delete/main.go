@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/reprovision"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 	"github.com/hpcng/warewulf/pkg/hostlist"
@@ -16,6 +17,7 @@ import (
 func CobraRunE(cmd *cobra.Command, args []string) error {
 	var count int
 	var nodeList []node.NodeInfo
+	var deleted []string
 
 	nodeDB, err := node.New()
 	if err != nil {
@@ -29,7 +31,21 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
+	nodes, err = warewulfd.SelectNodes(nodes, SetSelect)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not select nodes: %s\n", err)
+		os.Exit(1)
+	}
+
 	args = hostlist.Expand(args)
+	if len(args) == 0 {
+		if SetSelect == "" {
+			//nolint:errcheck
+			cmd.Usage()
+			os.Exit(1)
+		}
+		nodeList = nodes
+	}
 
 	for _, r := range args {
 		var match bool
@@ -56,6 +72,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			wwlog.Printf(wwlog.ERROR, "%s\n", err)
 		} else {
 			count++
+			deleted = append(deleted, n.Id.Get())
 			fmt.Printf("Deleting node: %s\n", n.Id.Print())
 		}
 	}
@@ -65,6 +82,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return errors.Wrap(err, "failed to persist nodedb")
 		}
+		removeStates(deleted)
 	} else {
 		q := fmt.Sprintf("Are you sure you want to delete %d nodes(s)", count)
 
@@ -80,6 +98,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			if err != nil {
 				return errors.Wrap(err, "failed to persist nodedb")
 			}
+			removeStates(deleted)
 
 			err = warewulfd.DaemonReload()
 			if err != nil {
@@ -91,3 +110,14 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+/*
+Forgets the provisioning state of the deleted nodes, so that a new node of
+the same name is installed again
+*/
+func removeStates(nodeIDs []string) {
+	err := reprovision.Remove(nodeIDs...)
+	if err != nil {
+		wwlog.Printf(wwlog.WARN, "Could not remove the provisioning state of the nodes: %s\n", err)
+	}
+}
@@ -8,12 +8,11 @@ import (
 var (
 	baseCmd = &cobra.Command{
 		DisableFlagsInUseLine: true,
-		Use:     "delete [OPTIONS] NODE [NODE ...]",
-		Short:   "Delete a node from Warewulf",
-		Long:    "This command will remove NODE(s) from the Warewulf node configuration.",
-		Args:    cobra.MinimumNArgs(1),
-		RunE:    CobraRunE,
-		Aliases: []string{"rm", "del"},
+		Use:                   "delete [OPTIONS] NODE [NODE ...]",
+		Short:                 "Delete a node from Warewulf",
+		Long:                  "This command will remove NODE(s) from the Warewulf node configuration.",
+		RunE:                  CobraRunE,
+		Aliases:               []string{"rm", "del"},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
 				return nil, cobra.ShellCompDirectiveNoFileComp
@@ -28,13 +27,15 @@ var (
 			return node_names, cobra.ShellCompDirectiveNoFileComp
 		},
 	}
-	SetYes   bool
-	SetForce string
+	SetYes    bool
+	SetForce  string
+	SetSelect string
 )
 
 func init() {
 	baseCmd.PersistentFlags().StringVarP(&SetForce, "force", "f", "", "Force node delete")
 	baseCmd.PersistentFlags().BoolVarP(&SetYes, "yes", "y", false, "Set 'yes' to all questions asked")
+	baseCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
 
 }
 
@@ -0,0 +1,85 @@
+package accept
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/discovery"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	pending, err := discovery.Find(args[0])
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+
+	conf, err := warewulfconf.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open Warewulf configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	nodeDB, err := node.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	var n node.NodeInfo
+	var netdev string
+	if len(args) == 2 {
+		n, netdev, err = nodeDB.BindHwaddr(args[1], SetNetName, pending.Hwaddr)
+	} else {
+		var rules []node.DiscoveryRule
+		rules, err = node.CompileDiscoveryRules(conf.Discovery)
+		if err == nil {
+			n, netdev, err = nodeDB.DiscoverNode(pending.Request(), rules, conf.Discovery == nil || conf.Discovery.Fallback)
+		}
+		if err == nil && !n.Id.Defined() {
+			err = errors.New("no node is proposed for " + pending.Hwaddr + ", give the node name")
+		}
+	}
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+
+	err = nodeDB.NodeUpdate(n)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+	if conf.Warewulf.LearnUUID && pending.UUID != "" && !n.UUID.Defined() {
+		err = nodeDB.SetUUID(n.Id.Get(), pending.UUID)
+		if err != nil {
+			wwlog.Printf(wwlog.WARN, "Node: %s, not binding uuid: %s\n", n.Id.Get(), err)
+		}
+	}
+	_, err = nodeDB.AllocateIpaddrs([]string{n.Id.Get()})
+	if err != nil {
+		return errors.Wrap(err, "failed to allocate addresses")
+	}
+
+	err = nodeDB.Persist()
+	if err != nil {
+		return errors.Wrap(err, "failed to persist nodedb")
+	}
+	err = discovery.Remove(pending.Hwaddr)
+	if err != nil {
+		wwlog.Printf(wwlog.WARN, "Could not remove pending discovery: %s\n", err)
+	}
+	fmt.Printf("Bound %s to %s:%s\n", pending.Hwaddr, n.Id.Get(), netdev)
+
+	err = warewulfd.DaemonReload()
+	if err != nil {
+		return errors.Wrap(err, "failed to reload warewulf daemon")
+	}
+	return nil
+}
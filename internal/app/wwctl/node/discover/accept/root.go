@@ -0,0 +1,48 @@
+package accept
+
+import (
+	"github.com/hpcng/warewulf/internal/pkg/discovery"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "accept [OPTIONS] HWADDR [NODE]",
+		Short:                 "Bind a machine waiting for approval to a node",
+		Long: "This command binds the hardware address of a machine waiting for approval\n" +
+			"to NODE, which has to exist. Without NODE the node the discovery rules\n" +
+			"propose is used, which is added if the rule allows it. The address is set on\n" +
+			"the network device given with --netname or on the first one without a\n" +
+			"hardware address.",
+		RunE: CobraRunE,
+		Args: cobra.RangeArgs(1, 2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			var ret []string
+			if len(args) == 0 {
+				list, _ := discovery.List()
+				for _, p := range list {
+					ret = append(ret, p.Hwaddr)
+				}
+			} else if len(args) == 1 {
+				nodeDB, _ := node.New()
+				nodes, _ := nodeDB.FindAllNodes()
+				for _, n := range nodes {
+					ret = append(ret, n.Id.Get())
+				}
+			}
+			return ret, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	SetNetName string
+)
+
+func init() {
+	baseCmd.PersistentFlags().StringVarP(&SetNetName, "netname", "n", "", "Network device of the node to bind the hardware address to")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
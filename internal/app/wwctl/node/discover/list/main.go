@@ -0,0 +1,63 @@
+package list
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hpcng/warewulf/internal/pkg/discovery"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+)
+
+func formatTime(t int64) string {
+	if t == 0 {
+		return "--"
+	}
+	return time.Unix(t, 0).Format("2006-01-02 15:04:05")
+}
+
+func orDash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "--"
+	}
+	return s
+}
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	list, err := discovery.List()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read pending discoveries: %s\n", err)
+		os.Exit(1)
+	}
+
+	if ShowAll {
+		for _, p := range list {
+			fmt.Printf("################################################################################\n")
+			fmt.Printf("%-18s %-12s %s\n", p.Hwaddr, "FirstSeen", formatTime(p.FirstSeen))
+			fmt.Printf("%-18s %-12s %s\n", p.Hwaddr, "LastSeen", formatTime(p.LastSeen))
+			fmt.Printf("%-18s %-12s %s\n", p.Hwaddr, "Ipaddr", orDash(p.Ipaddr))
+			fmt.Printf("%-18s %-12s %s\n", p.Hwaddr, "UUID", orDash(p.UUID))
+			fmt.Printf("%-18s %-12s %s\n", p.Hwaddr, "Serial", orDash(p.Serial))
+			fmt.Printf("%-18s %-12s %s\n", p.Hwaddr, "AssetTag", orDash(p.AssetTag))
+			fmt.Printf("%-18s %-12s %s\n", p.Hwaddr, "CircuitId", orDash(p.CircuitId))
+			fmt.Printf("%-18s %-12s %s\n", p.Hwaddr, "RemoteId", orDash(p.RemoteId))
+			fmt.Printf("%-18s %-12s %s\n", p.Hwaddr, "Node", orDash(p.Node))
+			fmt.Printf("%-18s %-12s %t\n", p.Hwaddr, "Rejected", p.Rejected)
+		}
+		return nil
+	}
+
+	fmt.Printf("%-18s %-19s %-19s %-15s %-36s %-12s %-12s %s\n", "HWADDR", "FIRST SEEN", "LAST SEEN", "IPADDR", "UUID", "ASSET TAG", "NODE", "STATE")
+	fmt.Println(strings.Repeat("=", 150))
+	for _, p := range list {
+		state := "pending"
+		if p.Rejected {
+			state = "rejected"
+		}
+		fmt.Printf("%-18s %-19s %-19s %-15s %-36s %-12s %-12s %s\n", p.Hwaddr, formatTime(p.FirstSeen), formatTime(p.LastSeen),
+			orDash(p.Ipaddr), orDash(p.UUID), orDash(p.AssetTag), orDash(p.Node), state)
+	}
+	return nil
+}
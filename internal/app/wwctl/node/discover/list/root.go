@@ -0,0 +1,28 @@
+package list
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "list [OPTIONS]",
+		Short:                 "List the machines waiting for approval",
+		Long: "This command lists the machines which requested a boot but are not bound\n" +
+			"to a node, with the node the discovery rules propose for them.",
+		RunE:    CobraRunE,
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+	}
+	ShowAll bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().BoolVarP(&ShowAll, "all", "a", false, "Show also the serial and the switch port of the machines")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,23 @@
+package reject
+
+import (
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/discovery"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	var err error
+	if SetRemove {
+		err = discovery.Remove(args...)
+	} else {
+		err = discovery.Reject(args...)
+	}
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package reject
+
+import (
+	"github.com/hpcng/warewulf/internal/pkg/discovery"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "reject [OPTIONS] HWADDR [HWADDR ...]",
+		Short:                 "Reject the discovery of machines",
+		Long: "This command rejects machines waiting for approval. They are not bound to a\n" +
+			"node, also not by the discovery rules, until they are accepted. With --remove\n" +
+			"they are removed from the list instead and recorded again on their next boot.",
+		RunE: CobraRunE,
+		Args: cobra.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			list, _ := discovery.List()
+			var hwaddrs []string
+			for _, p := range list {
+				hwaddrs = append(hwaddrs, p.Hwaddr)
+			}
+			return hwaddrs, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	SetRemove bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().BoolVar(&SetRemove, "remove", false, "Remove the machines from the list")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
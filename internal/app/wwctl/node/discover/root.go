@@ -0,0 +1,31 @@
+package discover
+
+import (
+	"github.com/hpcng/warewulf/internal/app/wwctl/node/discover/accept"
+	"github.com/hpcng/warewulf/internal/app/wwctl/node/discover/list"
+	"github.com/hpcng/warewulf/internal/app/wwctl/node/discover/reject"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "discover COMMAND [OPTIONS]",
+		Short:                 "Approve the discovery of unknown nodes",
+		Long: "Machines which request a boot but are not bound to a node are recorded by\n" +
+			"warewulfd with their hardware address, source IP, UUID and asset tag. With\n" +
+			"'approve: true' in the discovery section of warewulf.conf they are only\n" +
+			"bound to a node when they are accepted with these commands.",
+	}
+)
+
+func init() {
+	baseCmd.AddCommand(list.GetCommand())
+	baseCmd.AddCommand(accept.GetCommand())
+	baseCmd.AddCommand(reject.GetCommand())
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,144 @@
+package nodeexec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hpcng/warewulf/internal/pkg/batch"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/remoteexec"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/hpcng/warewulf/pkg/hostlist"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	dash := cmd.ArgsLenAtDash()
+	if dash < 1 || dash >= len(args) {
+		//nolint:errcheck
+		cmd.Usage()
+		os.Exit(1)
+	}
+	command := args[dash:]
+
+	nodeDB, err := node.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	nodes, err := nodeDB.FindAllNodes()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not get node list: %s\n", err)
+		os.Exit(1)
+	}
+
+	nodes, err = warewulfd.SelectNodes(nodes, SetSelect)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not select nodes: %s\n", err)
+		os.Exit(1)
+	}
+
+	nodes = node.FilterByName(nodes, hostlist.Expand(args[:dash]))
+	if len(nodes) == 0 {
+		wwlog.Printf(wwlog.ERROR, "No nodes found\n")
+		os.Exit(1)
+	}
+
+	var failed int32
+	batchpool := batch.New(FanOut)
+	for _, n := range nodes {
+		nodename := n.Id.Get()
+		batchpool.Submit(func() {
+			if !runCommand(nodename, command) {
+				atomic.AddInt32(&failed, 1)
+			}
+		})
+	}
+	batchpool.Run()
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// output of several nodes is printed line by line
+var printLock sync.Mutex
+
+/*
+Prints complete lines prefixed with the name of the node
+*/
+type lineWriter struct {
+	prefix  string
+	out     io.Writer
+	partial string
+}
+
+func (w *lineWriter) write(data string) {
+	lines := strings.Split(w.partial+data, "\n")
+	w.partial = lines[len(lines)-1]
+	printLock.Lock()
+	defer printLock.Unlock()
+	for _, line := range lines[:len(lines)-1] {
+		fmt.Fprintf(w.out, "%s: %s\n", w.prefix, line)
+	}
+}
+
+func (w *lineWriter) flush() {
+	if w.partial != "" {
+		w.write("\n")
+	}
+}
+
+/*
+Runs the command on a node and prints its output, returns false if the
+command failed or could not be run
+*/
+func runCommand(nodename string, command []string) bool {
+	stdout := &lineWriter{prefix: nodename, out: os.Stdout}
+	stderr := &lineWriter{prefix: nodename, out: os.Stderr}
+
+	wwlog.Printf(wwlog.DEBUG, "Sending command to node '%s': %s\n", nodename, command)
+	id, err := warewulfd.SubmitCommand(remoteexec.Request{Node: nodename, Command: command, Timeout: SetTimeout})
+	if err != nil {
+		stderr.write(fmt.Sprintf("%s\n", err))
+		return false
+	}
+
+	offset := 0
+	for {
+		status, err := warewulfd.CommandStatus(id, offset, 30)
+		if err != nil {
+			stderr.write(fmt.Sprintf("%s\n", err))
+			return false
+		}
+		for _, o := range status.Output {
+			if o.Stream == remoteexec.Stderr {
+				stderr.write(o.Data)
+			} else {
+				stdout.write(o.Data)
+			}
+		}
+		offset = status.Next
+		if !status.Done {
+			continue
+		}
+		stdout.flush()
+		stderr.flush()
+		if status.Error != "" {
+			stderr.write(fmt.Sprintf("%s\n", status.Error))
+			return false
+		}
+		if status.ExitCode != 0 {
+			stderr.write(fmt.Sprintf("exit code %d\n", status.ExitCode))
+			return false
+		}
+		return true
+	}
+}
@@ -0,0 +1,33 @@
+package nodeexec
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "exec [OPTIONS] NODE_PATTERN... -- COMMAND [ARGS...]",
+		Short:                 "Run a command on nodes through wwclient",
+		Long: "Runs a command on nodes without ssh. The command is queued in warewulfd and\n" +
+			"run by wwclient on the nodes, which streams the output back. Nodes only run\n" +
+			"commands which match a pattern in /etc/warewulf/wwclient.exec of their\n" +
+			"runtime overlay, argument by argument. The command is run without a shell.",
+		RunE: CobraRunE,
+		Args: cobra.MinimumNArgs(2),
+	}
+	FanOut     int
+	SetTimeout int
+	SetSelect  string
+)
+
+func init() {
+	baseCmd.PersistentFlags().IntVarP(&FanOut, "fanout", "f", 32, "How many nodes run the command in parallel")
+	baseCmd.PersistentFlags().IntVarP(&SetTimeout, "timeout", "t", 60, "Seconds after which the command is killed")
+	baseCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,57 @@
+package explain
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	nodeDB, err := node.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	nodes, err := nodeDB.FindAllNodes()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not get node list: %s\n", err)
+		os.Exit(1)
+	}
+
+	var n *node.NodeInfo
+	for i := range nodes {
+		if nodes[i].Id.Get() == args[0] {
+			n = &nodes[i]
+		}
+	}
+	if n == nil {
+		wwlog.Printf(wwlog.ERROR, "Node does not exist: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	fields := args[1:]
+	if len(fields) == 0 {
+		fields = n.DefinedFields()
+	}
+
+	fmt.Printf("Profiles: %s\n\n", strings.Join(n.Profiles, ","))
+	fmt.Printf("%-32s %-12s %s\n", "FIELD", "FROM", "VALUE")
+	for _, field := range fields {
+		layers, err := n.Explain(field)
+		if err != nil {
+			wwlog.Printf(wwlog.ERROR, "%s\n", err)
+			os.Exit(1)
+		}
+		for _, l := range layers {
+			fmt.Printf("%-32s %-12s %s\n", field, l.From, strings.Join(l.Value, ","))
+		}
+		fmt.Printf("%-32s %-12s %s\n", field, "=>", strings.Join(n.Fields()[field].GetSlice(), ","))
+	}
+
+	return nil
+}
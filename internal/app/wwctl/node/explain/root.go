@@ -0,0 +1,41 @@
+package explain
+
+import (
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "explain [OPTIONS] NODE [FIELD ...]",
+		Short:                 "Show where the values of a node come from",
+		Long: "This command shows for every FIELD of NODE (or all fields with a value) the\n" +
+			"default, the value of each profile in the order they are applied and the\n" +
+			"value of the node itself, followed by the resulting value. Later profiles\n" +
+			"take precedence over earlier ones and the node over all profiles. Values of\n" +
+			"the overlays and the kernel args starting with '+' are appended to the\n" +
+			"inherited value. Fields are named as in nodes.conf, e.g. 'kernel.args' or\n" +
+			"'network devices.default.ipaddr'.",
+		RunE: CobraRunE,
+		Args: cobra.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			nodeDB, _ := node.New()
+			nodes, _ := nodeDB.FindAllNodes()
+			var node_names []string
+			for _, node := range nodes {
+				node_names = append(node_names, node.Id.Get())
+			}
+			return node_names, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+)
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
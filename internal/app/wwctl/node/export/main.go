@@ -0,0 +1,51 @@
+package export
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/hpcng/warewulf/pkg/hostlist"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	nodeDB, err := node.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	records, err := nodeDB.Records(hostlist.Expand(args))
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+
+	format := SetFormat
+	if format == "" {
+		format = node.FormatFromFileName(SetOutput)
+	}
+
+	var buffer bytes.Buffer
+	err = records.Write(&buffer, format)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not export nodes: %s\n", err)
+		os.Exit(1)
+	}
+
+	if SetOutput == "" {
+		_, err = os.Stdout.Write(buffer.Bytes())
+		return err
+	}
+	err = util.WriteFileAtomic(SetOutput, buffer.Bytes(), 0644)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not write %s: %s\n", SetOutput, err)
+		os.Exit(1)
+	}
+	wwlog.Printf(wwlog.INFO, "Exported %d nodes to %s\n", len(records), SetOutput)
+
+	return nil
+}
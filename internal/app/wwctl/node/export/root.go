@@ -0,0 +1,27 @@
+package export
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "export [OPTIONS] [NODENAME...]",
+		Short:                 "Export nodes to a yaml, json or csv file",
+		Long: "This command writes the configuration of the given nodes, or of all nodes,\n" +
+			"as it is stored in the node database. Values inherited from profiles are not\n" +
+			"included. The output can be changed and imported again with 'wwctl node import'.",
+		RunE: CobraRunE,
+	}
+	SetFormat string
+	SetOutput string
+)
+
+func init() {
+	baseCmd.PersistentFlags().StringVarP(&SetFormat, "format", "f", "", "Format of the output: yaml, json or csv (default from the file extension or yaml)")
+	baseCmd.PersistentFlags().StringVarP(&SetOutput, "output", "o", "", "Write to this file instead of stdout")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
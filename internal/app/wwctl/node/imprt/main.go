@@ -0,0 +1,91 @@
+package imprt
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	format := SetFormat
+	if format == "" {
+		format = node.FormatFromFileName(args[0])
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+	records, err := node.ReadRecords(file, format)
+	file.Close()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read %s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+
+	nodeDB, err := node.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	results, err := nodeDB.ImportRecords(records, SetUpsert)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "%s\n", err)
+		os.Exit(1)
+	}
+
+	var missing bool
+	for _, name := range records.Names() {
+		c := nodeDB.Nodes[name].ContainerName
+		if c != "" && !container.ValidSource(c) {
+			wwlog.Printf(wwlog.ERROR, "%s: container does not exist: %s\n", name, c)
+			missing = true
+		}
+	}
+	if missing {
+		os.Exit(1)
+	}
+
+	for _, r := range results {
+		if r.Added {
+			fmt.Printf("add node %s\n", r.Name)
+		} else {
+			fmt.Printf("update node %s\n", r.Name)
+		}
+		for _, c := range r.Changes {
+			fmt.Printf("  %-30s %s -> %s\n", c.Field, value(c.Old), value(c.New))
+		}
+	}
+	if SetDryRun || len(results) == 0 {
+		wwlog.Printf(wwlog.INFO, "%d nodes to change\n", len(results))
+		return nil
+	}
+
+	err = nodeDB.Persist()
+	if err != nil {
+		return errors.Wrap(err, "failed to persist nodedb")
+	}
+	wwlog.Printf(wwlog.INFO, "Changed %d nodes\n", len(results))
+
+	err = warewulfd.DaemonReload()
+	if err != nil {
+		return errors.Wrap(err, "failed to reload warewulf daemon")
+	}
+
+	return nil
+}
+
+func value(v string) string {
+	if v == "" {
+		return "--"
+	}
+	return v
+}
@@ -0,0 +1,35 @@
+package imprt
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "import [OPTIONS] FILE",
+		Short:                 "Import nodes from a yaml, json or csv file",
+		Long: "This command adds the nodes of FILE, as written by 'wwctl node export', to\n" +
+			"Warewulf. The format is taken from the file extension unless --format is set.\n" +
+			"In csv files the first column is the node name and the header holds the\n" +
+			"field names of nodes.conf, nested fields are joined with a dot, e.g.\n" +
+			"'network devices.default.hwaddr', and lists are comma separated.\n\n" +
+			"Existing nodes are only changed with --upsert, then the fields of the file\n" +
+			"replace the ones of the node and UNSET removes a field. All nodes are\n" +
+			"validated before anything is written.",
+		RunE: CobraRunE,
+		Args: cobra.ExactArgs(1),
+	}
+	SetFormat string
+	SetUpsert bool
+	SetDryRun bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().StringVarP(&SetFormat, "format", "f", "", "Format of the file: yaml, json or csv")
+	baseCmd.PersistentFlags().BoolVarP(&SetUpsert, "upsert", "u", false, "Update existing nodes instead of failing")
+	baseCmd.PersistentFlags().BoolVarP(&SetDryRun, "dry-run", "n", false, "Only show the changes, do not write them")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,136 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hpcng/warewulf/internal/pkg/inventory"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/hpcng/warewulf/pkg/hostlist"
+	"github.com/spf13/cobra"
+)
+
+func formatTime(t int64) string {
+	if t == 0 {
+		return "--"
+	}
+	return time.Unix(t, 0).Format("2006-01-02 15:04:05")
+}
+
+func orDash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "--"
+	}
+	return s
+}
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	nodeDB, err := node.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	nodes, err := nodeDB.FindAllNodes()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not get node list: %s\n", err)
+		os.Exit(1)
+	}
+
+	nodes, err = warewulfd.SelectNodes(nodes, SetSelect)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not select nodes: %s\n", err)
+		os.Exit(1)
+	}
+	nodes = node.FilterByName(nodes, hostlist.Expand(args))
+
+	var records []inventory.Record
+	for _, n := range nodes {
+		record, err := inventory.Load(n.Id.Get())
+		if os.IsNotExist(err) {
+			record.Node = n.Id.Get()
+		} else if err != nil {
+			wwlog.Printf(wwlog.ERROR, "Could not read inventory of %s: %s\n", n.Id.Get(), err)
+			continue
+		}
+		records = append(records, record)
+	}
+
+	if ShowChanges {
+		fmt.Printf("%-20s %-19s %-6s %-20s %s\n", "NODE", "TIME", "KIND", "ITEM", "CHANGE")
+		fmt.Println(strings.Repeat("=", 100))
+		for _, r := range records {
+			for _, c := range r.Changes {
+				var change string
+				switch c.Change {
+				case inventory.Added:
+					change = "added: " + c.New
+				case inventory.Removed:
+					change = "removed: " + c.Old
+				default:
+					change = c.Old + " -> " + c.New
+				}
+				fmt.Printf("%-20s %-19s %-6s %-20s %s\n", r.Node, formatTime(c.Time), c.Kind, c.Item, change)
+			}
+		}
+
+	} else if ShowAll {
+		for _, r := range records {
+			inv := r.Inventory
+			fmt.Printf("################################################################################\n")
+			fmt.Printf("%-20s %-10s %s\n", "NODE", "FIELD", "VALUE")
+			if r.Updated == 0 {
+				fmt.Printf("%-20s %-10s %s\n", r.Node, "Updated", "-- (no inventory reported)")
+				continue
+			}
+			fmt.Printf("%-20s %-10s %s\n", r.Node, "Updated", formatTime(r.Updated))
+			fmt.Printf("%-20s %-10s %s\n", r.Node, "System", orDash(strings.TrimSpace(inv.System.Manufacturer+" "+inv.System.Product)))
+			fmt.Printf("%-20s %-10s %s\n", r.Node, "Serial", orDash(inv.System.Serial))
+			fmt.Printf("%-20s %-10s %s\n", r.Node, "UUID", orDash(inv.System.UUID))
+			fmt.Printf("%-20s %-10s %s\n", r.Node, "AssetTag", orDash(inv.System.AssetTag))
+			fmt.Printf("%-20s %-10s %s\n", r.Node, "Board", orDash(strings.TrimSpace(inv.Board.Manufacturer+" "+inv.Board.Product+" "+inv.Board.Serial)))
+			fmt.Printf("%-20s %-10s %s\n", r.Node, "Bios", orDash(strings.TrimSpace(inv.Bios.Vendor+" "+inv.Bios.Version+" "+inv.Bios.Date)))
+			for _, cpu := range inv.Cpus {
+				fmt.Printf("%-20s %-10s %s: %s, %d cores, %d threads\n", r.Node, "Cpu", cpu.Socket, cpu.Model, cpu.Cores, cpu.Threads)
+			}
+			fmt.Printf("%-20s %-10s %d MiB\n", r.Node, "Memory", inv.Memory.Total)
+			for _, dimm := range inv.Memory.Dimms {
+				fmt.Printf("%-20s %-10s %s: %d MiB %s %s %s %s\n", r.Node, "Dimm", dimm.Locator, dimm.Size, dimm.Type, dimm.Speed, dimm.Manufacturer, dimm.Serial)
+			}
+			for _, disk := range inv.Disks {
+				fmt.Printf("%-20s %-10s %s: %s %s %s\n", r.Node, "Disk", disk.Name, inventory.FormatBytes(disk.Size), disk.Model, disk.Serial)
+			}
+			for _, nic := range inv.Nics {
+				speed := ""
+				if nic.Speed > 0 {
+					speed = strconv.Itoa(nic.Speed) + "Mb/s"
+				}
+				fmt.Printf("%-20s %-10s %s: %s %s %s %s\n", r.Node, "Nic", nic.Name, nic.Hwaddr, nic.Link, speed, nic.Driver)
+			}
+			for _, gpu := range inv.Gpus {
+				fmt.Printf("%-20s %-10s %s: %s:%s %s\n", r.Node, "Gpu", gpu.Address, gpu.Vendor, gpu.Device, gpu.Driver)
+			}
+		}
+
+	} else {
+		fmt.Printf("%-20s %-19s %-26s %-5s %-9s %-5s %-5s %-5s %s\n", "NODE", "UPDATED", "SYSTEM", "CPUS", "MEMORY", "DISKS", "NICS", "GPUS", "CHANGES")
+		fmt.Println(strings.Repeat("=", 110))
+		for _, r := range records {
+			if r.Updated == 0 {
+				fmt.Printf("%-20s %-19s %-26s %-5s %-9s %-5s %-5s %-5s %s\n", r.Node, "--", "--", "--", "--", "--", "--", "--", "--")
+				continue
+			}
+			inv := r.Inventory
+			fmt.Printf("%-20s %-19s %-26s %-5d %-9s %-5d %-5d %-5d %d\n", r.Node, formatTime(r.Updated),
+				orDash(strings.TrimSpace(inv.System.Manufacturer+" "+inv.System.Product)), len(inv.Cpus),
+				inventory.FormatBytes(inv.Memory.Total*1024*1024), len(inv.Disks), len(inv.Nics), len(inv.Gpus), len(r.Changes))
+		}
+	}
+
+	return nil
+}
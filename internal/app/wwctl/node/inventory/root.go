@@ -0,0 +1,43 @@
+package inventory
+
+import (
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "inventory [OPTIONS] [PATTERN ...]",
+		Short:                 "Show the hardware inventory of nodes",
+		Long: "This command shows the hardware inventory which wwclient reports for every\n" +
+			"node: SMBIOS system, board and BIOS data, CPUs, memory, disks, network\n" +
+			"interfaces and GPUs. When a new inventory differs from the previous one,\n" +
+			"e.g. because a DIMM or a disk disappeared, the change is recorded and\n" +
+			"shown with --changes.",
+		RunE: CobraRunE,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			nodeDB, _ := node.New()
+			nodes, _ := nodeDB.FindAllNodes()
+			var node_names []string
+			for _, node := range nodes {
+				node_names = append(node_names, node.Id.Get())
+			}
+			return node_names, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	ShowAll     bool
+	ShowChanges bool
+	SetSelect   string
+)
+
+func init() {
+	baseCmd.PersistentFlags().BoolVarP(&ShowAll, "all", "a", false, "Show the complete inventory of the nodes")
+	baseCmd.PersistentFlags().BoolVarP(&ShowChanges, "changes", "c", false, "Show the recorded hardware changes")
+	baseCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
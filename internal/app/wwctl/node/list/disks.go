@@ -0,0 +1,85 @@
+package list
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+)
+
+/*
+The type, name and configuration of the disks, raids and file systems of the
+node, sorted by their name
+*/
+func diskLines(n node.NodeInfo) (lines [][3]string) {
+	disks, raids, fileSystems := n.DisksConf()
+
+	var names []string
+	for name := range disks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		disk := disks[name]
+		var conf []string
+		if disk.Id != "" {
+			conf = append(conf, "id="+disk.Id)
+		}
+		if disk.SizeGiB != "" {
+			conf = append(conf, "size>="+disk.SizeGiB+"GiB")
+		}
+		conf = append(conf, "wipe="+disk.WipeTable)
+		lines = append(lines, [3]string{"disk", name, strings.Join(conf, " ")})
+
+		var partnames []string
+		for partname := range disk.Partitions {
+			partnames = append(partnames, partname)
+		}
+		sort.Strings(partnames)
+		for _, partname := range partnames {
+			part := disk.Partitions[partname]
+			size := "rest"
+			if part.SizeMiB != "" {
+				size = part.SizeMiB + "MiB"
+			}
+			conf := []string{"disk=" + name, "size=" + size}
+			if part.Number != "" {
+				conf = append(conf, "number="+part.Number)
+			}
+			if part.Type != "" {
+				conf = append(conf, "type="+part.Type)
+			}
+			lines = append(lines, [3]string{"part", partname, strings.Join(conf, " ")})
+		}
+	}
+
+	names = nil
+	for name := range raids {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		raid := raids[name]
+		lines = append(lines, [3]string{"raid", name, fmt.Sprintf("level=%s devices=%s", raid.Level, strings.Join(raid.Devices, ","))})
+	}
+
+	names = nil
+	for name := range fileSystems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fs := fileSystems[name]
+		conf := []string{fs.Format}
+		if fs.Path != "" {
+			conf = append(conf, fs.Path)
+		}
+		if fs.MountOptions != "" {
+			conf = append(conf, "options="+fs.MountOptions)
+		}
+		conf = append(conf, "wipe="+fs.WipeFileSystem)
+		lines = append(lines, [3]string{"fs", name, strings.Join(conf, " ")})
+	}
+	return lines
+}
@@ -0,0 +1,30 @@
+package list
+
+import (
+	"testing"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_diskLines(t *testing.T) {
+	var n node.NodeInfo
+	assert.Empty(t, diskLines(n))
+
+	disk := n.Disk("nvme")
+	disk.Id.Set("nvme-SAMSUNG*")
+	disk.SizeGiB.Set("500")
+	disk.Partition("swap").SizeMiB.Set("16384")
+	disk.Partition("scratch").Number.Set("2")
+	fs := n.FileSystem("/dev/disk/by-partlabel/scratch")
+	fs.Format.Set("xfs")
+	fs.Path.Set("/scratch")
+	fs.WipeFileSystem.Set("yes")
+
+	assert.Equal(t, [][3]string{
+		{"disk", "nvme", "id=nvme-SAMSUNG* size>=500GiB wipe=never"},
+		{"part", "scratch", "disk=nvme size=rest number=2"},
+		{"part", "swap", "disk=nvme size=16384MiB"},
+		{"fs", "/dev/disk/by-partlabel/scratch", "xfs /scratch wipe=always"},
+	}, diskLines(n))
+}
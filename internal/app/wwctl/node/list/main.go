@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 	"github.com/hpcng/warewulf/pkg/hostlist"
 	"github.com/spf13/cobra"
@@ -27,6 +28,12 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
+	nodes, err = warewulfd.SelectNodes(nodes, SetSelect)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not select nodes: %s\n", err)
+		os.Exit(1)
+	}
+
 	args = hostlist.Expand(args)
 
 	if ShowAll {
@@ -41,6 +48,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Discoverable", node.Discoverable.Source(), node.Discoverable.PrintB())
 
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Container", node.ContainerName.Source(), node.ContainerName.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Arch", node.Arch.Source(), node.Arch.Print())
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "KernelOverride", node.Kernel.Override.Source(), node.Kernel.Override.Print())
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "KernelArgs", node.Kernel.Args.Source(), node.Kernel.Args.Print())
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "SystemOverlay", node.SystemOverlay.Source(), node.SystemOverlay.Print())
@@ -49,6 +57,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Init", node.Init.Source(), node.Init.Print())
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Root", node.Root.Source(), node.Root.Print())
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "AssetKey", node.AssetKey.Source(), node.AssetKey.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "UUID", node.UUID.Source(), node.UUID.Print())
 
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "IpmiIpaddr", node.Ipmi.Ipaddr.Source(), node.Ipmi.Ipaddr.Print())
 			fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "IpmiNetmask", node.Ipmi.Netmask.Source(), node.Ipmi.Netmask.Print())
@@ -69,6 +78,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), name+":IPADDR6", netdev.Ipaddr.Source(), netdev.Ipaddr6.Print())
 				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), name+":NETMASK", netdev.Netmask.Source(), netdev.Netmask.Print())
 				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), name+":GATEWAY", netdev.Gateway.Source(), netdev.Gateway.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), name+":NETWORK", netdev.Network.Source(), netdev.Network.Print())
 				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), name+":TYPE", netdev.Type.Source(), netdev.Type.Print())
 				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), name+":ONBOOT", netdev.OnBoot.Source(), netdev.OnBoot.PrintB())
 				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), name+":PRIMARY", netdev.Primary.Source(), netdev.Primary.PrintB())
@@ -77,6 +87,29 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 				}
 			}
 
+			for name, disk := range node.Disks {
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Disk["+name+"]:ID", disk.Id.Source(), disk.Id.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Disk["+name+"]:SIZE", disk.SizeGiB.Source(), disk.SizeGiB.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Disk["+name+"]:WIPE", disk.WipeTable.Source(), disk.WipeTable.Print())
+				for partname, part := range disk.Partitions {
+					fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Part["+partname+"]:NUMBER", part.Number.Source(), part.Number.Print())
+					fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Part["+partname+"]:SIZE", part.SizeMiB.Source(), part.SizeMiB.Print())
+					fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Part["+partname+"]:TYPE", part.Type.Source(), part.Type.Print())
+				}
+			}
+
+			for name, raid := range node.Raids {
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Raid["+name+"]:LEVEL", raid.Level.Source(), raid.Level.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "Raid["+name+"]:DEVICES", raid.Devices.Source(), raid.Devices.Print())
+			}
+
+			for name, fs := range node.FileSystems {
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "FS["+name+"]:FORMAT", fs.Format.Source(), fs.Format.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "FS["+name+"]:PATH", fs.Path.Source(), fs.Path.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "FS["+name+"]:WIPE", fs.WipeFileSystem.Source(), fs.WipeFileSystem.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", node.Id.Get(), "FS["+name+"]:OPTIONS", fs.MountOptions.Source(), fs.MountOptions.Print())
+			}
+
 		}
 
 	} else if ShowNet {
@@ -102,6 +135,20 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			fmt.Printf("%-22s %-16s %-10s %-20s %-20s %-14s\n", node.Id.Get(), node.Ipmi.Ipaddr.Print(), node.Ipmi.Port.Print(), node.Ipmi.UserName.Print(), node.Ipmi.Password.Print(), node.Ipmi.Interface.Print())
 		}
 
+	} else if ShowDisks {
+		fmt.Printf("%-22s %-6s %-32s %s\n", "NODE NAME", "TYPE", "NAME", "CONFIGURATION")
+		fmt.Println(strings.Repeat("=", 100))
+
+		for _, node := range node.FilterByName(nodes, args) {
+			lines := diskLines(node)
+			if len(lines) == 0 {
+				fmt.Printf("%-22s %-6s %-32s %s\n", node.Id.Get(), "--", "--", "--")
+			}
+			for _, line := range lines {
+				fmt.Printf("%-22s %-6s %-32s %s\n", node.Id.Get(), line[0], line[1], line[2])
+			}
+		}
+
 	} else if ShowLong {
 		fmt.Printf("%-22s %-26s %-35s %s\n", "NODE NAME", "KERNEL OVERRIDE", "CONTAINER", "OVERLAYS (S/R)")
 		fmt.Println(strings.Repeat("=", 120))
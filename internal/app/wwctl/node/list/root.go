@@ -28,10 +28,12 @@ var (
 			return node_names, cobra.ShellCompDirectiveNoFileComp
 		},
 	}
-	ShowNet  bool
-	ShowIpmi bool
-	ShowAll  bool
-	ShowLong bool
+	ShowNet   bool
+	ShowIpmi  bool
+	ShowAll   bool
+	ShowLong  bool
+	ShowDisks bool
+	SetSelect string
 )
 
 func init() {
@@ -39,6 +41,8 @@ func init() {
 	baseCmd.PersistentFlags().BoolVarP(&ShowIpmi, "ipmi", "i", false, "Show node IPMI configurations")
 	baseCmd.PersistentFlags().BoolVarP(&ShowAll, "all", "a", false, "Show all node configurations")
 	baseCmd.PersistentFlags().BoolVarP(&ShowLong, "long", "l", false, "Show long or wide format")
+	baseCmd.PersistentFlags().BoolVarP(&ShowDisks, "disks", "d", false, "Show node disk and file system configurations")
+	baseCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
 }
 
 // GetRootCommand returns the root cobra.Command for the application.
@@ -0,0 +1,124 @@
+package nodereprovision
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/reprovision"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/hpcng/warewulf/pkg/hostlist"
+	"github.com/spf13/cobra"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	nodeDB, err := node.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not open node configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	nodes, err := nodeDB.FindAllNodes()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not get node list: %s\n", err)
+		os.Exit(1)
+	}
+
+	nodes, err = warewulfd.SelectNodes(nodes, SetSelect)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not select nodes: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(args) > 0 {
+		nodes = node.FilterByName(nodes, hostlist.Expand(args))
+	}
+
+	var nodeIDs []string
+	for _, n := range nodes {
+		if n.Root.Get() != "disk" {
+			if len(args) > 0 && !SetList {
+				wwlog.Printf(wwlog.WARN, "Node %s is not installed to disk, its root is %s\n", n.Id.Get(), n.Root.Get())
+			}
+			continue
+		}
+		nodeIDs = append(nodeIDs, n.Id.Get())
+	}
+
+	if SetList || len(args) == 0 && SetSelect == "" {
+		return list(nodes)
+	}
+
+	if len(nodeIDs) == 0 {
+		fmt.Printf("No nodes found\n")
+		os.Exit(1)
+	}
+
+	if SetCancel {
+		err = reprovision.Cancel(nodeIDs...)
+	} else {
+		err = reprovision.Request(time.Now().Unix(), nodeIDs...)
+	}
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not update the provisioning state: %s\n", err)
+		os.Exit(1)
+	}
+	for _, id := range nodeIDs {
+		if SetCancel {
+			fmt.Printf("Canceled reprovision of node: %s\n", id)
+		} else {
+			fmt.Printf("Node will be reprovisioned on its next boot: %s\n", id)
+		}
+	}
+	return nil
+}
+
+func formatTime(t int64) string {
+	if t == 0 {
+		return "--"
+	}
+	return time.Unix(t, 0).Format("2006-01-02 15:04:05")
+}
+
+/*
+Shows the provisioning state of the nodes with their root on disk, the
+container is outdated if it changed since the node was installed
+*/
+func list(nodes []node.NodeInfo) error {
+	states, err := reprovision.List()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read the provisioning state: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%-22s %-14s %-19s %-19s %s\n", "NODE NAME", "STATE", "REQUESTED", "PROVISIONED", "CONTAINER")
+	fmt.Println(strings.Repeat("=", 90))
+	for _, n := range nodes {
+		if n.Root.Get() != "disk" {
+			continue
+		}
+		s := states[n.Id.Get()]
+		state := "installed"
+		if s.Started != 0 {
+			state = "provisioning"
+		} else if s.Requested != 0 {
+			state = "reprovision"
+		} else if s.Provisioned == 0 {
+			state = "not installed"
+		}
+		image := "--"
+		if s.Digest != "" {
+			image = "current"
+			if s.Digest != container.ImageDigest(n.ContainerName.Get()) {
+				image = "outdated"
+			}
+		}
+		fmt.Printf("%-22s %-14s %-19s %-19s %s\n", n.Id.Get(), state, formatTime(s.Requested),
+			formatTime(s.Provisioned), image)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package nodereprovision
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "reprovision [OPTIONS] [NODE_PATTERN...]",
+		Short:                 "Install nodes with their root on disk again",
+		Long: "Nodes with the root 'disk' boot from their disk, they are only installed if it\n" +
+			"doesn't boot. This command requests that the nodes are installed again on their\n" +
+			"next boot, their disks are partitioned and formatted where configured to be\n" +
+			"wiped. The request is finished when the install reports to warewulfd. Without\n" +
+			"nodes, or with --list, the provisioning state of the nodes is shown.",
+		RunE: CobraRunE,
+		Args: cobra.MinimumNArgs(0),
+	}
+	SetCancel bool
+	SetList   bool
+	SetSelect string
+)
+
+func init() {
+	baseCmd.PersistentFlags().BoolVar(&SetCancel, "cancel", false, "Cancel the reprovision of the nodes")
+	baseCmd.PersistentFlags().BoolVarP(&SetList, "list", "l", false, "Show the provisioning state of the nodes")
+	baseCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -4,7 +4,14 @@ import (
 	"github.com/hpcng/warewulf/internal/app/wwctl/node/add"
 	"github.com/hpcng/warewulf/internal/app/wwctl/node/console"
 	"github.com/hpcng/warewulf/internal/app/wwctl/node/delete"
+	"github.com/hpcng/warewulf/internal/app/wwctl/node/discover"
+	nodeexec "github.com/hpcng/warewulf/internal/app/wwctl/node/exec"
+	"github.com/hpcng/warewulf/internal/app/wwctl/node/explain"
+	"github.com/hpcng/warewulf/internal/app/wwctl/node/export"
+	"github.com/hpcng/warewulf/internal/app/wwctl/node/imprt"
+	"github.com/hpcng/warewulf/internal/app/wwctl/node/inventory"
 	"github.com/hpcng/warewulf/internal/app/wwctl/node/list"
+	nodereprovision "github.com/hpcng/warewulf/internal/app/wwctl/node/reprovision"
 	"github.com/hpcng/warewulf/internal/app/wwctl/node/sensors"
 	"github.com/hpcng/warewulf/internal/app/wwctl/node/set"
 	nodestatus "github.com/hpcng/warewulf/internal/app/wwctl/node/status"
@@ -30,6 +37,13 @@ func init() {
 	baseCmd.AddCommand(delete.GetCommand())
 	baseCmd.AddCommand(console.GetCommand())
 	baseCmd.AddCommand(nodestatus.GetCommand())
+	baseCmd.AddCommand(imprt.GetCommand())
+	baseCmd.AddCommand(export.GetCommand())
+	baseCmd.AddCommand(explain.GetCommand())
+	baseCmd.AddCommand(inventory.GetCommand())
+	baseCmd.AddCommand(discover.GetCommand())
+	baseCmd.AddCommand(nodeexec.GetCommand())
+	baseCmd.AddCommand(nodereprovision.GetCommand())
 }
 
 // GetRootCommand returns the root cobra.Command for the application.
@@ -5,6 +5,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/hpcng/warewulf/internal/pkg/kernel"
 	"github.com/hpcng/warewulf/internal/pkg/node"
 	"github.com/hpcng/warewulf/internal/pkg/util"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
@@ -30,7 +32,13 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
-	if SetNodeAll || (len(args) == 0 && len(nodes) > 0) {
+	nodes, err = warewulfd.SelectNodes(nodes, SetSelect)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not select nodes: %s\n", err)
+		os.Exit(1)
+	}
+
+	if SetNodeAll || (len(args) == 0 && SetSelect == "" && len(nodes) > 0) {
 		fmt.Printf("\n*** WARNING: This command will modify all nodes! ***\n\n")
 	} else {
 		nodes = node.FilterByName(nodes, args)
@@ -41,6 +49,11 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
+	if SetUUID != "" && len(nodes) > 1 {
+		wwlog.Printf(wwlog.ERROR, "A uuid can only be bound to one node\n")
+		os.Exit(1)
+	}
+
 	for _, n := range nodes {
 		wwlog.Printf(wwlog.VERBOSE, "Evaluating node: %s\n", n.Id.Get())
 
@@ -54,6 +67,11 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			n.ContainerName.Set(SetContainer)
 		}
 
+		if SetArch != "" {
+			wwlog.Printf(wwlog.VERBOSE, "Node: %s, Setting architecture to: %s\n", n.Id.Get(), SetArch)
+			n.Arch.Set(util.NormalizeArch(SetArch))
+		}
+
 		if SetInit != "" {
 			wwlog.Printf(wwlog.VERBOSE, "Node: %s, Setting init command to: %s\n", n.Id.Get(), SetInit)
 			n.Init.Set(SetInit)
@@ -220,6 +238,16 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			n.NetDevs[SetNetName].Ipaddr.Set(NewIpaddr)
 		}
 
+		if SetNetwork != "" {
+			if SetNetName == "" {
+				wwlog.Printf(wwlog.ERROR, "You must include the '--netname' option\n")
+				os.Exit(1)
+			}
+
+			wwlog.Printf(wwlog.VERBOSE, "Node: %s:%s, Setting network to: %s\n", n.Id.Get(), SetNetName, SetNetwork)
+			n.NetDevs[SetNetName].Network.Set(SetNetwork)
+		}
+
 		if SetNetmask != "" {
 			if SetNetName == "" {
 				wwlog.Printf(wwlog.ERROR, "You must include the '--netname' option\n")
@@ -368,15 +396,56 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 				delete(n.NetDevs[SetNetName].Tags, key)
 			}
 		}
+		if SetArch != "" || SetContainer != "" || SetKernelOverride != "" {
+			arch := n.Arch.Get()
+			err := container.CheckArch(n.ContainerName.Get(), arch)
+			if err == nil && n.Kernel.Override.Defined() {
+				err = kernel.CheckArch(n.Kernel.Override.Get(), arch)
+			}
+			if err != nil && SetForce {
+				wwlog.Printf(wwlog.WARN, "Node: %s, %s\n", n.Id.Get(), err)
+			} else if err != nil {
+				wwlog.Printf(wwlog.ERROR, "Node: %s, %s (use --force to override)\n", n.Id.Get(), err)
+				os.Exit(1)
+			}
+		}
+
+		setDisks(&n)
+
 		err := nodeDB.NodeUpdate(n)
 		if err != nil {
 			wwlog.Printf(wwlog.ERROR, "%s\n", err)
 			os.Exit(1)
 		}
 
+		if SetUUID != "" || SetResetUUID {
+			wwlog.Printf(wwlog.VERBOSE, "Node: %s, Setting uuid to: %s\n", n.Id.Get(), SetUUID)
+			err = nodeDB.SetUUID(n.Id.Get(), SetUUID)
+			if err != nil {
+				wwlog.Printf(wwlog.ERROR, "Node: %s, %s\n", n.Id.Get(), err)
+				os.Exit(1)
+			}
+		}
+
 		count++
 	}
 
+	var nodeIDs []string
+	for _, n := range nodes {
+		nodeIDs = append(nodeIDs, n.Id.Get())
+	}
+	_, err = nodeDB.AllocateIpaddrs(nodeIDs)
+	if err != nil {
+		return errors.Wrap(err, "failed to allocate addresses")
+	}
+	problems, err := nodeDB.CheckIpaddrs()
+	if err != nil {
+		return errors.Wrap(err, "failed to check addresses")
+	}
+	if err := node.IpaddrError(problems, nodeIDs); err != nil {
+		return err
+	}
+
 	if SetYes {
 		err := nodeDB.Persist()
 		if err != nil {
@@ -34,12 +34,14 @@ var (
 	}
 	SetComment        string
 	SetContainer      string
+	SetArch           string
 	SetKernelOverride string
 	SetKernelArgs     string
 	SetNetName        string
 	SetNetDev         string
 	SetIpaddr         string
 	SetNetmask        string
+	SetNetwork        string
 	SetGateway        string
 	SetHwaddr         string
 	SetType           string
@@ -69,16 +71,40 @@ var (
 	SetDiscoverable   bool
 	SetUndiscoverable bool
 	SetRoot           string
+	SetDiskName       string
+	SetDiskId         string
+	SetDiskSize       string
+	SetDiskWipe       string
+	SetDiskDel        bool
+	SetPartName       string
+	SetPartNumber     string
+	SetPartSize       string
+	SetPartType       string
+	SetPartDel        bool
+	SetRaidName       string
+	SetRaidLevel      string
+	SetRaidDevices    []string
+	SetRaidDel        bool
+	SetFsName         string
+	SetFsFormat       string
+	SetFsPath         string
+	SetFsWipe         string
+	SetFsOptions      string
+	SetFsDel          bool
 	SetTags           []string
 	SetDelTags        []string
 	SetAssetKey       string
+	SetUUID           string
+	SetResetUUID      bool
 	SetNetTags        []string
 	SetNetDelTags     []string
+	SetSelect         string
 )
 
 func init() {
 	baseCmd.PersistentFlags().StringVar(&SetComment, "comment", "", "Set a comment for this node")
 	baseCmd.PersistentFlags().StringVarP(&SetContainer, "container", "C", "", "Set the container (VNFS) for this node")
+	baseCmd.PersistentFlags().StringVar(&SetArch, "arch", "", "Set the architecture of the node (e.g. x86_64, aarch64)")
 	if err := baseCmd.RegisterFlagCompletionFunc("container", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		list, _ := container.ListSources()
 		return list, cobra.ShellCompDirectiveNoFileComp
@@ -96,8 +122,30 @@ func init() {
 	baseCmd.PersistentFlags().StringVarP(&SetClusterName, "cluster", "c", "", "Set the node's cluster group")
 	baseCmd.PersistentFlags().StringVar(&SetIpxe, "ipxe", "", "Set the node's iPXE template name")
 	baseCmd.PersistentFlags().StringVarP(&SetInit, "init", "i", "", "Define the init process to boot the container")
-	baseCmd.PersistentFlags().StringVar(&SetRoot, "root", "", "Define the rootfs")
+	baseCmd.PersistentFlags().StringVar(&SetRoot, "root", "", "Define the rootfs (initramfs, tmpfs or disk)")
+	baseCmd.PersistentFlags().StringVar(&SetDiskName, "diskname", "", "Define the disk to configure by its path (e.g. /dev/sda) or a name if it is matched by id or size")
+	baseCmd.PersistentFlags().StringVar(&SetDiskId, "diskid", "", "Match the disk by a glob of its /dev/disk/by-id name (e.g. nvme-SAMSUNG*)")
+	baseCmd.PersistentFlags().StringVar(&SetDiskSize, "disksize", "", "Match the disk by its minimal size in GiB")
+	baseCmd.PersistentFlags().StringVar(&SetDiskWipe, "diskwipe", "", "Set the wipe policy of the partition table (never, always or mismatch)")
+	baseCmd.PersistentFlags().BoolVar(&SetDiskDel, "diskdel", false, "Delete the disk")
+	baseCmd.PersistentFlags().StringVar(&SetPartName, "partname", "", "Define the partition of the disk to configure by its label")
+	baseCmd.PersistentFlags().StringVar(&SetPartNumber, "partnumber", "", "Set the number of the partition")
+	baseCmd.PersistentFlags().StringVar(&SetPartSize, "partsize", "", "Set the size of the partition in MiB, without a size it fills the disk")
+	baseCmd.PersistentFlags().StringVar(&SetPartType, "parttype", "", "Set the GPT type code of the partition (e.g. 8300, 8200, fd00)")
+	baseCmd.PersistentFlags().BoolVar(&SetPartDel, "partdel", false, "Delete the partition")
+	baseCmd.PersistentFlags().StringVar(&SetRaidName, "raidname", "", "Define the raid to configure, it is created as /dev/md/NAME")
+	baseCmd.PersistentFlags().StringVar(&SetRaidLevel, "raidlevel", "", "Set the level of the raid (e.g. 0, 1)")
+	baseCmd.PersistentFlags().StringSliceVar(&SetRaidDevices, "raiddevices", []string{}, "Set the devices of the raid")
+	baseCmd.PersistentFlags().BoolVar(&SetRaidDel, "raiddel", false, "Delete the raid")
+	baseCmd.PersistentFlags().StringVar(&SetFsName, "fsname", "", "Define the file system to configure by its device (e.g. /dev/disk/by-partlabel/rootfs)")
+	baseCmd.PersistentFlags().StringVar(&SetFsFormat, "fsformat", "", "Set the format of the file system (e.g. ext4, xfs, swap)")
+	baseCmd.PersistentFlags().StringVar(&SetFsPath, "fspath", "", "Set the mount point of the file system")
+	baseCmd.PersistentFlags().StringVar(&SetFsWipe, "fswipe", "", "Set the wipe policy of the file system (never, always or mismatch)")
+	baseCmd.PersistentFlags().StringVar(&SetFsOptions, "fsoptions", "", "Set the mount options of the file system")
+	baseCmd.PersistentFlags().BoolVar(&SetFsDel, "fsdel", false, "Delete the file system")
 	baseCmd.PersistentFlags().StringVar(&SetAssetKey, "assetkey", "", "Set the node's Asset tag (key)")
+	baseCmd.PersistentFlags().StringVar(&SetUUID, "uuid", "", "Bind the node to the SMBIOS UUID of its hardware")
+	baseCmd.PersistentFlags().BoolVar(&SetResetUUID, "reset-uuid", false, "Remove the UUID binding, it is learned again if enabled")
 	baseCmd.PersistentFlags().StringVarP(&SetInitOverlay, "wwinit", "O", "", "Set the node's initialization overlay")
 	baseCmd.PersistentFlags().StringSliceVarP(&SetRuntimeOverlay, "runtime", "R", []string{}, "Set the node's runtime overlay")
 	if err := baseCmd.RegisterFlagCompletionFunc("runtime", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -138,6 +186,7 @@ func init() {
 	baseCmd.PersistentFlags().StringVarP(&SetNetName, "netname", "n", "default", "Define the network name to configure")
 	baseCmd.PersistentFlags().StringVarP(&SetNetDev, "netdev", "N", "", "Set the node's network device")
 	baseCmd.PersistentFlags().StringVarP(&SetIpaddr, "ipaddr", "I", "", "Set the node's network device IP address")
+	baseCmd.PersistentFlags().StringVar(&SetNetwork, "network", "", "Allocate the node's IP address from this network of warewulf.conf")
 	baseCmd.PersistentFlags().StringVarP(&SetNetmask, "netmask", "M", "", "Set the node's network device netmask")
 	baseCmd.PersistentFlags().StringVarP(&SetGateway, "gateway", "G", "", "Set the node's network device gateway")
 	baseCmd.PersistentFlags().StringVarP(&SetHwaddr, "hwaddr", "H", "", "Set the node's network device HW address")
@@ -153,6 +202,7 @@ func init() {
 	baseCmd.PersistentFlags().StringSliceVar(&SetDelTags, "tagdel", []string{}, "Delete tag")
 
 	baseCmd.PersistentFlags().BoolVarP(&SetNodeAll, "all", "a", false, "Set all nodes")
+	baseCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
 
 	baseCmd.PersistentFlags().BoolVarP(&SetYes, "yes", "y", false, "Set 'yes' to all questions asked")
 	baseCmd.PersistentFlags().BoolVarP(&SetForce, "force", "f", false, "Force configuration (even on error)")
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/hpcng/warewulf/internal/pkg/heartbeat"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 	"github.com/hpcng/warewulf/pkg/hostlist"
@@ -27,6 +28,9 @@ type NodeStatus struct {
 	Sent     string `json:"sent"`
 	Ipaddr   string `json:"ipaddr"`
 	Lastseen int64  `json:"last seen"`
+	// set by warewulfd for nodes which send heartbeats
+	State     string               `json:"state"`
+	Heartbeat *heartbeat.Heartbeat `json:"heartbeat"`
 }
 
 func CobraRunE(cmd *cobra.Command, args []string) error {
@@ -77,8 +81,8 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		fmt.Printf("%-20s %-20s %-25s %-10s\n", "NODENAME", "STAGE", "SENT", "LASTSEEN (s)")
-		fmt.Printf("%s\n", strings.Repeat("=", 80))
+		fmt.Printf("%-20s %-20s %-25s %-12s %-10s\n", "NODENAME", "STAGE", "SENT", "LASTSEEN (s)", "STATE")
+		fmt.Printf("%s\n", strings.Repeat("=", 91))
 
 		keys := make([]*NodeStatus, 0, len(nodeStatus.Nodes))
 
@@ -147,15 +151,26 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 				if SetUnknown {
 					continue
 				}
-				if rightnow-o.Lastseen >= int64(controller.Warewulf.UpdateInterval*2) {
-					color.Red("%-20s %-20s %-25s %-10d\n", o.NodeName, o.Stage, o.Sent, rightnow-o.Lastseen)
-				} else if rightnow-o.Lastseen >= int64(controller.Warewulf.UpdateInterval+5) {
-					color.Yellow("%-20s %-20s %-25s %-10d\n", o.NodeName, o.Stage, o.Sent, rightnow-o.Lastseen)
+				stale := rightnow-o.Lastseen >= int64(controller.Warewulf.UpdateInterval*2)
+				if SetUnhealthy && !stale && (o.State == "" || o.State == "UP") {
+					continue
+				}
+				line := fmt.Sprintf("%-20s %-20s %-25s %-12d %-10s", o.NodeName, o.Stage, o.Sent, rightnow-o.Lastseen, o.State)
+				if o.State == "UNHEALTHY" && o.Heartbeat != nil {
+					line += " failed: " + strings.Join(o.Heartbeat.FailedUnits, ",")
+				}
+				if stale || o.State == "DOWN" {
+					color.Red("%s\n", line)
+				} else if rightnow-o.Lastseen >= int64(controller.Warewulf.UpdateInterval+5) || o.State == "UNHEALTHY" {
+					color.Yellow("%s\n", line)
 				} else {
-					fmt.Printf("%-20s %-20s %-25s %-10d\n", o.NodeName, o.Stage, o.Sent, rightnow-o.Lastseen)
+					fmt.Printf("%s\n", line)
 				}
 			} else {
-				color.HiBlack("%-20s %-20s %-25s %-10s\n", o.NodeName, "--", "--", "--")
+				if SetUnhealthy {
+					continue
+				}
+				color.HiBlack("%-20s %-20s %-25s %-12s %-10s\n", o.NodeName, "--", "--", "--", "--")
 			}
 			if count+4 >= height && SetWatch {
 				if count+1 != len(keys) {
@@ -17,6 +17,7 @@ var (
 	SetSortLast    bool
 	SetSortReverse bool
 	SetUnknown     bool
+	SetUnhealthy   bool
 )
 
 func init() {
@@ -26,6 +27,7 @@ func init() {
 	baseCmd.PersistentFlags().BoolVarP(&SetSortLast, "last", "l", false, "Sort by the last check-in time")
 	baseCmd.PersistentFlags().BoolVarP(&SetSortReverse, "reverse", "r", false, "Reverse the sort order")
 	baseCmd.PersistentFlags().BoolVarP(&SetUnknown, "unknown", "u", false, "Only show nodes of unknown status")
+	baseCmd.PersistentFlags().BoolVarP(&SetUnhealthy, "unhealthy", "H", false, "Only show nodes which are down, unhealthy or stale")
 }
 
 // GetRootCommand returns the root cobra.Command for the application.
@@ -8,6 +8,7 @@ import (
 	"github.com/hpcng/warewulf/internal/pkg/node"
 	"github.com/hpcng/warewulf/internal/pkg/overlay"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 	"github.com/hpcng/warewulf/pkg/hostlist"
 	"github.com/spf13/cobra"
@@ -31,6 +32,12 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
+	nodes, err = warewulfd.SelectNodes(nodes, SetSelect)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not select nodes: %s\n", err)
+		os.Exit(1)
+	}
+
 	if len(args) > 0 {
 		args = hostlist.Expand(args)
 		nodes = node.FilterByName(nodes, args)
@@ -78,7 +85,7 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 
 	}
 
-	if BuildHost || (!BuildHost && !BuildNodes && len(args) == 0 && controller.Warewulf.EnableHostOverlay) {
+	if BuildHost || (!BuildHost && !BuildNodes && len(args) == 0 && SetSelect == "" && controller.Warewulf.EnableHostOverlay) {
 		err := overlay.BuildHostOverlay()
 		if err != nil {
 			wwlog.Printf(wwlog.WARN, "host overlay could not be built: %s\n", err)
@@ -95,6 +102,12 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			wwlog.Printf(wwlog.WARN, "Some overlays failed to be generated: %s\n", err)
 		}
+
+		// nodes waiting for changes of their runtime overlay get it now
+		err = warewulfd.DaemonNotifyOverlays()
+		if err != nil {
+			wwlog.Debug("Could not notify warewulfd: %s", err)
+		}
 	}
 	return nil
 }
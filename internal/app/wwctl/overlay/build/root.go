@@ -33,6 +33,7 @@ var (
 	BuildNodes  bool
 	OverlayNames []string
 	OverlayDir  string
+	SetSelect   string
 )
 
 func init() {
@@ -48,6 +49,7 @@ func init() {
 	}
 	baseCmd.PersistentFlags().StringVarP(&OverlayDir, "output", "o", "", `Do not create an overlay image, for distribution but write to
 	the given directory. An overlay must also be ge given to use this option.`)
+	baseCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
 
 }
 
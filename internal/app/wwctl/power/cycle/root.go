@@ -7,13 +7,18 @@ import (
 var (
 	powerCmd = &cobra.Command{
 		DisableFlagsInUseLine: true,
-		Use:   "cycle [OPTIONS] [PATTERN ...]",
-		Short: "Power cycle the given node(s)",
-		Long:  "This command cycles power for a set of nodes specified by PATTERN.",
-		RunE:  CobraRunE,
+		Use:                   "cycle [OPTIONS] [PATTERN ...]",
+		Short:                 "Power cycle the given node(s)",
+		Long:                  "This command cycles power for a set of nodes specified by PATTERN.",
+		RunE:                  CobraRunE,
 	}
+	SetSelect string
 )
 
+func init() {
+	powerCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
+}
+
 // GetRootCommand returns the root cobra.Command for the application.
 func GetCommand() *cobra.Command {
 	return powerCmd
@@ -7,6 +7,7 @@ import (
 	"github.com/hpcng/warewulf/internal/pkg/batch"
 	"github.com/hpcng/warewulf/internal/pkg/node"
 	"github.com/hpcng/warewulf/internal/pkg/power"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 	"github.com/hpcng/warewulf/pkg/hostlist"
 	"github.com/spf13/cobra"
@@ -27,9 +28,15 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
+	nodes, err = warewulfd.SelectNodes(nodes, SetSelect)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not select nodes: %s\n", err)
+		os.Exit(1)
+	}
+
 	if len(args) > 0 {
 		nodes = node.FilterByName(nodes, hostlist.Expand(args))
-	} else {
+	} else if SetSelect == "" {
 		//nolint:errcheck
 		cmd.Usage()
 		os.Exit(1)
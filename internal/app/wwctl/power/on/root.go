@@ -25,8 +25,13 @@ var (
 			return node_names, cobra.ShellCompDirectiveNoFileComp
 		},
 	}
+	SetSelect string
 )
 
+func init() {
+	powerCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
+}
+
 // GetRootCommand returns the root cobra.Command for the application.
 func GetCommand() *cobra.Command {
 	return powerCmd
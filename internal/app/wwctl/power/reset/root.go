@@ -8,10 +8,10 @@ import (
 var (
 	powerCmd = &cobra.Command{
 		DisableFlagsInUseLine: true,
-		Use:   "reset [OPTIONS] [PATTERN ...]",
-		Short: "Issue a reset to node(s)",
-		Long:  "This command will issue a reset to a set of nodes specified by PATTERN.",
-		RunE:  CobraRunE,
+		Use:                   "reset [OPTIONS] [PATTERN ...]",
+		Short:                 "Issue a reset to node(s)",
+		Long:                  "This command will issue a reset to a set of nodes specified by PATTERN.",
+		RunE:                  CobraRunE,
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
 				return nil, cobra.ShellCompDirectiveNoFileComp
@@ -26,8 +26,13 @@ var (
 			return node_names, cobra.ShellCompDirectiveNoFileComp
 		},
 	}
+	SetSelect string
 )
 
+func init() {
+	powerCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
+}
+
 // GetRootCommand returns the root cobra.Command for the application.
 func GetCommand() *cobra.Command {
 	return powerCmd
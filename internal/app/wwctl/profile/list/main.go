@@ -27,42 +27,45 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 	if ShowAll {
 		for _, profile := range node.FilterByName(profiles, args) {
 			fmt.Printf("################################################################################\n")
-			fmt.Printf("%-20s %-18s %s\n", "PROFILE NAME", "FIELD", "VALUE")
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "Id", profile.Id.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "Comment", profile.Comment.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "Cluster", profile.ClusterName.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", "PROFILE NAME", "FIELD", "PROFILE", "VALUE")
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Id", profile.Id.Source(), profile.Id.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Comment", profile.Comment.Source(), profile.Comment.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Cluster", profile.ClusterName.Source(), profile.ClusterName.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Profiles", "--", strings.Join(profile.Profiles, ","))
 
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "Discoverable", profile.Discoverable.PrintB())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Discoverable", profile.Discoverable.Source(), profile.Discoverable.PrintB())
 
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "Container", profile.ContainerName.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "KernelOverride", profile.Kernel.Override.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "KernelArgs", profile.Kernel.Args.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "Init", profile.Init.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "Root", profile.Root.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "AssetKey", profile.AssetKey.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Container", profile.ContainerName.Source(), profile.ContainerName.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Arch", profile.Arch.Source(), profile.Arch.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "KernelOverride", profile.Kernel.Override.Source(), profile.Kernel.Override.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "KernelArgs", profile.Kernel.Args.Source(), profile.Kernel.Args.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Init", profile.Init.Source(), profile.Init.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Root", profile.Root.Source(), profile.Root.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "AssetKey", profile.AssetKey.Source(), profile.AssetKey.Print())
 
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "SystemOverlay", profile.SystemOverlay.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "RuntimeOverlay", profile.RuntimeOverlay.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "Ipxe", profile.Ipxe.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "IpmiNetmask", profile.Ipmi.Netmask.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "IpmiPort", profile.Ipmi.Port.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "IpmiGateway", profile.Ipmi.Gateway.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "IpmiUserName", profile.Ipmi.UserName.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "IpmiInterface", profile.Ipmi.Interface.Print())
-			fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "IpmiWrite", profile.Ipmi.Write.PrintB())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "SystemOverlay", profile.SystemOverlay.Source(), profile.SystemOverlay.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "RuntimeOverlay", profile.RuntimeOverlay.Source(), profile.RuntimeOverlay.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Ipxe", profile.Ipxe.Source(), profile.Ipxe.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "IpmiNetmask", profile.Ipmi.Netmask.Source(), profile.Ipmi.Netmask.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "IpmiPort", profile.Ipmi.Port.Source(), profile.Ipmi.Port.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "IpmiGateway", profile.Ipmi.Gateway.Source(), profile.Ipmi.Gateway.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "IpmiUserName", profile.Ipmi.UserName.Source(), profile.Ipmi.UserName.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "IpmiInterface", profile.Ipmi.Interface.Source(), profile.Ipmi.Interface.Print())
+			fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "IpmiWrite", profile.Ipmi.Write.Source(), profile.Ipmi.Write.PrintB())
 
 			for keyname, key := range profile.Tags {
-				fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), "Tag["+keyname+"]", key.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), "Tag["+keyname+"]", key.Source(), key.Print())
 			}
 
 			for name, netdev := range profile.NetDevs {
-				fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), name+":IPADDR", netdev.Ipaddr.Print())
-				fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), name+":NETMASK", netdev.Netmask.Print())
-				fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), name+":GATEWAY", netdev.Gateway.Print())
-				fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), name+":HWADDR", netdev.Hwaddr.Print())
-				fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), name+":TYPE", netdev.Hwaddr.Print())
-				fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), name+":ONBOOT", netdev.OnBoot.PrintB())
-				fmt.Printf("%-20s %-18s %s\n", profile.Id.Get(), name+":PRIMARY", netdev.Primary.PrintB())
+				fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), name+":IPADDR", netdev.Ipaddr.Source(), netdev.Ipaddr.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), name+":NETMASK", netdev.Netmask.Source(), netdev.Netmask.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), name+":GATEWAY", netdev.Gateway.Source(), netdev.Gateway.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), name+":NETWORK", netdev.Network.Source(), netdev.Network.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), name+":HWADDR", netdev.Hwaddr.Source(), netdev.Hwaddr.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), name+":TYPE", netdev.Hwaddr.Source(), netdev.Hwaddr.Print())
+				fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), name+":ONBOOT", netdev.OnBoot.Source(), netdev.OnBoot.PrintB())
+				fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), name+":PRIMARY", netdev.Primary.Source(), netdev.Primary.PrintB())
 				for keyname, key := range netdev.Tags {
 					fmt.Printf("%-20s %-18s %-12s %s\n", profile.Id.Get(), name+":TAG["+keyname+"]", key.Source(), key.Print())
 				}
@@ -0,0 +1,94 @@
+package set
+
+import (
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+/*
+Applies the options of the disks, partitions, raids and file systems
+*/
+func setDisks(n *node.NodeInfo) {
+	if SetPartName != "" && SetDiskName == "" {
+		wwlog.Printf(wwlog.ERROR, "You must include the '--diskname' option\n")
+		os.Exit(1)
+	}
+
+	if SetDiskName != "" {
+		if SetDiskDel && SetPartName == "" {
+			if _, ok := n.Disks[SetDiskName]; !ok {
+				wwlog.Printf(wwlog.ERROR, "Disk doesn't exist: %s\n", SetDiskName)
+				os.Exit(1)
+			}
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, Deleting disk: %s\n", n.Id.Get(), SetDiskName)
+			delete(n.Disks, SetDiskName)
+			return
+		}
+		disk := n.Disk(SetDiskName)
+		if SetDiskId != "" {
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s:%s, Setting id to: %s\n", n.Id.Get(), SetDiskName, SetDiskId)
+			disk.Id.Set(SetDiskId)
+		}
+		if SetDiskSize != "" {
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s:%s, Setting size to: %s\n", n.Id.Get(), SetDiskName, SetDiskSize)
+			disk.SizeGiB.Set(SetDiskSize)
+		}
+		if SetDiskWipe != "" {
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s:%s, Setting wipe table to: %s\n", n.Id.Get(), SetDiskName, SetDiskWipe)
+			disk.WipeTable.Set(SetDiskWipe)
+		}
+
+		if SetPartName != "" {
+			if SetPartDel {
+				if _, ok := disk.Partitions[SetPartName]; !ok {
+					wwlog.Printf(wwlog.ERROR, "Partition doesn't exist: %s\n", SetPartName)
+					os.Exit(1)
+				}
+				wwlog.Printf(wwlog.VERBOSE, "Profile: %s:%s, Deleting partition: %s\n", n.Id.Get(), SetDiskName, SetPartName)
+				delete(disk.Partitions, SetPartName)
+			} else {
+				part := disk.Partition(SetPartName)
+				wwlog.Printf(wwlog.VERBOSE, "Profile: %s:%s, Setting partition %s\n", n.Id.Get(), SetDiskName, SetPartName)
+				part.Number.Set(SetPartNumber)
+				part.SizeMiB.Set(SetPartSize)
+				part.Type.Set(SetPartType)
+			}
+		}
+	}
+
+	if SetRaidName != "" {
+		if SetRaidDel {
+			if _, ok := n.Raids[SetRaidName]; !ok {
+				wwlog.Printf(wwlog.ERROR, "Raid doesn't exist: %s\n", SetRaidName)
+				os.Exit(1)
+			}
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, Deleting raid: %s\n", n.Id.Get(), SetRaidName)
+			delete(n.Raids, SetRaidName)
+		} else {
+			raid := n.Raid(SetRaidName)
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, Setting raid %s\n", n.Id.Get(), SetRaidName)
+			raid.Level.Set(SetRaidLevel)
+			raid.Devices.SetSlice(SetRaidDevices)
+		}
+	}
+
+	if SetFsName != "" {
+		if SetFsDel {
+			if _, ok := n.FileSystems[SetFsName]; !ok {
+				wwlog.Printf(wwlog.ERROR, "File system doesn't exist: %s\n", SetFsName)
+				os.Exit(1)
+			}
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, Deleting file system: %s\n", n.Id.Get(), SetFsName)
+			delete(n.FileSystems, SetFsName)
+		} else {
+			fs := n.FileSystem(SetFsName)
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, Setting file system %s\n", n.Id.Get(), SetFsName)
+			fs.Format.Set(SetFsFormat)
+			fs.Path.Set(SetFsPath)
+			fs.WipeFileSystem.Set(SetFsWipe)
+			fs.MountOptions.Set(SetFsOptions)
+		}
+	}
+}
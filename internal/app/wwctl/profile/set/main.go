@@ -5,7 +5,10 @@ import (
 	"os"
 	"strings"
 
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/hpcng/warewulf/internal/pkg/kernel"
 	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/util"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfd"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 	"github.com/manifoldco/promptui"
@@ -28,6 +31,11 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
+	profileNames := make(map[string]bool)
+	for _, p := range profiles {
+		profileNames[p.Id.Get()] = true
+	}
+
 	if SetAll {
 		fmt.Printf("\n*** WARNING: This command will modify all profiles! ***\n\n")
 	} else if len(args) > 0 {
@@ -55,6 +63,37 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			p.ClusterName.Set(SetClusterName)
 		}
 
+		if SetProfile != "" {
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, Setting included profiles to: %s\n", p.Id.Get(), SetProfile)
+			if SetProfile == "UNSET" {
+				p.Profiles = nil
+			} else {
+				p.Profiles = strings.Split(SetProfile, ",")
+			}
+		}
+
+		for _, name := range SetAddProfile {
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, including profile '%s'\n", p.Id.Get(), name)
+			p.Profiles = util.SliceAddUniqueElement(p.Profiles, name)
+		}
+
+		for _, name := range SetDelProfile {
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, removing included profile '%s'\n", p.Id.Get(), name)
+			p.Profiles = util.SliceRemoveElement(p.Profiles, name)
+		}
+
+		for _, name := range p.Profiles {
+			if !profileNames[name] {
+				wwlog.Printf(wwlog.ERROR, "Profile does not exist: %s\n", name)
+				os.Exit(1)
+			}
+		}
+
+		if SetArch != "" {
+			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, Setting architecture to: %s\n", p.Id.Get(), SetArch)
+			p.Arch.Set(util.NormalizeArch(SetArch))
+		}
+
 		if SetContainer != "" {
 			wwlog.Printf(wwlog.VERBOSE, "Profile: %s, Setting Container name to: %s\n", p.Id.Get(), SetContainer)
 			p.ContainerName.Set(SetContainer)
@@ -166,6 +205,16 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			p.NetDevs[SetNetName].Device.Set(SetNetDev)
 		}
 
+		if SetNetwork != "" {
+			if SetNetName == "" {
+				wwlog.Printf(wwlog.ERROR, "You must include the '--netname' option\n")
+				os.Exit(1)
+			}
+
+			wwlog.Printf(wwlog.VERBOSE, "Profile '%s': Setting network to: %s\n", p.Id.Get(), SetNetwork)
+			p.NetDevs[SetNetName].Network.Set(SetNetwork)
+		}
+
 		if SetNetmask != "" {
 			if SetNetName == "" {
 				wwlog.Printf(wwlog.ERROR, "You must include the '--netname' option\n")
@@ -305,6 +354,22 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 			}
 		}
 
+		if SetArch != "" || SetContainer != "" || SetKernelOverride != "" {
+			arch := p.Arch.Get()
+			err := container.CheckArch(p.ContainerName.Get(), arch)
+			if err == nil && p.Kernel.Override.Defined() {
+				err = kernel.CheckArch(p.Kernel.Override.Get(), arch)
+			}
+			if err != nil && SetForce {
+				wwlog.Printf(wwlog.WARN, "Profile: %s, %s\n", p.Id.Get(), err)
+			} else if err != nil {
+				wwlog.Printf(wwlog.ERROR, "Profile: %s, %s (use --force to override)\n", p.Id.Get(), err)
+				os.Exit(1)
+			}
+		}
+
+		setDisks(&p)
+
 		err := nodeDB.ProfileUpdate(p)
 		if err != nil {
 			wwlog.Printf(wwlog.ERROR, "%s\n", err)
@@ -312,6 +377,13 @@ func CobraRunE(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	for _, p := range profiles {
+		if _, err := nodeDB.ResolveProfiles([]string{p.Id.Get()}); err != nil {
+			wwlog.Printf(wwlog.ERROR, "Profile %s: %s\n", p.Id.Get(), err)
+			os.Exit(1)
+		}
+	}
+
 	if len(profiles) > 0 {
 		if SetYes {
 			err := nodeDB.Persist()
@@ -1,6 +1,7 @@
 package wwctl
 
 import (
+	"github.com/hpcng/warewulf/internal/app/wwctl/config"
 	"github.com/hpcng/warewulf/internal/app/wwctl/configure"
 	"github.com/hpcng/warewulf/internal/app/wwctl/container"
 	"github.com/hpcng/warewulf/internal/app/wwctl/kernel"
@@ -10,6 +11,7 @@ import (
 	"github.com/hpcng/warewulf/internal/app/wwctl/profile"
 	"github.com/hpcng/warewulf/internal/app/wwctl/server"
 	"github.com/hpcng/warewulf/internal/app/wwctl/ssh"
+	"github.com/hpcng/warewulf/internal/app/wwctl/upgrade"
 	"github.com/hpcng/warewulf/internal/app/wwctl/version"
 	"github.com/hpcng/warewulf/internal/pkg/help"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
@@ -47,9 +49,11 @@ func init() {
 	rootCmd.AddCommand(power.GetCommand())
 	rootCmd.AddCommand(profile.GetCommand())
 	rootCmd.AddCommand(configure.GetCommand())
+	rootCmd.AddCommand(config.GetCommand())
 	rootCmd.AddCommand(server.GetCommand())
 	rootCmd.AddCommand(version.GetCommand())
 	rootCmd.AddCommand(ssh.GetCommand())
+	rootCmd.AddCommand(upgrade.GetCommand())
 }
 
 // GetRootCommand returns the root cobra.Command for the application.
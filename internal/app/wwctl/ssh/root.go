@@ -13,10 +13,11 @@ var (
 		RunE:                  CobraRunE,
 		Args:                  cobra.MinimumNArgs(2),
 	}
-	DryRun  bool
-	FanOut  int
-	Sleep   int
-	SshPath string
+	DryRun    bool
+	FanOut    int
+	Sleep     int
+	SshPath   string
+	SetSelect string
 )
 
 func init() {
@@ -24,6 +25,7 @@ func init() {
 	baseCmd.PersistentFlags().IntVarP(&FanOut, "fanout", "f", 32, "How many connections to run in parallel")
 	baseCmd.PersistentFlags().IntVarP(&Sleep, "sleep", "s", 0, "Seconds to sleep inbetween processes")
 	baseCmd.PersistentFlags().StringVar(&SshPath, "rsh", "/usr/bin/ssh", "Path to use for RSH/SSH command")
+	baseCmd.PersistentFlags().StringVar(&SetSelect, "select", "", "Select nodes by their configuration or stage, e.g. profile=gpu,tag:rack=12")
 }
 
 // GetRootCommand returns the root cobra.Command for the application.
@@ -0,0 +1,102 @@
+package upgrade
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/hpcng/warewulf/internal/pkg/migrate"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+func CobraRunE(cmd *cobra.Command, args []string) error {
+	if SetDryRun {
+		// warewulf.conf is read without warewulfconf.New(), which would
+		// migrate it
+		data := dryRun(migrate.Warewulf, warewulfconf.ConfigFile)
+		var conf warewulfconf.ControllerConf
+		err := yaml.Unmarshal(data, &conf)
+		if err != nil {
+			wwlog.Printf(wwlog.ERROR, "Could not read %s: %s\n", warewulfconf.ConfigFile, err)
+			os.Exit(1)
+		}
+		if nodesFile, ok := nodesFile(conf); ok {
+			dryRun(migrate.Nodes, nodesFile)
+		}
+		return nil
+	}
+
+	if os.Geteuid() != 0 {
+		wwlog.Printf(wwlog.ERROR, "Only root can upgrade the configuration\n")
+		os.Exit(1)
+	}
+
+	upgrade(migrate.Warewulf, warewulfconf.ConfigFile)
+
+	conf, err := warewulfconf.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read the Warewulf configuration: %s\n", err)
+		os.Exit(1)
+	}
+	if nodesFile, ok := nodesFile(conf); ok {
+		upgrade(migrate.Nodes, nodesFile)
+	}
+	return nil
+}
+
+/*
+nodes.conf of the yaml backend, the sqlite backend has no versions
+*/
+func nodesFile(conf warewulfconf.ControllerConf) (string, bool) {
+	if conf.NodeDB == nil || conf.NodeDB.Backend == "" {
+		return node.ConfigFile, true
+	}
+	if conf.NodeDB.Backend != node.BackendYaml {
+		return "", false
+	}
+	if conf.NodeDB.Path != "" {
+		return conf.NodeDB.Path, true
+	}
+	return node.ConfigFile, true
+}
+
+func upgrade(schema *migrate.Schema, fileName string) {
+	from, err := schema.Upgrade(fileName)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not upgrade %s: %s\n", fileName, err)
+		os.Exit(1)
+	}
+	if from == schema.Current {
+		fmt.Printf("%s has the current version %d\n", fileName, from)
+	}
+}
+
+/*
+Prints the migrated document if the file has an older version and returns
+the migrated document
+*/
+func dryRun(schema *migrate.Schema, fileName string) []byte {
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	} else if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read %s: %s\n", fileName, err)
+		os.Exit(1)
+	}
+	out, from, err := schema.Migrate(data, schema.Current)
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not migrate %s: %s\n", fileName, err)
+		os.Exit(1)
+	}
+	if from == schema.Current {
+		fmt.Printf("%s has the current version %d\n", fileName, from)
+		return out
+	}
+	fmt.Printf("%s would be migrated from version %d to %d:\n%s", fileName, from, schema.Current, out)
+	return out
+}
@@ -0,0 +1,27 @@
+package upgrade
+
+import "github.com/spf13/cobra"
+
+var (
+	baseCmd = &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use:                   "upgrade [OPTIONS]",
+		Short:                 "Upgrade the configuration files",
+		Long: "Migrate warewulf.conf and nodes.conf of an older version to the current\n" +
+			"version. The original files are kept as <file>.ww<version>. The files are\n" +
+			"also migrated when they are read by wwctl or warewulfd, with --dry-run the\n" +
+			"migrated files are only shown.",
+		RunE: CobraRunE,
+		Args: cobra.ExactArgs(0),
+	}
+	SetDryRun bool
+)
+
+func init() {
+	baseCmd.PersistentFlags().BoolVarP(&SetDryRun, "dry-run", "n", false, "Only show the migrated files, do not write them")
+}
+
+// GetRootCommand returns the root cobra.Command for the application.
+func GetCommand() *cobra.Command {
+	return baseCmd
+}
@@ -0,0 +1,406 @@
+package configcheck
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/container"
+	"github.com/hpcng/warewulf/internal/pkg/ippool"
+	"github.com/hpcng/warewulf/internal/pkg/kernel"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/overlay"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	LevelError   = "ERROR"
+	LevelWarning = "WARNING"
+)
+
+/*
+A problem found in warewulf.conf or in the node configuration. Object is
+e.g. "node n1", Field the yaml name of the value in dotted notation.
+*/
+type Problem struct {
+	Level   string
+	Object  string
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Field == "" {
+		return fmt.Sprintf("%-8s %s: %s", p.Level, p.Object, p.Message)
+	}
+	return fmt.Sprintf("%-8s %s: %s: %s", p.Level, p.Object, p.Field, p.Message)
+}
+
+/*
+The containers, kernels, overlays, iPXE templates and profiles the nodes may
+reference
+*/
+type Inventory struct {
+	Containers    map[string]bool
+	Images        map[string]bool
+	Kernels       map[string]bool
+	Overlays      map[string]bool
+	IpxeTemplates map[string]bool
+	Profiles      map[string]bool
+}
+
+func toSet(list []string) map[string]bool {
+	ret := make(map[string]bool)
+	for _, item := range list {
+		ret[item] = true
+	}
+	return ret
+}
+
+/*
+Directory of the iPXE templates
+*/
+func IpxeTemplateDir() string {
+	return path.Join(buildconfig.SYSCONFDIR(), "warewulf/ipxe")
+}
+
+/*
+Collects the containers, kernels, overlays and iPXE templates which exist on
+this host
+*/
+func LoadInventory(profiles []string) (Inventory, error) {
+	inv := Inventory{Profiles: toSet(profiles), Images: make(map[string]bool)}
+	containers, err := container.ListSources()
+	if err != nil {
+		return inv, fmt.Errorf("could not list containers: %s", err)
+	}
+	inv.Containers = toSet(containers)
+	for _, name := range containers {
+		if util.IsFile(container.ImageFile(name)) {
+			inv.Images[name] = true
+		}
+	}
+	kernels, err := kernel.ListKernels()
+	if err != nil {
+		return inv, fmt.Errorf("could not list kernels: %s", err)
+	}
+	inv.Kernels = toSet(kernels)
+	overlays, err := overlay.FindOverlays()
+	if err != nil {
+		return inv, err
+	}
+	inv.Overlays = toSet(overlays)
+	inv.IpxeTemplates = make(map[string]bool)
+	files, err := ioutil.ReadDir(IpxeTemplateDir())
+	if err != nil {
+		return inv, fmt.Errorf("could not list iPXE templates: %s", err)
+	}
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".ipxe") {
+			inv.IpxeTemplates[strings.TrimSuffix(file.Name(), ".ipxe")] = true
+		}
+	}
+	return inv, nil
+}
+
+type checker struct {
+	object   string
+	problems []Problem
+}
+
+func (c *checker) errorf(field string, format string, a ...interface{}) {
+	c.problems = append(c.problems, Problem{Level: LevelError, Object: c.object, Field: field, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *checker) warnf(field string, format string, a ...interface{}) {
+	c.problems = append(c.problems, Problem{Level: LevelWarning, Object: c.object, Field: field, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *checker) ipv4(field string, value string) {
+	if value != "" && net.ParseIP(value).To4() == nil {
+		c.errorf(field, "invalid IPv4 address: %s", value)
+	}
+}
+
+// names of disks, partitions, raids and file systems are used in the shell
+// scripts and the fstab of the node
+var diskNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+func (c *checker) diskName(field string, name string) {
+	if !diskNameRegexp.MatchString(name) {
+		c.errorf(field, "invalid characters in %q, allowed are letters, digits and _@%%+=:,./-", name)
+	}
+}
+
+func (c *checker) netmask(field string, value string) {
+	if value == "" {
+		return
+	}
+	ip := net.ParseIP(value).To4()
+	if ip == nil {
+		c.errorf(field, "invalid netmask: %s", value)
+		return
+	}
+	if ones, bits := net.IPMask(ip).Size(); ones == 0 && bits == 0 {
+		c.errorf(field, "netmask is not contiguous: %s", value)
+	}
+}
+
+/*
+Checks the values of warewulf.conf. The file is read again strictly so that
+misspelled keys, which are ignored otherwise, are reported.
+*/
+func CheckController(conf warewulfconf.ControllerConf, fileName string) []Problem {
+	c := checker{object: "warewulf.conf"}
+	if data, err := ioutil.ReadFile(fileName); err != nil {
+		c.errorf("", "%s", err)
+	} else {
+		var strict warewulfconf.ControllerConf
+		if err := yaml.UnmarshalStrict(data, &strict); err != nil {
+			c.warnf("", "%s", err)
+		}
+	}
+
+	if conf.Ipaddr == "" {
+		c.errorf("ipaddr", "is not set")
+	}
+	c.ipv4("ipaddr", conf.Ipaddr)
+	c.netmask("netmask", conf.Netmask)
+	c.ipv4("network", conf.Network)
+	if conf.Ipaddr6 != "" {
+		if _, _, err := net.ParseCIDR(conf.Ipaddr6); err != nil {
+			c.errorf("ipaddr6", "invalid IPv6 address in CIDR notation: %s", conf.Ipaddr6)
+		}
+	}
+	if conf.Warewulf != nil && (conf.Warewulf.Port <= 0 || conf.Warewulf.Port > 65535) {
+		c.errorf("warewulf.port", "invalid port: %d", conf.Warewulf.Port)
+	}
+	if conf.Dhcp != nil && conf.Dhcp.Enabled {
+		c.ipv4("dhcp.range start", conf.Dhcp.RangeStart)
+		c.ipv4("dhcp.range end", conf.Dhcp.RangeEnd)
+		start := net.ParseIP(conf.Dhcp.RangeStart)
+		end := net.ParseIP(conf.Dhcp.RangeEnd)
+		mask := net.ParseIP(conf.Netmask).To4()
+		network := net.ParseIP(conf.Network)
+		if start != nil && end != nil && mask != nil && network != nil {
+			subnet := net.IPNet{IP: network.Mask(net.IPMask(mask)), Mask: net.IPMask(mask)}
+			if !subnet.Contains(start) || !subnet.Contains(end) {
+				c.errorf("dhcp", "range %s-%s is not in network %s", conf.Dhcp.RangeStart, conf.Dhcp.RangeEnd, subnet.String())
+			}
+		}
+	}
+	if conf.NodeDB != nil && conf.NodeDB.Backend != node.BackendYaml && conf.NodeDB.Backend != node.BackendSqlite {
+		c.errorf("nodedb.backend", "unknown backend: %s", conf.NodeDB.Backend)
+	}
+	if _, err := ippool.FromConf(conf); err != nil {
+		c.errorf("networks", "%s", err)
+	}
+	if _, err := node.CompileDiscoveryRules(conf.Discovery); err != nil {
+		c.errorf("discovery", "%s", err)
+	}
+	return c.problems
+}
+
+/*
+Checks the resolved values of nodes or profiles (kind is "node" or
+"profile") and their references to containers, kernels, overlays, iPXE
+templates and profiles
+*/
+func CheckNodes(kind string, nodes []node.NodeInfo, inv Inventory) []Problem {
+	var ret []Problem
+	hwaddrs := make(map[string]string)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Id.Get() < nodes[j].Id.Get() })
+	for _, n := range nodes {
+		c := checker{object: kind + " " + n.Id.Get()}
+		for _, p := range n.Profiles {
+			if !inv.Profiles[p] {
+				c.errorf("profiles", "profile does not exist: %s", p)
+			}
+		}
+
+		if name := n.ContainerName.Get(); name == "" {
+			if kind == "node" {
+				c.warnf("container name", "is not set, the node can't be provisioned")
+			}
+		} else if !inv.Containers[name] {
+			c.errorf("container name", "container does not exist: %s", name)
+		} else if !inv.Images[name] {
+			c.warnf("container name", "image of container %s is not built", name)
+		}
+
+		if n.Kernel != nil {
+			if name := n.Kernel.Override.Get(); name != "" && !inv.Kernels[name] {
+				c.errorf("kernel.override", "kernel does not exist: %s", name)
+			}
+		}
+		for _, name := range n.SystemOverlay.GetSlice() {
+			if !inv.Overlays[name] {
+				c.errorf("system overlay", "overlay does not exist: %s", name)
+			}
+		}
+		for _, name := range n.RuntimeOverlay.GetSlice() {
+			if !inv.Overlays[name] {
+				c.errorf("runtime overlay", "overlay does not exist: %s", name)
+			}
+		}
+		if name := n.Ipxe.Get(); name != "" && !inv.IpxeTemplates[name] {
+			c.errorf("ipxe template", "iPXE template does not exist: %s", name)
+		}
+
+		if n.Ipmi != nil {
+			c.ipv4("ipmi.ipaddr", n.Ipmi.Ipaddr.Get())
+			c.netmask("ipmi.netmask", n.Ipmi.Netmask.Get())
+			c.ipv4("ipmi.gateway", n.Ipmi.Gateway.Get())
+			if port := n.Ipmi.Port.Get(); port != "" {
+				if i, err := strconv.Atoi(port); err != nil || i <= 0 || i > 65535 {
+					c.errorf("ipmi.port", "invalid port: %s", port)
+				}
+			}
+		}
+
+		var devNames []string
+		for name := range n.NetDevs {
+			devNames = append(devNames, name)
+		}
+		sort.Strings(devNames)
+		for _, name := range devNames {
+			netdev := n.NetDevs[name]
+			prefix := "network devices." + name + "."
+			if hwaddr := netdev.Hwaddr.Get(); hwaddr != "" {
+				if _, err := net.ParseMAC(hwaddr); err != nil {
+					c.errorf(prefix+"hwaddr", "invalid MAC address: %s", hwaddr)
+				} else if owner, ok := hwaddrs[strings.ToLower(hwaddr)]; ok && kind == "node" {
+					c.errorf(prefix+"hwaddr", "%s is also used by %s", hwaddr, owner)
+				} else if kind == "node" {
+					hwaddrs[strings.ToLower(hwaddr)] = n.Id.Get() + ":" + name
+				}
+			}
+			c.ipv4(prefix+"ipaddr", netdev.Ipaddr.Get())
+			c.netmask(prefix+"netmask", netdev.Netmask.Get())
+			c.ipv4(prefix+"gateway", netdev.Gateway.Get())
+			if ipaddr6 := netdev.Ipaddr6.Get(); ipaddr6 != "" {
+				if _, _, err := net.ParseCIDR(ipaddr6); err != nil {
+					c.errorf(prefix+"ipaddr6", "invalid IPv6 address in CIDR notation: %s", ipaddr6)
+				}
+			}
+		}
+		c.disks(kind, &n)
+		ret = append(ret, c.problems...)
+	}
+	return ret
+}
+
+/*
+Checks the disks, raids and file systems of a node and that a node with its
+root on disk has a root file system
+*/
+func (c *checker) disks(kind string, n *node.NodeInfo) {
+	switch root := n.Root.Get(); root {
+	case "", "initramfs", "tmpfs":
+	case "disk":
+		if kind == "node" && n.RootDevice() == "" {
+			c.errorf("root", "is disk, but no file system has the path /")
+		}
+	default:
+		c.warnf("root", "unknown root: %s", root)
+	}
+
+	var names []string
+	for diskname := range n.Disks {
+		names = append(names, diskname)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		disk := n.Disks[name]
+		prefix := "disks." + name + "."
+		c.diskName("disks."+name, name)
+		if size := disk.SizeGiB.Get(); size != "" {
+			if i, err := strconv.Atoi(size); err != nil || i <= 0 {
+				c.errorf(prefix+"size", "invalid size in GiB: %s", size)
+			}
+		}
+		if _, err := node.WipePolicy(disk.WipeTable.Get()); err != nil {
+			c.errorf(prefix+"wipe table", "%s", err)
+		}
+	}
+
+	disks, raids, fileSystems := n.DisksConf()
+	names = nil
+	for diskname, disk := range disks {
+		for partname := range disk.Partitions {
+			names = append(names, diskname+".partitions."+partname)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		diskpart := strings.SplitN(name, ".partitions.", 2)
+		part := disks[diskpart[0]].Partitions[diskpart[1]]
+		prefix := "disks." + name + "."
+		c.diskName("disks."+name, diskpart[1])
+		if part.Number != "" {
+			if i, err := strconv.Atoi(part.Number); err != nil || i <= 0 {
+				c.errorf(prefix+"number", "invalid partition number: %s", part.Number)
+			}
+		}
+		if part.SizeMiB != "" {
+			if i, err := strconv.Atoi(part.SizeMiB); err != nil || i <= 0 {
+				c.errorf(prefix+"size", "invalid size in MiB: %s", part.SizeMiB)
+			}
+		}
+	}
+	names = nil
+	for raidname := range raids {
+		names = append(names, raidname)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.diskName("raids."+name, name)
+		if len(raids[name].Devices) == 0 {
+			c.errorf("raids."+name+".devices", "raid has no devices")
+		}
+		for _, dev := range raids[name].Devices {
+			c.diskName("raids."+name+".devices", dev)
+		}
+	}
+	names = nil
+	for fsname := range fileSystems {
+		names = append(names, fsname)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.diskName("filesystems."+name, name)
+		if fsPath := fileSystems[name].Path; fsPath != "" {
+			c.diskName("filesystems."+name+".path", fsPath)
+		}
+		if fileSystems[name].Format == "" {
+			c.errorf("filesystems."+name+".format", "is not set")
+		}
+		if _, err := node.WipePolicy(n.FileSystems[name].WipeFileSystem.Get()); err != nil {
+			c.errorf("filesystems."+name+".wipe filesystem", "%s", err)
+		}
+	}
+}
+
+/*
+Converts the problems of node.CheckIpaddrs
+*/
+func FromIpaddrProblems(problems []node.IpaddrProblem) []Problem {
+	var ret []Problem
+	for _, p := range problems {
+		ret = append(ret, Problem{
+			Level:   LevelError,
+			Object:  "node " + p.Node,
+			Field:   "network devices." + p.NetDev + ".ipaddr",
+			Message: p.Ipaddr + ": " + p.Problem,
+		})
+	}
+	return ret
+}
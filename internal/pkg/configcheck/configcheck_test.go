@@ -0,0 +1,118 @@
+package configcheck
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+)
+
+func newNode(name string) node.NodeInfo {
+	var n node.NodeInfo
+	n.Id.Set(name)
+	n.Kernel = new(node.KernelEntry)
+	n.Ipmi = new(node.IpmiEntry)
+	n.NetDevs = make(map[string]*node.NetDevEntry)
+	n.Profiles = []string{"default"}
+	n.ContainerName.Set("rocky")
+	n.Ipxe.Set("default")
+	n.SystemOverlay.SetSlice([]string{"wwinit"})
+	n.RuntimeOverlay.SetSlice([]string{"generic"})
+	return n
+}
+
+func testInventory() Inventory {
+	return Inventory{
+		Containers:    map[string]bool{"rocky": true, "alma": true},
+		Images:        map[string]bool{"rocky": true},
+		Kernels:       map[string]bool{"5.14": true},
+		Overlays:      map[string]bool{"wwinit": true, "generic": true},
+		IpxeTemplates: map[string]bool{"default": true},
+		Profiles:      map[string]bool{"default": true},
+	}
+}
+
+func Test_CheckNodes(t *testing.T) {
+	good := newNode("n1")
+	good.NetDevs["default"] = &node.NetDevEntry{}
+	good.NetDevs["default"].Hwaddr.Set("08:00:27:39:46:70")
+	good.NetDevs["default"].Ipaddr.Set("10.0.0.1")
+	good.NetDevs["default"].Netmask.Set("255.255.255.0")
+
+	bad := newNode("n2")
+	bad.Profiles = []string{"default", "missing"}
+	bad.ContainerName.Set("alma")
+	bad.Kernel.Override.Set("6.0")
+	bad.SystemOverlay.SetSlice([]string{"wwinit", "nope"})
+	bad.Ipxe.Set("custom")
+	bad.Ipmi.Port.Set("http")
+	bad.NetDevs["default"] = &node.NetDevEntry{}
+	bad.NetDevs["default"].Hwaddr.Set("08:00:27:39:46:70")
+	bad.NetDevs["default"].Ipaddr.Set("10.0.0.300")
+	bad.NetDevs["default"].Netmask.Set("255.0.255.0")
+	bad.NetDevs["ib"] = &node.NetDevEntry{}
+	bad.NetDevs["ib"].Hwaddr.Set("not-a-mac")
+	bad.NetDevs["ib"].Ipaddr6.Set("fd00::1")
+	bad.Root.Set("disk")
+	bad.Disk("/dev/sda").Partition("rootfs").SizeMiB.Set("20G")
+	bad.Disk("nvme").SizeGiB.Set("1T")
+	bad.Disk("nvme").WipeTable.Set("sometimes")
+	bad.FileSystem("/dev/disk/by-partlabel/rootfs").Path.Set("/var")
+	bad.Disk("/dev/sda").Partition("root fs").SizeMiB.Set("100")
+	bad.FileSystem("/dev/disk/by-partlabel/data").Path.Set("/data;reboot")
+	bad.FileSystem("/dev/disk/by-partlabel/data").Format.Set("xfs")
+
+	problems := CheckNodes("node", []node.NodeInfo{bad, good}, testInventory())
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	assert.Equal(t, []string{
+		"ERROR    node n2: profiles: profile does not exist: missing",
+		"WARNING  node n2: container name: image of container alma is not built",
+		"ERROR    node n2: kernel.override: kernel does not exist: 6.0",
+		"ERROR    node n2: system overlay: overlay does not exist: nope",
+		"ERROR    node n2: ipxe template: iPXE template does not exist: custom",
+		"ERROR    node n2: ipmi.port: invalid port: http",
+		"ERROR    node n2: network devices.default.hwaddr: 08:00:27:39:46:70 is also used by n1:default",
+		"ERROR    node n2: network devices.default.ipaddr: invalid IPv4 address: 10.0.0.300",
+		"ERROR    node n2: network devices.default.netmask: netmask is not contiguous: 255.0.255.0",
+		"ERROR    node n2: network devices.ib.hwaddr: invalid MAC address: not-a-mac",
+		"ERROR    node n2: network devices.ib.ipaddr6: invalid IPv6 address in CIDR notation: fd00::1",
+		"ERROR    node n2: root: is disk, but no file system has the path /",
+		"ERROR    node n2: disks.nvme.size: invalid size in GiB: 1T",
+		"ERROR    node n2: disks.nvme.wipe table: unknown wipe policy: sometimes",
+		"ERROR    node n2: disks./dev/sda.partitions.root fs: invalid characters in \"root fs\", allowed are letters, digits and _@%+=:,./-",
+		"ERROR    node n2: disks./dev/sda.partitions.rootfs.size: invalid size in MiB: 20G",
+		"ERROR    node n2: filesystems./dev/disk/by-partlabel/data.path: invalid characters in \"/data;reboot\", allowed are letters, digits and _@%+=:,./-",
+		"ERROR    node n2: filesystems./dev/disk/by-partlabel/rootfs.format: is not set",
+	}, got)
+}
+
+func Test_CheckController(t *testing.T) {
+	conf := warewulfconf.ControllerConf{
+		Ipaddr:   "192.168.200.1",
+		Netmask:  "255.255.255.0",
+		Network:  "192.168.200.0",
+		Warewulf: &warewulfconf.WarewulfConf{Port: 9983},
+		Dhcp:     &warewulfconf.DhcpConf{Enabled: true, RangeStart: "192.168.200.50", RangeEnd: "192.168.201.99"},
+		NodeDB:   &warewulfconf.NodeDBConf{Backend: "ldap"},
+		Networks: map[string]*warewulfconf.NetworkConf{"compute": {Subnet: "10.0.0.0/33"}},
+		Discovery: &warewulfconf.DiscoveryConf{Rules: []*warewulfconf.DiscoveryRule{
+			{Match: map[string]string{"port": ".*"}, Node: "n1"},
+		}},
+	}
+	fileName := path.Join(t.TempDir(), "warewulf.conf")
+	assert.NoError(t, os.WriteFile(fileName, []byte("ipaddr: 192.168.200.1\nnetmsk: 255.255.255.0\n"), 0644))
+
+	problems := CheckController(conf, fileName)
+	var fields []string
+	for _, p := range problems {
+		fields = append(fields, p.Level+" "+p.Field)
+	}
+	assert.Equal(t, []string{"WARNING ", "ERROR dhcp", "ERROR nodedb.backend", "ERROR networks", "ERROR discovery"}, fields)
+}
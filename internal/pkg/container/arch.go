@@ -0,0 +1,50 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hpcng/warewulf/internal/pkg/util"
+)
+
+/*
+Returns the architecture of the container, as recorded at import time. For
+containers imported by older versions the rootfs is inspected.
+*/
+func Arch(name string) string {
+	arch, err := ioutil.ReadFile(ArchFile(name))
+	if err == nil {
+		return util.NormalizeArch(string(arch))
+	}
+	return util.FindArch(RootFsDir(name))
+}
+
+/*
+Records the architecture of the container, if arch is empty it is
+detected from the rootfs.
+*/
+func SetArch(name string, arch string) error {
+	if arch == "" {
+		arch = util.FindArch(RootFsDir(name))
+	}
+	if arch == "" {
+		return fmt.Errorf("could not detect architecture of container %s", name)
+	}
+	return ioutil.WriteFile(ArchFile(name), []byte(util.NormalizeArch(arch)+"\n"), 0644)
+}
+
+/*
+Returns an error if the container was built for another architecture than
+arch. Unknown architectures always match.
+*/
+func CheckArch(name string, arch string) error {
+	containerArch := Arch(name)
+	if arch == "" || containerArch == "" {
+		return nil
+	}
+	if util.NormalizeArch(arch) != containerArch {
+		return fmt.Errorf("container %s has architecture %s, not %s", name, containerArch, strings.TrimSpace(arch))
+	}
+	return nil
+}
@@ -1,11 +1,14 @@
 package container
 
 import (
+	"io/ioutil"
 	"path"
 	"strings"
+	"syscall"
 
 	"github.com/pkg/errors"
 
+	"github.com/hpcng/warewulf/internal/pkg/cpio"
 	"github.com/hpcng/warewulf/internal/pkg/util"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 )
@@ -23,6 +26,9 @@ func Build(name string, buildForce bool) error {
 		wwlog.Debug("Checking if there have been any updates to the VNFS directory")
 		if util.PathIsNewer(rootfsPath, imagePath) {
 			wwlog.Info("Skipping (VNFS is current)")
+			if !util.IsFile(ImageDigestFile(name)) {
+				return writeImageDigest(name)
+			}
 			return nil
 		}
 	}
@@ -43,7 +49,18 @@ func Build(name string, buildForce bool) error {
 		}
 	}
 
-	err := util.BuildFsImage(
+	// a delegated root file system is owned by the ids of the user namespace
+	delegation, err := GetDelegation(name)
+	if err != nil {
+		return err
+	}
+	var attr *syscall.SysProcAttr
+	if delegation != nil {
+		attr = delegation.SysProcAttr()
+	}
+
+	err = util.BuildFsImageAs(
+		attr,
 		"VNFS container " + name,
 		rootfsPath,
 		imagePath,
@@ -52,6 +69,33 @@ func Build(name string, buildForce bool) error {
 		// ignore cross-device files
 		true,
 		"newc")
+	if err != nil {
+		return err
+	}
 
-	return err
+	return writeImageDigest(name)
+}
+
+/*
+Records the digest of the container image, which nodes report as the digest
+of their running container
+*/
+func writeImageDigest(name string) error {
+	digest, err := cpio.DigestFile(ImageFile(name))
+	if err != nil {
+		return errors.Wrapf(err, "could not compute digest of container image %s", name)
+	}
+	return util.WriteFileAtomic(ImageDigestFile(name), []byte(digest+"\n"), 0644)
+}
+
+/*
+Returns the digest of the container image, empty if the image was built
+before digests were recorded
+*/
+func ImageDigest(name string) string {
+	digest, err := ioutil.ReadFile(ImageDigestFile(name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(digest))
 }
@@ -4,6 +4,7 @@ import (
 	"path"
 
 	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
 )
 
 func SourceParentDir() string {
@@ -18,6 +19,18 @@ func RootFsDir(name string) string {
 	return path.Join(SourceDir(name), "rootfs")
 }
 
+func DelegationFile(name string) string {
+	return path.Join(SourceDir(name), "delegation.json")
+}
+
+func ArchFile(name string) string {
+	return path.Join(SourceDir(name), "arch")
+}
+
+func OciBlobCacheDir() string {
+	return path.Join(warewulfconf.DataStore(), "oci")
+}
+
 func ImageParentDir() string {
 	return path.Join(buildconfig.WWPROVISIONDIR(), "container/")
 }
@@ -25,3 +38,7 @@ func ImageParentDir() string {
 func ImageFile(name string) string {
 	return path.Join(ImageParentDir(), name+".img")
 }
+
+func ImageDigestFile(name string) string {
+	return path.Join(ImageParentDir(), name+".img.digest")
+}
@@ -0,0 +1,377 @@
+package container
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hpcng/warewulf/internal/pkg/util"
+
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+	"github.com/pkg/errors"
+)
+
+/*
+A contiguous range of subordinate ids as found in /etc/subuid and /etc/subgid
+*/
+type IDRange struct {
+	Start int
+	Count int
+}
+
+/*
+Mapping of a range of ids inside of the container to ids on the host
+*/
+type IDMap struct {
+	ContainerID int
+	HostID      int
+	Size        int
+}
+
+/*
+Returns true if the given user is allowed to modify the container. Root is
+always allowed, all other users must be delegated in warewulf.conf, either
+for the container itself or for '*'.
+*/
+func MayModify(containerName string, u *user.User) bool {
+	if u.Uid == "0" {
+		return true
+	}
+	conf, err := warewulfconf.New()
+	if err != nil {
+		wwlog.Printf(wwlog.ERROR, "Could not read warewulf.conf: %s\n", err)
+		return false
+	}
+	var groups []string
+	gids, err := u.GroupIds()
+	if err != nil {
+		wwlog.Printf(wwlog.WARN, "Could not get groups of %s: %s\n", u.Username, err)
+	}
+	for _, gid := range gids {
+		g, err := user.LookupGroupId(gid)
+		if err != nil {
+			continue
+		}
+		groups = append(groups, g.Name)
+	}
+	return mayModify(conf.Container.Delegates, containerName, u.Username, groups)
+}
+
+func mayModify(delegates map[string][]string, containerName string, username string, groups []string) bool {
+	for _, key := range []string{containerName, "*"} {
+		for _, d := range delegates[key] {
+			if strings.HasPrefix(d, "@") {
+				for _, g := range groups {
+					if d[1:] == g {
+						return true
+					}
+				}
+			} else if d == username {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+Returns the subordinate uid ranges of the given user
+*/
+func SubUIDs(u *user.User) ([]IDRange, error) {
+	return subIDFile("/etc/subuid", u.Username, u.Uid)
+}
+
+/*
+Returns the subordinate gid ranges of the given user, /etc/subgid is
+keyed by user name or uid, not by group
+*/
+func SubGIDs(u *user.User) ([]IDRange, error) {
+	return subIDFile("/etc/subgid", u.Username, u.Uid)
+}
+
+func subIDFile(fileName string, name string, id string) ([]IDRange, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseSubIDs(f, name, id)
+}
+
+func parseSubIDs(r io.Reader, name string, id string) ([]IDRange, error) {
+	var ret []IDRange
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) != 3 || (fields[0] != name && fields[0] != id) {
+			continue
+		}
+		start, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid start in line: %s", line)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid count in line: %s", line)
+		}
+		ret = append(ret, IDRange{Start: start, Count: count})
+	}
+	return ret, scanner.Err()
+}
+
+/*
+Create the id mappings for a user namespace. The calling user becomes root
+inside of the container and the subordinate ranges are mapped consecutively
+from id 1 onwards.
+*/
+func NamespaceMap(id int, subs []IDRange) []IDMap {
+	ret := []IDMap{{ContainerID: 0, HostID: id, Size: 1}}
+	next := 1
+	for _, s := range subs {
+		if s.Count <= 0 {
+			continue
+		}
+		ret = append(ret, IDMap{ContainerID: next, HostID: s.Start, Size: s.Count})
+		next += s.Count
+	}
+	return ret
+}
+
+/*
+Arguments for newuidmap/newgidmap
+*/
+func MapArgs(maps []IDMap) []string {
+	var ret []string
+	for _, m := range maps {
+		ret = append(ret, strconv.Itoa(m.ContainerID), strconv.Itoa(m.HostID), strconv.Itoa(m.Size))
+	}
+	return ret
+}
+
+/*
+The user a container is delegated to. Its root file system is owned by the
+host ids of the mappings, so that the user can modify it in a user
+namespace with these mappings.
+*/
+type Delegation struct {
+	User   string  `json:"user"`
+	UidMap []IDMap `json:"uid map"`
+	GidMap []IDMap `json:"gid map"`
+}
+
+/*
+Returns the delegation of the container, nil if it isn't delegated
+*/
+func GetDelegation(name string) (*Delegation, error) {
+	buffer, err := ioutil.ReadFile(DelegationFile(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var d Delegation
+	err = json.Unmarshal(buffer, &d)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse %s", DelegationFile(name))
+	}
+	return &d, nil
+}
+
+/*
+Process attributes which run a process as root of a user namespace with the
+mappings of the delegation, only root may set up these mappings
+*/
+func (d *Delegation) SysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Cloneflags:                 syscall.CLONE_NEWUSER,
+		UidMappings:                SysProcIDMaps(d.UidMap),
+		GidMappings:                SysProcIDMaps(d.GidMap),
+		GidMappingsEnableSetgroups: true,
+		Credential:                 &syscall.Credential{Uid: 0, Gid: 0},
+	}
+}
+
+/*
+The mappings in the form of syscall.SysProcAttr
+*/
+func SysProcIDMaps(maps []IDMap) []syscall.SysProcIDMap {
+	var ret []syscall.SysProcIDMap
+	for _, m := range maps {
+		ret = append(ret, syscall.SysProcIDMap{ContainerID: m.ContainerID, HostID: m.HostID, Size: m.Size})
+	}
+	return ret
+}
+
+/*
+Delegates the container to the user by changing the owners of its root file
+system to the ids of the user namespace of the user, the user becomes root.
+A container is delegated to one user at a time, a previous delegation is
+removed first. Must be run as root.
+*/
+func Delegate(name string, u *user.User) error {
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return err
+	}
+	subuids, err := SubUIDs(u)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	subgids, err := SubGIDs(u)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = Undelegate(name)
+	if err != nil {
+		return err
+	}
+	d := Delegation{
+		User:   u.Username,
+		UidMap: NamespaceMap(uid, subuids),
+		GidMap: NamespaceMap(gid, subgids),
+	}
+	err = shiftOwners(RootFsDir(name), &d, false)
+	if err != nil {
+		return errors.Wrapf(err, "could not change the owners of container %s", name)
+	}
+	buffer, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(DelegationFile(name), buffer, 0644)
+}
+
+/*
+Removes the delegation of the container, the owners of its root file system
+are changed back to the ids inside of the container
+*/
+func Undelegate(name string) error {
+	d, err := GetDelegation(name)
+	if err != nil || d == nil {
+		return err
+	}
+	err = shiftOwners(RootFsDir(name), d, true)
+	if err != nil {
+		return errors.Wrapf(err, "could not change the owners of container %s back", name)
+	}
+	return os.Remove(DelegationFile(name))
+}
+
+/*
+Changes the owners of all files below root from the ids inside of the
+container to the host ids of the mappings, or back if reverse is set. On an
+error the files which were already changed are changed back.
+*/
+func shiftOwners(root string, d *Delegation, reverse bool) error {
+	var done []string
+	err := filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		err = shiftOwner(name, info, d, reverse)
+		if err != nil {
+			return err
+		}
+		done = append(done, name)
+		return nil
+	})
+	if err != nil {
+		for _, name := range done {
+			info, lerr := os.Lstat(name)
+			if lerr == nil {
+				lerr = shiftOwner(name, info, d, !reverse)
+			}
+			if lerr != nil {
+				wwlog.Printf(wwlog.WARN, "Could not change the owner of %s back: %s\n", name, lerr)
+			}
+		}
+	}
+	return err
+}
+
+// the file system calls of shiftOwner, the tests replace them as changing
+// the owners of files needs root
+var (
+	fileOwner = func(name string, info os.FileInfo) (int, int, bool) {
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return 0, 0, false
+		}
+		return int(stat.Uid), int(stat.Gid), true
+	}
+	lchown   = os.Lchown
+	chmod    = os.Chmod
+	getxattr = syscall.Getxattr
+	setxattr = syscall.Setxattr
+)
+
+/*
+Changes the owner of a single file, set-id bits and file capabilities, which
+chown clears, are kept
+*/
+func shiftOwner(name string, info os.FileInfo, d *Delegation, reverse bool) error {
+	fileUid, fileGid, ok := fileOwner(name, info)
+	if !ok {
+		return errors.Errorf("no owner of %s", name)
+	}
+	uid, uidOk := mapID(d.UidMap, fileUid, reverse)
+	gid, gidOk := mapID(d.GidMap, fileGid, reverse)
+	if !uidOk || !gidOk {
+		return errors.Errorf("%s is owned by %d:%d, which is not in the mappings of %s", name, fileUid, fileGid, d.User)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return lchown(name, uid, gid)
+	}
+	capability := make([]byte, 1024)
+	n, err := getxattr(name, "security.capability", capability)
+	if err != nil {
+		n = 0
+	}
+	err = lchown(name, uid, gid)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 {
+		err = chmod(name, info.Mode())
+		if err != nil {
+			return err
+		}
+	}
+	if n > 0 {
+		return setxattr(name, "security.capability", capability[:n], 0)
+	}
+	return nil
+}
+
+/*
+Maps an id inside of the container to the host id, or back if reverse is
+set. false if the id isn't mapped.
+*/
+func mapID(maps []IDMap, id int, reverse bool) (int, bool) {
+	for _, m := range maps {
+		from, to := m.ContainerID, m.HostID
+		if reverse {
+			from, to = to, from
+		}
+		if id >= from && id < from+m.Size {
+			return to + id - from, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,267 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_mayModify(t *testing.T) {
+	delegates := map[string][]string{
+		"rocky-8": {"alice", "@imagers"},
+		"*":       {"bob"},
+	}
+	tests := []struct {
+		name      string
+		container string
+		user      string
+		groups    []string
+		want      bool
+	}{
+		{"delegatedUser", "rocky-8", "alice", nil, true},
+		{"delegatedGroup", "rocky-8", "carol", []string{"users", "imagers"}, true},
+		{"wildcardUser", "centos-7", "bob", nil, true},
+		{"otherContainer", "centos-7", "alice", nil, false},
+		{"groupNotUser", "rocky-8", "imagers", nil, false},
+		{"unknownUser", "rocky-8", "mallory", []string{"users"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, mayModify(delegates, tt.container, tt.user, tt.groups))
+		})
+	}
+	assert.False(t, mayModify(nil, "rocky-8", "alice", nil))
+}
+
+func Test_parseSubIDs(t *testing.T) {
+	subuid := `
+# comment
+alice:100000:65536
+bob:165536:65536
+1000:231072:1000
+broken line
+`
+	got, err := parseSubIDs(strings.NewReader(subuid), "alice", "1000")
+	assert.NoError(t, err)
+	assert.Equal(t, []IDRange{{100000, 65536}, {231072, 1000}}, got)
+
+	got, err = parseSubIDs(strings.NewReader(subuid), "carol", "1001")
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	_, err = parseSubIDs(strings.NewReader("alice:x:1"), "alice", "1000")
+	assert.Error(t, err)
+}
+
+func Test_NamespaceMap(t *testing.T) {
+	maps := NamespaceMap(1000, []IDRange{{100000, 65536}, {0, 0}, {231072, 1000}})
+	assert.Equal(t, []IDMap{
+		{ContainerID: 0, HostID: 1000, Size: 1},
+		{ContainerID: 1, HostID: 100000, Size: 65536},
+		{ContainerID: 65537, HostID: 231072, Size: 1000},
+	}, maps)
+	assert.Equal(t, []string{"0", "1000", "1", "1", "100000", "65536", "65537", "231072", "1000"}, MapArgs(maps))
+}
+
+func Test_mapID(t *testing.T) {
+	maps := NamespaceMap(1000, []IDRange{{100000, 65536}})
+	id, ok := mapID(maps, 0, false)
+	assert.True(t, ok)
+	assert.Equal(t, 1000, id)
+	id, ok = mapID(maps, 1000, false)
+	assert.True(t, ok)
+	assert.Equal(t, 100999, id)
+	id, ok = mapID(maps, 100999, true)
+	assert.True(t, ok)
+	assert.Equal(t, 1000, id)
+	_, ok = mapID(maps, 65537, false)
+	assert.False(t, ok)
+}
+
+// the owners, set-id bits and capabilities of files in memory, chown clears
+// the set-id bits and the capabilities like the kernel does
+type fakeOwners struct {
+	owners map[string][2]int
+	setid  map[string]bool
+	caps   map[string][]byte
+	failOn string
+}
+
+func (f *fakeOwners) install(t *testing.T) {
+	savedOwner, savedLchown, savedChmod, savedGetxattr, savedSetxattr := fileOwner, lchown, chmod, getxattr, setxattr
+	t.Cleanup(func() {
+		fileOwner, lchown, chmod, getxattr, setxattr = savedOwner, savedLchown, savedChmod, savedGetxattr, savedSetxattr
+	})
+	fileOwner = func(name string, info os.FileInfo) (int, int, bool) {
+		owner, ok := f.owners[name]
+		return owner[0], owner[1], ok
+	}
+	lchown = func(name string, uid int, gid int) error {
+		if name == f.failOn {
+			return syscall.EPERM
+		}
+		f.owners[name] = [2]int{uid, gid}
+		delete(f.setid, name)
+		delete(f.caps, name)
+		return nil
+	}
+	chmod = func(name string, mode os.FileMode) error {
+		f.setid[name] = mode&(os.ModeSetuid|os.ModeSetgid) != 0
+		return nil
+	}
+	getxattr = func(name string, attr string, dest []byte) (int, error) {
+		c, ok := f.caps[name]
+		if !ok {
+			return 0, syscall.ENODATA
+		}
+		return copy(dest, c), nil
+	}
+	setxattr = func(name string, attr string, data []byte, flags int) error {
+		f.caps[name] = append([]byte(nil), data...)
+		return nil
+	}
+}
+
+// the mapping of the owners and the rollback without root
+func Test_shiftOwnersFake(t *testing.T) {
+	rootfs := t.TempDir()
+	assert.NoError(t, os.MkdirAll(path.Join(rootfs, "etc"), 0755))
+	assert.NoError(t, os.MkdirAll(path.Join(rootfs, "home/user"), 0700))
+	assert.NoError(t, ioutil.WriteFile(path.Join(rootfs, "etc/hostname"), []byte("old\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(path.Join(rootfs, "ping"), nil, 0755))
+	assert.NoError(t, ioutil.WriteFile(path.Join(rootfs, "su"), nil, 0755))
+	assert.NoError(t, os.Chmod(path.Join(rootfs, "su"), 0755|os.ModeSetuid))
+	assert.NoError(t, os.Symlink("etc/hostname", path.Join(rootfs, "link")))
+
+	f := &fakeOwners{
+		owners: make(map[string][2]int),
+		setid:  map[string]bool{path.Join(rootfs, "su"): true},
+		caps:   map[string][]byte{path.Join(rootfs, "ping"): []byte("cap_net_raw")},
+	}
+	for _, name := range []string{"", "etc", "etc/hostname", "home", "ping", "su", "link"} {
+		f.owners[path.Join(rootfs, name)] = [2]int{0, 0}
+	}
+	f.owners[path.Join(rootfs, "home/user")] = [2]int{1000, 1000}
+	original := make(map[string][2]int)
+	for name, owner := range f.owners {
+		original[name] = owner
+	}
+	f.install(t)
+
+	d := &Delegation{
+		User:   "delegate",
+		UidMap: NamespaceMap(4000, []IDRange{{200000, 65536}}),
+		GidMap: NamespaceMap(4000, []IDRange{{200000, 65536}}),
+	}
+	assert.NoError(t, shiftOwners(rootfs, d, false))
+	assert.Equal(t, [2]int{4000, 4000}, f.owners[path.Join(rootfs, "etc/hostname")])
+	assert.Equal(t, [2]int{4000, 4000}, f.owners[path.Join(rootfs, "link")])
+	assert.Equal(t, [2]int{200999, 200999}, f.owners[path.Join(rootfs, "home/user")])
+	assert.True(t, f.setid[path.Join(rootfs, "su")])
+	assert.Equal(t, []byte("cap_net_raw"), f.caps[path.Join(rootfs, "ping")])
+
+	assert.NoError(t, shiftOwners(rootfs, d, true))
+	assert.Equal(t, original, f.owners)
+	assert.True(t, f.setid[path.Join(rootfs, "su")])
+	assert.Equal(t, []byte("cap_net_raw"), f.caps[path.Join(rootfs, "ping")])
+
+	// ids outside of the mappings are an error and the files which were
+	// already changed are changed back
+	f.owners[path.Join(rootfs, "home/user")] = [2]int{70000, 0}
+	assert.Error(t, shiftOwners(rootfs, d, false))
+	f.owners[path.Join(rootfs, "home/user")] = [2]int{1000, 1000}
+	assert.Equal(t, original, f.owners)
+
+	// so are the files before a failed chown
+	f.failOn = path.Join(rootfs, "su")
+	assert.Error(t, shiftOwners(rootfs, d, false))
+	assert.Equal(t, original, f.owners)
+	assert.Equal(t, []byte("cap_net_raw"), f.caps[path.Join(rootfs, "ping")])
+}
+
+// writes to the root file system from the user namespace of the delegate,
+// which needs root to set up the mappings
+func Test_shiftOwners(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root")
+	}
+	tmp := t.TempDir()
+	// the delegate must be able to reach the root file system
+	for dir := tmp; dir != path.Dir(dir); dir = path.Dir(dir) {
+		if dir == os.TempDir() {
+			break
+		}
+		assert.NoError(t, os.Chmod(dir, 0755))
+	}
+	rootfs := path.Join(tmp, "rootfs")
+	assert.NoError(t, os.MkdirAll(path.Join(rootfs, "etc"), 0755))
+	assert.NoError(t, os.MkdirAll(path.Join(rootfs, "home/user"), 0700))
+	assert.NoError(t, os.Chown(path.Join(rootfs, "home/user"), 1000, 1000))
+	assert.NoError(t, ioutil.WriteFile(path.Join(rootfs, "etc/hostname"), []byte("old\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(path.Join(rootfs, "su"), nil, 0755))
+	assert.NoError(t, os.Chmod(path.Join(rootfs, "su"), 0755|os.ModeSetuid))
+
+	d := &Delegation{
+		User:   "delegate",
+		UidMap: NamespaceMap(4000, []IDRange{{200000, 65536}}),
+		GidMap: NamespaceMap(4000, []IDRange{{200000, 65536}}),
+	}
+	write := func() error {
+		cmd := exec.Command("/bin/sh", "-c", "echo new > etc/hostname && touch new home/user/file")
+		cmd.Dir = rootfs
+		cmd.SysProcAttr = d.SysProcAttr()
+		return cmd.Run()
+	}
+	owner := func(name string) (uint32, uint32) {
+		info, err := os.Lstat(path.Join(rootfs, name))
+		assert.NoError(t, err)
+		stat := info.Sys().(*syscall.Stat_t)
+		return stat.Uid, stat.Gid
+	}
+
+	if err := exec.Command("/bin/sh", "-c", "true").Run(); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	// not delegated, the files of root aren't mapped
+	err := write()
+	if err != nil && strings.Contains(err.Error(), "operation not permitted") {
+		t.Skip("user namespaces are not available")
+	}
+	assert.Error(t, err)
+
+	assert.NoError(t, shiftOwners(rootfs, d, false))
+	uid, gid := owner("etc/hostname")
+	assert.Equal(t, []uint32{4000, 4000}, []uint32{uid, gid})
+	uid, _ = owner("home/user")
+	assert.Equal(t, uint32(200999), uid)
+	info, err := os.Stat(path.Join(rootfs, "su"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.ModeSetuid, info.Mode()&os.ModeSetuid)
+
+	assert.NoError(t, write())
+	content, err := ioutil.ReadFile(path.Join(rootfs, "etc/hostname"))
+	assert.NoError(t, err)
+	assert.Equal(t, "new\n", string(content))
+	uid, _ = owner("new")
+	assert.Equal(t, uint32(4000), uid)
+
+	assert.NoError(t, shiftOwners(rootfs, d, true))
+	uid, _ = owner("new")
+	assert.Equal(t, uint32(0), uid)
+	uid, gid = owner("home/user/file")
+	assert.Equal(t, []uint32{0, 0}, []uint32{uid, gid})
+	uid, _ = owner("home/user")
+	assert.Equal(t, uint32(1000), uid)
+
+	// ids outside of the mappings are an error and nothing is changed
+	assert.NoError(t, os.Chown(path.Join(rootfs, "etc/hostname"), 70000, 0))
+	assert.Error(t, shiftOwners(rootfs, d, false))
+	uid, _ = owner("etc")
+	assert.Equal(t, uint32(0), uid)
+}
@@ -9,15 +9,16 @@ import (
 	"github.com/containers/storage/drivers/copy"
 	"github.com/pkg/errors"
 
-	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
 	"github.com/hpcng/warewulf/internal/pkg/oci"
 	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 )
 
-func ImportDocker(uri string, name string, sCtx *types.SystemContext) error {
-	OciBlobCacheDir := 	warewulfconf.DataStore() + "/oci"
-
-	err := os.MkdirAll(OciBlobCacheDir, 0755)
+/*
+Import an oci image, if offline is set only the local blob cache is used
+*/
+func ImportDocker(uri string, name string, sCtx *types.SystemContext, offline bool) error {
+	err := os.MkdirAll(OciBlobCacheDir(), 0755)
 	if err != nil {
 		return err
 	}
@@ -34,8 +35,9 @@ func ImportDocker(uri string, name string, sCtx *types.SystemContext) error {
 	}
 
 	p, err := oci.NewPuller(
-		oci.OptSetBlobCachePath(OciBlobCacheDir),
+		oci.OptSetBlobCachePath(OciBlobCacheDir()),
 		oci.OptSetSystemContext(sCtx),
+		oci.OptSetOffline(offline),
 	)
 	if err != nil {
 		return err
@@ -49,6 +51,10 @@ func ImportDocker(uri string, name string, sCtx *types.SystemContext) error {
 		return err
 	}
 
+	if err := SetArch(name, ""); err != nil {
+		wwlog.Warn("%s", err)
+	}
+
 	return nil
 }
 
@@ -73,5 +79,9 @@ func ImportDirectory(uri string, name string) error {
 		return err
 	}
 
+	if err := SetArch(name, ""); err != nil {
+		wwlog.Warn("%s", err)
+	}
+
 	return nil
 }
@@ -40,6 +40,14 @@ func SyncUids(containerName string, showOnly bool) error {
 	passwdName := "/etc/passwd"
 	groupName := "/etc/group"
 	fullPath := RootFsDir(containerName)
+	// the owners of a delegated container are the host ids of the delegate
+	delegation, err := GetDelegation(containerName)
+	if err != nil {
+		return err
+	}
+	if delegation != nil {
+		return errors.Errorf("container %s is delegated to %s, remove the delegation before synchronizing the users", containerName, delegation.User)
+	}
 	hostName, err := createPasswdMap(passwdName)
 	if err != nil {
 		wwlog.Printf(wwlog.ERROR, "Could not open "+passwdName)
@@ -0,0 +1,209 @@
+package cpio
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+)
+
+const (
+	newcMagic  = "070701"
+	headerSize = 110
+	trailer    = "TRAILER!!!"
+
+	ModeType    = 0170000
+	ModeDir     = 0040000
+	ModeRegular = 0100000
+	ModeSymlink = 0120000
+)
+
+/*
+A member of a cpio archive in the newc format, which is the format of the
+overlay images. The content of a symlink is its target.
+*/
+type Header struct {
+	Name  string
+	Mode  uint32
+	Uid   int
+	Gid   int
+	Nlink int
+	Mtime int64
+	Size  int64
+}
+
+func (h *Header) IsDir() bool {
+	return h.Mode&ModeType == ModeDir
+}
+
+func (h *Header) IsRegular() bool {
+	return h.Mode&ModeType == ModeRegular
+}
+
+func (h *Header) IsSymlink() bool {
+	return h.Mode&ModeType == ModeSymlink
+}
+
+/*
+Permission bits including setuid, setgid and sticky
+*/
+func (h *Header) Perm() os.FileMode {
+	mode := os.FileMode(h.Mode & 0777)
+	if h.Mode&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if h.Mode&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if h.Mode&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+/*
+Reads the members of a newc archive, the content of the current member is
+read with Read
+*/
+type Reader struct {
+	r         *bufio.Reader
+	remaining int64
+	pad       int64
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+func padding(n int64) int64 {
+	return (4 - n%4) % 4
+}
+
+/*
+Returns the next member, io.EOF at the end of the archive
+*/
+func (cr *Reader) Next() (*Header, error) {
+	if _, err := io.CopyN(io.Discard, cr.r, cr.remaining+cr.pad); err != nil {
+		return nil, err
+	}
+	cr.remaining, cr.pad = 0, 0
+
+	buf := make([]byte, headerSize)
+	if _, err := io.ReadFull(cr.r, buf); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if string(buf[:6]) != newcMagic {
+		return nil, fmt.Errorf("not a newc cpio archive: %q", buf[:6])
+	}
+	var fields [13]uint64
+	for i := range fields {
+		v, err := strconv.ParseUint(string(buf[6+i*8:14+i*8]), 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header: %s", err)
+		}
+		fields[i] = v
+	}
+	nameSize := int64(fields[11])
+	if nameSize == 0 || nameSize > 4096 {
+		return nil, fmt.Errorf("invalid name size in cpio header: %d", nameSize)
+	}
+	name := make([]byte, nameSize+padding(headerSize+nameSize))
+	if _, err := io.ReadFull(cr.r, name); err != nil {
+		return nil, err
+	}
+	hdr := &Header{
+		Name:  string(name[:nameSize-1]),
+		Mode:  uint32(fields[1]),
+		Uid:   int(fields[2]),
+		Gid:   int(fields[3]),
+		Nlink: int(fields[4]),
+		Mtime: int64(fields[5]),
+		Size:  int64(fields[6]),
+	}
+	if hdr.Name == trailer {
+		return nil, io.EOF
+	}
+	cr.remaining = hdr.Size
+	cr.pad = padding(hdr.Size)
+	return hdr, nil
+}
+
+/*
+Reads the content of the current member
+*/
+func (cr *Reader) Read(p []byte) (int, error) {
+	if cr.remaining == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+	n, err := cr.r.Read(p)
+	cr.remaining -= int64(n)
+	if err == io.EOF && cr.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+/*
+Computes the digest of the members of an archive while they are read, Add is
+called for every member and its content is written to the returned writer
+*/
+type Digester struct {
+	hash hash.Hash
+}
+
+func NewDigester() *Digester {
+	return &Digester{hash: sha256.New()}
+}
+
+func (d *Digester) Add(hdr *Header) io.Writer {
+	fmt.Fprintf(d.hash, "%s\x00%o %d %d %d\x00", hdr.Name, hdr.Mode, hdr.Uid, hdr.Gid, hdr.Size)
+	return d.hash
+}
+
+func (d *Digester) Sum() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+/*
+Returns a digest of the members of an archive, their names, modes, owners and
+contents. Modification times are not included, so an archive which is built
+again from the same files has the same digest.
+*/
+func Digest(r io.Reader) (string, error) {
+	digester := NewDigester()
+	cr := NewReader(r)
+	for {
+		hdr, err := cr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(digester.Add(hdr), cr); err != nil {
+			return "", err
+		}
+	}
+	return digester.Sum(), nil
+}
+
+/*
+Digest of an archive file
+*/
+func DigestFile(fileName string) (string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return Digest(f)
+}
@@ -0,0 +1,110 @@
+package cpio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type member struct {
+	name  string
+	mode  uint32
+	mtime int64
+	data  string
+}
+
+// writes a newc archive as cpio -H newc does
+func writeArchive(members []member) []byte {
+	var buf bytes.Buffer
+	write := func(m member) {
+		fmt.Fprintf(&buf, "%s%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			newcMagic, 1, m.mode, 0, 0, 1, m.mtime, len(m.data), 0, 0, 0, 0, len(m.name)+1, 0)
+		buf.WriteString(m.name + "\x00")
+		buf.Write(make([]byte, padding(int64(headerSize+len(m.name)+1))))
+		buf.WriteString(m.data)
+		buf.Write(make([]byte, padding(int64(len(m.data)))))
+	}
+	for _, m := range members {
+		write(m)
+	}
+	write(member{name: trailer})
+	return buf.Bytes()
+}
+
+var testMembers = []member{
+	{name: "etc", mode: ModeDir | 0755, mtime: 100},
+	{name: "etc/hosts", mode: ModeRegular | 0644, mtime: 100, data: "127.0.0.1 localhost\n"},
+	{name: "etc/localtime", mode: ModeSymlink | 0777, mtime: 100, data: "/usr/share/zoneinfo/UTC"},
+	{name: "usr/bin/tool", mode: ModeRegular | 04755, mtime: 100, data: "#!/bin/sh\n"},
+}
+
+func Test_Reader(t *testing.T) {
+	cr := NewReader(bytes.NewReader(writeArchive(testMembers)))
+	for _, m := range testMembers {
+		hdr, err := cr.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, m.name, hdr.Name)
+		assert.Equal(t, m.mode, hdr.Mode)
+		assert.Equal(t, int64(len(m.data)), hdr.Size)
+		data, err := ioutil.ReadAll(cr)
+		assert.NoError(t, err)
+		assert.Equal(t, m.data, string(data))
+	}
+	_, err := cr.Next()
+	assert.Equal(t, io.EOF, err)
+
+	// skipping the contents
+	cr = NewReader(bytes.NewReader(writeArchive(testMembers)))
+	var names []string
+	for {
+		hdr, err := cr.Next()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		names = append(names, hdr.Name)
+		if hdr.Name == "usr/bin/tool" {
+			assert.True(t, hdr.IsRegular())
+			assert.Equal(t, os.ModeSetuid|0755, hdr.Perm())
+		}
+	}
+	assert.Equal(t, []string{"etc", "etc/hosts", "etc/localtime", "usr/bin/tool"}, names)
+
+	_, err = NewReader(bytes.NewReader([]byte("070707garbage"))).Next()
+	assert.Error(t, err)
+	_, err = NewReader(bytes.NewReader(writeArchive(testMembers)[:200])).Next()
+	assert.NoError(t, err)
+}
+
+func Test_Digest(t *testing.T) {
+	digest, err := Digest(bytes.NewReader(writeArchive(testMembers)))
+	assert.NoError(t, err)
+
+	rebuilt := make([]member, len(testMembers))
+	copy(rebuilt, testMembers)
+	for i := range rebuilt {
+		rebuilt[i].mtime = 200
+	}
+	same, err := Digest(bytes.NewReader(writeArchive(rebuilt)))
+	assert.NoError(t, err)
+	assert.Equal(t, digest, same, "modification times are ignored")
+
+	rebuilt[1].data = "127.0.0.1 localhost.localdomain\n"
+	changed, err := Digest(bytes.NewReader(writeArchive(rebuilt)))
+	assert.NoError(t, err)
+	assert.NotEqual(t, digest, changed)
+
+	rebuilt[1].data = testMembers[1].data
+	rebuilt[1].mode = ModeRegular | 0600
+	changed, err = Digest(bytes.NewReader(writeArchive(rebuilt)))
+	assert.NoError(t, err)
+	assert.NotEqual(t, digest, changed)
+
+	_, err = Digest(bytes.NewReader(writeArchive(testMembers)[:300]))
+	assert.Error(t, err)
+}
@@ -0,0 +1,269 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+)
+
+/*
+File with the machines which requested a boot but are not bound to a node
+*/
+var File string
+
+func init() {
+	if File == "" {
+		File = path.Join(buildconfig.LOCALSTATEDIR(), "warewulf/discovery.json")
+	}
+}
+
+/*
+Limits of the recorded machines, as any machine on the network can add
+entries. Entries which were not seen for Expiry seconds are dropped and only
+MaxPending entries are kept, the ones seen least recently are dropped first.
+Rejected machines are kept until they are removed. A repeated boot request
+which changes nothing but the last seen time is only written after
+SeenInterval seconds.
+*/
+var (
+	MaxPending         = 1000
+	Expiry       int64 = 7 * 24 * 60 * 60
+	SeenInterval int64 = 60
+)
+
+/*
+An unknown machine waiting for approval. Node is the node the discovery rules
+propose for it, rejected machines are neither bound nor proposed.
+*/
+type Pending struct {
+	Hwaddr    string `json:"hwaddr"`
+	FirstSeen int64  `json:"first seen"`
+	LastSeen  int64  `json:"last seen"`
+	Ipaddr    string `json:"ipaddr,omitempty"`
+	UUID      string `json:"uuid,omitempty"`
+	Serial    string `json:"serial,omitempty"`
+	AssetTag  string `json:"asset tag,omitempty"`
+	CircuitId string `json:"circuit id,omitempty"`
+	RemoteId  string `json:"remote id,omitempty"`
+	Node      string `json:"node,omitempty"`
+	Rejected  bool   `json:"rejected,omitempty"`
+}
+
+/*
+The properties of the boot request of the machine for the discovery rules
+*/
+func (p Pending) Request() node.DiscoveryRequest {
+	return node.DiscoveryRequest{
+		Hwaddr:    p.Hwaddr,
+		Ipaddr:    p.Ipaddr,
+		UUID:      p.UUID,
+		Serial:    p.Serial,
+		AssetTag:  p.AssetTag,
+		CircuitId: p.CircuitId,
+		RemoteId:  p.RemoteId,
+	}
+}
+
+func load() ([]Pending, error) {
+	var ret []Pending
+	data, err := ioutil.ReadFile(File)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return ret, err
+	}
+	err = json.Unmarshal(data, &ret)
+	return ret, err
+}
+
+func save(list []Pending) error {
+	sort.Slice(list, func(i, j int) bool { return list[i].FirstSeen < list[j].FirstSeen })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path.Dir(File), 0755)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(File, data, 0644)
+}
+
+/*
+Runs fn on the list with the file locked, the list is saved if fn returns
+true. warewulfd and wwctl both modify the file.
+*/
+func modify(fn func(list []Pending) ([]Pending, bool, error)) error {
+	err := os.MkdirAll(path.Dir(File), 0755)
+	if err != nil {
+		return err
+	}
+	lock, err := util.LockFile(File + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	list, err := load()
+	if err != nil {
+		return err
+	}
+	list, changed, err := fn(list)
+	if err != nil || !changed {
+		return err
+	}
+	return save(list)
+}
+
+/*
+Returns the machines waiting for approval, the oldest first
+*/
+func List() ([]Pending, error) {
+	list, err := load()
+	sort.Slice(list, func(i, j int) bool { return list[i].FirstSeen < list[j].FirstSeen })
+	return list, err
+}
+
+/*
+Returns the entry of a machine
+*/
+func Find(hwaddr string) (Pending, error) {
+	list, err := load()
+	if err != nil {
+		return Pending{}, err
+	}
+	for _, p := range list {
+		if strings.EqualFold(p.Hwaddr, hwaddr) {
+			return p, nil
+		}
+	}
+	return Pending{}, fmt.Errorf("no pending discovery for %s", hwaddr)
+}
+
+/*
+Records a boot request of an unknown machine with the node the discovery
+rules propose, returns the updated entry. Expired entries are dropped.
+*/
+func Seen(req node.DiscoveryRequest, proposed string, now int64) (Pending, error) {
+	var ret Pending
+	err := modify(func(list []Pending) ([]Pending, bool, error) {
+		i := 0
+		for ; i < len(list); i++ {
+			if strings.EqualFold(list[i].Hwaddr, req.Hwaddr) {
+				break
+			}
+		}
+		found := i < len(list)
+		if !found {
+			list = append(list, Pending{Hwaddr: strings.ToLower(req.Hwaddr), FirstSeen: now})
+		}
+		p := &list[i]
+		updated := *p
+		updated.LastSeen = now
+		updated.Ipaddr = req.Ipaddr
+		updated.UUID = req.UUID
+		updated.Serial = req.Serial
+		updated.AssetTag = req.AssetTag
+		updated.CircuitId = req.CircuitId
+		updated.RemoteId = req.RemoteId
+		if !updated.Rejected {
+			updated.Node = proposed
+		}
+		if found && now-p.LastSeen < SeenInterval {
+			previous := *p
+			previous.LastSeen = now
+			if previous == updated {
+				// only the last seen time moved
+				ret = *p
+				return list, false, nil
+			}
+		}
+		*p = updated
+		ret = updated
+		return prune(list, now), true, nil
+	})
+	return ret, err
+}
+
+/*
+Drops the expired entries and the ones over MaxPending which were seen least
+recently, rejected entries are kept
+*/
+func prune(list []Pending, now int64) []Pending {
+	var ret []Pending
+	for _, p := range list {
+		if p.Rejected || now-p.LastSeen <= Expiry {
+			ret = append(ret, p)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Rejected != ret[j].Rejected {
+			return ret[i].Rejected
+		}
+		return ret[i].LastSeen > ret[j].LastSeen
+	})
+	count := 0
+	for i, p := range ret {
+		if !p.Rejected {
+			count++
+			if count > MaxPending {
+				return ret[:i]
+			}
+		}
+	}
+	return ret
+}
+
+/*
+Removes the entries of the machines, e.g. because they were bound to nodes
+*/
+func Remove(hwaddrs ...string) error {
+	return modify(func(list []Pending) ([]Pending, bool, error) {
+		var ret []Pending
+		for _, p := range list {
+			if !containsFold(hwaddrs, p.Hwaddr) {
+				ret = append(ret, p)
+			}
+		}
+		return ret, len(ret) != len(list), nil
+	})
+}
+
+/*
+Marks the machines as rejected, they are not bound to a node until they are
+accepted
+*/
+func Reject(hwaddrs ...string) error {
+	return modify(func(list []Pending) ([]Pending, bool, error) {
+		for _, hwaddr := range hwaddrs {
+			found := false
+			for i := range list {
+				if strings.EqualFold(list[i].Hwaddr, hwaddr) {
+					list[i].Rejected = true
+					list[i].Node = ""
+					found = true
+				}
+			}
+			if !found {
+				return list, false, fmt.Errorf("no pending discovery for %s", hwaddr)
+			}
+		}
+		return list, true, nil
+	})
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
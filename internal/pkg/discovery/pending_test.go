@@ -0,0 +1,100 @@
+package discovery
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
+)
+
+func Test_Pending(t *testing.T) {
+	saved := File
+	File = path.Join(t.TempDir(), "discovery.json")
+	defer func() { File = saved }()
+
+	list, err := List()
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	_, err = Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:B2", Ipaddr: "10.0.0.12"}, "", 200)
+	assert.NoError(t, err)
+	_, err = Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1", Ipaddr: "10.0.0.11"}, "n0001", 100)
+	assert.NoError(t, err)
+	p, err := Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:A1", Ipaddr: "10.0.0.13", UUID: "4c4c4544-0035-5910-8044-b4c04f4a4e32"}, "n0001", 300)
+	assert.NoError(t, err)
+	assert.Equal(t, Pending{Hwaddr: "00:00:00:00:00:a1", FirstSeen: 100, LastSeen: 300, Ipaddr: "10.0.0.13",
+		UUID: "4c4c4544-0035-5910-8044-b4c04f4a4e32", Node: "n0001"}, p)
+
+	list, err = List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "00:00:00:00:00:a1", list[0].Hwaddr)
+	assert.Equal(t, "00:00:00:00:00:b2", list[1].Hwaddr)
+
+	assert.NoError(t, Reject("00:00:00:00:00:a1"))
+	assert.Error(t, Reject("00:00:00:00:00:ff"))
+	p, err = Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1"}, "n0001", 400)
+	assert.NoError(t, err)
+	assert.True(t, p.Rejected)
+	assert.Equal(t, "", p.Node)
+
+	assert.NoError(t, Remove("00:00:00:00:00:A1"))
+	_, err = Find("00:00:00:00:00:a1")
+	assert.Error(t, err)
+	p, err = Find("00:00:00:00:00:b2")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.12", p.Ipaddr)
+}
+
+func Test_PendingLimits(t *testing.T) {
+	saved, savedMax := File, MaxPending
+	File = path.Join(t.TempDir(), "discovery.json")
+	MaxPending = 2
+	defer func() { File, MaxPending = saved, savedMax }()
+
+	_, err := Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:01"}, "", 100)
+	assert.NoError(t, err)
+	assert.NoError(t, Reject("00:00:00:00:00:01"))
+	_, err = Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:02"}, "", 100)
+	assert.NoError(t, err)
+
+	// a repeated request is only written after SeenInterval
+	p, err := Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:02"}, "", 100+SeenInterval-1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), p.LastSeen)
+	p, err = Find("00:00:00:00:00:02")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), p.LastSeen)
+	p, err = Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:02", Ipaddr: "10.0.0.2"}, "", 100+SeenInterval-1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100+SeenInterval-1), p.LastSeen)
+	p, err = Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:02", Ipaddr: "10.0.0.2"}, "", 300)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(300), p.LastSeen)
+
+	// the entry seen least recently is dropped, rejected ones are kept
+	_, err = Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:03"}, "", 400)
+	assert.NoError(t, err)
+	_, err = Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:04"}, "", 500)
+	assert.NoError(t, err)
+	list, err := List()
+	assert.NoError(t, err)
+	var hwaddrs []string
+	for _, p := range list {
+		hwaddrs = append(hwaddrs, p.Hwaddr)
+	}
+	assert.Equal(t, []string{"00:00:00:00:00:01", "00:00:00:00:00:03", "00:00:00:00:00:04"}, hwaddrs)
+
+	// as are expired ones
+	_, err = Seen(node.DiscoveryRequest{Hwaddr: "00:00:00:00:00:05"}, "", 450+Expiry)
+	assert.NoError(t, err)
+	list, err = List()
+	assert.NoError(t, err)
+	hwaddrs = nil
+	for _, p := range list {
+		hwaddrs = append(hwaddrs, p.Hwaddr)
+	}
+	assert.Equal(t, []string{"00:00:00:00:00:01", "00:00:00:00:00:04", "00:00:00:00:00:05"}, hwaddrs)
+}
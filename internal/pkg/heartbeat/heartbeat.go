@@ -0,0 +1,136 @@
+package heartbeat
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+The state of a node which wwclient reports periodically, memory is in MiB
+*/
+type Heartbeat struct {
+	Time            int64      `json:"time"`
+	Uptime          int64      `json:"uptime"`
+	Load            [3]float64 `json:"load"`
+	MemTotal        uint64     `json:"memory total"`
+	MemAvailable    uint64     `json:"memory available"`
+	Kernel          string     `json:"kernel,omitempty"`
+	Container       string     `json:"container,omitempty"`
+	ContainerDigest string     `json:"container digest,omitempty"`
+	FailedUnits     []string   `json:"failed units,omitempty"`
+}
+
+/*
+Collects the heartbeat of the local host, failed units are only reported on
+hosts running systemd
+*/
+func Collect() Heartbeat {
+	hb := collectFiles("/")
+	out, err := exec.Command("systemctl", "list-units", "--state=failed", "--plain", "--no-legend", "--no-pager").Output()
+	if err == nil {
+		hb.FailedUnits = parseFailedUnits(string(out))
+	}
+	hb.Time = time.Now().Unix()
+	return hb
+}
+
+func readFields(fileName string) []string {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
+
+/*
+Collects the heartbeat from procfs and the Warewulf configuration below root
+*/
+func collectFiles(root string) Heartbeat {
+	var hb Heartbeat
+	if fields := readFields(path.Join(root, "proc/uptime")); len(fields) > 0 {
+		uptime, _ := strconv.ParseFloat(fields[0], 64)
+		hb.Uptime = int64(uptime)
+	}
+	if fields := readFields(path.Join(root, "proc/loadavg")); len(fields) >= 3 {
+		for i := range hb.Load {
+			hb.Load[i], _ = strconv.ParseFloat(fields[i], 64)
+		}
+	}
+	if fields := readFields(path.Join(root, "proc/sys/kernel/osrelease")); len(fields) > 0 {
+		hb.Kernel = fields[0]
+	}
+	hb.MemTotal, hb.MemAvailable = collectMemory(path.Join(root, "proc/meminfo"))
+	config := readConfig(path.Join(root, "warewulf/config"))
+	hb.Container = config["WWCONTAINER"]
+	hb.ContainerDigest = config["WWCONTAINER_DIGEST"]
+	return hb
+}
+
+func collectMemory(fileName string) (uint64, uint64) {
+	var total, available uint64
+	file, err := os.Open(fileName)
+	if err != nil {
+		return 0, 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, _ := strconv.ParseUint(fields[1], 10, 64)
+		switch fields[0] {
+		case "MemTotal:":
+			total = value / 1024
+		case "MemAvailable:":
+			available = value / 1024
+		}
+	}
+	return total, available
+}
+
+/*
+Reads the shell variables of the Warewulf configuration of the node
+*/
+func readConfig(fileName string) map[string]string {
+	ret := make(map[string]string)
+	file, err := os.Open(fileName)
+	if err != nil {
+		return ret
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		kv := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		ret[kv[0]] = strings.Trim(kv[1], `"`)
+	}
+	return ret
+}
+
+func parseFailedUnits(out string) []string {
+	var ret []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "●"))
+		if len(fields) > 0 {
+			ret = append(ret, fields[0])
+		}
+	}
+	return ret
+}
+
+/*
+A node is unhealthy if systemd units failed
+*/
+func (hb Heartbeat) Healthy() bool {
+	return len(hb.FailedUnits) == 0
+}
@@ -0,0 +1,49 @@
+package heartbeat
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_collectFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"proc/uptime":               "3725.41 14000.12\n",
+		"proc/loadavg":              "0.52 1.25 2.00 2/431 12345\n",
+		"proc/meminfo":              "MemTotal:       16384000 kB\nMemFree:         1024000 kB\nMemAvailable:    8192000 kB\n",
+		"proc/sys/kernel/osrelease": "4.18.0-348.el8.x86_64\n",
+		"warewulf/config":           "WWCONTAINER=rocky-8\nWWCONTAINER_DIGEST=abc123\nWWHOSTNAME=n1\nWWIPMI_IPADDR=\"\"\n",
+	}
+	for name, content := range files {
+		fileName := path.Join(root, name)
+		assert.NoError(t, os.MkdirAll(path.Dir(fileName), 0755))
+		assert.NoError(t, ioutil.WriteFile(fileName, []byte(content), 0644))
+	}
+
+	assert.Equal(t, Heartbeat{
+		Uptime:          3725,
+		Load:            [3]float64{0.52, 1.25, 2},
+		MemTotal:        16000,
+		MemAvailable:    8000,
+		Kernel:          "4.18.0-348.el8.x86_64",
+		Container:       "rocky-8",
+		ContainerDigest: "abc123",
+	}, collectFiles(root))
+
+	assert.Equal(t, Heartbeat{}, collectFiles(path.Join(root, "missing")))
+}
+
+func Test_parseFailedUnits(t *testing.T) {
+	out := `● munge.service loaded failed failed MUNGE authentication service
+slurmd.service       loaded failed failed Slurm node daemon
+
+`
+	assert.Equal(t, []string{"munge.service", "slurmd.service"}, parseFailedUnits(out))
+	assert.Empty(t, parseFailedUnits(""))
+	assert.True(t, Heartbeat{}.Healthy())
+	assert.False(t, Heartbeat{FailedUnits: []string{"munge.service"}}.Healthy())
+}
@@ -0,0 +1,169 @@
+package inventory
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+/*
+A difference between two inventories of a node, Item identifies the component
+within its kind, e.g. the locator of a DIMM or the name of a disk
+*/
+type Change struct {
+	Time   int64  `json:"time"`
+	Kind   string `json:"kind"`
+	Item   string `json:"item"`
+	Change string `json:"change"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Change {
+	case Added:
+		return fmt.Sprintf("%s %s added: %s", c.Kind, c.Item, c.New)
+	case Removed:
+		return fmt.Sprintf("%s %s removed: %s", c.Kind, c.Item, c.Old)
+	}
+	return fmt.Sprintf("%s %s changed: %s -> %s", c.Kind, c.Item, c.Old, c.New)
+}
+
+/*
+A component which is compared by its key, desc is shown in the change
+*/
+type component struct {
+	key  string
+	desc string
+}
+
+func diffComponents(kind string, old []component, new []component) []Change {
+	var ret []Change
+	oldMap := make(map[string]string)
+	for _, c := range old {
+		oldMap[c.key] = c.desc
+	}
+	newMap := make(map[string]string)
+	for _, c := range new {
+		newMap[c.key] = c.desc
+	}
+	for _, c := range old {
+		if desc, ok := newMap[c.key]; !ok {
+			ret = append(ret, Change{Kind: kind, Item: c.key, Change: Removed, Old: c.desc})
+		} else if desc != c.desc {
+			ret = append(ret, Change{Kind: kind, Item: c.key, Change: Changed, Old: c.desc, New: desc})
+		}
+	}
+	for _, c := range new {
+		if _, ok := oldMap[c.key]; !ok {
+			ret = append(ret, Change{Kind: kind, Item: c.key, Change: Added, New: c.desc})
+		}
+	}
+	return ret
+}
+
+func diffValue(kind string, item string, old string, new string) []Change {
+	if old == new {
+		return nil
+	}
+	return []Change{{Kind: kind, Item: item, Change: Changed, Old: old, New: new}}
+}
+
+func cpuComponents(cpus []Cpu) []component {
+	var ret []component
+	for _, cpu := range cpus {
+		ret = append(ret, component{cpu.Socket, fmt.Sprintf("%s (%d cores, %d threads)", cpu.Model, cpu.Cores, cpu.Threads)})
+	}
+	return ret
+}
+
+func dimmComponents(dimms []Dimm) []component {
+	var ret []component
+	for _, dimm := range dimms {
+		ret = append(ret, component{dimm.Locator, fmt.Sprintf("%d MiB %s %s", dimm.Size, dimm.Type, dimm.Serial)})
+	}
+	return ret
+}
+
+/*
+Disks are identified by their serial, as the names of the devices may change
+between boots. The name is not compared for the same reason.
+*/
+func diskComponents(disks []Disk) []component {
+	var ret []component
+	for _, disk := range disks {
+		key := disk.Serial
+		if key == "" {
+			key = disk.Name
+		}
+		ret = append(ret, component{key, strings.Join(strings.Fields(disk.Model+" "+FormatBytes(disk.Size)), " ")})
+	}
+	return ret
+}
+
+/*
+The state of the link is not compared, it changes with the cabling and the
+switch and not with the hardware of the node
+*/
+func nicComponents(nics []Nic) []component {
+	var ret []component
+	for _, nic := range nics {
+		ret = append(ret, component{nic.Hwaddr, nic.Name})
+	}
+	return ret
+}
+
+func gpuComponents(gpus []Gpu) []component {
+	var ret []component
+	for _, gpu := range gpus {
+		ret = append(ret, component{gpu.Address, gpu.Vendor + ":" + gpu.Device})
+	}
+	return ret
+}
+
+/*
+Returns the differences between the old and the new inventory of a node, e.g.
+a DIMM or a disk which disappeared
+*/
+func Diff(old Inventory, new Inventory) []Change {
+	var ret []Change
+	ret = append(ret, diffValue("system", "serial", old.System.Serial, new.System.Serial)...)
+	ret = append(ret, diffValue("system", "uuid", old.System.UUID, new.System.UUID)...)
+	ret = append(ret, diffValue("board", "serial", old.Board.Serial, new.Board.Serial)...)
+	ret = append(ret, diffValue("bios", "version", old.Bios.Version, new.Bios.Version)...)
+	ret = append(ret, diffComponents("cpu", cpuComponents(old.Cpus), cpuComponents(new.Cpus))...)
+	// the memory the kernel reports differs slightly between kernel versions
+	ret = append(ret, diffValue("memory", "total",
+		strconv.FormatUint(old.Memory.Total/1024, 10)+" GiB", strconv.FormatUint(new.Memory.Total/1024, 10)+" GiB")...)
+	ret = append(ret, diffComponents("dimm", dimmComponents(old.Memory.Dimms), dimmComponents(new.Memory.Dimms))...)
+	ret = append(ret, diffComponents("disk", diskComponents(old.Disks), diskComponents(new.Disks))...)
+	ret = append(ret, diffComponents("nic", nicComponents(old.Nics), nicComponents(new.Nics))...)
+	ret = append(ret, diffComponents("gpu", gpuComponents(old.Gpus), gpuComponents(new.Gpus))...)
+	for i := range ret {
+		ret[i].Time = new.Collected
+	}
+	return ret
+}
+
+/*
+Formats a size in bytes with a binary unit, e.g. 480.0G
+*/
+func FormatBytes(size uint64) string {
+	units := []string{"B", "K", "M", "G", "T", "P"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", size, units[i])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[i])
+}
@@ -0,0 +1,308 @@
+package inventory
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+/*
+The hardware of a node as reported by wwclient
+*/
+type Inventory struct {
+	Collected int64  `json:"collected"`
+	System    System `json:"system"`
+	Board     Board  `json:"board"`
+	Bios      Bios   `json:"bios"`
+	Cpus      []Cpu  `json:"cpus,omitempty"`
+	Memory    Memory `json:"memory"`
+	Disks     []Disk `json:"disks,omitempty"`
+	Nics      []Nic  `json:"nics,omitempty"`
+	Gpus      []Gpu  `json:"gpus,omitempty"`
+}
+
+type System struct {
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Product      string `json:"product,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+	UUID         string `json:"uuid,omitempty"`
+	AssetTag     string `json:"asset tag,omitempty"`
+}
+
+type Board struct {
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Product      string `json:"product,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+}
+
+type Bios struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Version string `json:"version,omitempty"`
+	Date    string `json:"date,omitempty"`
+}
+
+/*
+A CPU socket, Threads is the number of logical CPUs of the socket
+*/
+type Cpu struct {
+	Socket  string `json:"socket"`
+	Model   string `json:"model,omitempty"`
+	Cores   int    `json:"cores,omitempty"`
+	Threads int    `json:"threads,omitempty"`
+}
+
+/*
+Total is the memory in MiB as seen by the kernel, the DIMMs are the populated
+memory devices of SMBIOS
+*/
+type Memory struct {
+	Total uint64 `json:"total"`
+	Dimms []Dimm `json:"dimms,omitempty"`
+}
+
+type Dimm struct {
+	Locator      string `json:"locator"`
+	Size         uint64 `json:"size"`
+	Type         string `json:"type,omitempty"`
+	Speed        string `json:"speed,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+	PartNumber   string `json:"part number,omitempty"`
+}
+
+/*
+A block device, Size is in bytes
+*/
+type Disk struct {
+	Name       string `json:"name"`
+	Model      string `json:"model,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	Size       uint64 `json:"size"`
+	Rotational bool   `json:"rotational,omitempty"`
+}
+
+/*
+A network interface, Link is the operational state (up, down, ...) and Speed
+is in Mb/s
+*/
+type Nic struct {
+	Name   string `json:"name"`
+	Hwaddr string `json:"hwaddr"`
+	Link   string `json:"link,omitempty"`
+	Speed  int    `json:"speed,omitempty"`
+	Driver string `json:"driver,omitempty"`
+}
+
+/*
+A display controller on the PCI bus, Vendor and Device are the PCI IDs
+*/
+type Gpu struct {
+	Address string `json:"address"`
+	Vendor  string `json:"vendor"`
+	Device  string `json:"device"`
+	Driver  string `json:"driver,omitempty"`
+}
+
+/*
+Collects the inventory of the local host. Missing SMBIOS data is not an
+error, as e.g. virtual machines may not provide it.
+*/
+func Collect() (Inventory, error) {
+	inv, err := collectFiles("/")
+	if err != nil {
+		return inv, err
+	}
+	err = collectSMBIOS(&inv)
+	if err != nil {
+		wwlog.Warn("Could not read SMBIOS: %s", err)
+	}
+	inv.Collected = time.Now().Unix()
+	return inv, nil
+}
+
+func readString(fileName string) string {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readInt(fileName string) int64 {
+	i, _ := strconv.ParseInt(readString(fileName), 10, 64)
+	return i
+}
+
+func linkName(fileName string) string {
+	target, err := os.Readlink(fileName)
+	if err != nil {
+		return ""
+	}
+	return path.Base(target)
+}
+
+/*
+Collects the inventory from procfs and sysfs below root
+*/
+func collectFiles(root string) (Inventory, error) {
+	var inv Inventory
+	var err error
+	inv.Cpus, err = collectCpus(path.Join(root, "proc/cpuinfo"))
+	if err != nil {
+		return inv, err
+	}
+	inv.Memory.Total = collectMemTotal(path.Join(root, "proc/meminfo"))
+	inv.Disks = collectDisks(path.Join(root, "sys/block"))
+	inv.Nics = collectNics(path.Join(root, "sys/class/net"))
+	inv.Gpus = collectGpus(path.Join(root, "sys/bus/pci/devices"))
+	return inv, nil
+}
+
+func collectCpus(fileName string) ([]Cpu, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sockets := make(map[string]*Cpu)
+	var model, socket string
+	var cores int
+	flush := func() {
+		if model == "" && socket == "" {
+			return
+		}
+		cpu, ok := sockets[socket]
+		if !ok {
+			cpu = &Cpu{Socket: socket, Model: model, Cores: cores}
+			sockets[socket] = cpu
+		}
+		cpu.Threads++
+		model, socket, cores = "", "", 0
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "model name":
+			model = value
+		case "physical id":
+			socket = value
+		case "cpu cores":
+			cores, _ = strconv.Atoi(value)
+		}
+	}
+	flush()
+
+	var ret []Cpu
+	for _, cpu := range sockets {
+		ret = append(ret, *cpu)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Socket < ret[j].Socket })
+	return ret, scanner.Err()
+}
+
+func collectMemTotal(fileName string) uint64 {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, _ := strconv.ParseUint(fields[1], 10, 64)
+			return kb / 1024
+		}
+	}
+	return 0
+}
+
+/*
+Only block devices backed by hardware are reported, which excludes loop,
+ram and device mapper devices
+*/
+func collectDisks(dir string) []Disk {
+	var ret []Disk
+	entries, _ := ioutil.ReadDir(dir)
+	for _, entry := range entries {
+		devDir := path.Join(dir, entry.Name())
+		if _, err := os.Stat(path.Join(devDir, "device")); err != nil {
+			continue
+		}
+		disk := Disk{
+			Name:       entry.Name(),
+			Model:      readString(path.Join(devDir, "device/model")),
+			Serial:     readString(path.Join(devDir, "device/serial")),
+			Size:       uint64(readInt(path.Join(devDir, "size"))) * 512,
+			Rotational: readString(path.Join(devDir, "queue/rotational")) == "1",
+		}
+		ret = append(ret, disk)
+	}
+	return ret
+}
+
+/*
+Only physical interfaces are reported, virtual ones like lo or bridges have
+no device
+*/
+func collectNics(dir string) []Nic {
+	var ret []Nic
+	entries, _ := ioutil.ReadDir(dir)
+	for _, entry := range entries {
+		devDir := path.Join(dir, entry.Name())
+		if _, err := os.Stat(path.Join(devDir, "device")); err != nil {
+			continue
+		}
+		nic := Nic{
+			Name:   entry.Name(),
+			Hwaddr: strings.ToLower(readString(path.Join(devDir, "address"))),
+			Link:   readString(path.Join(devDir, "operstate")),
+			Driver: linkName(path.Join(devDir, "device/driver")),
+		}
+		// speed is -1 or can't be read if the link is down
+		if speed := readInt(path.Join(devDir, "speed")); speed > 0 {
+			nic.Speed = int(speed)
+		}
+		ret = append(ret, nic)
+	}
+	return ret
+}
+
+/*
+PCI devices of the display controller class 0x03
+*/
+func collectGpus(dir string) []Gpu {
+	var ret []Gpu
+	entries, _ := ioutil.ReadDir(dir)
+	for _, entry := range entries {
+		devDir := path.Join(dir, entry.Name())
+		if !strings.HasPrefix(readString(path.Join(devDir, "class")), "0x03") {
+			continue
+		}
+		ret = append(ret, Gpu{
+			Address: entry.Name(),
+			Vendor:  readString(path.Join(devDir, "vendor")),
+			Device:  readString(path.Join(devDir, "device")),
+			Driver:  linkName(path.Join(devDir, "driver")),
+		})
+	}
+	return ret
+}
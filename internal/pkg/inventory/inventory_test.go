@@ -0,0 +1,181 @@
+package inventory
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const cpuinfo = `processor	: 0
+model name	: Intel(R) Xeon(R) Gold 6230 CPU @ 2.10GHz
+physical id	: 0
+cpu cores	: 2
+
+processor	: 1
+model name	: Intel(R) Xeon(R) Gold 6230 CPU @ 2.10GHz
+physical id	: 0
+cpu cores	: 2
+
+processor	: 2
+model name	: Intel(R) Xeon(R) Gold 6230 CPU @ 2.10GHz
+physical id	: 1
+cpu cores	: 2
+`
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		fileName := path.Join(root, name)
+		assert.NoError(t, os.MkdirAll(path.Dir(fileName), 0755))
+		assert.NoError(t, ioutil.WriteFile(fileName, []byte(content), 0644))
+	}
+}
+
+func Test_collectFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/cpuinfo":                            cpuinfo,
+		"proc/meminfo":                            "MemTotal:       196608000 kB\nMemFree:        1000 kB\n",
+		"sys/block/nvme0n1/device/model":          "Samsung SSD 970\n",
+		"sys/block/nvme0n1/device/serial":         "S1234\n",
+		"sys/block/nvme0n1/size":                  "1000215216\n",
+		"sys/block/nvme0n1/queue/rotational":      "0\n",
+		"sys/block/loop0/size":                    "100\n",
+		"sys/class/net/eth0/device/vendor":        "0x8086\n",
+		"sys/class/net/eth0/address":              "00:11:22:AA:BB:CC\n",
+		"sys/class/net/eth0/operstate":            "up\n",
+		"sys/class/net/eth0/speed":                "25000\n",
+		"sys/class/net/eth1/device/vendor":        "0x8086\n",
+		"sys/class/net/eth1/address":              "00:11:22:aa:bb:cd\n",
+		"sys/class/net/eth1/operstate":            "down\n",
+		"sys/class/net/eth1/speed":                "-1\n",
+		"sys/class/net/lo/address":                "00:00:00:00:00:00\n",
+		"sys/bus/pci/devices/0000:3b:00.0/class":  "0x030200\n",
+		"sys/bus/pci/devices/0000:3b:00.0/vendor": "0x10de\n",
+		"sys/bus/pci/devices/0000:3b:00.0/device": "0x1eb8\n",
+		"sys/bus/pci/devices/0000:00:1f.0/class":  "0x060100\n",
+		"sys/bus/pci/devices/0000:00:1f.0/vendor": "0x8086\n",
+		"sys/bus/pci/devices/0000:00:1f.0/device": "0xa1c1\n",
+	})
+	assert.NoError(t, os.Symlink("../../../bus/pci/drivers/nvidia", path.Join(root, "sys/bus/pci/devices/0000:3b:00.0/driver")))
+
+	inv, err := collectFiles(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []Cpu{
+		{Socket: "0", Model: "Intel(R) Xeon(R) Gold 6230 CPU @ 2.10GHz", Cores: 2, Threads: 2},
+		{Socket: "1", Model: "Intel(R) Xeon(R) Gold 6230 CPU @ 2.10GHz", Cores: 2, Threads: 1},
+	}, inv.Cpus)
+	assert.Equal(t, uint64(192000), inv.Memory.Total)
+	assert.Equal(t, []Disk{{Name: "nvme0n1", Model: "Samsung SSD 970", Serial: "S1234", Size: 512110190592}}, inv.Disks)
+	assert.Equal(t, []Nic{
+		{Name: "eth0", Hwaddr: "00:11:22:aa:bb:cc", Link: "up", Speed: 25000},
+		{Name: "eth1", Hwaddr: "00:11:22:aa:bb:cd", Link: "down"},
+	}, inv.Nics)
+	assert.Equal(t, []Gpu{{Address: "0000:3b:00.0", Vendor: "0x10de", Device: "0x1eb8", Driver: "nvidia"}}, inv.Gpus)
+}
+
+func testInventory() Inventory {
+	return Inventory{
+		Collected: 100,
+		System:    System{Serial: "SN1"},
+		Memory: Memory{Total: 16384, Dimms: []Dimm{
+			{Locator: "DIMM_A1", Size: 8192, Type: "DDR4", Serial: "D1"},
+			{Locator: "DIMM_B1", Size: 8192, Type: "DDR4", Serial: "D2"},
+		}},
+		Disks: []Disk{{Name: "sda", Serial: "S1", Size: 1024}, {Name: "sdb", Serial: "S2", Size: 1024}},
+		Nics:  []Nic{{Name: "eth0", Hwaddr: "00:11:22:33:44:55", Link: "up"}},
+	}
+}
+
+func Test_Diff(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(inv *Inventory)
+		expected []Change
+	}{
+		{
+			name:   "unchanged",
+			modify: func(inv *Inventory) {},
+		},
+		{
+			name: "dimm removed",
+			modify: func(inv *Inventory) {
+				inv.Memory.Total = 8150
+				inv.Memory.Dimms = inv.Memory.Dimms[:1]
+			},
+			expected: []Change{
+				{Time: 200, Kind: "memory", Item: "total", Change: Changed, Old: "16 GiB", New: "7 GiB"},
+				{Time: 200, Kind: "dimm", Item: "DIMM_B1", Change: Removed, Old: "8192 MiB DDR4 D2"},
+			},
+		},
+		{
+			name: "disk renamed",
+			modify: func(inv *Inventory) {
+				inv.Disks = []Disk{{Name: "sdb", Serial: "S1", Size: 1024}, {Name: "sda", Serial: "S2", Size: 1024}}
+			},
+		},
+		{
+			name: "disk replaced",
+			modify: func(inv *Inventory) {
+				inv.Disks = []Disk{{Name: "sda", Serial: "S1", Size: 1024}, {Name: "sdb", Serial: "S3", Size: 2048}}
+			},
+			expected: []Change{
+				{Time: 200, Kind: "disk", Item: "S2", Change: Removed, Old: "1.0K"},
+				{Time: 200, Kind: "disk", Item: "S3", Change: Added, New: "2.0K"},
+			},
+		},
+		{
+			name: "link down",
+			modify: func(inv *Inventory) {
+				inv.Nics[0].Link = "down"
+			},
+		},
+		{
+			name: "nic replaced",
+			modify: func(inv *Inventory) {
+				inv.Nics[0].Hwaddr = "00:11:22:33:44:66"
+			},
+			expected: []Change{
+				{Time: 200, Kind: "nic", Item: "00:11:22:33:44:55", Change: Removed, Old: "eth0"},
+				{Time: 200, Kind: "nic", Item: "00:11:22:33:44:66", Change: Added, New: "eth0"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			new := testInventory()
+			new.Collected = 200
+			tt.modify(&new)
+			assert.Equal(t, tt.expected, Diff(testInventory(), new))
+		})
+	}
+}
+
+func Test_Save(t *testing.T) {
+	saved := Dir
+	Dir = path.Join(t.TempDir(), "inventory")
+	defer func() { Dir = saved }()
+
+	changes, err := Save("n1", testInventory(), 100)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	inv := testInventory()
+	inv.Collected = 0
+	inv.Disks = inv.Disks[1:]
+	changes, err = Save("n1", inv, 200)
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{{Time: 200, Kind: "disk", Item: "S1", Change: Removed, Old: "1.0K"}}, changes)
+
+	record, err := Load("n1")
+	assert.NoError(t, err)
+	assert.Equal(t, "n1", record.Node)
+	assert.Equal(t, int64(200), record.Updated)
+	assert.Equal(t, inv, record.Inventory)
+	assert.Equal(t, changes, record.Changes)
+
+	_, err = Load("n2")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,100 @@
+package inventory
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/talos-systems/go-smbios/smbios"
+)
+
+// LPDDR5, the last memory type known to the smbios library
+const maxMemoryType = 0x23
+
+func smbiosString(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "Unknown" || strings.EqualFold(s, "Not Specified") {
+		return ""
+	}
+	return s
+}
+
+/*
+Size of a memory device in MiB, 0 if the slot is empty
+*/
+func dimmSize(dev smbios.MemoryDeviceStructure) uint64 {
+	if len(dev.Formatted) < 10 {
+		return 0
+	}
+	size := dev.Size()
+	switch {
+	case size == 0xFFFF:
+		return 0
+	case size == 0x7FFF && len(dev.Formatted) >= 28:
+		// the extended size is in MiB
+		return uint64(binary.LittleEndian.Uint32(dev.Formatted[24:28]) & 0x7FFFFFFF)
+	case size&0x8000 != 0:
+		return uint64(size&0x7FFF) / 1024
+	}
+	return uint64(size)
+}
+
+/*
+Adds the system, board, BIOS and memory device information of SMBIOS
+*/
+func collectSMBIOS(inv *Inventory) error {
+	dump, err := smbios.New()
+	if err != nil {
+		return err
+	}
+	sys := dump.SystemInformation()
+	inv.System = System{
+		Manufacturer: smbiosString(sys.Manufacturer()),
+		Product:      smbiosString(sys.ProductName()),
+		Serial:       smbiosString(sys.SerialNumber()),
+		AssetTag:     smbiosString(dump.SystemEnclosure().AssetTagNumber()),
+	}
+	if uuid, err := sys.UUID(); err == nil {
+		inv.System.UUID = uuid.String()
+	}
+	board := dump.BaseboardInformation()
+	inv.Board = Board{
+		Manufacturer: smbiosString(board.Manufacturer()),
+		Product:      smbiosString(board.Product()),
+		Serial:       smbiosString(board.SerialNumber()),
+	}
+	bios := dump.BIOSInformation()
+	inv.Bios = Bios{
+		Vendor:  smbiosString(bios.Vendor()),
+		Version: smbiosString(bios.Version()),
+		Date:    smbiosString(bios.ReleaseDate()),
+	}
+
+	// the library only keeps the last memory device, so all structures of
+	// type 17 are read here
+	for _, s := range dump.Structures {
+		if s.Header.Type != 17 {
+			continue
+		}
+		dev := smbios.MemoryDeviceStructure{Structure: s}
+		size := dimmSize(dev)
+		if size == 0 {
+			continue
+		}
+		dimm := Dimm{
+			Locator:      smbiosString(dev.Locator()),
+			Size:         size,
+			Manufacturer: smbiosString(dev.Manufacturer()),
+			Serial:       smbiosString(dev.SerialNumber()),
+			PartNumber:   smbiosString(dev.PartNumber()),
+		}
+		// MemoryType.String() panics on types newer than the library
+		if len(dev.Formatted) >= 15 && dev.MemoryType() <= maxMemoryType {
+			dimm.Type = smbiosString(dev.MemoryType().String())
+		}
+		if len(dev.Formatted) >= 19 {
+			dimm.Speed = dev.Speed().String()
+		}
+		inv.Memory.Dimms = append(inv.Memory.Dimms, dimm)
+	}
+	return nil
+}
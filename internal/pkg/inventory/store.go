@@ -0,0 +1,90 @@
+package inventory
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+)
+
+/*
+Directory with the inventories of the nodes, one json file per node
+*/
+var Dir string
+
+// number of changes which are kept per node
+const maxChanges = 100
+
+func init() {
+	if Dir == "" {
+		Dir = path.Join(buildconfig.LOCALSTATEDIR(), "warewulf/inventory")
+	}
+}
+
+/*
+The stored inventory of a node with the changes which were detected when
+new inventories were reported, the oldest change first
+*/
+type Record struct {
+	Node      string    `json:"node"`
+	Updated   int64     `json:"updated"`
+	Inventory Inventory `json:"inventory"`
+	Changes   []Change  `json:"changes,omitempty"`
+}
+
+func recordFile(nodeName string) string {
+	return path.Join(Dir, nodeName+".json")
+}
+
+/*
+Loads the stored inventory of a node
+*/
+func Load(nodeName string) (Record, error) {
+	var ret Record
+	data, err := ioutil.ReadFile(recordFile(nodeName))
+	if err != nil {
+		return ret, err
+	}
+	err = json.Unmarshal(data, &ret)
+	return ret, err
+}
+
+/*
+Stores the inventory of a node and returns the changes to the previous one.
+The first inventory of a node has no changes.
+*/
+func Save(nodeName string, inv Inventory, now int64) ([]Change, error) {
+	record, err := Load(nodeName)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var changes []Change
+	if err == nil {
+		changes = Diff(record.Inventory, inv)
+		for i := range changes {
+			if changes[i].Time == 0 {
+				changes[i].Time = now
+			}
+		}
+	}
+	record.Node = nodeName
+	record.Updated = now
+	record.Inventory = inv
+	record.Changes = append(record.Changes, changes...)
+	if len(record.Changes) > maxChanges {
+		record.Changes = record.Changes[len(record.Changes)-maxChanges:]
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return changes, util.WriteFileAtomic(recordFile(nodeName), data, 0644)
+}
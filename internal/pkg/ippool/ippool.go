@@ -0,0 +1,256 @@
+package ippool
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+)
+
+const (
+	AllocNext  = "next"
+	AllocIndex = "index"
+)
+
+var hostIndexRegexp = regexp.MustCompile(`([0-9]+)$`)
+
+/*
+Inclusive range of IPv4 addresses
+*/
+type Range struct {
+	Start uint32
+	End   uint32
+}
+
+/*
+A network from which addresses are allocated, only IPv4 is supported
+*/
+type Pool struct {
+	Name       string
+	Subnet     *net.IPNet
+	Gateway    net.IP
+	Reserved   []Range
+	Allocation string
+	Base       net.IP
+}
+
+/*
+Creates the pool for a network of warewulf.conf
+*/
+func New(name string, conf *warewulfconf.NetworkConf) (*Pool, error) {
+	ip, subnet, err := net.ParseCIDR(conf.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("network %s: invalid subnet: %s", name, conf.Subnet)
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("network %s: only IPv4 subnets are supported", name)
+	}
+	pool := &Pool{
+		Name:       name,
+		Subnet:     subnet,
+		Allocation: conf.Allocation,
+		Base:       subnet.IP.To4(),
+	}
+	if pool.Allocation == "" {
+		pool.Allocation = AllocNext
+	}
+	if pool.Allocation != AllocNext && pool.Allocation != AllocIndex {
+		return nil, fmt.Errorf("network %s: unknown allocation: %s", name, conf.Allocation)
+	}
+	if conf.Gateway != "" {
+		pool.Gateway = net.ParseIP(conf.Gateway).To4()
+		if pool.Gateway == nil || !subnet.Contains(pool.Gateway) {
+			return nil, fmt.Errorf("network %s: invalid gateway: %s", name, conf.Gateway)
+		}
+	}
+	if conf.Base != "" {
+		pool.Base = net.ParseIP(conf.Base).To4()
+		if pool.Base == nil || !subnet.Contains(pool.Base) {
+			return nil, fmt.Errorf("network %s: invalid base: %s", name, conf.Base)
+		}
+	}
+	for _, r := range conf.Reserved {
+		reserved, err := parseRange(r)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: %s", name, err)
+		}
+		pool.Reserved = append(pool.Reserved, reserved)
+	}
+	return pool, nil
+}
+
+/*
+Creates the pools of all networks of warewulf.conf
+*/
+func FromConf(conf warewulfconf.ControllerConf) (map[string]*Pool, error) {
+	ret := make(map[string]*Pool)
+	for name, n := range conf.Networks {
+		if n == nil {
+			continue
+		}
+		pool, err := New(name, n)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = pool
+	}
+	return ret, nil
+}
+
+/*
+Parses a single address, a range first-last or a subnet
+*/
+func parseRange(r string) (Range, error) {
+	r = strings.TrimSpace(r)
+	if strings.Contains(r, "/") {
+		_, subnet, err := net.ParseCIDR(r)
+		if err != nil || subnet.IP.To4() == nil {
+			return Range{}, fmt.Errorf("invalid reserved subnet: %s", r)
+		}
+		start := toUint(subnet.IP)
+		ones, bits := subnet.Mask.Size()
+		return Range{Start: start, End: start + uint32(1)<<uint(bits-ones) - 1}, nil
+	}
+	parts := strings.SplitN(r, "-", 2)
+	start := net.ParseIP(strings.TrimSpace(parts[0])).To4()
+	end := start
+	if len(parts) == 2 {
+		end = net.ParseIP(strings.TrimSpace(parts[1])).To4()
+	}
+	if start == nil || end == nil || toUint(end) < toUint(start) {
+		return Range{}, fmt.Errorf("invalid reserved range: %s", r)
+	}
+	return Range{Start: toUint(start), End: toUint(end)}, nil
+}
+
+/*
+Returns the dynamic range of the dhcp server of warewulf.conf, false if dhcp
+is disabled or the range is invalid
+*/
+func DhcpRange(conf warewulfconf.ControllerConf) (Range, bool) {
+	if conf.Dhcp == nil || !conf.Dhcp.Enabled || conf.Dhcp.RangeStart == "" {
+		return Range{}, false
+	}
+	start := net.ParseIP(conf.Dhcp.RangeStart).To4()
+	end := net.ParseIP(conf.Dhcp.RangeEnd).To4()
+	if start == nil || end == nil || toUint(end) < toUint(start) {
+		return Range{}, false
+	}
+	return Range{Start: toUint(start), End: toUint(end)}, true
+}
+
+/*
+true if the address is within the range
+*/
+func (r Range) Contains(ip net.IP) bool {
+	ip = ip.To4()
+	if ip == nil {
+		return false
+	}
+	i := toUint(ip)
+	return i >= r.Start && i <= r.End
+}
+
+/*
+The addresses of the range in dotted notation
+*/
+func (r Range) Addresses() []string {
+	var ret []string
+	for i := r.Start; i <= r.End && i >= r.Start; i++ {
+		ret = append(ret, toIP(i).String())
+	}
+	return ret
+}
+
+func toUint(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func toIP(i uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, i)
+	return ip
+}
+
+/*
+Netmask of the subnet in dotted notation
+*/
+func (pool *Pool) Netmask() string {
+	return net.IP(pool.Subnet.Mask).String()
+}
+
+/*
+Returns the reason why ip can't be assigned to a node, or an empty string if
+it can be used
+*/
+func (pool *Pool) Check(ip net.IP) string {
+	ip = ip.To4()
+	if ip == nil || !pool.Subnet.Contains(ip) {
+		return fmt.Sprintf("not in subnet %s of network %s", pool.Subnet, pool.Name)
+	}
+	i := toUint(ip)
+	network := toUint(pool.Subnet.IP)
+	ones, bits := pool.Subnet.Mask.Size()
+	if bits-ones > 1 && (i == network || i == network+uint32(1)<<uint(bits-ones)-1) {
+		return fmt.Sprintf("network or broadcast address of network %s", pool.Name)
+	}
+	if pool.Gateway != nil && ip.Equal(pool.Gateway) {
+		return fmt.Sprintf("gateway of network %s", pool.Name)
+	}
+	for _, r := range pool.Reserved {
+		if i >= r.Start && i <= r.End {
+			return fmt.Sprintf("reserved in network %s", pool.Name)
+		}
+	}
+	return ""
+}
+
+/*
+Returns the number at the end of the first label of a node name, e.g. 42 for
+n0042 or rack1-n0042.cluster
+*/
+func HostIndex(nodeName string) (int, bool) {
+	m := hostIndexRegexp.FindString(strings.SplitN(nodeName, ".", 2)[0])
+	if m == "" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(m)
+	return i, err == nil
+}
+
+/*
+Returns an address for the node which is not used yet. With index allocation
+the address is derived from the node name, otherwise the lowest free address
+is returned.
+*/
+func (pool *Pool) Allocate(nodeName string, used map[string]bool) (net.IP, error) {
+	if pool.Allocation == AllocIndex {
+		index, ok := HostIndex(nodeName)
+		if !ok {
+			return nil, fmt.Errorf("network %s: node name %s has no index", pool.Name, nodeName)
+		}
+		ip := toIP(toUint(pool.Base) + uint32(index))
+		if reason := pool.Check(ip); reason != "" {
+			return nil, fmt.Errorf("address %s of %s is %s", ip, nodeName, reason)
+		}
+		if used[ip.String()] {
+			return nil, fmt.Errorf("address %s of %s is already in use", ip, nodeName)
+		}
+		return ip, nil
+	}
+
+	start := toUint(pool.Subnet.IP)
+	ones, bits := pool.Subnet.Mask.Size()
+	end := start + uint32(1)<<uint(bits-ones) - 1
+	for i := start; i <= end && i >= start; i++ {
+		ip := toIP(i)
+		if pool.Check(ip) == "" && !used[ip.String()] {
+			return ip, nil
+		}
+	}
+	return nil, fmt.Errorf("network %s has no free addresses", pool.Name)
+}
@@ -0,0 +1,113 @@
+package ippool
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+)
+
+func Test_New(t *testing.T) {
+	tests := []struct {
+		name string
+		conf warewulfconf.NetworkConf
+		err  bool
+	}{
+		{"valid", warewulfconf.NetworkConf{Subnet: "10.0.0.0/24", Gateway: "10.0.0.1",
+			Reserved: []string{"10.0.0.2", "10.0.0.10-10.0.0.20", "10.0.0.128/25"}}, false},
+		{"no subnet", warewulfconf.NetworkConf{}, true},
+		{"ipv6", warewulfconf.NetworkConf{Subnet: "fd00::/64"}, true},
+		{"gateway outside", warewulfconf.NetworkConf{Subnet: "10.0.0.0/24", Gateway: "10.0.1.1"}, true},
+		{"bad range", warewulfconf.NetworkConf{Subnet: "10.0.0.0/24", Reserved: []string{"10.0.0.20-10.0.0.10"}}, true},
+		{"bad allocation", warewulfconf.NetworkConf{Subnet: "10.0.0.0/24", Allocation: "random"}, true},
+	}
+	for _, tt := range tests {
+		conf := tt.conf
+		_, err := New(tt.name, &conf)
+		assert.Equal(t, tt.err, err != nil, tt.name)
+	}
+}
+
+func Test_AllocateNext(t *testing.T) {
+	pool, err := New("compute", &warewulfconf.NetworkConf{
+		Subnet:   "10.0.0.0/29",
+		Gateway:  "10.0.0.1",
+		Reserved: []string{"10.0.0.3-10.0.0.4"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "255.255.255.248", pool.Netmask())
+
+	used := map[string]bool{"10.0.0.2": true}
+	var got []string
+	for {
+		ip, err := pool.Allocate("n1", used)
+		if err != nil {
+			break
+		}
+		got = append(got, ip.String())
+		used[ip.String()] = true
+	}
+	assert.Equal(t, []string{"10.0.0.5", "10.0.0.6"}, got)
+}
+
+func Test_AllocateIndex(t *testing.T) {
+	pool, err := New("compute", &warewulfconf.NetworkConf{
+		Subnet:     "10.0.0.0/24",
+		Allocation: AllocIndex,
+		Base:       "10.0.0.100",
+		Reserved:   []string{"10.0.0.150"},
+	})
+	assert.NoError(t, err)
+
+	ip, err := pool.Allocate("n0042", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.142", ip.String())
+
+	ip, err = pool.Allocate("rack1-n0007.cluster", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.107", ip.String())
+
+	_, err = pool.Allocate("n0042", map[string]bool{"10.0.0.142": true})
+	assert.Error(t, err)
+	_, err = pool.Allocate("n0050", nil)
+	assert.Error(t, err)
+	_, err = pool.Allocate("n0200", nil)
+	assert.Error(t, err)
+	_, err = pool.Allocate("master", nil)
+	assert.Error(t, err)
+}
+
+func Test_Check(t *testing.T) {
+	pool, err := New("compute", &warewulfconf.NetworkConf{Subnet: "10.0.0.0/24", Gateway: "10.0.0.254"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", pool.Check(net.ParseIP("10.0.0.5")))
+	assert.NotEqual(t, "", pool.Check(net.ParseIP("10.0.1.5")))
+	assert.NotEqual(t, "", pool.Check(net.ParseIP("10.0.0.0")))
+	assert.NotEqual(t, "", pool.Check(net.ParseIP("10.0.0.255")))
+	assert.NotEqual(t, "", pool.Check(net.ParseIP("10.0.0.254")))
+}
+
+func Test_DhcpRange(t *testing.T) {
+	tests := []struct {
+		name string
+		dhcp *warewulfconf.DhcpConf
+		ok   bool
+	}{
+		{"none", nil, false},
+		{"disabled", &warewulfconf.DhcpConf{RangeStart: "10.0.0.50", RangeEnd: "10.0.0.52"}, false},
+		{"valid", &warewulfconf.DhcpConf{Enabled: true, RangeStart: "10.0.0.50", RangeEnd: "10.0.0.52"}, true},
+		{"reversed", &warewulfconf.DhcpConf{Enabled: true, RangeStart: "10.0.0.52", RangeEnd: "10.0.0.50"}, false},
+		{"invalid", &warewulfconf.DhcpConf{Enabled: true, RangeStart: "10.0.0.50", RangeEnd: "end"}, false},
+	}
+	for _, tt := range tests {
+		r, ok := DhcpRange(warewulfconf.ControllerConf{Dhcp: tt.dhcp})
+		assert.Equal(t, tt.ok, ok, tt.name)
+		if ok {
+			assert.Equal(t, []string{"10.0.0.50", "10.0.0.51", "10.0.0.52"}, r.Addresses(), tt.name)
+			assert.True(t, r.Contains(net.ParseIP("10.0.0.51")), tt.name)
+			assert.False(t, r.Contains(net.ParseIP("10.0.0.53")), tt.name)
+		}
+	}
+}
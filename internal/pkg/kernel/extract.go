@@ -0,0 +1,160 @@
+package kernel
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+	"github.com/pkg/errors"
+
+	"github.com/hpcng/warewulf/internal/pkg/oci"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+/*
+Paths of an image or package which are needed to build a kernel
+*/
+var kernelPaths = []string{
+	"boot",
+	"lib/modules",
+	"lib/firmware",
+	"usr/lib/modules",
+	"usr/lib/firmware",
+}
+
+/*
+Extract a kernel rpm or deb package into root
+*/
+func ExtractPackage(pkg string, root string) error {
+	if !util.IsFile(pkg) {
+		return errors.New("package does not exist: " + pkg)
+	}
+	pkg, err := filepath.Abs(pkg)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case strings.HasSuffix(pkg, ".rpm"):
+		wwlog.Verbose("Extracting rpm: %s", pkg)
+		err = pipe(root, exec.Command("rpm2cpio", pkg), exec.Command("cpio", "--quiet", "-idm"))
+	case strings.HasSuffix(pkg, ".deb"):
+		wwlog.Verbose("Extracting deb: %s", pkg)
+		err = extractDeb(pkg, root)
+	default:
+		return errors.New("unknown package format, must be .rpm or .deb: " + pkg)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not extract %s", pkg)
+	}
+
+	return mergeUsr(root)
+}
+
+func extractDeb(pkg string, root string) error {
+	if _, err := exec.LookPath("dpkg-deb"); err == nil {
+		out, err := exec.Command("dpkg-deb", "-x", pkg, root).CombinedOutput()
+		if err != nil {
+			return errors.Wrap(err, string(out))
+		}
+		return nil
+	}
+
+	// without dpkg a deb is just an ar archive with a data.tar.* member
+	tmpDir, err := ioutil.TempDir("", "wwkernel-deb-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	cmd := exec.Command("ar", "x", pkg)
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrap(err, string(out))
+	}
+	data, _ := filepath.Glob(path.Join(tmpDir, "data.tar*"))
+	if len(data) == 0 {
+		return errors.New("no data archive in deb")
+	}
+	out, err := exec.Command("tar", "-xf", data[0], "-C", root).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, string(out))
+	}
+	return nil
+}
+
+/*
+Run the commands as pipeline in the directory dir
+*/
+func pipe(dir string, source *exec.Cmd, sink *exec.Cmd) error {
+	sink.Dir = dir
+	out, err := source.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	sink.Stdin = out
+	var sinkOut strings.Builder
+	sink.Stdout = &sinkOut
+	sink.Stderr = &sinkOut
+	if err := sink.Start(); err != nil {
+		return err
+	}
+	if err := source.Run(); err != nil {
+		_ = sink.Wait()
+		return errors.Wrap(err, source.Path)
+	}
+	if err := sink.Wait(); err != nil {
+		return errors.Wrap(err, sinkOut.String())
+	}
+	return nil
+}
+
+/*
+Extract only the kernel, modules and firmware of an oci image into root
+*/
+func ExtractOci(uri string, root string, blobCache string, sCtx *types.SystemContext) error {
+	p, err := oci.NewPuller(
+		oci.OptSetBlobCachePath(blobCache),
+		oci.OptSetSystemContext(sCtx),
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.GenerateID(context.Background(), uri); err != nil {
+		return err
+	}
+
+	if err := p.Extract(context.Background(), uri, root, kernelPaths); err != nil {
+		return err
+	}
+
+	return mergeUsr(root)
+}
+
+/*
+Distributions with a merged /usr install modules and firmware under /usr/lib,
+but Build expects them under /lib
+*/
+func mergeUsr(root string) error {
+	for _, dir := range []string{"modules", "firmware"} {
+		usrDir := path.Join(root, "usr/lib", dir)
+		libDir := path.Join(root, "lib", dir)
+		if !util.IsDir(usrDir) || util.IsDir(libDir) {
+			continue
+		}
+		if err := os.MkdirAll(path.Dir(libDir), 0755); err != nil {
+			return err
+		}
+		wwlog.Debug("Moving %s to %s", usrDir, libDir)
+		if err := os.Rename(usrDir, libDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -86,6 +86,46 @@ func KernelVersionFile(kernelName string) string {
 	return path.Join(KernelImageTopDir(), kernelName, "version")
 }
 
+func KernelArchFile(kernelName string) string {
+	if kernelName == "" {
+		wwlog.Error("Kernel Name is not defined")
+		return ""
+	}
+
+	if !util.ValidString(kernelName, "^[a-zA-Z0-9-._]+$") {
+		wwlog.Error("Runtime overlay name contains illegal characters: %s", kernelName)
+		return ""
+	}
+
+	return path.Join(KernelImageTopDir(), kernelName, "arch")
+}
+
+/*
+Returns the architecture the kernel was imported for, empty if unknown
+*/
+func GetKernelArch(kernelName string) string {
+	arch, err := ioutil.ReadFile(KernelArchFile(kernelName))
+	if err != nil {
+		return ""
+	}
+	return util.NormalizeArch(string(arch))
+}
+
+/*
+Returns an error if the kernel was imported for another architecture than
+arch. Unknown architectures always match.
+*/
+func CheckArch(kernelName string, arch string) error {
+	kernelArch := GetKernelArch(kernelName)
+	if arch == "" || kernelArch == "" {
+		return nil
+	}
+	if util.NormalizeArch(arch) != kernelArch {
+		return fmt.Errorf("kernel %s has architecture %s, not %s", kernelName, kernelArch, arch)
+	}
+	return nil
+}
+
 func ListKernels() ([]string, error) {
 	var ret []string
 
@@ -111,7 +151,11 @@ func ListKernels() ([]string, error) {
 	return ret, nil
 }
 
-func Build(kernelVersion, kernelName, root string) (string, error) {
+/*
+Build the kernel and kmods image from root, if policy is not empty only the
+selected modules and firmware are packed into the kmods image
+*/
+func Build(kernelVersion, kernelName, root string, policy *ModulePolicy) (string, error) {
 	kernelDriversRelative := path.Join("/lib/modules/", kernelVersion)
 	kernelDrivers := path.Join(root, kernelDriversRelative)
 	kernelDestination := KernelImage(kernelName)
@@ -188,7 +232,22 @@ func Build(kernelVersion, kernelName, root string) (string, error) {
 
 	}
 
-	if _, err := os.Stat(kernelDrivers); err == nil {
+	if _, err := os.Stat(kernelDrivers); err == nil && !policy.Empty() {
+		wwlog.Verbose("Creating image for %s drivers with module policy: %s", kernelName, root)
+		files, err := policy.Files(root, kernelVersion)
+		if err != nil {
+			return "", errors.Wrap(err, "could not apply module policy")
+		}
+		err = buildKmodsImage(root, driversDestination, files)
+		if err != nil {
+			return "", err
+		}
+		err = policy.Write(ModulePolicyFile(kernelName))
+		if err != nil {
+			return "", errors.Wrap(err, "could not write module policy")
+		}
+	} else if err == nil {
+		_ = os.Remove(ModulePolicyFile(kernelName))
 		name := kernelName + " drivers"
 		wwlog.Verbose("Creating image for %s: %s", name, root)
 
@@ -225,9 +284,71 @@ func Build(kernelVersion, kernelName, root string) (string, error) {
 	if err != nil {
 		return "", errors.Wrap(err, "Could not sync kernel version")
 	}
+
+	if arch := findArch(root, kernelDrivers); arch != "" {
+		wwlog.Verbose("Creating arch file: %s", arch)
+		err = ioutil.WriteFile(KernelArchFile(kernelName), []byte(arch+"\n"), 0644)
+		if err != nil {
+			return "", errors.Wrap(err, "Could not write kernel arch")
+		}
+	} else {
+		wwlog.Warn("Could not detect the architecture of %s", root)
+	}
 	return "Done", nil
 }
 
+/*
+Pack the given files (relative to root) into the kmods image
+*/
+func buildKmodsImage(root string, imagePath string, files []string) (err error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = util.FirstError(err, os.Chdir(cwd))
+	}()
+
+	err = os.Chdir(root)
+	if err != nil {
+		return errors.Wrapf(err, "failed chdir to %s", root)
+	}
+
+	// dereference symbolic links
+	err = util.CpioCreate(files, imagePath, "newc", "-L")
+	if err != nil {
+		return errors.Wrapf(err, "failed creating image: %s", imagePath)
+	}
+	wwlog.Info("Created image for %s: %s", root, imagePath)
+
+	err = util.FileGz(imagePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to compress image: %s", imagePath+".gz")
+	}
+	return nil
+}
+
+/*
+Architecture of the root, packages and images only containing a kernel have
+no binaries, so the modules are checked as well
+*/
+func findArch(root string, kernelDrivers string) string {
+	if arch := util.FindArch(root); arch != "" {
+		return arch
+	}
+	var arch string
+	_ = filepath.Walk(kernelDrivers, func(path string, info os.FileInfo, err error) error {
+		if err != nil || arch != "" {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() && filepath.Ext(path) == ".ko" {
+			arch, _ = util.ElfArch(path)
+		}
+		return nil
+	})
+	return arch
+}
+
 func DeleteKernel(name string) error {
 	fullPath := path.Join(KernelImageTopDir(), name)
 
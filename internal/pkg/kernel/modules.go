@@ -0,0 +1,356 @@
+package kernel
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+/*
+ModulePolicy selects the modules which are packed into the kmods image of a
+kernel. Dependencies of the selected modules and the firmware they need are
+added automatically. An empty policy selects all modules and firmware.
+*/
+type ModulePolicy struct {
+	// module names, shell globs are allowed (e.g. mlx5_*)
+	Include []string `yaml:"include,omitempty"`
+	// driver classes as path below kernel/ (e.g. drivers/net/ethernet/intel, fs/xfs)
+	Classes []string `yaml:"classes,omitempty"`
+	// modalias strings of the hardware, as found in /sys/devices/**/modalias
+	Modaliases []string `yaml:"modaliases,omitempty"`
+	// module names or paths below kernel/ which are never packed
+	Exclude []string `yaml:"exclude,omitempty"`
+	// additional firmware files below /lib/firmware, globs are allowed
+	Firmware []string `yaml:"firmware,omitempty"`
+}
+
+/*
+Files of /lib/modules/VERSION which are needed by modprobe and udev
+*/
+var moduleMetadata = []string{
+	"modules.order",
+	"modules.builtin",
+	"modules.builtin.modinfo",
+	"modules.dep",
+	"modules.dep.bin",
+	"modules.alias",
+	"modules.alias.bin",
+	"modules.softdep",
+	"modules.symbols",
+	"modules.symbols.bin",
+	"modules.builtin.bin",
+	"modules.devname",
+}
+
+/*
+Returns the firmware files a module needs, can be replaced for tests
+*/
+var moduleFirmware = func(modulePath string) ([]string, error) {
+	out, err := exec.Command("modinfo", "-F", "firmware", modulePath).Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+func ModulePolicyFile(kernelName string) string {
+	return path.Join(KernelImageTopDir(), kernelName, "modules-policy.yaml")
+}
+
+/*
+Read a module policy from a yaml file
+*/
+func ReadModulePolicy(fileName string) (*ModulePolicy, error) {
+	var policy ModulePolicy
+	buffer, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(buffer, &policy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse module policy %s", fileName)
+	}
+	return &policy, nil
+}
+
+/*
+Read a hardware profile, which are the modalias lines of a node, e.g. created
+with: find /sys/devices -name modalias -exec cat {} +
+*/
+func ReadHardwareProfile(fileName string) ([]string, error) {
+	lines, err := util.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "#") {
+			ret = append(ret, l)
+		}
+	}
+	return ret, nil
+}
+
+/*
+Returns true if nothing is selected, so the whole module tree is packed
+*/
+func (policy *ModulePolicy) Empty() bool {
+	return policy == nil || (len(policy.Include) == 0 && len(policy.Classes) == 0 &&
+		len(policy.Modaliases) == 0 && len(policy.Firmware) == 0)
+}
+
+func (policy *ModulePolicy) Write(fileName string) error {
+	buffer, err := yaml.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, buffer, 0644)
+}
+
+/*
+Module name as used by modprobe for the path of a module file
+*/
+func moduleName(modulePath string) string {
+	name := path.Base(modulePath)
+	if i := strings.Index(name, ".ko"); i > 0 {
+		name = name[:i]
+	}
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+type moduleIndex struct {
+	// module path (relative to the module dir) -> paths of dependencies
+	deps map[string][]string
+	// module name -> module path
+	paths map[string]string
+	// modalias pattern -> module names
+	aliases map[string][]string
+}
+
+func readModuleIndex(moduleDir string) (*moduleIndex, error) {
+	index := &moduleIndex{
+		deps:    make(map[string][]string),
+		paths:   make(map[string]string),
+		aliases: make(map[string][]string),
+	}
+
+	dep, err := os.Open(path.Join(moduleDir, "modules.dep"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open modules.dep, run depmod in the root")
+	}
+	defer dep.Close()
+	scanner := bufio.NewScanner(dep)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.SplitN(scanner.Text(), ":", 2)
+		if len(line) != 2 {
+			continue
+		}
+		module := strings.TrimSpace(line[0])
+		index.deps[module] = strings.Fields(line[1])
+		index.paths[moduleName(module)] = module
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	alias, err := os.Open(path.Join(moduleDir, "modules.alias"))
+	if os.IsNotExist(err) {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer alias.Close()
+	scanner = bufio.NewScanner(alias)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "alias" {
+			continue
+		}
+		index.aliases[fields[1]] = append(index.aliases[fields[1]], strings.ReplaceAll(fields[2], "-", "_"))
+	}
+	return index, scanner.Err()
+}
+
+func (policy *ModulePolicy) excluded(module string) bool {
+	for _, e := range policy.Exclude {
+		e = strings.TrimPrefix(e, "kernel/")
+		if ok, _ := path.Match(strings.ReplaceAll(e, "-", "_"), moduleName(module)); ok {
+			return true
+		}
+		if strings.HasPrefix(strings.TrimPrefix(module, "kernel/"), strings.TrimSuffix(e, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Resolve the policy against the modules of moduleDir, returns the paths of the
+selected modules and all their dependencies relative to moduleDir
+*/
+func (policy *ModulePolicy) resolve(moduleDir string) ([]string, error) {
+	index, err := readModuleIndex(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []string
+	for _, inc := range policy.Include {
+		found := false
+		for name, module := range index.paths {
+			if ok, _ := path.Match(strings.ReplaceAll(inc, "-", "_"), name); ok {
+				selected = append(selected, module)
+				found = true
+			}
+		}
+		if !found {
+			wwlog.Warn("Module not found (or built in): %s", inc)
+		}
+	}
+	for _, class := range policy.Classes {
+		prefix := path.Join("kernel", strings.Trim(class, "/")) + "/"
+		for module := range index.deps {
+			if strings.HasPrefix(module, prefix) {
+				selected = append(selected, module)
+			}
+		}
+	}
+	for _, modalias := range policy.Modaliases {
+		for pattern, names := range index.aliases {
+			if ok, _ := path.Match(pattern, modalias); !ok {
+				continue
+			}
+			for _, name := range names {
+				if module, ok := index.paths[name]; ok {
+					selected = append(selected, module)
+				}
+			}
+		}
+	}
+
+	// add the dependencies, excluded modules are only dropped if nothing
+	// else needs them
+	modules := make(map[string]bool)
+	var queue []string
+	for _, module := range selected {
+		if policy.excluded(module) {
+			wwlog.Verbose("Excluding module: %s", module)
+			continue
+		}
+		queue = append(queue, module)
+	}
+	for len(queue) > 0 {
+		module := queue[0]
+		queue = queue[1:]
+		if modules[module] {
+			continue
+		}
+		modules[module] = true
+		queue = append(queue, index.deps[module]...)
+	}
+
+	var ret []string
+	for module := range modules {
+		ret = append(ret, module)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+/*
+Returns the firmware files (relative to firmwareDir) needed by the modules and
+the ones listed in the policy
+*/
+func (policy *ModulePolicy) firmware(moduleDir string, modules []string, firmwareDir string) []string {
+	wanted := make(map[string]bool)
+	for _, module := range modules {
+		fws, err := moduleFirmware(path.Join(moduleDir, module))
+		if err != nil {
+			wwlog.Warn("Could not get firmware of %s: %s", module, err)
+			continue
+		}
+		for _, fw := range fws {
+			wanted[fw] = true
+		}
+	}
+	var ret []string
+	for fw := range wanted {
+		// firmware is often compressed
+		var found bool
+		for _, suffix := range []string{"", ".xz", ".zst"} {
+			if util.IsFile(path.Join(firmwareDir, fw+suffix)) {
+				ret = append(ret, fw+suffix)
+				found = true
+			}
+		}
+		if !found {
+			wwlog.Debug("Firmware not found: %s", fw)
+		}
+	}
+	for _, pattern := range policy.Firmware {
+		matches, _ := filepath.Glob(path.Join(firmwareDir, pattern))
+		for _, m := range matches {
+			rel, _ := filepath.Rel(firmwareDir, m)
+			ret = append(ret, rel)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+/*
+Returns the files of the kmods image relative to root, including the parent
+directories, as the initramfs unpacker doesn't create them
+*/
+func (policy *ModulePolicy) Files(root string, kernelVersion string) ([]string, error) {
+	moduleDirRel := path.Join("lib/modules", kernelVersion)
+	moduleDir := path.Join(root, moduleDirRel)
+	firmwareDirRel := "lib/firmware"
+
+	modules, err := policy.resolve(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+	wwlog.Info("Selected %d modules", len(modules))
+
+	var files []string
+	for _, m := range moduleMetadata {
+		if util.IsFile(path.Join(moduleDir, m)) {
+			files = append(files, path.Join(moduleDirRel, m))
+		}
+	}
+	for _, module := range modules {
+		files = append(files, path.Join(moduleDirRel, module))
+	}
+	for _, fw := range policy.firmware(moduleDir, modules, path.Join(root, firmwareDirRel)) {
+		files = append(files, path.Join(firmwareDirRel, fw))
+	}
+
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		for d := path.Dir(f); d != "." && d != "/"; d = path.Dir(d) {
+			dirs[d] = true
+		}
+	}
+	for d := range dirs {
+		files = append(files, d)
+	}
+	sort.Strings(files)
+	for i := range files {
+		files[i] = "./" + files[i]
+	}
+	return files, nil
+}
@@ -0,0 +1,101 @@
+package kernel
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testModulesDep = `kernel/drivers/net/ethernet/intel/e1000e/e1000e.ko.xz: kernel/drivers/ptp/ptp.ko.xz kernel/drivers/pps/pps_core.ko.xz
+kernel/drivers/ptp/ptp.ko.xz: kernel/drivers/pps/pps_core.ko.xz
+kernel/drivers/pps/pps_core.ko.xz:
+kernel/drivers/net/ethernet/mellanox/mlx5/core/mlx5_core.ko.xz: kernel/drivers/net/ethernet/mellanox/mlxfw/mlxfw.ko.xz
+kernel/drivers/net/ethernet/mellanox/mlxfw/mlxfw.ko.xz:
+kernel/drivers/gpu/drm/nouveau/nouveau.ko.xz:
+kernel/fs/xfs/xfs.ko.xz: kernel/lib/libcrc32c.ko.xz
+kernel/lib/libcrc32c.ko.xz:
+`
+
+const testModulesAlias = `# Aliases extracted from modules themselves.
+alias pci:v00008086d000015B8sv*sd*bc*sc*i* e1000e
+alias pci:v000015B3d00001017sv*sd*bc*sc*i* mlx5_core
+alias pci:v000010DEd*sv*sd*bc03sc*i* nouveau
+`
+
+func newTestModuleRoot(t *testing.T) string {
+	root := t.TempDir()
+	moduleDir := path.Join(root, "lib/modules/5.14.0")
+	assert.NoError(t, os.MkdirAll(moduleDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(path.Join(moduleDir, "modules.dep"), []byte(testModulesDep), 0644))
+	assert.NoError(t, ioutil.WriteFile(path.Join(moduleDir, "modules.alias"), []byte(testModulesAlias), 0644))
+	assert.NoError(t, os.MkdirAll(path.Join(root, "lib/firmware/mellanox"), 0755))
+	assert.NoError(t, ioutil.WriteFile(path.Join(root, "lib/firmware/mellanox/mlx5.bin.xz"), nil, 0644))
+	assert.NoError(t, os.MkdirAll(path.Join(root, "lib/firmware/nvidia"), 0755))
+	assert.NoError(t, ioutil.WriteFile(path.Join(root, "lib/firmware/nvidia/gsp.bin"), nil, 0644))
+	moduleFirmware = func(modulePath string) ([]string, error) {
+		if moduleName(modulePath) == "mlx5_core" {
+			return []string{"mellanox/mlx5.bin", "mellanox/missing.bin"}, nil
+		}
+		return nil, nil
+	}
+	return root
+}
+
+func Test_ModulePolicy_resolve(t *testing.T) {
+	root := newTestModuleRoot(t)
+	moduleDir := path.Join(root, "lib/modules/5.14.0")
+
+	tests := []struct {
+		name   string
+		policy ModulePolicy
+		want   []string
+	}{
+		{"include", ModulePolicy{Include: []string{"e1000e"}}, []string{
+			"kernel/drivers/net/ethernet/intel/e1000e/e1000e.ko.xz",
+			"kernel/drivers/pps/pps_core.ko.xz",
+			"kernel/drivers/ptp/ptp.ko.xz"}},
+		{"glob", ModulePolicy{Include: []string{"mlx5*"}}, []string{
+			"kernel/drivers/net/ethernet/mellanox/mlx5/core/mlx5_core.ko.xz",
+			"kernel/drivers/net/ethernet/mellanox/mlxfw/mlxfw.ko.xz"}},
+		{"class", ModulePolicy{Classes: []string{"fs"}}, []string{
+			"kernel/fs/xfs/xfs.ko.xz",
+			"kernel/lib/libcrc32c.ko.xz"}},
+		{"modalias", ModulePolicy{Modaliases: []string{"pci:v000010DEd00002204sv00001458sd00004043bc03sc00i00"}}, []string{
+			"kernel/drivers/gpu/drm/nouveau/nouveau.ko.xz"}},
+		{"exclude", ModulePolicy{Classes: []string{"drivers/net"}, Exclude: []string{"drivers/net/ethernet/intel"}}, []string{
+			"kernel/drivers/net/ethernet/mellanox/mlx5/core/mlx5_core.ko.xz",
+			"kernel/drivers/net/ethernet/mellanox/mlxfw/mlxfw.ko.xz"}},
+		{"excludeDependency", ModulePolicy{Include: []string{"ptp"}, Exclude: []string{"pps_core"}}, []string{
+			"kernel/drivers/pps/pps_core.ko.xz",
+			"kernel/drivers/ptp/ptp.ko.xz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.resolve(moduleDir)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_ModulePolicy_Files(t *testing.T) {
+	root := newTestModuleRoot(t)
+	policy := ModulePolicy{Include: []string{"mlx5_core"}, Firmware: []string{"nvidia/*"}}
+	assert.False(t, policy.Empty())
+
+	files, err := policy.Files(root, "5.14.0")
+	assert.NoError(t, err)
+	assert.Contains(t, files, "./lib/modules/5.14.0/modules.dep")
+	assert.Contains(t, files, "./lib/modules/5.14.0/kernel/drivers/net/ethernet/mellanox/mlxfw/mlxfw.ko.xz")
+	assert.Contains(t, files, "./lib/firmware/mellanox/mlx5.bin.xz")
+	assert.Contains(t, files, "./lib/firmware/nvidia/gsp.bin")
+	assert.NotContains(t, files, "./lib/modules/5.14.0/kernel/fs/xfs/xfs.ko.xz")
+	// parent directories must be in the image
+	assert.Contains(t, files, "./lib/modules/5.14.0/kernel/drivers/net/ethernet/mellanox")
+	assert.Contains(t, files, "./lib")
+
+	assert.True(t, (&ModulePolicy{Exclude: []string{"foo"}}).Empty())
+}
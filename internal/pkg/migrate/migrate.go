@@ -0,0 +1,297 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+const versionKey = "WW_INTERNAL"
+
+/*
+A single migration which transforms a document of version From into a
+document of version From+1. The document is read into a yaml.MapSlice, so
+that the order of the keys is kept.
+*/
+type Step struct {
+	From        int
+	Description string
+	Apply       func(doc yaml.MapSlice) (yaml.MapSlice, error)
+}
+
+/*
+The versions of a configuration file and the steps between them. Documents
+without WW_INTERNAL are treated as version Unversioned.
+*/
+type Schema struct {
+	Name        string
+	Current     int
+	Unversioned int
+	Steps       []Step
+}
+
+/*
+Returns the WW_INTERNAL version of the document
+*/
+func (s *Schema) Version(data []byte) (int, error) {
+	var doc yaml.MapSlice
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return 0, err
+	}
+	return s.version(doc)
+}
+
+func (s *Schema) version(doc yaml.MapSlice) (int, error) {
+	value, ok := get(doc, versionKey)
+	if !ok || value == nil {
+		return s.Unversioned, nil
+	}
+	switch v := value.(type) {
+	case int:
+		if v == 0 {
+			return s.Unversioned, nil
+		}
+		return v, nil
+	case string:
+		i, err := strconv.Atoi(v)
+		if err == nil {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: invalid %s: %v", s.Name, versionKey, value)
+}
+
+func (s *Schema) step(from int) (Step, bool) {
+	for _, step := range s.Steps {
+		if step.From == from {
+			return step, true
+		}
+	}
+	return Step{}, false
+}
+
+/*
+Migrates the document to version to, or to the current version if to is 0.
+Returns the migrated document and the version it had before. If the document
+already has the requested version it is returned unchanged.
+*/
+func (s *Schema) Migrate(data []byte, to int) ([]byte, int, error) {
+	if to == 0 {
+		to = s.Current
+	}
+	if to > s.Current {
+		return nil, 0, fmt.Errorf("%s: version %d is not known, the current version is %d", s.Name, to, s.Current)
+	}
+	var doc yaml.MapSlice
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	from, err := s.version(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from == to {
+		return data, from, nil
+	}
+	if from > to {
+		return nil, from, fmt.Errorf("%s: can't migrate version %d back to %d", s.Name, from, to)
+	}
+
+	for v := from; v < to; v++ {
+		step, ok := s.step(v)
+		if !ok {
+			return nil, from, fmt.Errorf("%s: no migration from version %d to %d", s.Name, v, v+1)
+		}
+		wwlog.Verbose("%s: migrating from version %d to %d: %s", s.Name, v, v+1, step.Description)
+		doc, err = step.Apply(doc)
+		if err != nil {
+			return nil, from, fmt.Errorf("%s: migration from version %d to %d failed: %s", s.Name, v, v+1, err)
+		}
+		doc = setFirst(doc, versionKey, v+1)
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, from, err
+	}
+	return out, from, nil
+}
+
+/*
+Name of the backup of fileName which is written before a document of the
+given version is migrated
+*/
+func BackupFile(fileName string, version int) string {
+	return fmt.Sprintf("%s.ww%d", fileName, version)
+}
+
+/*
+Returns data, the content of fileName, with the current version. If it has an
+older version, the file is migrated with Upgrade(). If the file can't be
+written, e.g. by an unprivileged user, data is only migrated in memory.
+*/
+func (s *Schema) Load(fileName string, data []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return data, nil
+	}
+	from, err := s.Version(data)
+	if err != nil {
+		return nil, err
+	}
+	if from == s.Current {
+		return data, nil
+	}
+	_, err = s.Upgrade(fileName)
+	if err == nil {
+		var upgraded []byte
+		upgraded, err = ioutil.ReadFile(fileName)
+		if err == nil {
+			data = upgraded
+		}
+	}
+	if err != nil {
+		wwlog.Warn("Could not migrate %s from version %d to %d: %s", fileName, from, s.Current, err)
+	}
+	out, _, err := s.Migrate(data, s.Current)
+	return out, err
+}
+
+/*
+Migrates fileName to the current version. The file is locked with the lock
+which its writers take. If it has an older version, the original content is
+saved with BackupFile() and the file is replaced with the migrated document.
+Returns the version the file had, a missing or empty file is left alone.
+*/
+func (s *Schema) Upgrade(fileName string) (int, error) {
+	lock, err := util.LockFile(fileName + ".lock")
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Unlock()
+	_, from, err := s.UpgradeLocked(fileName)
+	return from, err
+}
+
+/*
+Same as Upgrade() for a caller which holds the lock of fileName. Returns the
+content of the file after the migration and the version it had before.
+*/
+func (s *Schema) UpgradeLocked(fileName string) ([]byte, int, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil, s.Current, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return data, s.Current, nil
+	}
+	out, from, err := s.Migrate(data, s.Current)
+	if err != nil {
+		return nil, from, err
+	}
+	if from == s.Current {
+		return data, from, nil
+	}
+
+	fi, err := os.Stat(fileName)
+	if err != nil {
+		return nil, from, err
+	}
+	backup := BackupFile(fileName, from)
+	err = ioutil.WriteFile(backup, data, fi.Mode().Perm())
+	if err != nil {
+		return nil, from, err
+	}
+	err = util.WriteFileAtomic(fileName, out, fi.Mode().Perm())
+	if err != nil {
+		return nil, from, err
+	}
+	wwlog.Info("Migrated %s from version %d to %d, the old version is saved as %s", fileName, from, s.Current, backup)
+	return out, from, nil
+}
+
+/*
+Helpers for yaml.MapSlice, which is a list of key value pairs
+*/
+
+func get(m yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+func getMap(m yaml.MapSlice, key string) yaml.MapSlice {
+	value, _ := get(m, key)
+	ret, _ := value.(yaml.MapSlice)
+	return ret
+}
+
+/*
+Sets the value of key, a new key is appended
+*/
+func set(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+/*
+Sets the value of key, a new key is inserted at the beginning
+*/
+func setFirst(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	if _, ok := get(m, key); ok {
+		return set(m, key, value)
+	}
+	return append(yaml.MapSlice{{Key: key, Value: value}}, m...)
+}
+
+func remove(m yaml.MapSlice, key string) yaml.MapSlice {
+	var ret yaml.MapSlice
+	for _, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			continue
+		}
+		ret = append(ret, item)
+	}
+	return ret
+}
+
+/*
+Replaces the key old with the key new, the position of old is kept
+*/
+func rename(m yaml.MapSlice, old string, new string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if k, ok := item.Key.(string); ok && k == old {
+			m[i] = yaml.MapItem{Key: new, Value: value}
+			return m
+		}
+	}
+	return set(m, new, value)
+}
+
+/*
+Returns the scalar value as a string
+*/
+func scalar(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
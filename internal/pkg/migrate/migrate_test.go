@@ -0,0 +1,175 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(path.Join("testdata", name))
+	assert.NoError(t, err)
+	return data
+}
+
+func Test_Migrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   *Schema
+		input    string
+		expected string
+		from     int
+		wantErr  bool
+	}{
+		{name: "nodes 42", schema: Nodes, input: "nodes-42.conf", expected: "nodes-42.expected", from: 42},
+		{name: "nodes 42 with legacy keys", schema: Nodes, input: "nodes-42-legacy.conf", expected: "nodes-42-legacy.expected", from: 42},
+		{name: "nodes current", schema: Nodes, input: "nodes-43.conf", expected: "nodes-43.conf", from: 43},
+		{name: "nodes newer", schema: Nodes, input: "nodes-44.conf", from: 44, wantErr: true},
+		{name: "warewulf.conf 42", schema: Warewulf, input: "warewulf-42.conf", expected: "warewulf-42.expected", from: 42},
+		{name: "warewulf.conf current", schema: Warewulf, input: "warewulf-43.conf", expected: "warewulf-43.conf", from: 43},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, from, err := tt.schema.Migrate(readFixture(t, tt.input), 0)
+			assert.Equal(t, tt.from, from)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, string(readFixture(t, tt.expected)), string(out))
+
+			// migrating the result again must not change it
+			again, from, err := tt.schema.Migrate(out, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.schema.Current, from)
+			assert.Equal(t, string(out), string(again))
+		})
+	}
+}
+
+func appendStep(key string) func(yaml.MapSlice) (yaml.MapSlice, error) {
+	return func(doc yaml.MapSlice) (yaml.MapSlice, error) {
+		steps, _ := get(doc, "steps")
+		list, _ := steps.([]interface{})
+		return set(doc, "steps", append(list, key)), nil
+	}
+}
+
+func Test_MigrateChain(t *testing.T) {
+	schema := &Schema{
+		Name:        "test",
+		Current:     4,
+		Unversioned: 1,
+		Steps: []Step{
+			{From: 3, Apply: appendStep("3-4")},
+			{From: 1, Apply: appendStep("1-2")},
+			{From: 2, Apply: appendStep("2-3")},
+		},
+	}
+	tests := []struct {
+		name     string
+		input    string
+		to       int
+		expected string
+		from     int
+		wantErr  bool
+	}{
+		{name: "unversioned", input: "foo: bar\n", expected: "WW_INTERNAL: 4\nfoo: bar\nsteps:\n- 1-2\n- 2-3\n- 3-4\n", from: 1},
+		{name: "partial", input: "WW_INTERNAL: 2\n", to: 3, expected: "WW_INTERNAL: 3\nsteps:\n- 2-3\n", from: 2},
+		{name: "current", input: "WW_INTERNAL: 4\n", expected: "WW_INTERNAL: 4\n", from: 4},
+		{name: "unknown target", input: "WW_INTERNAL: 2\n", to: 5, wantErr: true},
+		{name: "backwards", input: "WW_INTERNAL: 3\n", to: 2, from: 3, wantErr: true},
+		{name: "version 0", input: "WW_INTERNAL: 0\n", expected: "WW_INTERNAL: 4\nsteps:\n- 1-2\n- 2-3\n- 3-4\n", from: 1},
+		{name: "invalid version", input: "WW_INTERNAL: foo\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, from, err := schema.Migrate([]byte(tt.input), tt.to)
+			assert.Equal(t, tt.from, from)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, string(out))
+			}
+		})
+	}
+
+	schema.Steps = schema.Steps[:1]
+	_, _, err := schema.Migrate([]byte("WW_INTERNAL: 2\n"), 0)
+	assert.EqualError(t, err, "test: no migration from version 2 to 3")
+}
+
+func Test_Upgrade(t *testing.T) {
+	dir := t.TempDir()
+	fileName := path.Join(dir, "nodes.conf")
+	data := readFixture(t, "nodes-42.conf")
+	assert.NoError(t, ioutil.WriteFile(fileName, data, 0640))
+
+	from, err := Nodes.Upgrade(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, from)
+
+	written, err := ioutil.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, string(readFixture(t, "nodes-42.expected")), string(written))
+	backup, err := ioutil.ReadFile(BackupFile(fileName, 42))
+	assert.NoError(t, err)
+	assert.Equal(t, data, backup)
+	fi, err := os.Stat(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+
+	// the current version is left alone
+	from, err = Nodes.Upgrade(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, 43, from)
+	again, err := ioutil.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, written, again)
+
+	// as is a missing file
+	_, err = Nodes.Upgrade(path.Join(dir, "missing.conf"))
+	assert.NoError(t, err)
+	assert.NoFileExists(t, path.Join(dir, "missing.conf"))
+}
+
+func Test_Load(t *testing.T) {
+	dir := t.TempDir()
+	fileName := path.Join(dir, "nodes.conf")
+	data := readFixture(t, "nodes-42.conf")
+	assert.NoError(t, ioutil.WriteFile(fileName, data, 0640))
+
+	// reading an old file migrates it with a backup
+	loaded, err := Nodes.Load(fileName, data)
+	assert.NoError(t, err)
+	assert.Equal(t, string(readFixture(t, "nodes-42.expected")), string(loaded))
+	written, err := ioutil.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, loaded, written)
+	backup, err := ioutil.ReadFile(BackupFile(fileName, 42))
+	assert.NoError(t, err)
+	assert.Equal(t, data, backup)
+
+	again, err := Nodes.Load(fileName, written)
+	assert.NoError(t, err)
+	assert.Equal(t, written, again)
+
+	// a file which can't be migrated is only migrated in memory
+	missing := path.Join(dir, "missing", "nodes.conf")
+	loaded, err = Nodes.Load(missing, data)
+	assert.NoError(t, err)
+	assert.Equal(t, string(readFixture(t, "nodes-42.expected")), string(loaded))
+	assert.NoFileExists(t, missing)
+
+	empty, err := Nodes.Load(fileName, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
@@ -0,0 +1,180 @@
+package migrate
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+)
+
+/*
+The versions of nodes.conf, files without WW_INTERNAL were written by
+Warewulf 4.2
+*/
+var Nodes = &Schema{
+	Name:        "nodes.conf",
+	Current:     buildconfig.WWVer,
+	Unversioned: 42,
+	Steps: []Step{
+		{
+			From:        42,
+			Description: "move kernel and ipmi settings into sub sections, keys into tags and convert booleans to strings",
+			Apply:       forEachNodeConf(nodeConf42),
+		},
+	},
+}
+
+/*
+Applies fn to every profile and node of the document
+*/
+func forEachNodeConf(fn func(name string, conf yaml.MapSlice) (yaml.MapSlice, error)) func(yaml.MapSlice) (yaml.MapSlice, error) {
+	return func(doc yaml.MapSlice) (yaml.MapSlice, error) {
+		for _, section := range []string{"nodeprofiles", "nodes"} {
+			objects := getMap(doc, section)
+			for i, item := range objects {
+				conf, ok := item.Value.(yaml.MapSlice)
+				if !ok {
+					continue
+				}
+				conf, err := fn(scalar(item.Key), conf)
+				if err != nil {
+					return nil, err
+				}
+				objects[i].Value = conf
+			}
+		}
+		return doc, nil
+	}
+}
+
+/*
+Moves the given keys of conf into the sub section name, e.g. "kernel args"
+into kernel.args. Values which are already set in the sub section are kept.
+*/
+func nest(conf yaml.MapSlice, name string, keys [][2]string) yaml.MapSlice {
+	sub := getMap(conf, name)
+	_, hasSub := get(conf, name)
+	moved := false
+	for _, key := range keys {
+		value, ok := get(conf, key[0])
+		if !ok {
+			continue
+		}
+		if _, ok := get(sub, key[1]); !ok && scalar(value) != "" {
+			sub = set(sub, key[1], scalar(value))
+		}
+		if hasSub {
+			conf = remove(conf, key[0])
+		} else {
+			conf = rename(conf, key[0], name, nil)
+			hasSub = true
+		}
+		moved = true
+	}
+	if !moved {
+		return conf
+	}
+	if len(sub) == 0 {
+		return remove(conf, name)
+	}
+	return set(conf, name, sub)
+}
+
+/*
+Booleans of version 42 become strings, false is dropped as it was the
+default and must not override the value of a profile
+*/
+func boolString(conf yaml.MapSlice, old string, new string) yaml.MapSlice {
+	value, ok := get(conf, old)
+	if !ok {
+		return conf
+	}
+	if _, ok := get(conf, new); ok && old != new {
+		return remove(conf, old)
+	}
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return rename(conf, old, new, "true")
+		}
+		return remove(conf, old)
+	case nil:
+		return remove(conf, old)
+	}
+	return rename(conf, old, new, scalar(value))
+}
+
+func nodeConf42(name string, conf yaml.MapSlice) (yaml.MapSlice, error) {
+	for _, key := range []string{"runtime overlay", "system overlay"} {
+		value, ok := get(conf, key)
+		if !ok {
+			continue
+		}
+		overlays, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var list []interface{}
+		for _, overlay := range strings.Split(overlays, ",") {
+			if overlay = strings.TrimSpace(overlay); overlay != "" {
+				list = append(list, overlay)
+			}
+		}
+		if len(list) == 0 {
+			conf = remove(conf, key)
+		} else {
+			conf = set(conf, key, list)
+		}
+	}
+
+	conf = nest(conf, "kernel", [][2]string{
+		{"kernel version", "override"},
+		{"kernel override", "override"},
+		{"kernel args", "args"},
+	})
+	conf = nest(conf, "ipmi", [][2]string{
+		{"ipmi username", "username"},
+		{"ipmi password", "password"},
+		{"ipmi ipaddr", "ipaddr"},
+		{"ipmi netmask", "netmask"},
+		{"ipmi port", "port"},
+		{"ipmi gateway", "gateway"},
+		{"ipmi interface", "interface"},
+		{"ipmi write", "write"},
+	})
+	conf = boolString(conf, "discoverable", "discoverable")
+
+	if keys, ok := get(conf, "keys"); ok {
+		tags := getMap(conf, "tags")
+		if keys, ok := keys.(yaml.MapSlice); ok {
+			for _, item := range keys {
+				if _, ok := get(tags, scalar(item.Key)); !ok {
+					tags = set(tags, scalar(item.Key), scalar(item.Value))
+				}
+			}
+		}
+		if _, ok := get(conf, "tags"); ok {
+			conf = remove(conf, "keys")
+			conf = set(conf, "tags", tags)
+		} else if len(tags) > 0 {
+			conf = rename(conf, "keys", "tags", tags)
+		} else {
+			conf = remove(conf, "keys")
+		}
+	}
+
+	netdevs := getMap(conf, "network devices")
+	for i, item := range netdevs {
+		netdev, ok := item.Value.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+		// version 42 used the name of the network device as device
+		if _, ok := get(netdev, "device"); !ok {
+			netdev = setFirst(netdev, "device", scalar(item.Key))
+		}
+		netdevs[i].Value = boolString(netdev, "default", "primary")
+	}
+	return conf, nil
+}
@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"gopkg.in/yaml.v2"
+
+	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+)
+
+/*
+The versions of warewulf.conf, files without WW_INTERNAL were written by
+Warewulf 4.2
+*/
+var Warewulf = &Schema{
+	Name:        "warewulf.conf",
+	Current:     buildconfig.WWVer,
+	Unversioned: 42,
+	Steps: []Step{
+		{
+			From:        42,
+			Description: "convert the nfs exports into export paths",
+			Apply:       warewulfConf42,
+		},
+	},
+}
+
+/*
+Version 42 had a plain list of exported directories, version 43 has the
+export and mount options for every path
+*/
+func warewulfConf42(doc yaml.MapSlice) (yaml.MapSlice, error) {
+	nfs := getMap(doc, "nfs")
+	exports, ok := get(nfs, "exports")
+	if !ok {
+		return doc, nil
+	}
+	var paths []interface{}
+	if list, ok := exports.([]interface{}); ok {
+		for _, p := range list {
+			paths = append(paths, yaml.MapSlice{
+				{Key: "path", Value: scalar(p)},
+				{Key: "export options", Value: "rw,sync,no_subtree_check"},
+				{Key: "mount options", Value: "defaults"},
+				{Key: "mount", Value: true},
+			})
+		}
+	}
+	if _, ok := get(nfs, "export paths"); ok {
+		nfs = remove(nfs, "exports")
+	} else {
+		nfs = rename(nfs, "exports", "export paths", paths)
+	}
+	return set(doc, "nfs", nfs), nil
+}
@@ -0,0 +1,442 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+/*
+Append only log of all changes of nodes.conf, one json object per line
+*/
+var AuditLogFile string
+
+func init() {
+	if AuditLogFile == "" {
+		AuditLogFile = path.Join(buildconfig.LOCALSTATEDIR(), "warewulf/nodes.log")
+	}
+}
+
+/*
+A change of nodes.conf, written by a single Persist()
+*/
+type Change struct {
+	Id      int            `json:"id"`
+	Time    time.Time      `json:"time"`
+	User    string         `json:"user"`
+	Command string         `json:"command"`
+	Objects []ObjectChange `json:"objects"`
+}
+
+/*
+Change of a single node or profile. Before and After hold the complete yaml
+of the object, so that the change can be reverted, they are empty if the
+object was added or deleted. The ipmi passwords are replaced by
+RedactedPassword.
+*/
+type ObjectChange struct {
+	Kind   string        `json:"kind"`
+	Name   string        `json:"name"`
+	Before string        `json:"before,omitempty"`
+	After  string        `json:"after,omitempty"`
+	Fields []FieldChange `json:"fields"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+/*
+Replaces the ipmi passwords in the audit log
+*/
+const RedactedPassword = "*****"
+
+/*
+Returns true if the change touched the given node or profile
+*/
+func (change *Change) Touches(kind string, name string) bool {
+	for _, o := range change.Objects {
+		if o.Kind == kind && o.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Reads all changes of the audit log, the oldest first. Corrupt lines, e.g. of
+a write which was interrupted, are skipped with a warning.
+*/
+func ReadAuditLog() ([]Change, error) {
+	var ret []Change
+	f, err := os.Open(AuditLogFile)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var change Change
+		err = json.Unmarshal(scanner.Bytes(), &change)
+		if err != nil {
+			wwlog.Warn("Skipping corrupt entry in %s: %s", AuditLogFile, err)
+			continue
+		}
+		ret = append(ret, change)
+	}
+	return ret, scanner.Err()
+}
+
+/*
+Returns the change with the given id
+*/
+func GetChange(id int) (Change, error) {
+	changes, err := ReadAuditLog()
+	if err != nil {
+		return Change{}, err
+	}
+	for _, c := range changes {
+		if c.Id == id {
+			return c, nil
+		}
+	}
+	return Change{}, fmt.Errorf("change %d not found", id)
+}
+
+/*
+Restores the nodes and profiles to the state before the given change. A node
+or profile which was modified again after the change is only restored if
+force is set. The ipmi passwords aren't logged, so the current ones are kept.
+*/
+func (config *nodeYaml) Revert(change Change, force bool) error {
+	var conflicts []string
+	for _, o := range change.Objects {
+		objects := config.objects(o.Kind)
+		if objects == nil {
+			return fmt.Errorf("unknown kind of object: %s", o.Kind)
+		}
+		if !force && !bytes.Equal(confBytes(redactConf(objects[o.Name])), []byte(o.After)) {
+			conflicts = append(conflicts, o.Kind+" "+o.Name)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("modified since change %d: %s", change.Id, strings.Join(conflicts, ", "))
+	}
+
+	config.modified()
+	for _, o := range change.Objects {
+		objects := config.objects(o.Kind)
+		if o.Before == "" {
+			delete(objects, o.Name)
+			continue
+		}
+		var conf NodeConf
+		err := yaml.Unmarshal([]byte(o.Before), &conf)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse %s %s of change %d", o.Kind, o.Name, change.Id)
+		}
+		keepPasswords(&conf, objects[o.Name])
+		objects[o.Name] = &conf
+	}
+	return nil
+}
+
+func (config *nodeYaml) objects(kind string) map[string]*NodeConf {
+	switch kind {
+	case "node":
+		if config.Nodes == nil {
+			config.Nodes = make(map[string]*NodeConf)
+		}
+		return config.Nodes
+	case "profile":
+		if config.NodeProfiles == nil {
+			config.NodeProfiles = make(map[string]*NodeConf)
+		}
+		return config.NodeProfiles
+	}
+	return nil
+}
+
+/*
+Compares the old content of nodes.conf with the new one and returns the
+changed nodes and profiles, sorted by name
+*/
+func diffConfig(oldData []byte, newData []byte) ([]ObjectChange, error) {
+	var oldConf, newConf nodeYaml
+	err := yaml.Unmarshal(oldData, &oldConf)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(newData, &newConf)
+	if err != nil {
+		return nil, err
+	}
+	ret := diffConfs("profile", oldConf.NodeProfiles, newConf.NodeProfiles)
+	ret = append(ret, diffConfs("node", oldConf.Nodes, newConf.Nodes)...)
+	return ret, nil
+}
+
+func diffConfs(kind string, oldConfs, newConfs map[string]*NodeConf) []ObjectChange {
+	var ret []ObjectChange
+	names := make(map[string]bool)
+	for _, m := range []map[string]*NodeConf{oldConfs, newConfs} {
+		for name := range m {
+			names[name] = true
+		}
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		before, after := confBytes(oldConfs[name]), confBytes(newConfs[name])
+		if bytes.Equal(before, after) {
+			continue
+		}
+		fields := diffFields(before, after)
+		for i := range fields {
+			if fields[i].Field == "ipmi password" || fields[i].Field == "ipmi.password" {
+				fields[i].Old = redact(fields[i].Old)
+				fields[i].New = redact(fields[i].New)
+			}
+		}
+		ret = append(ret, ObjectChange{
+			Kind:   kind,
+			Name:   name,
+			Before: string(confBytes(redactConf(oldConfs[name]))),
+			After:  string(confBytes(redactConf(newConfs[name]))),
+			Fields: fields,
+		})
+	}
+	return ret
+}
+
+func redact(password string) string {
+	if password == "" {
+		return ""
+	}
+	return RedactedPassword
+}
+
+/*
+Copy of the node or profile with the ipmi passwords redacted
+*/
+func redactConf(conf *NodeConf) *NodeConf {
+	if conf == nil {
+		return nil
+	}
+	ret := *conf
+	ret.IpmiPassword = redact(ret.IpmiPassword)
+	if ret.Ipmi != nil {
+		ipmi := *ret.Ipmi
+		ipmi.Password = redact(ipmi.Password)
+		ret.Ipmi = &ipmi
+	}
+	return &ret
+}
+
+/*
+Replaces the redacted ipmi passwords of a logged node or profile by the ones
+of the current one
+*/
+func keepPasswords(conf *NodeConf, current *NodeConf) {
+	if current == nil {
+		current = &NodeConf{}
+	}
+	if conf.IpmiPassword == RedactedPassword {
+		conf.IpmiPassword = current.IpmiPassword
+	}
+	if conf.Ipmi != nil && conf.Ipmi.Password == RedactedPassword {
+		conf.Ipmi.Password = ""
+		if current.Ipmi != nil {
+			conf.Ipmi.Password = current.Ipmi.Password
+		}
+	}
+}
+
+/*
+Field level differences of two yaml documents, nested fields are joined with
+a dot, e.g. "network devices.default.ipaddr"
+*/
+func diffFields(before []byte, after []byte) []FieldChange {
+	oldFields := make(map[string]string)
+	newFields := make(map[string]string)
+	flattenYaml(before, oldFields)
+	flattenYaml(after, newFields)
+
+	names := make(map[string]bool)
+	for _, m := range []map[string]string{oldFields, newFields} {
+		for name := range m {
+			names[name] = true
+		}
+	}
+	var ret []FieldChange
+	for name := range names {
+		if oldFields[name] != newFields[name] {
+			ret = append(ret, FieldChange{Field: name, Old: oldFields[name], New: newFields[name]})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Field < ret[j].Field })
+	return ret
+}
+
+func flattenYaml(data []byte, fields map[string]string) {
+	var content map[interface{}]interface{}
+	if yaml.Unmarshal(data, &content) != nil {
+		return
+	}
+	flattenValue("", content, fields)
+}
+
+func flattenValue(prefix string, value interface{}, fields map[string]string) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		for key, val := range v {
+			name := fmt.Sprint(key)
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			flattenValue(name, val, fields)
+		}
+	case []interface{}:
+		var list []string
+		for _, val := range v {
+			list = append(list, fmt.Sprint(val))
+		}
+		fields[prefix] = strings.Join(list, ",")
+	case nil:
+	default:
+		fields[prefix] = fmt.Sprint(v)
+	}
+}
+
+/*
+The user who runs the command, the invoking user for sudo
+*/
+func auditUser() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && sudoUser != name {
+		name = fmt.Sprintf("%s (sudo %s)", sudoUser, name)
+	}
+	return name
+}
+
+/*
+Appends the changed objects to the audit log, must be called with the node
+database locked as the ids are assigned sequentially
+*/
+func writeAuditLog(objects []ObjectChange) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	err := os.MkdirAll(path.Dir(AuditLogFile), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(AuditLogFile, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lastId, complete, err := lastAuditId(f)
+	if err != nil {
+		return err
+	}
+	change := Change{
+		Id:      lastId + 1,
+		Time:    time.Now(),
+		User:    auditUser(),
+		Command: strings.Join(os.Args, " "),
+		Objects: objects,
+	}
+	buffer, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	buffer = append(buffer, '\n')
+	if !complete {
+		// ends the line of an interrupted write, which is skipped when read
+		buffer = append([]byte{'\n'}, buffer...)
+	}
+	_, err = f.Write(buffer)
+	if err != nil {
+		return err
+	}
+	wwlog.Debug("Recorded change %d of the node configuration", change.Id)
+	return f.Sync()
+}
+
+/*
+Returns the id of the last valid change in the audit log and false if the
+log doesn't end with a complete line. Only the end of the log is read.
+*/
+func lastAuditId(f *os.File) (int, bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, true, err
+	}
+	const chunkSize = 64 * 1024
+	var tail []byte
+	complete := true
+	checked := 0
+	for offset := info.Size(); offset > 0; {
+		n := int64(chunkSize)
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		chunk := make([]byte, n)
+		_, err = f.ReadAt(chunk, offset)
+		if err != nil {
+			return 0, complete, err
+		}
+		if tail == nil {
+			complete = chunk[len(chunk)-1] == '\n'
+		}
+		tail = append(chunk, tail...)
+		lines := bytes.Split(tail, []byte{'\n'})
+		// the first line is only complete at the start of the log
+		first := 1
+		if offset == 0 {
+			first = 0
+		}
+		for i := len(lines) - 1 - checked; i >= first; i-- {
+			checked++
+			var change struct {
+				Id int `json:"id"`
+			}
+			if json.Unmarshal(lines[i], &change) == nil && change.Id > 0 {
+				return change.Id, complete, nil
+			}
+		}
+	}
+	return 0, complete, nil
+}
@@ -0,0 +1,147 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_diffFields(t *testing.T) {
+	before := []byte(`comment: old
+profiles:
+- default
+network devices:
+  default:
+    ipaddr: 10.0.0.1
+`)
+	after := []byte(`comment: new
+profiles:
+- default
+- test
+network devices:
+  default:
+    ipaddr: 10.0.0.1
+    netmask: 255.255.255.0
+`)
+	assert.Equal(t, []FieldChange{
+		{Field: "comment", Old: "old", New: "new"},
+		{Field: "network devices.default.netmask", New: "255.255.255.0"},
+		{Field: "profiles", Old: "default", New: "default,test"},
+	}, diffFields(before, after))
+}
+
+func Test_AuditLogRevert(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	config, err := New()
+	assert.NoError(t, err)
+	config.Nodes["n1"].Comment = "changed"
+	assert.NoError(t, config.DelNode("n2"))
+	assert.NoError(t, config.Persist())
+
+	config, err = New()
+	assert.NoError(t, err)
+	_, err = config.AddNode("n3")
+	assert.NoError(t, err)
+	assert.NoError(t, config.Persist())
+
+	changes, err := ReadAuditLog()
+	assert.NoError(t, err)
+	if !assert.Len(t, changes, 2) {
+		return
+	}
+	assert.Equal(t, 1, changes[0].Id)
+	assert.Equal(t, 2, changes[1].Id)
+	assert.True(t, changes[0].Touches("node", "n1"))
+	assert.True(t, changes[0].Touches("node", "n2"))
+	assert.False(t, changes[0].Touches("node", "n3"))
+	assert.Equal(t, []FieldChange{{Field: "comment", Old: "first", New: "changed"}},
+		changes[0].Objects[0].Fields)
+
+	change, err := GetChange(1)
+	assert.NoError(t, err)
+	config, err = New()
+	assert.NoError(t, err)
+	assert.NoError(t, config.Revert(change, false))
+	assert.NoError(t, config.Persist())
+
+	config, err = New()
+	assert.NoError(t, err)
+	assert.Equal(t, "first", config.Nodes["n1"].Comment)
+	assert.Equal(t, "second", config.Nodes["n2"].Comment)
+	assert.Contains(t, config.Nodes, "n3")
+
+	// n1 was changed by the revert, so reverting change 1 again conflicts
+	err = config.Revert(change, false)
+	assert.Error(t, err)
+	assert.NoError(t, config.Revert(change, true))
+
+	_, err = GetChange(42)
+	assert.Error(t, err)
+}
+
+func Test_AuditLogCorrupt(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	// a change larger than the chunks which are read from the end, followed
+	// by an interrupted write
+	long := `{"id":41,"command":"` + strings.Repeat("x", 100*1024) + `","objects":[]}`
+	assert.NoError(t, ioutil.WriteFile(AuditLogFile, []byte(long+"\n{\"id\":42,\"comm"), 0640))
+
+	config, err := New()
+	assert.NoError(t, err)
+	config.Nodes["n1"].Comment = "changed"
+	assert.NoError(t, config.Persist())
+
+	changes, err := ReadAuditLog()
+	assert.NoError(t, err)
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, 41, changes[0].Id)
+		assert.Equal(t, 42, changes[1].Id)
+		assert.True(t, changes[1].Touches("node", "n1"))
+	}
+
+	f, err := os.Open(AuditLogFile)
+	assert.NoError(t, err)
+	defer f.Close()
+	id, complete, err := lastAuditId(f)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, id)
+	assert.True(t, complete)
+}
+
+func Test_AuditLogPasswords(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	config, err := New()
+	assert.NoError(t, err)
+	config.Nodes["n1"].Ipmi = &IpmiConf{UserName: "admin", Password: "secret"}
+	assert.NoError(t, config.Persist())
+
+	config, err = New()
+	assert.NoError(t, err)
+	config.Nodes["n1"].Ipmi.Password = "newsecret"
+	config.Nodes["n1"].Comment = "changed"
+	assert.NoError(t, config.Persist())
+
+	data, err := ioutil.ReadFile(AuditLogFile)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	changes, err := ReadAuditLog()
+	assert.NoError(t, err)
+	if !assert.Len(t, changes, 2) {
+		return
+	}
+	assert.Contains(t, changes[1].Objects[0].Fields,
+		FieldChange{Field: "ipmi.password", Old: RedactedPassword, New: RedactedPassword})
+
+	// the revert keeps the current password
+	config, err = New()
+	assert.NoError(t, err)
+	assert.NoError(t, config.Revert(changes[1], false))
+	assert.Equal(t, "first", config.Nodes["n1"].Comment)
+	assert.Equal(t, "newsecret", config.Nodes["n1"].Ipmi.Password)
+}
@@ -0,0 +1,244 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/migrate"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+const (
+	BackendYaml   = "yaml"
+	BackendSqlite = "sqlite"
+)
+
+/*
+Storage of the nodes and profiles. A backend is created for every New() and
+remembers what it has loaded, so that store() can detect concurrent changes.
+*/
+type backend interface {
+	// the file holding the configuration, its modification time tells if
+	// the configuration has changed
+	file() string
+	load() (nodeYaml, error)
+	store(config *nodeYaml) error
+	// names of the nodes with a network device with the given hwaddr or
+	// ipaddr, nil if the backend has no index and all nodes must be checked
+	lookup(field string, value string) ([]string, error)
+}
+
+/*
+Creates the backend configured in warewulf.conf
+*/
+var newBackend = func() (backend, error) {
+	conf, err := warewulfconf.New()
+	if err != nil {
+		return nil, err
+	}
+	if conf.NodeDB == nil {
+		return OpenBackend(BackendYaml, "")
+	}
+	return OpenBackend(conf.NodeDB.Backend, conf.NodeDB.Path)
+}
+
+/*
+Opens the backend of the given kind, if fileName is empty the default location
+is used.
+*/
+func OpenBackend(kind string, fileName string) (backend, error) {
+	switch kind {
+	case BackendYaml, "":
+		if fileName == "" {
+			fileName = ConfigFile
+		}
+		return &yamlBackend{fileName: fileName}, nil
+	case BackendSqlite:
+		if fileName == "" {
+			fileName = path.Join(buildconfig.LOCALSTATEDIR(), "warewulf/nodes.db")
+		}
+		return &sqliteBackend{fileName: fileName}, nil
+	}
+	return nil, fmt.Errorf("unknown node database backend: %s", kind)
+}
+
+/*
+Returns the file of the configured node database, which is nodes.conf for the
+yaml backend
+*/
+func DatabaseFile() string {
+	db, err := newBackend()
+	if err != nil {
+		return ConfigFile
+	}
+	return db.file()
+}
+
+/*
+Opens the configuration of the given backend
+*/
+func NewFromBackend(db backend) (nodeYaml, error) {
+	ret, err := db.load()
+	if err != nil {
+		return ret, err
+	}
+	ret.db = db
+	if ret.Nodes == nil {
+		ret.Nodes = make(map[string]*NodeConf)
+	}
+	if ret.NodeProfiles == nil {
+		ret.NodeProfiles = make(map[string]*NodeConf)
+	}
+	return ret, nil
+}
+
+/*
+Replaces all nodes and profiles of the configuration with the ones of other,
+the changes are written with Persist()
+*/
+func (config *nodeYaml) Replace(other nodeYaml) {
+	config.modified()
+	config.WWInternal = other.WWInternal
+	config.Nodes = other.Nodes
+	config.NodeProfiles = other.NodeProfiles
+}
+
+/*
+nodes.conf, the whole file is read and written at once
+*/
+type yamlBackend struct {
+	fileName string
+	// content of nodes.conf when it was read, used to detect concurrent
+	// modifications on store(). nil if the configuration wasn't read from
+	// the file, every node and profile in the file is then kept by store()
+	loaded []byte
+	// names of the nodes by the hwaddr and ipaddr of their network devices,
+	// as of the last load() or store()
+	index map[string]map[string][]string
+}
+
+func (db *yamlBackend) file() string {
+	return db.fileName
+}
+
+func (db *yamlBackend) load() (nodeYaml, error) {
+	var ret nodeYaml
+
+	wwlog.Printf(wwlog.VERBOSE, "Opening node configuration file: %s\n", db.fileName)
+	data, err := ioutil.ReadFile(db.fileName)
+	if err != nil {
+		return ret, err
+	}
+
+	// nodes.conf of an older version is migrated with a backup, the
+	// migrated content is the base of the merge in store()
+	migrated, err := migrate.Nodes.Load(db.fileName, data)
+	if err != nil {
+		return ret, err
+	}
+
+	wwlog.Printf(wwlog.DEBUG, "Unmarshaling the node configuration\n")
+	err = yaml.Unmarshal(migrated, &ret)
+	if err != nil {
+		return ret, err
+	}
+	db.loaded = migrated
+	db.index = indexNodes(ret.Nodes)
+
+	return ret, nil
+}
+
+/*
+The file is locked while it is reread, written and replaced atomically. If it
+was changed by someone else since it was read, the changes are merged per node
+and profile, an error is returned if the same node or profile was changed on
+both sides. A configuration which wasn't read from the file is merged with an
+empty base, so it never drops what others have written. A file of an older
+version is migrated with a backup before it is merged.
+*/
+func (db *yamlBackend) store(config *nodeYaml) error {
+	lock, err := util.LockFile(db.fileName + ".lock")
+	if err != nil {
+		return errors.Wrap(err, "could not lock node configuration")
+	}
+	defer lock.Unlock()
+
+	current, _, err := migrate.Nodes.UpgradeLocked(db.fileName)
+	if err != nil {
+		return err
+	}
+	if current != nil && !bytes.Equal(current, db.loaded) {
+		wwlog.Verbose("%s was modified since it was read, merging changes", db.fileName)
+		err = config.merge(db.loaded, current)
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = util.WriteFileAtomic(db.fileName, out, 0644)
+	if err != nil {
+		return err
+	}
+	db.loaded = out
+	db.index = indexNodes(config.Nodes)
+
+	objects, err := diffConfig(current, out)
+	if err == nil {
+		err = writeAuditLog(objects)
+	}
+	if err != nil {
+		wwlog.Warn("Could not write audit log %s: %s", AuditLogFile, err)
+	}
+
+	return nil
+}
+
+func (db *yamlBackend) lookup(field string, value string) ([]string, error) {
+	if field == "hwaddr" {
+		value = strings.ToLower(value)
+	}
+	if db.index == nil || db.index[field] == nil {
+		return nil, nil
+	}
+	return append([]string{}, db.index[field][value]...), nil
+}
+
+/*
+Index of the nodes by the hwaddr and ipaddr which are set on their network
+devices, values which come from profiles are not indexed
+*/
+func indexNodes(nodes map[string]*NodeConf) map[string]map[string][]string {
+	ret := map[string]map[string][]string{"hwaddr": {}, "ipaddr": {}}
+	for _, name := range sortedNodeNames(nodes) {
+		if nodes[name] == nil {
+			continue
+		}
+		for _, dev := range nodes[name].NetDevs {
+			if dev == nil {
+				continue
+			}
+			if dev.Hwaddr != "" {
+				hwaddr := strings.ToLower(dev.Hwaddr)
+				ret["hwaddr"][hwaddr] = append(ret["hwaddr"][hwaddr], name)
+			}
+			if dev.Ipaddr != "" {
+				ret["ipaddr"][dev.Ipaddr] = append(ret["ipaddr"][dev.Ipaddr], name)
+			}
+		}
+	}
+	return ret
+}
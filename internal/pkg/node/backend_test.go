@@ -0,0 +1,121 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_YamlLookup(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	config, err := New()
+	assert.NoError(t, err)
+	config.Nodes["n1"].NetDevs = map[string]*NetDevs{
+		"eth0": {Hwaddr: "08:00:27:39:46:70", Ipaddr: "10.0.0.1"},
+	}
+	assert.NoError(t, config.Persist())
+
+	names, err := config.db.lookup("hwaddr", "08:00:27:39:46:70")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"n1"}, names)
+
+	config, err = New()
+	assert.NoError(t, err)
+	names, err = config.db.lookup("hwaddr", "08:00:27:39:46:70")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"n1"}, names)
+	names, err = config.db.lookup("ipaddr", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"n1"}, names)
+	names, err = config.db.lookup("ipaddr", "10.0.0.2")
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	// the index is not updated before the next store, the nodes are checked
+	// if it is outdated
+	config.Nodes["n1"].NetDevs["eth0"].Ipaddr = ""
+	config.Nodes["n2"].NetDevs = map[string]*NetDevs{
+		"eth0": {Ipaddr: "10.0.0.1"},
+	}
+	config.modified()
+	n, err := config.FindByIpaddr("10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "n2", n.Id.Get())
+}
+
+func Test_FindAllNodesCached(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	config, err := New()
+	assert.NoError(t, err)
+	nodes, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 2)
+	assert.NotNil(t, config.resolved)
+
+	again, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	assert.Equal(t, nodes, again)
+
+	// modifications drop the resolved nodes
+	for _, n := range nodes {
+		if n.Id.Get() == "n1" {
+			n.Comment.Set("updated")
+			assert.NoError(t, config.NodeUpdate(n))
+		}
+	}
+	assert.Nil(t, config.resolved)
+	_, err = config.AddNode("n3")
+	assert.NoError(t, err)
+	nodes, err = config.FindAllNodes()
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 3)
+	for _, n := range nodes {
+		if n.Id.Get() == "n1" {
+			assert.Equal(t, "updated", n.Comment.Get())
+		}
+	}
+
+	assert.NoError(t, config.DelNode("n3"))
+	nodes, err = config.FindAllNodes()
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 2)
+}
+
+func Test_FindAllNodesCopies(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	config, err := New()
+	assert.NoError(t, err)
+	config.Nodes["n1"].Profiles = []string{"default"}
+	config.Nodes["n1"].Tags = map[string]string{"rack": "1"}
+	config.Nodes["n1"].NetDevs = map[string]*NetDevs{
+		"default": {Ipaddr: "10.0.0.1", Tags: map[string]string{"vlan": "10"}},
+	}
+	nodes, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	for i := range nodes {
+		if nodes[i].Id.Get() != "n1" {
+			continue
+		}
+		n := &nodes[i]
+		n.Comment.Set("changed")
+		n.Kernel.Args.Set("quiet")
+		n.Ipmi.Ipaddr.Set("10.1.0.1")
+		n.Profiles[0] = "changed"
+		n.Tags["rack"].Set("2")
+		n.Tags["new"] = &Entry{}
+		n.NetDevs["default"].Ipaddr.Set("10.0.0.9")
+		n.NetDevs["default"].Tags["vlan"].Set("20")
+		n.NetDevs["new"] = &NetDevEntry{}
+	}
+
+	cached, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	config.modified()
+	resolved, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	assert.Equal(t, resolved, cached)
+	assert.NotEqual(t, nodes, cached)
+}
@@ -3,17 +3,16 @@ package node
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"path"
+	"reflect"
 	"sort"
 	"strings"
 
 	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/ippool"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
-
-	"gopkg.in/yaml.v2"
 )
 
 var ConfigFile string
@@ -24,17 +23,16 @@ func init() {
 	}
 }
 
+/*
+Opens the node configuration of the backend which is configured in
+warewulf.conf, nodes.conf by default
+*/
 func New() (nodeYaml, error) {
-	var ret nodeYaml
-
-	wwlog.Printf(wwlog.VERBOSE, "Opening node configuration file: %s\n", ConfigFile)
-	data, err := ioutil.ReadFile(ConfigFile)
+	db, err := newBackend()
 	if err != nil {
-		return ret, err
+		return nodeYaml{}, err
 	}
-
-	wwlog.Printf(wwlog.DEBUG, "Unmarshaling the node configuration\n")
-	err = yaml.Unmarshal(data, &ret)
+	ret, err := NewFromBackend(db)
 	if err != nil {
 		return ret, err
 	}
@@ -47,16 +45,119 @@ func New() (nodeYaml, error) {
 /*
 Get all the nodes of a configuration. This function also merges
 the nodes with the given profiles and set the default values
-for every node
+for every node. The nodes are resolved once and kept until the
+configuration is modified, the callers get copies of them and
+changes of the returned nodes must be saved with NodeUpdate().
 */
 func (config *nodeYaml) FindAllNodes() ([]NodeInfo, error) {
+	if config.resolved == nil {
+		nodes, err := config.findNodes(config.Nodes)
+		if err != nil {
+			return nodes, err
+		}
+		config.resolved = nodes
+	}
+	ret := make([]NodeInfo, len(config.resolved))
+	for i := range config.resolved {
+		ret[i] = config.resolved[i].copy()
+	}
+	return ret, nil
+}
+
+/*
+Deep copy of the node, which shares no entries, maps or slices with it
+*/
+func (n *NodeInfo) copy() NodeInfo {
+	var ret NodeInfo
+	copyValue(reflect.ValueOf(&ret).Elem(), reflect.ValueOf(*n))
+	return ret
+}
+
+func copyValue(dst reflect.Value, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		copyValue(dst.Elem(), src.Elem())
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			value := reflect.New(src.Type().Elem()).Elem()
+			copyValue(value, iter.Value())
+			dst.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Struct:
+		// the values of an entry are unexported
+		if ent, ok := src.Interface().(Entry); ok {
+			dst.Set(reflect.ValueOf(ent.copy()))
+			return
+		}
+		for i := 0; i < src.NumField(); i++ {
+			copyValue(dst.Field(i), src.Field(i))
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+func (ent Entry) copy() Entry {
+	ret := ent
+	ret.value = copyStrings(ent.value)
+	ret.altvalue = copyStrings(ent.altvalue)
+	ret.def = copyStrings(ent.def)
+	if ent.layers != nil {
+		ret.layers = make([]EntryLayer, len(ent.layers))
+		for i, layer := range ent.layers {
+			ret.layers[i] = EntryLayer{From: layer.From, Value: copyStrings(layer.Value)}
+		}
+	}
+	return ret
+}
+
+func copyStrings(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	return append([]string{}, list...)
+}
+
+/*
+Drops the resolved nodes, called by every modification of the nodes
+and profiles
+*/
+func (config *nodeYaml) modified() {
+	config.resolved = nil
+}
+
+/*
+Merges the given nodes of the configuration with their profiles
+*/
+func (config *nodeYaml) findNodes(nodes map[string]*NodeConf) ([]NodeInfo, error) {
 	var ret []NodeInfo
 	wwconfig, err := warewulfconf.New()
 	if err != nil {
 		return ret, err
 	}
+	pools, err := ippool.FromConf(wwconfig)
+	if err != nil {
+		wwlog.Warn("Ignoring networks of warewulf.conf: %s", err)
+	}
 	wwlog.Printf(wwlog.DEBUG, "Finding all nodes...\n")
-	for nodename, node := range config.Nodes {
+	for nodename, node := range nodes {
 		var n NodeInfo
 
 		wwlog.Printf(wwlog.DEBUG, "In node loop: %s\n", nodename)
@@ -70,6 +171,9 @@ func (config *nodeYaml) FindAllNodes() ([]NodeInfo, error) {
 		n.Init.SetDefault("/sbin/init")
 		n.Root.SetDefault("initramfs")
 		n.Kernel.Args.SetDefault("quiet crashkernel=no vga=791")
+		n.SystemOverlay.SetMerge(",")
+		n.RuntimeOverlay.SetMerge(",")
+		n.Kernel.Args.SetMerge(" ")
 
 		fullname := strings.SplitN(nodename, ".", 2)
 		if len(fullname) > 1 {
@@ -85,6 +189,7 @@ func (config *nodeYaml) FindAllNodes() ([]NodeInfo, error) {
 		n.Id.Set(nodename)
 		n.Comment.Set(node.Comment)
 		n.ContainerName.Set(node.ContainerName)
+		n.Arch.Set(node.Arch)
 		n.ClusterName.Set(node.ClusterName)
 		n.Ipxe.Set(node.Ipxe)
 		n.Init.Set(node.Init)
@@ -120,6 +225,7 @@ func (config *nodeYaml) FindAllNodes() ([]NodeInfo, error) {
 		n.RuntimeOverlay.SetSlice(node.RuntimeOverlay)
 		n.Root.Set(node.Root)
 		n.AssetKey.Set(node.AssetKey)
+		n.UUID.Set(node.UUID)
 		n.Discoverable.Set(node.Discoverable)
 		// backward compatibility
 		n.Kernel.Args.Set(node.KernelArgs)
@@ -164,6 +270,7 @@ func (config *nodeYaml) FindAllNodes() ([]NodeInfo, error) {
 			n.NetDevs[devname].Netmask.SetDefault("255.255.255.0")
 			n.NetDevs[devname].Hwaddr.Set(strings.ToLower(netdev.Hwaddr))
 			n.NetDevs[devname].Gateway.Set(netdev.Gateway)
+			n.NetDevs[devname].Network.Set(netdev.Network)
 			n.NetDevs[devname].Type.Set(netdev.Type)
 			n.NetDevs[devname].OnBoot.Set(netdev.OnBoot)
 			n.NetDevs[devname].Primary.Set(netdev.Primary)
@@ -200,90 +307,24 @@ func (config *nodeYaml) FindAllNodes() ([]NodeInfo, error) {
 			n.Tags[keyname].Set(key)
 		}
 
-		for _, p := range n.Profiles {
-			if _, ok := config.NodeProfiles[p]; !ok {
-				wwlog.Printf(wwlog.WARN, "Profile not found for node '%s': %s\n", nodename, p)
-				continue
-			}
-
-			wwlog.Printf(wwlog.VERBOSE, "Merging profile into node: %s <- %s\n", nodename, p)
-
-			n.Comment.SetAlt(config.NodeProfiles[p].Comment, p)
-			n.ClusterName.SetAlt(config.NodeProfiles[p].ClusterName, p)
-			n.ContainerName.SetAlt(config.NodeProfiles[p].ContainerName, p)
-			if config.NodeProfiles[p].Kernel != nil {
-				n.Kernel.Args.SetAlt(config.NodeProfiles[p].Kernel.Args, p)
-			}
-			n.Ipxe.SetAlt(config.NodeProfiles[p].Ipxe, p)
-			n.Init.SetAlt(config.NodeProfiles[p].Init, p)
-			if config.NodeProfiles[p].Ipmi != nil {
-				n.Ipmi.Ipaddr.SetAlt(config.NodeProfiles[p].Ipmi.Ipaddr, p)
-				n.Ipmi.Netmask.SetAlt(config.NodeProfiles[p].Ipmi.Netmask, p)
-				n.Ipmi.Port.SetAlt(config.NodeProfiles[p].Ipmi.Port, p)
-				n.Ipmi.Gateway.SetAlt(config.NodeProfiles[p].Ipmi.Gateway, p)
-				n.Ipmi.UserName.SetAlt(config.NodeProfiles[p].Ipmi.UserName, p)
-				n.Ipmi.Password.SetAlt(config.NodeProfiles[p].Ipmi.Password, p)
-				n.Ipmi.Interface.SetAlt(config.NodeProfiles[p].Ipmi.Interface, p)
-				n.Ipmi.Write.SetAlt(config.NodeProfiles[p].Ipmi.Write, p)
-			}
-			n.SystemOverlay.SetAltSlice(config.NodeProfiles[p].SystemOverlay, p)
-			n.RuntimeOverlay.SetAltSlice(config.NodeProfiles[p].RuntimeOverlay, p)
-			n.Root.SetAlt(config.NodeProfiles[p].Root, p)
-			n.AssetKey.SetAlt(config.NodeProfiles[p].AssetKey, p)
-			n.Discoverable.SetAlt(config.NodeProfiles[p].Discoverable, p)
-
-			if config.NodeProfiles[p].Kernel != nil {
-				if config.NodeProfiles[p].Kernel.Override != "" {
-					n.Kernel.Override.SetAlt(config.NodeProfiles[p].Kernel.Override, p)
-				} else if config.NodeProfiles[p].Kernel.Version != "" {
-					n.Kernel.Override.SetAlt(config.NodeProfiles[p].Kernel.Version, p)
-				}
-			}
-
-			for devname, netdev := range config.NodeProfiles[p].NetDevs {
-				if _, ok := n.NetDevs[devname]; !ok {
-					var netdev NetDevEntry
-					n.NetDevs[devname] = &netdev
-				}
-				wwlog.Printf(wwlog.DEBUG, "Updating profile (%s) netdev: %s\n", p, devname)
-
-				n.NetDevs[devname].Device.SetAlt(netdev.Device, p)
-				n.NetDevs[devname].Ipaddr.SetAlt(netdev.Ipaddr, p) //FIXME? <- Ipaddr must be uniq
-				n.NetDevs[devname].Netmask.SetAlt(netdev.Netmask, p)
-				n.NetDevs[devname].Hwaddr.SetAlt(strings.ToLower(netdev.Hwaddr), p)
-				n.NetDevs[devname].Gateway.SetAlt(netdev.Gateway, p)
-				n.NetDevs[devname].Type.SetAlt(netdev.Type, p)
-				n.NetDevs[devname].OnBoot.SetAlt(netdev.OnBoot, p)
-				n.NetDevs[devname].Primary.SetAlt(netdev.Primary, p)
-				if len(netdev.Tags) != 0 {
-					if len(n.NetDevs[devname].Tags) == 0 {
-						n.NetDevs[devname].Tags = make(map[string]*Entry)
-					}
-					for keyname, key := range netdev.Tags {
-						if _, ok := n.NetDevs[devname].Tags[keyname]; !ok {
-							var keyVar Entry
-							n.NetDevs[devname].Tags[keyname] = &keyVar
-						}
-						n.NetDevs[devname].Tags[keyname].SetAlt(key, p)
-					}
-				}
-			}
+		n.setDisks(node)
 
-			// Merge Keys into Tags for backwards compatibility
-			if len(config.NodeProfiles[p].Tags) == 0 {
-				config.NodeProfiles[p].Tags = make(map[string]string)
-			}
-			for keyname, key := range config.NodeProfiles[p].Keys {
-				config.NodeProfiles[p].Tags[keyname] = key
-				delete(config.NodeProfiles[p].Keys, keyname)
-			}
+		profiles, err := config.ResolveProfiles(n.Profiles)
+		if err != nil {
+			wwlog.Warn("Node %s: %s", nodename, err)
+		}
+		n.AllProfiles = profiles
+		for _, p := range profiles {
+			config.mergeProfile(&n, p)
+		}
 
-			for keyname, key := range config.NodeProfiles[p].Tags {
-				if _, ok := n.Tags[keyname]; !ok {
-					var key Entry
-					n.Tags[keyname] = &key
+		// netmask and gateway default to the ones of the network
+		for _, netdev := range n.NetDevs {
+			if pool, ok := pools[netdev.Network.Get()]; ok {
+				netdev.Netmask.SetDefault(pool.Netmask())
+				if pool.Gateway != nil {
+					netdev.Gateway.SetDefault(pool.Gateway.String())
 				}
-				n.Tags[keyname].SetAlt(key, p)
 			}
 		}
 
@@ -305,6 +346,141 @@ func (config *nodeYaml) FindAllNodes() ([]NodeInfo, error) {
 	return ret, nil
 }
 
+/*
+Applies the values of profile p to n, values which are already set by
+earlier profiles are overwritten
+*/
+func (config *nodeYaml) mergeProfile(n *NodeInfo, p string) {
+	if _, ok := config.NodeProfiles[p]; !ok {
+		wwlog.Printf(wwlog.WARN, "Profile not found for '%s': %s\n", n.Id.Get(), p)
+		return
+	}
+
+	wwlog.Printf(wwlog.VERBOSE, "Merging profile into node: %s <- %s\n", n.Id.Get(), p)
+
+	n.Comment.SetAlt(config.NodeProfiles[p].Comment, p)
+	n.ClusterName.SetAlt(config.NodeProfiles[p].ClusterName, p)
+	n.ContainerName.SetAlt(config.NodeProfiles[p].ContainerName, p)
+	n.Arch.SetAlt(config.NodeProfiles[p].Arch, p)
+	if config.NodeProfiles[p].Kernel != nil {
+		n.Kernel.Args.SetAlt(config.NodeProfiles[p].Kernel.Args, p)
+	}
+	n.Ipxe.SetAlt(config.NodeProfiles[p].Ipxe, p)
+	n.Init.SetAlt(config.NodeProfiles[p].Init, p)
+	if config.NodeProfiles[p].Ipmi != nil {
+		n.Ipmi.Ipaddr.SetAlt(config.NodeProfiles[p].Ipmi.Ipaddr, p)
+		n.Ipmi.Netmask.SetAlt(config.NodeProfiles[p].Ipmi.Netmask, p)
+		n.Ipmi.Port.SetAlt(config.NodeProfiles[p].Ipmi.Port, p)
+		n.Ipmi.Gateway.SetAlt(config.NodeProfiles[p].Ipmi.Gateway, p)
+		n.Ipmi.UserName.SetAlt(config.NodeProfiles[p].Ipmi.UserName, p)
+		n.Ipmi.Password.SetAlt(config.NodeProfiles[p].Ipmi.Password, p)
+		n.Ipmi.Interface.SetAlt(config.NodeProfiles[p].Ipmi.Interface, p)
+		n.Ipmi.Write.SetAlt(config.NodeProfiles[p].Ipmi.Write, p)
+	}
+	n.SystemOverlay.SetAltSlice(config.NodeProfiles[p].SystemOverlay, p)
+	n.RuntimeOverlay.SetAltSlice(config.NodeProfiles[p].RuntimeOverlay, p)
+	n.Root.SetAlt(config.NodeProfiles[p].Root, p)
+	n.AssetKey.SetAlt(config.NodeProfiles[p].AssetKey, p)
+	n.Discoverable.SetAlt(config.NodeProfiles[p].Discoverable, p)
+
+	if config.NodeProfiles[p].Kernel != nil {
+		if config.NodeProfiles[p].Kernel.Override != "" {
+			n.Kernel.Override.SetAlt(config.NodeProfiles[p].Kernel.Override, p)
+		} else if config.NodeProfiles[p].Kernel.Version != "" {
+			n.Kernel.Override.SetAlt(config.NodeProfiles[p].Kernel.Version, p)
+		}
+	}
+
+	for devname, netdev := range config.NodeProfiles[p].NetDevs {
+		if _, ok := n.NetDevs[devname]; !ok {
+			var netdev NetDevEntry
+			n.NetDevs[devname] = &netdev
+		}
+		wwlog.Printf(wwlog.DEBUG, "Updating profile (%s) netdev: %s\n", p, devname)
+
+		n.NetDevs[devname].Device.SetAlt(netdev.Device, p)
+		n.NetDevs[devname].Ipaddr.SetAlt(netdev.Ipaddr, p) //FIXME? <- Ipaddr must be uniq
+		n.NetDevs[devname].Netmask.SetAlt(netdev.Netmask, p)
+		n.NetDevs[devname].Hwaddr.SetAlt(strings.ToLower(netdev.Hwaddr), p)
+		n.NetDevs[devname].Gateway.SetAlt(netdev.Gateway, p)
+		n.NetDevs[devname].Network.SetAlt(netdev.Network, p)
+		n.NetDevs[devname].Type.SetAlt(netdev.Type, p)
+		n.NetDevs[devname].OnBoot.SetAlt(netdev.OnBoot, p)
+		n.NetDevs[devname].Primary.SetAlt(netdev.Primary, p)
+		if len(netdev.Tags) != 0 {
+			if len(n.NetDevs[devname].Tags) == 0 {
+				n.NetDevs[devname].Tags = make(map[string]*Entry)
+			}
+			for keyname, key := range netdev.Tags {
+				if _, ok := n.NetDevs[devname].Tags[keyname]; !ok {
+					var keyVar Entry
+					n.NetDevs[devname].Tags[keyname] = &keyVar
+				}
+				n.NetDevs[devname].Tags[keyname].SetAlt(key, p)
+			}
+		}
+	}
+
+	// Merge Keys into Tags for backwards compatibility
+	if len(config.NodeProfiles[p].Tags) == 0 {
+		config.NodeProfiles[p].Tags = make(map[string]string)
+	}
+	for keyname, key := range config.NodeProfiles[p].Keys {
+		config.NodeProfiles[p].Tags[keyname] = key
+		delete(config.NodeProfiles[p].Keys, keyname)
+	}
+
+	for keyname, key := range config.NodeProfiles[p].Tags {
+		if _, ok := n.Tags[keyname]; !ok {
+			var key Entry
+			n.Tags[keyname] = &key
+		}
+		n.Tags[keyname].SetAlt(key, p)
+	}
+
+	n.setAltDisks(config.NodeProfiles[p], p)
+}
+
+/*
+Expands a list of profiles with the profiles they include, recursively. The
+included profiles come before the including one, every profile is applied
+only once at its first position. Profiles which would create a cycle are
+skipped and reported in the error, as are missing ones.
+*/
+func (config *nodeYaml) ResolveProfiles(names []string) ([]string, error) {
+	var ret []string
+	var problems []string
+	seen := make(map[string]bool)
+	var walk func(name string, path []string)
+	walk = func(name string, path []string) {
+		for _, p := range path {
+			if p == name {
+				problems = append(problems, "profile cycle: "+strings.Join(append(path, name), " -> "))
+				return
+			}
+		}
+		if seen[name] {
+			return
+		}
+		if profile, ok := config.NodeProfiles[name]; ok {
+			for _, parent := range profile.Profiles {
+				walk(parent, append(path, name))
+			}
+		}
+		if !seen[name] {
+			seen[name] = true
+			ret = append(ret, name)
+		}
+	}
+	for _, name := range names {
+		walk(name, nil)
+	}
+	if len(problems) > 0 {
+		return ret, errors.New(strings.Join(problems, ", "))
+	}
+	return ret, nil
+}
+
 func (config *nodeYaml) FindAllProfiles() ([]NodeInfo, error) {
 	var ret []NodeInfo
 
@@ -314,10 +490,15 @@ func (config *nodeYaml) FindAllProfiles() ([]NodeInfo, error) {
 		p.Tags = make(map[string]*Entry)
 		p.Kernel = new(KernelEntry)
 		p.Ipmi = new(IpmiEntry)
+		p.SystemOverlay.SetMerge(",")
+		p.RuntimeOverlay.SetMerge(",")
+		p.Kernel.Args.SetMerge(" ")
 		p.Id.Set(name)
+		p.Profiles = profile.Profiles
 		p.Comment.Set(profile.Comment)
 		p.ClusterName.Set(profile.ClusterName)
 		p.ContainerName.Set(profile.ContainerName)
+		p.Arch.Set(profile.Arch)
 		p.Ipxe.Set(profile.Ipxe)
 		p.Init.Set(profile.Init)
 		// backward compatibility
@@ -378,6 +559,7 @@ func (config *nodeYaml) FindAllProfiles() ([]NodeInfo, error) {
 			p.NetDevs[devname].Device.Set(netdev.Device)
 			p.NetDevs[devname].Netmask.Set(netdev.Netmask)
 			p.NetDevs[devname].Gateway.Set(netdev.Gateway)
+			p.NetDevs[devname].Network.Set(netdev.Network)
 			p.NetDevs[devname].Type.Set(netdev.Type)
 			p.NetDevs[devname].OnBoot.Set(netdev.OnBoot)
 			p.NetDevs[devname].Primary.Set(netdev.Primary)
@@ -418,6 +600,19 @@ func (config *nodeYaml) FindAllProfiles() ([]NodeInfo, error) {
 			p.Tags[keyname].Set(key)
 		}
 
+		p.setDisks(profile)
+
+		// the included profiles provide the inherited values
+		parents, err := config.ResolveProfiles(profile.Profiles)
+		if err != nil {
+			wwlog.Warn("Profile %s: %s", name, err)
+		}
+		for _, parent := range parents {
+			if parent != name {
+				config.mergeProfile(&p, parent)
+			}
+		}
+
 		// TODO: Validate or die on all inputs
 
 		ret = append(ret, p)
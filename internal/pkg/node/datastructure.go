@@ -13,39 +13,48 @@ type nodeYaml struct {
 	WWInternal   int `yaml:"WW_INTERNAL"`
 	NodeProfiles map[string]*NodeConf
 	Nodes        map[string]*NodeConf
+	// storage the configuration was loaded from
+	db backend
+	// nodes resolved by FindAllNodes(), nil after a modification
+	resolved []NodeInfo
 }
 
 /*
 NodeConf is the datastructure which is stored on disk.
 */
 type NodeConf struct {
-	Comment        string              `yaml:"comment,omitempty"`
-	ClusterName    string              `yaml:"cluster name,omitempty"`
-	ContainerName  string              `yaml:"container name,omitempty"`
-	Ipxe           string              `yaml:"ipxe template,omitempty"`
-	KernelVersion  string              `yaml:"kernel version,omitempty"`
-	KernelOverride string              `yaml:"kernel override,omitempty"`
-	KernelArgs     string              `yaml:"kernel args,omitempty"`
-	IpmiUserName   string              `yaml:"ipmi username,omitempty"`
-	IpmiPassword   string              `yaml:"ipmi password,omitempty"`
-	IpmiIpaddr     string              `yaml:"ipmi ipaddr,omitempty"`
-	IpmiNetmask    string              `yaml:"ipmi netmask,omitempty"`
-	IpmiPort       string              `yaml:"ipmi port,omitempty"`
-	IpmiGateway    string              `yaml:"ipmi gateway,omitempty"`
-	IpmiInterface  string              `yaml:"ipmi interface,omitempty"`
-	IpmiWrite      string              `yaml:"ipmi write,omitempty"`
-	RuntimeOverlay []string            `yaml:"runtime overlay,omitempty"`
-	SystemOverlay  []string            `yaml:"system overlay,omitempty"`
-	Kernel         *KernelConf         `yaml:"kernel,omitempty"`
-	Ipmi           *IpmiConf           `yaml:"ipmi,omitempty"`
-	Init           string              `yaml:"init,omitempty"`
-	Root           string              `yaml:"root,omitempty"`
-	AssetKey       string              `yaml:"asset key,omitempty"`
-	Discoverable   string              `yaml:"discoverable,omitempty"`
-	Profiles       []string            `yaml:"profiles,omitempty"`
-	NetDevs        map[string]*NetDevs `yaml:"network devices,omitempty"`
-	Tags           map[string]string   `yaml:"tags,omitempty"`
-	Keys           map[string]string   `yaml:"keys,omitempty"` // Reverse compatibility
+	Comment        string                 `yaml:"comment,omitempty"`
+	ClusterName    string                 `yaml:"cluster name,omitempty"`
+	ContainerName  string                 `yaml:"container name,omitempty"`
+	Arch           string                 `yaml:"arch,omitempty"`
+	Ipxe           string                 `yaml:"ipxe template,omitempty"`
+	KernelVersion  string                 `yaml:"kernel version,omitempty"`
+	KernelOverride string                 `yaml:"kernel override,omitempty"`
+	KernelArgs     string                 `yaml:"kernel args,omitempty"`
+	IpmiUserName   string                 `yaml:"ipmi username,omitempty"`
+	IpmiPassword   string                 `yaml:"ipmi password,omitempty"`
+	IpmiIpaddr     string                 `yaml:"ipmi ipaddr,omitempty"`
+	IpmiNetmask    string                 `yaml:"ipmi netmask,omitempty"`
+	IpmiPort       string                 `yaml:"ipmi port,omitempty"`
+	IpmiGateway    string                 `yaml:"ipmi gateway,omitempty"`
+	IpmiInterface  string                 `yaml:"ipmi interface,omitempty"`
+	IpmiWrite      string                 `yaml:"ipmi write,omitempty"`
+	RuntimeOverlay []string               `yaml:"runtime overlay,omitempty"`
+	SystemOverlay  []string               `yaml:"system overlay,omitempty"`
+	Kernel         *KernelConf            `yaml:"kernel,omitempty"`
+	Ipmi           *IpmiConf              `yaml:"ipmi,omitempty"`
+	Init           string                 `yaml:"init,omitempty"`
+	Root           string                 `yaml:"root,omitempty"`
+	AssetKey       string                 `yaml:"asset key,omitempty"`
+	UUID           string                 `yaml:"uuid,omitempty"`
+	Discoverable   string                 `yaml:"discoverable,omitempty"`
+	Profiles       []string               `yaml:"profiles,omitempty"`
+	NetDevs        map[string]*NetDevs    `yaml:"network devices,omitempty"`
+	Tags           map[string]string      `yaml:"tags,omitempty"`
+	Keys           map[string]string      `yaml:"keys,omitempty"` // Reverse compatibility
+	Disks          map[string]*Disk       `yaml:"disks,omitempty"`
+	Raids          map[string]*Raid       `yaml:"raids,omitempty"`
+	FileSystems    map[string]*FileSystem `yaml:"filesystems,omitempty"`
 }
 
 type IpmiConf struct {
@@ -75,24 +84,82 @@ type NetDevs struct {
 	Prefix  string            `yaml:"prefix,omitempty"`
 	Netmask string            `yaml:"netmask,omitempty"`
 	Gateway string            `yaml:"gateway,omitempty"`
+	Network string            `yaml:"network,omitempty"`
 	Primary string            `yaml:"primary,omitempty"`
 	Default string            `yaml:"default,omitempty"` /* backward compatibility */
 	Tags    map[string]string `yaml:"tags,omitempty"`
 }
 
+/*
+A local disk by its device path, the partitions are indexed by their label.
+If an id or a size is set, the name is only a label and the device is the
+first disk whose /dev/disk/by-id link matches the id glob and which has at
+least the size in GiB. The wipe policy is never, always or mismatch.
+*/
+type Disk struct {
+	Id         string                `yaml:"id,omitempty"`
+	SizeGiB    string                `yaml:"size,omitempty"`
+	WipeTable  string                `yaml:"wipe table,omitempty"`
+	Partitions map[string]*Partition `yaml:"partitions,omitempty"`
+}
+
+/*
+A partition of a disk, the size is in MiB and an empty size fills the rest
+of the disk. The type is a GPT type code, e.g. 8300 or fd00.
+*/
+type Partition struct {
+	Number  string `yaml:"number,omitempty"`
+	SizeMiB string `yaml:"size,omitempty"`
+	Type    string `yaml:"type,omitempty"`
+}
+
+/*
+A software RAID which is created as /dev/md/NAME
+*/
+type Raid struct {
+	Level   string   `yaml:"level,omitempty"`
+	Devices []string `yaml:"devices,omitempty"`
+}
+
+/*
+A file system by its device path, swap is a file system of the format swap.
+The wipe policy is never, always or mismatch.
+*/
+type FileSystem struct {
+	Format         string `yaml:"format,omitempty"`
+	Path           string `yaml:"path,omitempty"`
+	WipeFileSystem string `yaml:"wipe filesystem,omitempty"`
+	MountOptions   string `yaml:"mount options,omitempty"`
+}
+
 /******
  * Internal code data representations
  ******/
 /*
 Holds string values, when accessed via Get, its value
 is returned which is the default or if set the value
-from the profile or if set the value of the node itself
+from the profile or if set the value of the node itself.
+Profiles are applied in their order, so the last one wins.
+For list entries (merge is set) a value starting with '+'
+is appended to the inherited value instead of replacing it.
 */
 type Entry struct {
 	value    []string
 	altvalue []string
 	from     string
 	def      []string
+	// separator of appended values, empty if values replace each other
+	merge string
+	// values set by the profiles, in the order they were applied
+	layers []EntryLayer
+}
+
+/*
+A value which was set for an entry by a profile, the default or the node
+*/
+type EntryLayer struct {
+	From  string
+	Value []string
 }
 
 /*
@@ -107,6 +174,7 @@ type NodeInfo struct {
 	Comment        Entry
 	ClusterName    Entry
 	ContainerName  Entry
+	Arch           Entry
 	Ipxe           Entry
 	RuntimeOverlay Entry
 	SystemOverlay  Entry
@@ -114,12 +182,19 @@ type NodeInfo struct {
 	Discoverable   Entry
 	Init           Entry //TODO: Finish adding this...
 	AssetKey       Entry
+	UUID           Entry
 	Kernel         *KernelEntry
 	Ipmi           *IpmiEntry
 	Profiles       []string
 	GroupProfiles  []string
-	NetDevs        map[string]*NetDevEntry
-	Tags           map[string]*Entry
+	// profiles applied to the node with the ones they include, in the
+	// order they are applied
+	AllProfiles []string
+	NetDevs     map[string]*NetDevEntry
+	Tags        map[string]*Entry
+	Disks       map[string]*DiskEntry
+	Raids       map[string]*RaidEntry
+	FileSystems map[string]*FileSystemEntry
 }
 
 type IpmiEntry struct {
@@ -150,10 +225,36 @@ type NetDevEntry struct {
 	Prefix  Entry
 	Netmask Entry
 	Gateway Entry
+	Network Entry
 	Primary Entry
 	Tags    map[string]*Entry
 }
 
+type DiskEntry struct {
+	Id         Entry
+	SizeGiB    Entry
+	WipeTable  Entry
+	Partitions map[string]*PartitionEntry
+}
+
+type PartitionEntry struct {
+	Number  Entry
+	SizeMiB Entry
+	Type    Entry
+}
+
+type RaidEntry struct {
+	Level   Entry
+	Devices Entry
+}
+
+type FileSystemEntry struct {
+	Format         Entry
+	Path           Entry
+	WipeFileSystem Entry
+	MountOptions   Entry
+}
+
 func init() {
 	// Check that nodes.conf is found
 	if !util.IsFile(ConfigFile) {
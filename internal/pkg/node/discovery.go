@@ -0,0 +1,256 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+/*
+The properties of an unknown node which requested a boot, they are matched
+against the discovery rules of warewulf.conf
+*/
+type DiscoveryRequest struct {
+	Hwaddr    string
+	Ipaddr    string
+	UUID      string
+	Serial    string
+	AssetTag  string
+	CircuitId string
+	RemoteId  string
+}
+
+func (req DiscoveryRequest) values() map[string]string {
+	return map[string]string{
+		"hwaddr":    req.Hwaddr,
+		"ipaddr":    req.Ipaddr,
+		"uuid":      req.UUID,
+		"serial":    req.Serial,
+		"assettag":  req.AssetTag,
+		"circuitid": req.CircuitId,
+		"remoteid":  req.RemoteId,
+	}
+}
+
+var validNodeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+var discoveryFuncs = template.FuncMap{
+	"atoi": func(s string) int {
+		i, _ := strconv.Atoi(s)
+		return i
+	},
+	"lower":   strings.ToLower,
+	"replace": strings.ReplaceAll,
+}
+
+/*
+A compiled rule of warewulf.conf
+*/
+type DiscoveryRule struct {
+	match    map[string]*regexp.Regexp
+	node     *template.Template
+	NetDev   string
+	Create   bool
+	Profiles []string
+}
+
+/*
+Compiles the discovery rules, the expressions of match have to match the
+whole value of the property
+*/
+func CompileDiscoveryRules(conf *warewulfconf.DiscoveryConf) ([]DiscoveryRule, error) {
+	var ret []DiscoveryRule
+	if conf == nil {
+		return ret, nil
+	}
+	known := DiscoveryRequest{}.values()
+	for i, r := range conf.Rules {
+		if r == nil {
+			continue
+		}
+		if r.Node == "" {
+			return nil, fmt.Errorf("rule %d: no node name given", i+1)
+		}
+		rule := DiscoveryRule{
+			match:    make(map[string]*regexp.Regexp),
+			NetDev:   r.NetDev,
+			Create:   r.Create,
+			Profiles: r.Profiles,
+		}
+		for key, expr := range r.Match {
+			if _, ok := known[key]; !ok {
+				return nil, fmt.Errorf("rule %d: unknown property to match: %s", i+1, key)
+			}
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				return nil, errors.Wrapf(err, "rule %d: invalid expression for %s", i+1, key)
+			}
+			rule.match[key] = re
+		}
+		tmpl, err := template.New(fmt.Sprintf("rule %d", i+1)).Funcs(discoveryFuncs).Option("missingkey=error").Parse(r.Node)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule %d: invalid node name template", i+1)
+		}
+		rule.node = tmpl
+		ret = append(ret, rule)
+	}
+	return ret, nil
+}
+
+/*
+Returns the name of the node for the request and true if the rule matches.
+The template of the name gets the properties of the request and the named
+groups of the expressions.
+*/
+func (rule DiscoveryRule) NodeName(req DiscoveryRequest) (string, bool, error) {
+	values := req.values()
+	data := make(map[string]string)
+	for key, value := range values {
+		data[key] = value
+	}
+	keys := make([]string, 0, len(rule.match))
+	for key := range rule.match {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		re := rule.match[key]
+		m := re.FindStringSubmatch(values[key])
+		if m == nil {
+			return "", false, nil
+		}
+		for i, name := range re.SubexpNames() {
+			if name != "" {
+				data[name] = m[i]
+			}
+		}
+	}
+	var buf bytes.Buffer
+	err := rule.node.Execute(&buf, data)
+	if err != nil {
+		return "", true, err
+	}
+	name := strings.TrimSpace(buf.String())
+	if !validNodeName.MatchString(name) {
+		return "", true, fmt.Errorf("invalid node name from %s: %q", rule.node.Name(), name)
+	}
+	return name, true, nil
+}
+
+/*
+Network device of the node the hardware address is bound to: the one of the
+rule, else the first one without a hardware address
+*/
+func discoveryNetDev(n NodeInfo, netdev string) (string, error) {
+	if netdev == "" {
+		for _, name := range sortedNetDevEntries(n.NetDevs) {
+			if !n.NetDevs[name].Hwaddr.Defined() {
+				netdev = name
+				break
+			}
+		}
+	}
+	if netdev == "" {
+		netdev = "default"
+	}
+	if dev, ok := n.NetDevs[netdev]; ok && dev.Hwaddr.Defined() {
+		return "", fmt.Errorf("%s:%s has already the hardware address %s", n.Id.Get(), netdev, dev.Hwaddr.Get())
+	}
+	return netdev, nil
+}
+
+/*
+Sets the hardware address on a network device of a node, which is not yet
+saved. The device is the given one or the first one without a hardware
+address. The node is no longer discoverable afterwards.
+*/
+func (config *nodeYaml) BindHwaddr(nodeID string, netdev string, hwaddr string) (NodeInfo, string, error) {
+	var ret NodeInfo
+	if _, err := net.ParseMAC(hwaddr); err != nil {
+		return ret, "", fmt.Errorf("invalid hardware address: %s", hwaddr)
+	}
+	if other, err := config.FindByHwaddr(hwaddr); err == nil {
+		return ret, "", fmt.Errorf("%s is already used by node %s", hwaddr, other.Id.Get())
+	}
+	if _, ok := config.Nodes[nodeID]; !ok {
+		return ret, "", fmt.Errorf("node does not exist: %s", nodeID)
+	}
+	nodes, err := config.findNodes(map[string]*NodeConf{nodeID: config.Nodes[nodeID]})
+	if err != nil {
+		return ret, "", errors.Wrapf(err, "could not read node %s", nodeID)
+	}
+	n := nodes[0]
+	netdev, err = discoveryNetDev(n, netdev)
+	if err != nil {
+		return ret, "", err
+	}
+	if _, ok := n.NetDevs[netdev]; !ok {
+		n.NetDevs[netdev] = new(NetDevEntry)
+		n.NetDevs[netdev].Tags = make(map[string]*Entry)
+	}
+	n.NetDevs[netdev].Hwaddr.Set(strings.ToLower(hwaddr))
+	if n.Discoverable.GetB() {
+		n.Discoverable.SetB(false)
+	}
+	return n, netdev, nil
+}
+
+/*
+Finds the node for an unknown machine with the discovery rules, the first
+matching rule names the node. Nodes which don't exist are added if the rule
+allows it. If no rule matches and fallback is set, the first discoverable node
+is used. The hardware address is set on the returned node, which is not yet
+saved. Returns an empty node if nothing matches.
+*/
+func (config *nodeYaml) DiscoverNode(req DiscoveryRequest, rules []DiscoveryRule, fallback bool) (NodeInfo, string, error) {
+	var ret NodeInfo
+	for _, rule := range rules {
+		name, ok, err := rule.NodeName(req)
+		if err != nil {
+			return ret, "", err
+		}
+		if !ok {
+			continue
+		}
+		wwlog.Verbose("%s matches %s, node: %s", req.Hwaddr, rule.node.Name(), name)
+		if _, ok := config.Nodes[name]; !ok {
+			if !rule.Create {
+				return ret, "", fmt.Errorf("node %s does not exist", name)
+			}
+			n, err := config.AddNode(name)
+			if err != nil {
+				return ret, "", err
+			}
+			if len(rule.Profiles) > 0 {
+				n.Profiles = rule.Profiles
+			}
+			err = config.NodeUpdate(n)
+			if err != nil {
+				return ret, "", err
+			}
+		}
+		return config.BindHwaddr(name, rule.NetDev, req.Hwaddr)
+	}
+
+	if !fallback {
+		return ret, "", nil
+	}
+	n, netdev, err := config.FindDiscoverableNode()
+	if err != nil {
+		// no discoverable node
+		return ret, "", nil
+	}
+	n.NetDevs[netdev].Hwaddr.Set(req.Hwaddr)
+	n.Discoverable.SetB(false)
+	return n, netdev, nil
+}
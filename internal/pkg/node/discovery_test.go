@@ -0,0 +1,160 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+)
+
+const discoveryConfig = `WW_INTERNAL: 43
+nodeprofiles:
+  default:
+    network devices:
+      default:
+        network: compute
+  gpu:
+    container name: gpu
+nodes:
+  n0001:
+    profiles:
+    - default
+    discoverable: "true"
+  n0002:
+    profiles:
+    - default
+    network devices:
+      default:
+        hwaddr: 00:00:00:00:00:02
+  n0003:
+    profiles:
+    - default
+    discoverable: "true"
+`
+
+var discoveryRules = []*warewulfconf.DiscoveryRule{
+	{Match: map[string]string{"serial": "SN-(?P<num>[0-9]+)"}, Node: `n{{printf "%04d" (atoi .num)}}`},
+	{Match: map[string]string{"circuitid": `Eth1/(?P<port>\d+)`, "remoteid": "(?P<switch>sw[0-9]+)"}, Node: "{{.switch}}-p{{.port}}", Create: true, Profiles: []string{"default", "gpu"}},
+	{Match: map[string]string{"uuid": "4c4c4544-.*"}, Node: "{{lower .assettag}}"},
+}
+
+func Test_DiscoverNode(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       DiscoveryRequest
+		fallback  bool
+		node      string
+		netdev    string
+		profiles  []string
+		container string
+		err       bool
+	}{
+		{
+			name:   "serial",
+			req:    DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1", Serial: "SN-3"},
+			node:   "n0003",
+			netdev: "default",
+		},
+		{
+			name: "serial of configured node",
+			req:  DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1", Serial: "SN-2"},
+			err:  true,
+		},
+		{
+			name: "serial of missing node",
+			req:  DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1", Serial: "SN-9"},
+			err:  true,
+		},
+		{
+			name:      "switch port creates node",
+			req:       DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1", CircuitId: "Eth1/7", RemoteId: "sw2"},
+			node:      "sw2-p7",
+			netdev:    "default",
+			profiles:  []string{"default", "gpu"},
+			container: "gpu",
+		},
+		{
+			name: "hwaddr of other node",
+			req:  DiscoveryRequest{Hwaddr: "00:00:00:00:00:02", Serial: "SN-3"},
+			err:  true,
+		},
+		{
+			name: "partial match",
+			req:  DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1", CircuitId: "Eth1/7x", RemoteId: "sw2"},
+		},
+		{
+			name: "empty name",
+			req:  DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1", UUID: "4c4c4544-0000"},
+			err:  true,
+		},
+		{
+			name: "no match without fallback",
+			req:  DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1"},
+		},
+		{
+			name:     "fallback",
+			req:      DiscoveryRequest{Hwaddr: "00:00:00:00:00:a1"},
+			fallback: true,
+			node:     "n0001",
+			netdev:   "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config nodeYaml
+			assert.NoError(t, yaml.Unmarshal([]byte(discoveryConfig), &config))
+			rules, err := CompileDiscoveryRules(&warewulfconf.DiscoveryConf{Rules: discoveryRules})
+			assert.NoError(t, err)
+
+			n, netdev, err := config.DiscoverNode(tt.req, rules, tt.fallback)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.node, n.Id.Get())
+			assert.Equal(t, tt.netdev, netdev)
+			if tt.node == "" {
+				return
+			}
+			assert.Equal(t, tt.req.Hwaddr, n.NetDevs[netdev].Hwaddr.Get())
+			assert.False(t, n.Discoverable.GetB())
+			if tt.profiles != nil {
+				assert.Equal(t, tt.profiles, n.Profiles)
+			}
+			assert.Equal(t, tt.container, n.ContainerName.Get())
+
+			assert.NoError(t, config.NodeUpdate(n))
+			found, err := config.FindByHwaddr(tt.req.Hwaddr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.node, found.Id.Get())
+		})
+	}
+}
+
+func Test_CompileDiscoveryRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule warewulfconf.DiscoveryRule
+		err  bool
+	}{
+		{name: "valid", rule: warewulfconf.DiscoveryRule{Match: map[string]string{"assettag": "R(?P<rack>[0-9]+)"}, Node: "r{{.rack}}"}},
+		{name: "unknown property", rule: warewulfconf.DiscoveryRule{Match: map[string]string{"port": "1"}, Node: "n1"}, err: true},
+		{name: "invalid expression", rule: warewulfconf.DiscoveryRule{Match: map[string]string{"serial": "("}, Node: "n1"}, err: true},
+		{name: "invalid template", rule: warewulfconf.DiscoveryRule{Node: "{{.serial"}, err: true},
+		{name: "no node", rule: warewulfconf.DiscoveryRule{Match: map[string]string{"serial": ".*"}}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			_, err := CompileDiscoveryRules(&warewulfconf.DiscoveryConf{Rules: []*warewulfconf.DiscoveryRule{&rule}})
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+The wipe policies of partition tables and file systems
+*/
+const (
+	WipeNever    = "never"
+	WipeAlways   = "always"
+	WipeMismatch = "mismatch"
+)
+
+/*
+Returns the wipe policy of value, yes and true are the same as always, an
+empty value, no and false are the same as never
+*/
+func WipePolicy(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", "no", "false", WipeNever:
+		return WipeNever, nil
+	case "yes", "true", WipeAlways:
+		return WipeAlways, nil
+	case WipeMismatch:
+		return WipeMismatch, nil
+	}
+	return WipeNever, fmt.Errorf("unknown wipe policy: %s", value)
+}
+
+/*
+Sets the disks, raids and file systems of the node configuration conf as the
+values of the node
+*/
+func (n *NodeInfo) setDisks(conf *NodeConf) {
+	n.initDisks()
+	for diskname, disk := range conf.Disks {
+		if disk == nil {
+			continue
+		}
+		d := n.Disk(diskname)
+		d.Id.Set(disk.Id)
+		d.SizeGiB.Set(disk.SizeGiB)
+		d.WipeTable.Set(disk.WipeTable)
+		for partname, part := range disk.Partitions {
+			if part == nil {
+				continue
+			}
+			p := d.Partition(partname)
+			p.Number.Set(part.Number)
+			p.SizeMiB.Set(part.SizeMiB)
+			p.Type.Set(part.Type)
+		}
+	}
+	for raidname, raid := range conf.Raids {
+		if raid == nil {
+			continue
+		}
+		r := n.Raid(raidname)
+		r.Level.Set(raid.Level)
+		r.Devices.SetSlice(raid.Devices)
+	}
+	for fsname, fs := range conf.FileSystems {
+		if fs == nil {
+			continue
+		}
+		f := n.FileSystem(fsname)
+		f.Format.Set(fs.Format)
+		f.Path.Set(fs.Path)
+		f.WipeFileSystem.Set(fs.WipeFileSystem)
+		f.MountOptions.Set(fs.MountOptions)
+	}
+}
+
+/*
+Sets the disks, raids and file systems of profile p as the inherited values
+of the node
+*/
+func (n *NodeInfo) setAltDisks(conf *NodeConf, p string) {
+	n.initDisks()
+	for diskname, disk := range conf.Disks {
+		if disk == nil {
+			continue
+		}
+		d := n.Disk(diskname)
+		d.Id.SetAlt(disk.Id, p)
+		d.SizeGiB.SetAlt(disk.SizeGiB, p)
+		d.WipeTable.SetAlt(disk.WipeTable, p)
+		for partname, part := range disk.Partitions {
+			if part == nil {
+				continue
+			}
+			dp := d.Partition(partname)
+			dp.Number.SetAlt(part.Number, p)
+			dp.SizeMiB.SetAlt(part.SizeMiB, p)
+			dp.Type.SetAlt(part.Type, p)
+		}
+	}
+	for raidname, raid := range conf.Raids {
+		if raid == nil {
+			continue
+		}
+		r := n.Raid(raidname)
+		r.Level.SetAlt(raid.Level, p)
+		r.Devices.SetAltSlice(raid.Devices, p)
+	}
+	for fsname, fs := range conf.FileSystems {
+		if fs == nil {
+			continue
+		}
+		f := n.FileSystem(fsname)
+		f.Format.SetAlt(fs.Format, p)
+		f.Path.SetAlt(fs.Path, p)
+		f.WipeFileSystem.SetAlt(fs.WipeFileSystem, p)
+		f.MountOptions.SetAlt(fs.MountOptions, p)
+	}
+}
+
+/*
+Writes the values which are set for the node itself to conf, disks, raids
+and file systems which are only inherited are skipped
+*/
+func (n *NodeInfo) disksConf(conf *NodeConf) {
+	conf.Disks = nil
+	for diskname, disk := range n.Disks {
+		if !disk.gotReal() {
+			continue
+		}
+		if conf.Disks == nil {
+			conf.Disks = make(map[string]*Disk)
+		}
+		d := &Disk{
+			Id:        disk.Id.GetReal(),
+			SizeGiB:   disk.SizeGiB.GetReal(),
+			WipeTable: disk.WipeTable.GetReal(),
+		}
+		for partname, part := range disk.Partitions {
+			if !part.gotReal() {
+				continue
+			}
+			if d.Partitions == nil {
+				d.Partitions = make(map[string]*Partition)
+			}
+			d.Partitions[partname] = &Partition{
+				Number:  part.Number.GetReal(),
+				SizeMiB: part.SizeMiB.GetReal(),
+				Type:    part.Type.GetReal(),
+			}
+		}
+		conf.Disks[diskname] = d
+	}
+	conf.Raids = nil
+	for raidname, raid := range n.Raids {
+		if !raid.Level.GotReal() && !raid.Devices.GotReal() {
+			continue
+		}
+		if conf.Raids == nil {
+			conf.Raids = make(map[string]*Raid)
+		}
+		conf.Raids[raidname] = &Raid{
+			Level:   raid.Level.GetReal(),
+			Devices: raid.Devices.GetRealSlice(),
+		}
+	}
+	conf.FileSystems = nil
+	for fsname, fs := range n.FileSystems {
+		if !fs.Format.GotReal() && !fs.Path.GotReal() && !fs.WipeFileSystem.GotReal() && !fs.MountOptions.GotReal() {
+			continue
+		}
+		if conf.FileSystems == nil {
+			conf.FileSystems = make(map[string]*FileSystem)
+		}
+		conf.FileSystems[fsname] = &FileSystem{
+			Format:         fs.Format.GetReal(),
+			Path:           fs.Path.GetReal(),
+			WipeFileSystem: fs.WipeFileSystem.GetReal(),
+			MountOptions:   fs.MountOptions.GetReal(),
+		}
+	}
+}
+
+/*
+The values of the disks, raids and file systems which are used for the
+templates of the overlays, unknown wipe policies are never
+*/
+func (n *NodeInfo) DisksConf() (map[string]*Disk, map[string]*Raid, map[string]*FileSystem) {
+	disks := make(map[string]*Disk)
+	for diskname, disk := range n.Disks {
+		d := &Disk{
+			Id:         disk.Id.Get(),
+			SizeGiB:    disk.SizeGiB.Get(),
+			WipeTable:  wipePolicy(disk.WipeTable.Get()),
+			Partitions: make(map[string]*Partition),
+		}
+		for partname, part := range disk.Partitions {
+			d.Partitions[partname] = &Partition{
+				Number:  part.Number.Get(),
+				SizeMiB: part.SizeMiB.Get(),
+				Type:    part.Type.Get(),
+			}
+		}
+		disks[diskname] = d
+	}
+	raids := make(map[string]*Raid)
+	for raidname, raid := range n.Raids {
+		raids[raidname] = &Raid{
+			Level:   raid.Level.Get(),
+			Devices: raid.Devices.GetSlice(),
+		}
+	}
+	fileSystems := make(map[string]*FileSystem)
+	for fsname, fs := range n.FileSystems {
+		fileSystems[fsname] = &FileSystem{
+			Format:         fs.Format.Get(),
+			Path:           fs.Path.Get(),
+			WipeFileSystem: wipePolicy(fs.WipeFileSystem.Get()),
+			MountOptions:   fs.MountOptions.Get(),
+		}
+	}
+	return disks, raids, fileSystems
+}
+
+/*
+The device of the file system which is mounted as root, empty if the root
+file system is not on disk
+*/
+func (n *NodeInfo) RootDevice() string {
+	for fsname, fs := range n.FileSystems {
+		if fs.Path.Get() == "/" {
+			return fsname
+		}
+	}
+	return ""
+}
+
+func wipePolicy(value string) string {
+	policy, _ := WipePolicy(value)
+	return policy
+}
+
+func (n *NodeInfo) initDisks() {
+	if n.Disks == nil {
+		n.Disks = make(map[string]*DiskEntry)
+	}
+	if n.Raids == nil {
+		n.Raids = make(map[string]*RaidEntry)
+	}
+	if n.FileSystems == nil {
+		n.FileSystems = make(map[string]*FileSystemEntry)
+	}
+}
+
+/*
+Returns the disk of the node, it is added if it doesn't exist
+*/
+func (n *NodeInfo) Disk(name string) *DiskEntry {
+	n.initDisks()
+	if _, ok := n.Disks[name]; !ok {
+		n.Disks[name] = &DiskEntry{Partitions: make(map[string]*PartitionEntry)}
+	}
+	return n.Disks[name]
+}
+
+/*
+Returns the partition of the disk, it is added if it doesn't exist
+*/
+func (disk *DiskEntry) Partition(name string) *PartitionEntry {
+	if disk.Partitions == nil {
+		disk.Partitions = make(map[string]*PartitionEntry)
+	}
+	if _, ok := disk.Partitions[name]; !ok {
+		disk.Partitions[name] = new(PartitionEntry)
+	}
+	return disk.Partitions[name]
+}
+
+/*
+Returns the raid of the node, it is added if it doesn't exist
+*/
+func (n *NodeInfo) Raid(name string) *RaidEntry {
+	n.initDisks()
+	if _, ok := n.Raids[name]; !ok {
+		n.Raids[name] = new(RaidEntry)
+		n.Raids[name].Devices.SetMerge(",")
+	}
+	return n.Raids[name]
+}
+
+/*
+Returns the file system of the node, it is added if it doesn't exist
+*/
+func (n *NodeInfo) FileSystem(name string) *FileSystemEntry {
+	n.initDisks()
+	if _, ok := n.FileSystems[name]; !ok {
+		n.FileSystems[name] = new(FileSystemEntry)
+	}
+	return n.FileSystems[name]
+}
+
+/*
+true if a value of the disk or its partitions is set directly and not
+inherited
+*/
+func (disk *DiskEntry) gotReal() bool {
+	if disk.Id.GotReal() || disk.SizeGiB.GotReal() || disk.WipeTable.GotReal() {
+		return true
+	}
+	for _, part := range disk.Partitions {
+		if part.gotReal() {
+			return true
+		}
+	}
+	return false
+}
+
+func (part *PartitionEntry) gotReal() bool {
+	return part.Number.GotReal() || part.SizeMiB.GotReal() || part.Type.GotReal()
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const disksConfig = `WW_INTERNAL: 43
+nodeprofiles:
+  default:
+    root: disk
+    disks:
+      /dev/sda:
+        wipe table: "true"
+        partitions:
+          rootfs:
+            number: "1"
+            size: "20480"
+    filesystems:
+      /dev/disk/by-partlabel/rootfs:
+        format: ext4
+        path: /
+nodes:
+  n1:
+    profiles:
+    - default
+    disks:
+      /dev/sda:
+        partitions:
+          scratch:
+            number: "2"
+    filesystems:
+      /dev/disk/by-partlabel/scratch:
+        format: xfs
+        path: /scratch
+`
+
+func Test_Disks(t *testing.T) {
+	var config nodeYaml
+	assert.NoError(t, yaml.Unmarshal([]byte(disksConfig), &config))
+
+	nodes, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	n := nodes[0]
+
+	assert.Equal(t, "/dev/disk/by-partlabel/rootfs", n.RootDevice())
+	assert.Equal(t, "true", n.Disks["/dev/sda"].WipeTable.Get())
+	assert.Equal(t, "default", n.Disks["/dev/sda"].WipeTable.Source())
+	assert.Equal(t, "20480", n.Disks["/dev/sda"].Partitions["rootfs"].SizeMiB.Get())
+	assert.Equal(t, "2", n.Disks["/dev/sda"].Partitions["scratch"].Number.Get())
+
+	disks, _, fileSystems := n.DisksConf()
+	assert.Len(t, disks["/dev/sda"].Partitions, 2)
+	assert.Equal(t, "xfs", fileSystems["/dev/disk/by-partlabel/scratch"].Format)
+
+	// inherited disks and file systems are not stored with the node
+	n.FileSystem("/dev/disk/by-partlabel/scratch").MountOptions.Set("noatime")
+	assert.NoError(t, config.NodeUpdate(n))
+	conf := config.Nodes["n1"]
+	assert.Len(t, conf.Disks["/dev/sda"].Partitions, 1)
+	assert.Equal(t, "", conf.Disks["/dev/sda"].WipeTable)
+	assert.Len(t, conf.FileSystems, 1)
+	assert.Equal(t, "noatime", conf.FileSystems["/dev/disk/by-partlabel/scratch"].MountOptions)
+}
+
+func Test_WipePolicy(t *testing.T) {
+	for value, policy := range map[string]string{
+		"":         WipeNever,
+		"no":       WipeNever,
+		"true":     WipeAlways,
+		"yes":      WipeAlways,
+		"Always":   WipeAlways,
+		"mismatch": WipeMismatch,
+	} {
+		p, err := WipePolicy(value)
+		assert.NoError(t, err)
+		assert.Equal(t, policy, p, value)
+	}
+	p, err := WipePolicy("sometimes")
+	assert.Error(t, err)
+	assert.Equal(t, WipeNever, p)
+}
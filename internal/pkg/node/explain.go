@@ -0,0 +1,111 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+Returns the entries of a node by the names of their fields in nodes.conf,
+nested fields are separated by dots, e.g. kernel.args or
+network devices.default.ipaddr
+*/
+func (n *NodeInfo) Fields() map[string]*Entry {
+	ret := map[string]*Entry{
+		"comment":         &n.Comment,
+		"cluster name":    &n.ClusterName,
+		"container name":  &n.ContainerName,
+		"arch":            &n.Arch,
+		"ipxe template":   &n.Ipxe,
+		"runtime overlay": &n.RuntimeOverlay,
+		"system overlay":  &n.SystemOverlay,
+		"init":            &n.Init,
+		"root":            &n.Root,
+		"asset key":       &n.AssetKey,
+		"uuid":            &n.UUID,
+		"discoverable":    &n.Discoverable,
+	}
+	if n.Kernel != nil {
+		ret["kernel.override"] = &n.Kernel.Override
+		ret["kernel.args"] = &n.Kernel.Args
+	}
+	if n.Ipmi != nil {
+		ret["ipmi.username"] = &n.Ipmi.UserName
+		ret["ipmi.password"] = &n.Ipmi.Password
+		ret["ipmi.ipaddr"] = &n.Ipmi.Ipaddr
+		ret["ipmi.netmask"] = &n.Ipmi.Netmask
+		ret["ipmi.port"] = &n.Ipmi.Port
+		ret["ipmi.gateway"] = &n.Ipmi.Gateway
+		ret["ipmi.interface"] = &n.Ipmi.Interface
+		ret["ipmi.write"] = &n.Ipmi.Write
+	}
+	for name, netdev := range n.NetDevs {
+		prefix := "network devices." + name + "."
+		ret[prefix+"type"] = &netdev.Type
+		ret[prefix+"onboot"] = &netdev.OnBoot
+		ret[prefix+"device"] = &netdev.Device
+		ret[prefix+"hwaddr"] = &netdev.Hwaddr
+		ret[prefix+"ipaddr"] = &netdev.Ipaddr
+		ret[prefix+"ip6addr"] = &netdev.Ipaddr6
+		ret[prefix+"netmask"] = &netdev.Netmask
+		ret[prefix+"gateway"] = &netdev.Gateway
+		ret[prefix+"network"] = &netdev.Network
+		ret[prefix+"primary"] = &netdev.Primary
+		for key, tag := range netdev.Tags {
+			ret[prefix+"tags."+key] = tag
+		}
+	}
+	for key, tag := range n.Tags {
+		ret["tags."+key] = tag
+	}
+	for name, disk := range n.Disks {
+		prefix := "disks." + name + "."
+		ret[prefix+"id"] = &disk.Id
+		ret[prefix+"size"] = &disk.SizeGiB
+		ret[prefix+"wipe table"] = &disk.WipeTable
+		for partname, part := range disk.Partitions {
+			partprefix := prefix + "partitions." + partname + "."
+			ret[partprefix+"number"] = &part.Number
+			ret[partprefix+"size"] = &part.SizeMiB
+			ret[partprefix+"type"] = &part.Type
+		}
+	}
+	for name, raid := range n.Raids {
+		prefix := "raids." + name + "."
+		ret[prefix+"level"] = &raid.Level
+		ret[prefix+"devices"] = &raid.Devices
+	}
+	for name, fs := range n.FileSystems {
+		prefix := "filesystems." + name + "."
+		ret[prefix+"format"] = &fs.Format
+		ret[prefix+"path"] = &fs.Path
+		ret[prefix+"wipe filesystem"] = &fs.WipeFileSystem
+		ret[prefix+"mount options"] = &fs.MountOptions
+	}
+	return ret
+}
+
+/*
+The layers of a field of the node, see Entry.Layers
+*/
+func (n *NodeInfo) Explain(field string) ([]EntryLayer, error) {
+	ent, ok := n.Fields()[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field: %s", field)
+	}
+	return ent.Layers(), nil
+}
+
+/*
+Names of the fields of the node which have a value
+*/
+func (n *NodeInfo) DefinedFields() []string {
+	var ret []string
+	for name, ent := range n.Fields() {
+		if ent.Defined() {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
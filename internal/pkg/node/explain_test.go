@@ -0,0 +1,80 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const layerConfig = `WW_INTERNAL: 43
+nodeprofiles:
+  base:
+    container name: rocky
+    kernel:
+      args: console=ttyS0
+    system overlay:
+    - wwinit
+    - base
+  gpu:
+    container name: rocky-gpu
+    kernel:
+      args: +nvidia-drm.modeset=1
+    system overlay:
+    - +gpu
+    runtime overlay:
+    - +gpu
+nodes:
+  n1:
+    profiles:
+    - base
+    - gpu
+    kernel:
+      args: +debug
+    system overlay:
+    - +base
+    - +n1
+  n2:
+    profiles:
+    - gpu
+    - base
+    runtime overlay:
+    - custom
+`
+
+func Test_Layers(t *testing.T) {
+	var config nodeYaml
+	assert.NoError(t, yaml.Unmarshal([]byte(layerConfig), &config))
+	nodes, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	byName := make(map[string]NodeInfo)
+	for _, n := range nodes {
+		byName[n.Id.Get()] = n
+	}
+
+	n1 := byName["n1"]
+	assert.Equal(t, "rocky-gpu", n1.ContainerName.Get())
+	assert.Equal(t, "console=ttyS0 nvidia-drm.modeset=1 debug", n1.Kernel.Args.Get())
+	assert.Equal(t, []string{"wwinit", "base", "gpu", "n1"}, n1.SystemOverlay.GetSlice())
+	assert.Equal(t, []string{"generic", "gpu"}, n1.RuntimeOverlay.GetSlice())
+	assert.Equal(t, []string{"+debug"}, n1.Kernel.Args.GetRealSlice())
+
+	layers, err := n1.Explain("kernel.args")
+	assert.NoError(t, err)
+	assert.Equal(t, []EntryLayer{
+		{From: "(default)", Value: []string{"quiet crashkernel=no vga=791"}},
+		{From: "base", Value: []string{"console=ttyS0"}},
+		{From: "gpu", Value: []string{"+nvidia-drm.modeset=1"}},
+		{From: "(node)", Value: []string{"+debug"}},
+	}, layers)
+	_, err = n1.Explain("no such field")
+	assert.Error(t, err)
+
+	// the order of the profiles decides which one wins
+	n2 := byName["n2"]
+	assert.Equal(t, "rocky", n2.ContainerName.Get())
+	assert.Equal(t, "console=ttyS0", n2.Kernel.Args.Get())
+	assert.Equal(t, []string{"wwinit", "base"}, n2.SystemOverlay.GetSlice())
+	assert.Equal(t, []string{"custom"}, n2.RuntimeOverlay.GetSlice())
+	assert.Contains(t, n2.DefinedFields(), "runtime overlay")
+}
@@ -0,0 +1,99 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+Three way merge of the in memory configuration with the content of nodes.conf
+which was written by someone else. base is the content which was read by New(),
+current the content of the file now. Nodes and profiles are the unit of the
+merge: the ones changed only on one side are taken from that side.
+*/
+func (config *nodeYaml) merge(base []byte, current []byte) error {
+	var baseConf, currentConf nodeYaml
+	err := yaml.Unmarshal(base, &baseConf)
+	if err != nil {
+		return errors.Wrap(err, "could not parse previous node configuration")
+	}
+	err = yaml.Unmarshal(current, &currentConf)
+	if err != nil {
+		return errors.Wrap(err, "could not parse current node configuration")
+	}
+
+	var conflicts []string
+	nodes, c := mergeConfs(baseConf.Nodes, config.Nodes, currentConf.Nodes)
+	for _, n := range c {
+		conflicts = append(conflicts, "node "+n)
+	}
+	profiles, c := mergeConfs(baseConf.NodeProfiles, config.NodeProfiles, currentConf.NodeProfiles)
+	for _, p := range c {
+		conflicts = append(conflicts, "profile "+p)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("node configuration was modified concurrently, conflicting changes of: %s",
+			strings.Join(conflicts, ", "))
+	}
+
+	if config.WWInternal == baseConf.WWInternal {
+		config.WWInternal = currentConf.WWInternal
+	}
+	config.modified()
+	config.Nodes = nodes
+	config.NodeProfiles = profiles
+	return nil
+}
+
+/*
+Merges the entries of ours and theirs which both derive from base, returns
+the merged entries and the names of the entries which changed on both sides
+*/
+func mergeConfs(base, ours, theirs map[string]*NodeConf) (map[string]*NodeConf, []string) {
+	ret := make(map[string]*NodeConf)
+	var conflicts []string
+
+	names := make(map[string]bool)
+	for _, m := range []map[string]*NodeConf{base, ours, theirs} {
+		for name := range m {
+			names[name] = true
+		}
+	}
+	for name := range names {
+		b, o, t := confBytes(base[name]), confBytes(ours[name]), confBytes(theirs[name])
+		var merged *NodeConf
+		switch {
+		case bytes.Equal(o, b):
+			merged = theirs[name]
+		case bytes.Equal(t, b), bytes.Equal(o, t):
+			merged = ours[name]
+		default:
+			conflicts = append(conflicts, name)
+			continue
+		}
+		if merged != nil {
+			ret[name] = merged
+		}
+	}
+	sort.Strings(conflicts)
+	return ret, conflicts
+}
+
+/*
+Canonical representation of a node or profile, nil for a missing one
+*/
+func confBytes(conf *NodeConf) []byte {
+	if conf == nil {
+		return nil
+	}
+	out, err := yaml.Marshal(conf)
+	if err != nil {
+		return nil
+	}
+	return out
+}
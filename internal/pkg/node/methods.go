@@ -94,7 +94,12 @@ func (ent *Entry) SetAlt(val string, from string) {
 	if val == "" {
 		return
 	}
-	ent.altvalue = []string{val}
+	ent.layers = append(ent.layers, EntryLayer{From: from, Value: []string{val}})
+	if ent.appends([]string{val}) {
+		ent.altvalue = ent.appendTo(ent.inherited(), []string{val})
+	} else {
+		ent.altvalue = []string{val}
+	}
 	ent.from = from
 }
 
@@ -102,6 +107,7 @@ func (ent *Entry) SetAlt(val string, from string) {
 Sets alternative bool
 */
 func (ent *Entry) SetAltB(val bool, from string) {
+	ent.layers = append(ent.layers, EntryLayer{From: from, Value: []string{fmt.Sprintf("%t", val)}})
 	if val {
 		ent.altvalue = []string{"true"}
 		ent.from = from
@@ -118,10 +124,105 @@ func (ent *Entry) SetAltSlice(val []string, from string) {
 	if len(val) == 0 {
 		return
 	}
-	ent.altvalue = val
+	ent.layers = append(ent.layers, EntryLayer{From: from, Value: val})
+	if ent.appends(val) {
+		ent.altvalue = ent.appendTo(ent.inherited(), val)
+	} else {
+		ent.altvalue = val
+	}
 	ent.from = from
 }
 
+/*
+Makes the entry a list whose values can be appended to the inherited value
+by prefixing them with '+', sep separates the values of a single string,
+e.g. " " for kernel arguments
+*/
+func (ent *Entry) SetMerge(sep string) {
+	ent.merge = sep
+}
+
+/*
+true if val is appended to the inherited value
+*/
+func (ent *Entry) appends(val []string) bool {
+	return ent.merge != "" && len(val) != 0 && strings.HasPrefix(val[0], "+")
+}
+
+/*
+The value of the profiles or the default value
+*/
+func (ent *Entry) inherited() []string {
+	if len(ent.altvalue) != 0 {
+		return ent.altvalue
+	}
+	return ent.def
+}
+
+/*
+Appends val, whose elements may be prefixed with '+', to base. Lists are
+merged without duplicates, a single string is joined with the separator.
+*/
+func (ent *Entry) appendTo(base []string, val []string) []string {
+	var add []string
+	for _, v := range val {
+		if v = strings.TrimPrefix(v, "+"); v != "" {
+			add = append(add, v)
+		}
+	}
+	if ent.merge != "," {
+		parts := append([]string{}, base...)
+		parts = append(parts, add...)
+		return []string{strings.Join(parts, ent.merge)}
+	}
+	ret := append([]string{}, base...)
+	for _, v := range add {
+		found := false
+		for _, b := range ret {
+			if b == v {
+				found = true
+			}
+		}
+		if !found {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+/*
+The effective value, the value of the node is appended to the inherited one
+if it starts with '+'
+*/
+func (ent *Entry) values() []string {
+	if len(ent.value) != 0 {
+		if ent.appends(ent.value) {
+			return ent.appendTo(ent.inherited(), ent.value)
+		}
+		return ent.value
+	}
+	if len(ent.altvalue) != 0 {
+		return ent.altvalue
+	}
+	return ent.def
+}
+
+/*
+Returns all values which were set for the entry, from the lowest precedence
+to the highest: the default, the profiles in their order and the node itself
+*/
+func (ent *Entry) Layers() []EntryLayer {
+	var ret []EntryLayer
+	if len(ent.def) != 0 {
+		ret = append(ret, EntryLayer{From: "(default)", Value: ent.def})
+	}
+	ret = append(ret, ent.layers...)
+	if len(ent.value) != 0 {
+		ret = append(ret, EntryLayer{From: "(node)", Value: ent.value})
+	}
+	return ret
+}
+
 /*
 Sets the default value of an entry.
 */
@@ -156,14 +257,8 @@ Gets the the entry of the value in folowing order
 * default value if set
 */
 func (ent *Entry) Get() string {
-	if len(ent.value) != 0 {
-		return ent.value[0]
-	}
-	if len(ent.altvalue) != 0 {
-		return ent.altvalue[0]
-	}
-	if len(ent.def) != 0 {
-		return ent.def[0]
+	if values := ent.values(); len(values) != 0 {
+		return values[0]
 	}
 	return ""
 }
@@ -185,17 +280,7 @@ func (ent *Entry) GetB() bool {
 Returns a string slice created from a comma seperated list of the value.
 */
 func (ent *Entry) GetSlice() []string {
-	var retval []string
-	if len(ent.value) != 0 {
-		return ent.value
-	}
-	if len(ent.altvalue) != 0 {
-		return ent.altvalue
-	}
-	if len(ent.def) != 0 {
-		return ent.def
-	}
-	return retval
+	return ent.values()
 }
 
 /*
@@ -238,7 +323,7 @@ nothing is defined '--' is returned.
 */
 func (ent *Entry) Print() string {
 	if len(ent.value) != 0 {
-		return strings.Join(ent.value, ",")
+		return strings.Join(ent.values(), ",")
 	}
 	if len(ent.altvalue) != 0 {
 		return strings.Join(ent.altvalue, ",")
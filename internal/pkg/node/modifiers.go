@@ -1,10 +1,7 @@
 package node
 
 import (
-	"os"
-
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 )
@@ -25,6 +22,7 @@ func (config *nodeYaml) AddNode(nodeID string) (NodeInfo, error) {
 		return n, errors.New("Nodename already exists: " + nodeID)
 	}
 
+	config.modified()
 	config.Nodes[nodeID] = &node
 	config.Nodes[nodeID].Profiles = []string{"default"}
 	config.Nodes[nodeID].NetDevs = make(map[string]*NetDevs)
@@ -33,6 +31,7 @@ func (config *nodeYaml) AddNode(nodeID string) (NodeInfo, error) {
 	n.NetDevs = make(map[string]*NetDevEntry)
 	n.Ipmi = new(IpmiEntry)
 	n.Kernel = new(KernelEntry)
+	n.initDisks()
 
 	return n, nil
 }
@@ -44,6 +43,7 @@ func (config *nodeYaml) DelNode(nodeID string) error {
 	}
 
 	wwlog.Printf(wwlog.VERBOSE, "Deleting node: %s\n", nodeID)
+	config.modified()
 	delete(config.Nodes, nodeID)
 
 	return nil
@@ -56,8 +56,10 @@ func (config *nodeYaml) NodeUpdate(node NodeInfo) error {
 		return errors.New("Nodename does not exist: " + nodeID)
 	}
 
+	config.modified()
 	config.Nodes[nodeID].Comment = node.Comment.GetReal()
 	config.Nodes[nodeID].ContainerName = node.ContainerName.GetReal()
+	config.Nodes[nodeID].Arch = node.Arch.GetReal()
 	config.Nodes[nodeID].ClusterName = node.ClusterName.GetReal()
 	config.Nodes[nodeID].Ipxe = node.Ipxe.GetReal()
 	config.Nodes[nodeID].Init = node.Init.GetReal()
@@ -85,6 +87,7 @@ func (config *nodeYaml) NodeUpdate(node NodeInfo) error {
 	config.Nodes[nodeID].SystemOverlay = node.SystemOverlay.GetRealSlice()
 	config.Nodes[nodeID].Root = node.Root.GetReal()
 	config.Nodes[nodeID].AssetKey = node.AssetKey.GetReal()
+	config.Nodes[nodeID].UUID = node.UUID.GetReal()
 	config.Nodes[nodeID].Discoverable = node.Discoverable.GetReal()
 
 	config.Nodes[nodeID].Profiles = node.Profiles
@@ -99,6 +102,7 @@ func (config *nodeYaml) NodeUpdate(node NodeInfo) error {
 		config.Nodes[nodeID].NetDevs[devname].Netmask = netdev.Netmask.GetReal()
 		config.Nodes[nodeID].NetDevs[devname].Hwaddr = netdev.Hwaddr.GetReal()
 		config.Nodes[nodeID].NetDevs[devname].Gateway = netdev.Gateway.GetReal()
+		config.Nodes[nodeID].NetDevs[devname].Network = netdev.Network.GetReal()
 		config.Nodes[nodeID].NetDevs[devname].Type = netdev.Type.GetReal()
 		config.Nodes[nodeID].NetDevs[devname].OnBoot = netdev.OnBoot.GetReal()
 		config.Nodes[nodeID].NetDevs[devname].Primary = netdev.Primary.GetReal()
@@ -117,6 +121,8 @@ func (config *nodeYaml) NodeUpdate(node NodeInfo) error {
 		}
 	}
 
+	node.disksConf(config.Nodes[nodeID])
+
 	return nil
 }
 
@@ -136,9 +142,11 @@ func (config *nodeYaml) AddProfile(profileID string) (NodeInfo, error) {
 		return n, errors.New("Profile name already exists: " + profileID)
 	}
 
+	config.modified()
 	config.NodeProfiles[profileID] = &node
 
 	n.Id.Set(profileID)
+	n.initDisks()
 
 	return n, nil
 }
@@ -150,6 +158,7 @@ func (config *nodeYaml) DelProfile(profileID string) error {
 	}
 
 	wwlog.Printf(wwlog.VERBOSE, "Deleting profile: %s\n", profileID)
+	config.modified()
 	delete(config.NodeProfiles, profileID)
 
 	return nil
@@ -166,6 +175,7 @@ func (config *nodeYaml) ProfileUpdate(profile NodeInfo) error {
 	}
 	config.NodeProfiles[profileID].Comment = profile.Comment.GetReal()
 	config.NodeProfiles[profileID].ContainerName = profile.ContainerName.GetReal()
+	config.NodeProfiles[profileID].Arch = profile.Arch.GetReal()
 	config.NodeProfiles[profileID].Ipxe = profile.Ipxe.GetReal()
 	config.NodeProfiles[profileID].Init = profile.Init.GetReal()
 	config.NodeProfiles[profileID].ClusterName = profile.ClusterName.GetReal()
@@ -195,8 +205,13 @@ func (config *nodeYaml) ProfileUpdate(profile NodeInfo) error {
 
 	config.NodeProfiles[profileID].Profiles = profile.Profiles
 
+	oldNetDevs := config.NodeProfiles[profileID].NetDevs
 	config.NodeProfiles[profileID].NetDevs = make(map[string]*NetDevs)
 	for devname, netdev := range profile.NetDevs {
+		// skip devices which are only inherited from included profiles
+		if _, ok := oldNetDevs[devname]; !ok && !netdev.gotReal() {
+			continue
+		}
 		var newdev NetDevs
 		config.NodeProfiles[profileID].NetDevs[devname] = &newdev
 
@@ -205,20 +220,28 @@ func (config *nodeYaml) ProfileUpdate(profile NodeInfo) error {
 		config.NodeProfiles[profileID].NetDevs[devname].Netmask = netdev.Netmask.GetReal()
 		config.NodeProfiles[profileID].NetDevs[devname].Hwaddr = netdev.Hwaddr.GetReal()
 		config.NodeProfiles[profileID].NetDevs[devname].Gateway = netdev.Gateway.GetReal()
+		config.NodeProfiles[profileID].NetDevs[devname].Network = netdev.Network.GetReal()
 		config.NodeProfiles[profileID].NetDevs[devname].Type = netdev.Type.GetReal()
 		config.NodeProfiles[profileID].NetDevs[devname].OnBoot = netdev.OnBoot.GetReal()
 		config.NodeProfiles[profileID].NetDevs[devname].Primary = netdev.Primary.GetReal()
 		config.NodeProfiles[profileID].NetDevs[devname].Tags = make(map[string]string)
 		for key := range netdev.Tags {
-			config.NodeProfiles[profileID].NetDevs[devname].Tags[key] = netdev.Tags[key].GetReal()
+			if netdev.Tags[key].GetReal() != "" {
+				config.NodeProfiles[profileID].NetDevs[devname].Tags[key] = netdev.Tags[key].GetReal()
+			}
 		}
 	}
 
+	config.modified()
 	config.NodeProfiles[profileID].Tags = make(map[string]string)
 	for keyname, key := range profile.Tags {
-		config.NodeProfiles[profileID].Tags[keyname] = key.GetReal()
+		if key.GetReal() != "" {
+			config.NodeProfiles[profileID].Tags[keyname] = key.GetReal()
+		}
 	}
 
+	profile.disksConf(config.NodeProfiles[profileID])
+
 	return nil
 }
 
@@ -228,25 +251,36 @@ func (config *nodeYaml) ProfileUpdate(profile NodeInfo) error {
  *
 ****/
 
+/*
+Writes the configuration to the backend it was loaded from. Concurrent
+changes of other nodes and profiles are kept, an error is returned if the
+same node or profile was changed by someone else. The changes are recorded
+in the audit log.
+*/
 func (config *nodeYaml) Persist() error {
-
-	out, err := yaml.Marshal(config)
-	if err != nil {
-		return err
+	if config.db == nil {
+		db, err := newBackend()
+		if err != nil {
+			return err
+		}
+		config.db = db
 	}
+	return config.db.store(config)
+}
 
-	file, err := os.OpenFile(ConfigFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		wwlog.Printf(wwlog.ERROR, "%s\n", err)
-		os.Exit(1)
+/*
+true if a value of the network device is set directly and not inherited
+*/
+func (netdev *NetDevEntry) gotReal() bool {
+	if netdev.Device.GotReal() || netdev.Ipaddr.GotReal() || netdev.Netmask.GotReal() ||
+		netdev.Hwaddr.GotReal() || netdev.Gateway.GotReal() || netdev.Network.GotReal() ||
+		netdev.Type.GotReal() || netdev.OnBoot.GotReal() || netdev.Primary.GotReal() {
+		return true
 	}
-
-	defer file.Close()
-
-	_, err = file.WriteString(string(out))
-	if err != nil {
-		return err
+	for _, tag := range netdev.Tags {
+		if tag.GotReal() {
+			return true
+		}
 	}
-
-	return nil
+	return false
 }
@@ -0,0 +1,235 @@
+package node
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/hpcng/warewulf/internal/pkg/ippool"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+/*
+A problem with the address of a network device
+*/
+type IpaddrProblem struct {
+	Node    string
+	NetDev  string
+	Ipaddr  string
+	Problem string
+	// node which uses the same address
+	UsedBy string
+}
+
+func (p IpaddrProblem) String() string {
+	return fmt.Sprintf("%s:%s %s: %s", p.Node, p.NetDev, p.Ipaddr, p.Problem)
+}
+
+/*
+Returns warewulf.conf with the networks, can be replaced for tests
+*/
+var networkConf = func() (warewulfconf.ControllerConf, error) {
+	return warewulfconf.New()
+}
+
+/*
+The user of an address, a network device or the ipmi interface of a node or
+the controller
+*/
+type ipaddrOwner struct {
+	Node string
+	Name string
+}
+
+/*
+Addresses which are used outside of the network devices of the nodes: the
+address of the controller and the ipmi interfaces of the nodes
+*/
+func reservedIpaddrs(conf warewulfconf.ControllerConf, nodes []NodeInfo) map[string]ipaddrOwner {
+	ret := make(map[string]ipaddrOwner)
+	if conf.Ipaddr != "" {
+		ret[strings.SplitN(conf.Ipaddr, "/", 2)[0]] = ipaddrOwner{Name: "the controller"}
+	}
+	for _, n := range nodes {
+		if n.Ipmi == nil {
+			continue
+		}
+		if ip := n.Ipmi.Ipaddr.Get(); ip != "" {
+			ret[ip] = ipaddrOwner{Node: n.Id.Get(), Name: n.Id.Get() + ":ipmi"}
+		}
+	}
+	return ret
+}
+
+func sortedNetDevEntries(netDevs map[string]*NetDevEntry) []string {
+	var ret []string
+	for name := range netDevs {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+/*
+Assigns addresses to the network devices of the given nodes which belong to
+a network of warewulf.conf, either directly or through a profile, but have no
+address yet. The addresses of the controller, the ipmi interfaces and the
+dhcp range aren't assigned. Returns the assigned addresses as
+node:netdev -> ipaddr.
+*/
+func (config *nodeYaml) AllocateIpaddrs(nodeIDs []string) (map[string]string, error) {
+	ret := make(map[string]string)
+	conf, err := networkConf()
+	if err != nil {
+		return ret, err
+	}
+	pools, err := ippool.FromConf(conf)
+	if err != nil {
+		return ret, err
+	}
+	nodes, err := config.FindAllNodes()
+	if err != nil {
+		return ret, err
+	}
+
+	used := make(map[string]bool)
+	for ip := range reservedIpaddrs(conf, nodes) {
+		used[ip] = true
+	}
+	if dhcp, ok := ippool.DhcpRange(conf); ok {
+		for _, ip := range dhcp.Addresses() {
+			used[ip] = true
+		}
+	}
+	resolved := make(map[string]NodeInfo)
+	for _, n := range nodes {
+		resolved[n.Id.Get()] = n
+		for _, netdev := range n.NetDevs {
+			if ip := netdev.Ipaddr.Get(); ip != "" {
+				used[ip] = true
+			}
+		}
+	}
+
+	for _, nodeID := range nodeIDs {
+		n, ok := resolved[nodeID]
+		if !ok {
+			return ret, fmt.Errorf("node does not exist: %s", nodeID)
+		}
+		for _, devName := range sortedNetDevEntries(n.NetDevs) {
+			netdev := n.NetDevs[devName]
+			network := netdev.Network.Get()
+			if network == "" || netdev.Ipaddr.Get() != "" {
+				continue
+			}
+			pool, ok := pools[network]
+			if !ok {
+				return ret, fmt.Errorf("%s:%s: network is not defined in warewulf.conf: %s", nodeID, devName, network)
+			}
+			ip, err := pool.Allocate(nodeID, used)
+			if err != nil {
+				return ret, err
+			}
+			used[ip.String()] = true
+
+			config.modified()
+			conf := config.Nodes[nodeID]
+			if conf.NetDevs == nil {
+				conf.NetDevs = make(map[string]*NetDevs)
+			}
+			if _, ok := conf.NetDevs[devName]; !ok {
+				conf.NetDevs[devName] = new(NetDevs)
+			}
+			conf.NetDevs[devName].Ipaddr = ip.String()
+			ret[nodeID+":"+devName] = ip.String()
+			wwlog.Info("Node: %s:%s, Allocated %s from network %s", nodeID, devName, ip, network)
+		}
+	}
+	return ret, nil
+}
+
+/*
+Checks the addresses of all nodes: an address must not be used twice, also
+not by the controller or an ipmi interface, must not be in the dhcp range and
+must be valid within the network of its device
+*/
+func (config *nodeYaml) CheckIpaddrs() ([]IpaddrProblem, error) {
+	var ret []IpaddrProblem
+	conf, err := networkConf()
+	if err != nil {
+		return ret, err
+	}
+	pools, err := ippool.FromConf(conf)
+	if err != nil {
+		return ret, err
+	}
+	nodes, err := config.FindAllNodes()
+	if err != nil {
+		return ret, err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Id.Get() < nodes[j].Id.Get() })
+
+	owners := reservedIpaddrs(conf, nodes)
+	dhcp, hasDhcp := ippool.DhcpRange(conf)
+	for _, n := range nodes {
+		for _, devName := range sortedNetDevEntries(n.NetDevs) {
+			netdev := n.NetDevs[devName]
+			ipaddr := netdev.Ipaddr.Get()
+			if ipaddr == "" {
+				continue
+			}
+			problem := IpaddrProblem{Node: n.Id.Get(), NetDev: devName, Ipaddr: ipaddr}
+			if owner, ok := owners[ipaddr]; ok {
+				dup := problem
+				dup.Problem = "also used by " + owner.Name
+				dup.UsedBy = owner.Node
+				ret = append(ret, dup)
+			} else {
+				owners[ipaddr] = ipaddrOwner{Node: n.Id.Get(), Name: n.Id.Get() + ":" + devName}
+			}
+			if hasDhcp && dhcp.Contains(net.ParseIP(ipaddr)) {
+				inRange := problem
+				inRange.Problem = "in the dhcp range of warewulf.conf"
+				ret = append(ret, inRange)
+			}
+
+			network := netdev.Network.Get()
+			if network == "" {
+				continue
+			}
+			pool, ok := pools[network]
+			if !ok {
+				problem.Problem = "network is not defined in warewulf.conf: " + network
+				ret = append(ret, problem)
+				continue
+			}
+			if reason := pool.Check(net.ParseIP(ipaddr)); reason != "" {
+				problem.Problem = reason
+				ret = append(ret, problem)
+			}
+		}
+	}
+	return ret, nil
+}
+
+/*
+Returns an error listing the problems which concern the given nodes
+*/
+func IpaddrError(problems []IpaddrProblem, nodeIDs []string) error {
+	wanted := make(map[string]bool)
+	for _, id := range nodeIDs {
+		wanted[id] = true
+	}
+	var msgs []string
+	for _, p := range problems {
+		if wanted[p.Node] || wanted[p.UsedBy] {
+			msgs = append(msgs, p.String())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid addresses:\n  %s", strings.Join(msgs, "\n  "))
+}
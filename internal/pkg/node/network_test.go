@@ -0,0 +1,141 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/hpcng/warewulf/internal/pkg/ippool"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+)
+
+const networkConfig = `WW_INTERNAL: 43
+nodeprofiles:
+  default:
+    network devices:
+      default:
+        network: compute
+nodes:
+  n0001:
+    profiles:
+    - default
+    network devices:
+      default:
+        ipaddr: 10.0.0.2
+  n0002:
+    profiles:
+    - default
+  n0003:
+    profiles:
+    - default
+  n0010:
+    profiles:
+    - default
+    network devices:
+      ib:
+        network: fabric
+`
+
+func setupNetworkTest(t *testing.T) nodeYaml {
+	return setupNetworkConfTest(t, warewulfconf.ControllerConf{})
+}
+
+/*
+Uses the test networks with the controller address and the dhcp range of
+conf
+*/
+func setupNetworkConfTest(t *testing.T, conf warewulfconf.ControllerConf) nodeYaml {
+	saved := networkConf
+	t.Cleanup(func() { networkConf = saved })
+	conf.Networks = map[string]*warewulfconf.NetworkConf{
+		"compute": {Subnet: "10.0.0.0/24", Gateway: "10.0.0.1"},
+		"fabric":  {Subnet: "10.1.0.0/24", Allocation: ippool.AllocIndex, Base: "10.1.0.100"},
+	}
+	networkConf = func() (warewulfconf.ControllerConf, error) {
+		return conf, nil
+	}
+	var config nodeYaml
+	assert.NoError(t, yaml.Unmarshal([]byte(networkConfig), &config))
+	return config
+}
+
+func Test_AllocateIpaddrs(t *testing.T) {
+	config := setupNetworkTest(t)
+	assigned, err := config.AllocateIpaddrs([]string{"n0002", "n0003", "n0010"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"n0002:default": "10.0.0.3",
+		"n0003:default": "10.0.0.4",
+		"n0010:default": "10.0.0.5",
+		"n0010:ib":      "10.1.0.110",
+	}, assigned)
+	assert.Equal(t, "10.0.0.3", config.Nodes["n0002"].NetDevs["default"].Ipaddr)
+	assert.Equal(t, "10.1.0.110", config.Nodes["n0010"].NetDevs["ib"].Ipaddr)
+
+	problems, err := config.CheckIpaddrs()
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+func Test_CheckIpaddrs(t *testing.T) {
+	config := setupNetworkTest(t)
+	config.Nodes["n0002"].NetDevs = map[string]*NetDevs{"default": {Ipaddr: "10.0.0.2"}}
+	config.Nodes["n0003"].NetDevs = map[string]*NetDevs{"default": {Ipaddr: "10.0.1.3"}}
+
+	problems, err := config.CheckIpaddrs()
+	assert.NoError(t, err)
+	assert.Equal(t, []IpaddrProblem{
+		{Node: "n0002", NetDev: "default", Ipaddr: "10.0.0.2", Problem: "also used by n0001:default", UsedBy: "n0001"},
+		{Node: "n0003", NetDev: "default", Ipaddr: "10.0.1.3", Problem: "not in subnet 10.0.0.0/24 of network compute"},
+	}, problems)
+
+	assert.Error(t, IpaddrError(problems, []string{"n0001"}))
+	assert.Error(t, IpaddrError(problems, []string{"n0003"}))
+	assert.NoError(t, IpaddrError(problems, []string{"n0010"}))
+}
+
+func Test_IpaddrsReserved(t *testing.T) {
+	dhcp := &warewulfconf.DhcpConf{Enabled: true, RangeStart: "10.0.0.3", RangeEnd: "10.0.0.10"}
+	tests := []struct {
+		name      string
+		conf      warewulfconf.ControllerConf
+		ipmi      string
+		allocated string
+		problem   string
+		usedBy    string
+	}{
+		{"controller", warewulfconf.ControllerConf{Ipaddr: "10.0.0.3"}, "", "10.0.0.4",
+			"also used by the controller", ""},
+		{"controller cidr", warewulfconf.ControllerConf{Ipaddr: "10.0.0.3/24"}, "", "10.0.0.4",
+			"also used by the controller", ""},
+		{"ipmi", warewulfconf.ControllerConf{}, "10.0.0.3", "10.0.0.4",
+			"also used by n0001:ipmi", "n0001"},
+		{"dhcp range", warewulfconf.ControllerConf{Dhcp: dhcp}, "", "10.0.0.11",
+			"in the dhcp range of warewulf.conf", ""},
+		{"dhcp disabled", warewulfconf.ControllerConf{Dhcp: &warewulfconf.DhcpConf{RangeStart: "10.0.0.3", RangeEnd: "10.0.0.10"}},
+			"", "10.0.0.3", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := setupNetworkConfTest(t, tt.conf)
+			if tt.ipmi != "" {
+				config.Nodes["n0001"].Ipmi = &IpmiConf{Ipaddr: tt.ipmi}
+			}
+			assigned, err := config.AllocateIpaddrs([]string{"n0002"})
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"n0002:default": tt.allocated}, assigned)
+
+			config.Nodes["n0002"].NetDevs["default"].Ipaddr = "10.0.0.3"
+			config.modified()
+			problems, err := config.CheckIpaddrs()
+			assert.NoError(t, err)
+			if tt.problem == "" {
+				assert.Empty(t, problems)
+				return
+			}
+			assert.Equal(t, []IpaddrProblem{{Node: "n0002", NetDev: "default", Ipaddr: "10.0.0.3",
+				Problem: tt.problem, UsedBy: tt.usedBy}}, problems)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const persistConfig = `WW_INTERNAL: 43
+nodeprofiles:
+  default:
+    comment: default profile
+nodes:
+  n1:
+    comment: first
+  n2:
+    comment: second
+`
+
+func setupPersistTest(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "ww-persist")
+	assert.NoError(t, err)
+	oldConfigFile, oldAuditLogFile := ConfigFile, AuditLogFile
+	ConfigFile = path.Join(dir, "nodes.conf")
+	AuditLogFile = path.Join(dir, "nodes.log")
+	assert.NoError(t, ioutil.WriteFile(ConfigFile, []byte(persistConfig), 0640))
+	return func() {
+		ConfigFile, AuditLogFile = oldConfigFile, oldAuditLogFile
+		os.RemoveAll(dir)
+	}
+}
+
+func Test_PersistMerge(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	a, err := New()
+	assert.NoError(t, err)
+	b, err := New()
+	assert.NoError(t, err)
+
+	a.Nodes["n1"].Comment = "changed by a"
+	_, err = a.AddNode("n3")
+	assert.NoError(t, err)
+	assert.NoError(t, a.Persist())
+
+	b.Nodes["n2"].Comment = "changed by b"
+	assert.NoError(t, b.Persist())
+
+	c, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, "changed by a", c.Nodes["n1"].Comment)
+	assert.Equal(t, "changed by b", c.Nodes["n2"].Comment)
+	assert.Contains(t, c.Nodes, "n3")
+	assert.Contains(t, c.NodeProfiles, "default")
+
+	fi, err := os.Stat(ConfigFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+}
+
+func Test_PersistDelete(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	a, err := New()
+	assert.NoError(t, err)
+	b, err := New()
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.DelNode("n1"))
+	assert.NoError(t, a.Persist())
+
+	b.Nodes["n2"].Comment = "changed by b"
+	assert.NoError(t, b.Persist())
+
+	c, err := New()
+	assert.NoError(t, err)
+	assert.NotContains(t, c.Nodes, "n1")
+	assert.Equal(t, "changed by b", c.Nodes["n2"].Comment)
+}
+
+func Test_PersistConflict(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	a, err := New()
+	assert.NoError(t, err)
+	b, err := New()
+	assert.NoError(t, err)
+
+	a.Nodes["n1"].Comment = "changed by a"
+	assert.NoError(t, a.Persist())
+
+	b.Nodes["n1"].Comment = "changed by b"
+	err = b.Persist()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "node n1")
+
+	c, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, "changed by a", c.Nodes["n1"].Comment)
+
+	// a can keep on working with its own view
+	a.Nodes["n2"].Comment = "changed again by a"
+	assert.NoError(t, a.Persist())
+}
+
+func Test_PersistUnloaded(t *testing.T) {
+	defer setupPersistTest(t)()
+
+	a, err := New()
+	assert.NoError(t, err)
+	a.Nodes["n1"].Comment = "changed by a"
+	assert.NoError(t, a.Persist())
+
+	// a configuration which wasn't read from nodes.conf keeps what is there
+	var b nodeYaml
+	b.Nodes = map[string]*NodeConf{"n3": {Comment: "added by b"}}
+	assert.NoError(t, b.Persist())
+
+	c, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, "changed by a", c.Nodes["n1"].Comment)
+	assert.Equal(t, "second", c.Nodes["n2"].Comment)
+	assert.Equal(t, "added by b", c.Nodes["n3"].Comment)
+	assert.Contains(t, c.NodeProfiles, "default")
+}
+
+func Test_NewMigrates(t *testing.T) {
+	defer setupPersistTest(t)()
+	old := []byte(`nodes:
+  n1:
+    runtime overlay: generic
+    kernel args: quiet
+    discoverable: true
+`)
+	assert.NoError(t, ioutil.WriteFile(ConfigFile, old, 0640))
+
+	config, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, 43, config.WWInternal)
+	assert.Equal(t, []string{"generic"}, config.Nodes["n1"].RuntimeOverlay)
+	assert.Equal(t, "quiet", config.Nodes["n1"].Kernel.Args)
+	assert.Equal(t, "true", config.Nodes["n1"].Discoverable)
+
+	// the file is migrated when it is read, the original is kept
+	backup, err := ioutil.ReadFile(ConfigFile + ".ww42")
+	assert.NoError(t, err)
+	assert.Equal(t, old, backup)
+	current, err := ioutil.ReadFile(ConfigFile)
+	assert.NoError(t, err)
+	assert.Equal(t, config.db.(*yamlBackend).loaded, current)
+
+	config.Nodes["n1"].Comment = "migrated"
+	assert.NoError(t, config.Persist())
+	config, err = New()
+	assert.NoError(t, err)
+	assert.Equal(t, "migrated", config.Nodes["n1"].Comment)
+
+	// an old file which replaced it since it was read is migrated before
+	// the changes are merged
+	restored := string(old) + "    comment: migrated\n  n2:\n    comment: restored\n"
+	assert.NoError(t, ioutil.WriteFile(ConfigFile, []byte(restored), 0640))
+	config.Nodes["n1"].Comment = "merged"
+	assert.NoError(t, config.Persist())
+	config, err = New()
+	assert.NoError(t, err)
+	assert.Equal(t, "merged", config.Nodes["n1"].Comment)
+	assert.Equal(t, "restored", config.Nodes["n2"].Comment)
+	assert.Equal(t, []string{"generic"}, config.Nodes["n1"].RuntimeOverlay)
+}
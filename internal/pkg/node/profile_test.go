@@ -0,0 +1,100 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const nestedConfig = `WW_INTERNAL: 43
+nodeprofiles:
+  default:
+    comment: default profile
+  gpu-base:
+    profiles:
+    - default
+    container name: rocky-gpu
+    kernel:
+      args: console=ttyS0
+    tags:
+      accel: gpu
+  gpu-a100:
+    profiles:
+    - gpu-base
+    kernel:
+      args: +a100
+  gpu-h100:
+    profiles:
+    - gpu-base
+    kernel:
+      args: +h100
+    tags:
+      accel: h100
+  loop-a:
+    profiles:
+    - loop-b
+  loop-b:
+    profiles:
+    - loop-a
+nodes:
+  n1:
+    profiles:
+    - gpu-a100
+    - gpu-h100
+  n2:
+    profiles:
+    - loop-a
+`
+
+func Test_ResolveProfiles(t *testing.T) {
+	var config nodeYaml
+	assert.NoError(t, yaml.Unmarshal([]byte(nestedConfig), &config))
+
+	profiles, err := config.ResolveProfiles([]string{"gpu-a100", "gpu-h100"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default", "gpu-base", "gpu-a100", "gpu-h100"}, profiles)
+
+	profiles, err = config.ResolveProfiles([]string{"loop-a"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "loop-a -> loop-b -> loop-a")
+	assert.Equal(t, []string{"loop-b", "loop-a"}, profiles)
+}
+
+func Test_NestedProfiles(t *testing.T) {
+	var config nodeYaml
+	assert.NoError(t, yaml.Unmarshal([]byte(nestedConfig), &config))
+
+	nodes, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	for _, n := range nodes {
+		if n.Id.Get() != "n1" {
+			continue
+		}
+		assert.Equal(t, "default profile", n.Comment.Get())
+		assert.Equal(t, "rocky-gpu", n.ContainerName.Get())
+		assert.Equal(t, "gpu-base", n.ContainerName.Source())
+		assert.Equal(t, "console=ttyS0 a100 h100", n.Kernel.Args.Get())
+		assert.Equal(t, "h100", n.Tags["accel"].Get())
+		assert.Equal(t, []string{"gpu-a100", "gpu-h100"}, n.Profiles)
+	}
+
+	profiles, err := config.FindAllProfiles()
+	assert.NoError(t, err)
+	for _, p := range profiles {
+		if p.Id.Get() != "gpu-a100" {
+			continue
+		}
+		assert.Equal(t, "rocky-gpu", p.ContainerName.Get())
+		assert.Equal(t, "", p.ContainerName.GetReal())
+		assert.Equal(t, "console=ttyS0 a100", p.Kernel.Args.Get())
+		assert.Equal(t, []string{"gpu-base"}, p.Profiles)
+
+		// inherited values are not stored in the including profile
+		assert.NoError(t, config.ProfileUpdate(p))
+		assert.Equal(t, "", config.NodeProfiles["gpu-a100"].ContainerName)
+		assert.Equal(t, "+a100", config.NodeProfiles["gpu-a100"].Kernel.Args)
+		assert.Empty(t, config.NodeProfiles["gpu-a100"].Tags)
+		assert.Equal(t, []string{"gpu-base"}, config.NodeProfiles["gpu-a100"].Profiles)
+	}
+}
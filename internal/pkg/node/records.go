@@ -0,0 +1,604 @@
+package node
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	FormatYaml = "yaml"
+	FormatJson = "json"
+	FormatCsv  = "csv"
+)
+
+/*
+Node records as they are stored in the node database, keyed by node name. The
+field names are the ones of nodes.conf in all formats, in csv nested fields
+are joined with a dot (e.g. "network devices.default.hwaddr") and lists with
+a comma. The fields are split by the structure of NodeConf, so that names of
+network devices, tags etc. may contain dots.
+*/
+type Records map[string]*NodeConf
+
+var nodeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_.-]*[a-zA-Z0-9])?$`)
+
+/*
+Returns the format for the extension of fileName, yaml if it is unknown
+*/
+func FormatFromFileName(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return FormatJson
+	case ".csv":
+		return FormatCsv
+	}
+	return FormatYaml
+}
+
+/*
+Returns the records of the given nodes of the configuration, all nodes if
+names is empty
+*/
+func (config *nodeYaml) Records(names []string) (Records, error) {
+	ret := make(Records)
+	if len(names) == 0 {
+		for name, n := range config.Nodes {
+			ret[name] = n
+		}
+		return ret, nil
+	}
+	for _, name := range names {
+		n, ok := config.Nodes[name]
+		if !ok {
+			return nil, errors.New("Nodename does not exist: " + name)
+		}
+		ret[name] = n
+	}
+	return ret, nil
+}
+
+/*
+Reads records in the given format
+*/
+func ReadRecords(r io.Reader, format string) (Records, error) {
+	ret := make(Records)
+	switch format {
+	case FormatYaml:
+		buffer, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		err = yaml.UnmarshalStrict(buffer, &ret)
+		if err != nil {
+			return nil, err
+		}
+	case FormatJson:
+		var content map[string]interface{}
+		err := json.NewDecoder(r).Decode(&content)
+		if err != nil {
+			return nil, err
+		}
+		for name, fields := range content {
+			n, err := recordFromValue(fields)
+			if err != nil {
+				return nil, errors.Wrapf(err, "node %s", name)
+			}
+			ret[name] = n
+		}
+	case FormatCsv:
+		reader := csv.NewReader(r)
+		reader.TrimLeadingSpace = true
+		lines, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 {
+			return ret, nil
+		}
+		header := lines[0]
+		if len(header) == 0 || header[0] != "name" {
+			return nil, errors.New("the first column of the csv header must be 'name'")
+		}
+		for i, line := range lines[1:] {
+			fields := make(map[string]string)
+			for j, value := range line[1:] {
+				if value != "" {
+					fields[header[j+1]] = value
+				}
+			}
+			n, err := recordFromFields(fields)
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", i+2)
+			}
+			if _, ok := ret[line[0]]; ok {
+				return nil, fmt.Errorf("line %d: node %s is listed twice", i+2, line[0])
+			}
+			ret[line[0]] = n
+		}
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+	for name, n := range ret {
+		if n == nil {
+			ret[name] = &NodeConf{}
+		}
+	}
+	return ret, nil
+}
+
+/*
+Writes the records sorted by name in the given format
+*/
+func (records Records) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatYaml:
+		buffer, err := yaml.Marshal(records)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(buffer)
+		return err
+	case FormatJson:
+		content := make(map[string]interface{})
+		for name, n := range records {
+			var value interface{}
+			err := yaml.Unmarshal(confBytes(n), &value)
+			if err != nil {
+				return err
+			}
+			content[name] = jsonValue(value)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(content)
+	case FormatCsv:
+		rows := make(map[string]map[string]string)
+		columns := make(map[string]bool)
+		for name, n := range records {
+			fields := make(map[string]string)
+			flattenYaml(confBytes(n), fields)
+			for field := range fields {
+				columns[field] = true
+			}
+			rows[name] = fields
+		}
+		header := append([]string{"name"}, sortedKeys(columns)...)
+		writer := csv.NewWriter(w)
+		err := writer.Write(header)
+		if err != nil {
+			return err
+		}
+		for _, name := range records.Names() {
+			line := []string{name}
+			for _, column := range header[1:] {
+				line = append(line, rows[name][column])
+			}
+			err = writer.Write(line)
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+/*
+Sorted names of the records
+*/
+func (records Records) Names() []string {
+	names := make(map[string]bool)
+	for name := range records {
+		names[name] = true
+	}
+	return sortedKeys(names)
+}
+
+/*
+Converts yaml maps to maps with string keys, which can be encoded as json
+*/
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		ret := make(map[string]interface{})
+		for key, val := range v {
+			ret[fmt.Sprint(key)] = jsonValue(val)
+		}
+		return ret
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonValue(v[i])
+		}
+	}
+	return value
+}
+
+func recordFromValue(value interface{}) (*NodeConf, error) {
+	buffer, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var n NodeConf
+	err = yaml.UnmarshalStrict(buffer, &n)
+	return &n, err
+}
+
+/*
+Splits a flattened field into the keys of its path by the type of NodeConf, so
+that map keys may contain dots, e.g. "tags.a.b" is the tag "a.b". The key of
+a map of structs is the shortest one after which the rest of the field is a
+field of the struct. Returns the type of the field, which tells if it is a
+list, and false if the field doesn't exist.
+*/
+func splitField(t reflect.Type, field string) ([]string, reflect.Type, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			if field == name {
+				return []string{name}, t.Field(i).Type, true
+			}
+			if strings.HasPrefix(field, name+".") {
+				parts, leaf, ok := splitField(t.Field(i).Type, field[len(name)+1:])
+				if ok {
+					return append([]string{name}, parts...), leaf, true
+				}
+			}
+		}
+	case reflect.Map:
+		elem := t.Elem()
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() != reflect.Struct {
+			return []string{field}, elem, field != ""
+		}
+		for i := 1; i < len(field); i++ {
+			if field[i] != '.' {
+				continue
+			}
+			parts, leaf, ok := splitField(elem, field[i+1:])
+			if ok {
+				return append([]string{field[:i]}, parts...), leaf, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+/*
+Creates a record from flattened fields as used in csv
+*/
+func recordFromFields(fields map[string]string) (*NodeConf, error) {
+	content := make(map[string]interface{})
+	for field, value := range fields {
+		parts, leaf, ok := splitField(reflect.TypeOf(NodeConf{}), field)
+		if !ok {
+			// unknown fields are reported by the unmarshaling
+			parts = strings.Split(field, ".")
+		}
+		m := content
+		for _, part := range parts[:len(parts)-1] {
+			sub, ok := m[part].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				m[part] = sub
+			}
+			m = sub
+		}
+		last := parts[len(parts)-1]
+		if ok && leaf.Kind() == reflect.Slice {
+			var list []string
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					list = append(list, v)
+				}
+			}
+			m[last] = list
+		} else {
+			m[last] = value
+		}
+	}
+	return recordFromValue(content)
+}
+
+/*
+Overlays the values of src which are set on dst, structs and maps are merged
+field by field and key by key, lists replace each other. A value which
+Entry.Set() treats as removal (e.g. UNSET) removes the value of dst, structs
+which end up empty are removed from their map.
+*/
+func overlayValue(dst reflect.Value, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(src.Type().Elem()))
+		}
+		overlayValue(dst.Elem(), src.Elem())
+		if emptyValue(dst) {
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).PkgPath == "" {
+				overlayValue(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(src.Type()))
+		}
+		for _, key := range src.MapKeys() {
+			value := reflect.New(src.Type().Elem()).Elem()
+			if old := dst.MapIndex(key); old.IsValid() {
+				value.Set(old)
+			}
+			overlayValue(value, src.MapIndex(key))
+			if emptyValue(value) {
+				dst.SetMapIndex(key, reflect.Value{})
+			} else {
+				dst.SetMapIndex(key, value)
+			}
+		}
+		if dst.Len() == 0 {
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+	case reflect.Slice:
+		if src.Len() == 0 {
+			return
+		}
+		if src.Len() == 1 && src.Index(0).Kind() == reflect.String && isUnset(src.Index(0).String()) {
+			dst.Set(reflect.Zero(dst.Type()))
+			return
+		}
+		dst.Set(src)
+	case reflect.String:
+		if src.String() == "" {
+			return
+		}
+		if isUnset(src.String()) {
+			dst.SetString("")
+			return
+		}
+		dst.Set(src)
+	default:
+		if !src.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
+
+/*
+True if the value has nothing set, recursing into structs
+*/
+func emptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr:
+		return v.IsNil() || emptyValue(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" && !emptyValue(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+/*
+Result of an import for a single node
+*/
+type ImportResult struct {
+	Name    string
+	Added   bool
+	Changes []FieldChange
+}
+
+/*
+Applies the records to the configuration. Existing nodes are only updated if
+upsert is set, the fields of the record replace the ones of the node and the
+values which Entry.Set() treats as removal (e.g. UNSET) remove a field. All
+records are validated, nothing is changed if one of them is invalid. Returns
+the changes per node, sorted by name.
+*/
+func (config *nodeYaml) ImportRecords(records Records, upsert bool) ([]ImportResult, error) {
+	var errs []string
+	updated := make(map[string]*NodeConf)
+	var ret []ImportResult
+	for _, name := range records.Names() {
+		record := records[name]
+		existing, exists := config.Nodes[name]
+		if exists && !upsert {
+			errs = append(errs, fmt.Sprintf("%s: node exists already", name))
+			continue
+		}
+		if !nodeNameRegexp.MatchString(name) {
+			errs = append(errs, fmt.Sprintf("%s: invalid node name", name))
+			continue
+		}
+
+		// same defaults as wwctl node add
+		n := &NodeConf{Profiles: []string{"default"}}
+		if exists {
+			n = new(NodeConf)
+			err := yaml.Unmarshal(confBytes(existing), n)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+				continue
+			}
+		}
+		if record != nil {
+			overlayValue(reflect.ValueOf(n).Elem(), reflect.ValueOf(record).Elem())
+		}
+		for _, e := range config.validateRecord(n) {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, e))
+		}
+		updated[name] = n
+
+		before := confBytes(existing)
+		after := confBytes(n)
+		if string(before) != string(after) || !exists {
+			ret = append(ret, ImportResult{Name: name, Added: !exists, Changes: diffFields(before, after)})
+		}
+	}
+
+	// addresses must be unique over all nodes, the ones of the nodes which
+	// are not imported are registered first
+	owners := make(map[string]string)
+	for _, imported := range []bool{false, true} {
+		for _, name := range sortedNodeNames(config.Nodes, updated) {
+			n, ok := updated[name]
+			if ok != imported {
+				continue
+			}
+			if !ok {
+				n = config.Nodes[name]
+			}
+			for _, devName := range sortedNetDevNames(n.NetDevs) {
+				dev := n.NetDevs[devName]
+				for _, addr := range []string{strings.ToLower(dev.Hwaddr), dev.Ipaddr} {
+					if addr == "" {
+						continue
+					}
+					if owner, ok := owners[addr]; ok && owner != name {
+						if imported {
+							errs = append(errs, fmt.Sprintf("%s: %s of %s is used by %s", name, addr, devName, owner))
+						}
+						continue
+					}
+					owners[addr] = name
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("invalid records:\n  %s", strings.Join(errs, "\n  "))
+	}
+	config.modified()
+	for name, n := range updated {
+		config.Nodes[name] = n
+	}
+	return ret, nil
+}
+
+func sortedNetDevNames(netDevs map[string]*NetDevs) []string {
+	names := make(map[string]bool)
+	for name, dev := range netDevs {
+		if dev != nil {
+			names[name] = true
+		}
+	}
+	return sortedKeys(names)
+}
+
+func sortedNodeNames(maps ...map[string]*NodeConf) []string {
+	names := make(map[string]bool)
+	for _, m := range maps {
+		for name := range m {
+			names[name] = true
+		}
+	}
+	return sortedKeys(names)
+}
+
+/*
+Checks the fields of a node record, returns the problems found
+*/
+func (config *nodeYaml) validateRecord(n *NodeConf) []string {
+	var errs []string
+	for _, p := range n.Profiles {
+		if _, ok := config.NodeProfiles[p]; !ok {
+			errs = append(errs, fmt.Sprintf("profile does not exist: %s", p))
+		}
+	}
+	checkIP := func(field string, value string) {
+		if value != "" && net.ParseIP(value) == nil {
+			errs = append(errs, fmt.Sprintf("invalid %s: %s", field, value))
+		}
+	}
+	for devName, dev := range n.NetDevs {
+		if dev == nil {
+			continue
+		}
+		if dev.Hwaddr != "" {
+			if _, err := net.ParseMAC(dev.Hwaddr); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid hwaddr of %s: %s", devName, dev.Hwaddr))
+			}
+		}
+		checkIP("ipaddr of "+devName, dev.Ipaddr)
+		checkIP("netmask of "+devName, dev.Netmask)
+		checkIP("gateway of "+devName, dev.Gateway)
+		if dev.Ipaddr6 != "" {
+			if _, _, err := net.ParseCIDR(dev.Ipaddr6); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid ip6addr of %s: %s", devName, dev.Ipaddr6))
+			}
+		}
+		for _, b := range []struct{ field, value string }{
+			{"onboot", dev.OnBoot}, {"primary", dev.Primary}} {
+			if b.value != "" && !isBool(b.value) {
+				errs = append(errs, fmt.Sprintf("invalid %s of %s: %s", b.field, devName, b.value))
+			}
+		}
+	}
+	if n.Ipmi != nil {
+		checkIP("ipmi ipaddr", n.Ipmi.Ipaddr)
+		checkIP("ipmi netmask", n.Ipmi.Netmask)
+		checkIP("ipmi gateway", n.Ipmi.Gateway)
+	}
+	if n.UUID != "" {
+		if _, err := NormalizeUUID(n.UUID); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if n.Discoverable != "" && !isBool(n.Discoverable) {
+		errs = append(errs, fmt.Sprintf("invalid discoverable: %s", n.Discoverable))
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+func isUnset(value string) bool {
+	switch value {
+	case "UNDEF", "DELETE", "UNSET", "--", "nil":
+		return true
+	}
+	return false
+}
+
+func isBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "1", "0":
+		return true
+	}
+	return false
+}
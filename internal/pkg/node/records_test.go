@@ -0,0 +1,180 @@
+package node
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const recordsConfig = `WW_INTERNAL: 43
+nodeprofiles:
+  default:
+    comment: default profile
+  test: {}
+nodes:
+  n1:
+    comment: first
+    container name: rocky
+    profiles:
+    - default
+    network devices:
+      default:
+        hwaddr: 08:00:27:39:46:70
+        ipaddr: 10.0.0.1
+    disks:
+      /dev/sda:
+        partitions:
+          root:
+            number: "1"
+      /dev/sdb:
+        partitions:
+          root:
+            number: "1"
+    raids:
+      md0:
+        level: "1"
+        devices:
+        - /dev/disk/by-partlabel/root
+        - /dev/sdb1
+    filesystems:
+      /dev/md/md0:
+        format: ext4
+        path: /
+`
+
+func recordsTestConfig(t *testing.T) nodeYaml {
+	var config nodeYaml
+	assert.NoError(t, yaml.Unmarshal([]byte(recordsConfig), &config))
+	return config
+}
+
+func Test_RecordsRoundTrip(t *testing.T) {
+	config := recordsTestConfig(t)
+	records, err := config.Records(nil)
+	assert.NoError(t, err)
+
+	for _, format := range []string{FormatYaml, FormatJson, FormatCsv} {
+		var buffer bytes.Buffer
+		assert.NoError(t, records.Write(&buffer, format), format)
+		read, err := ReadRecords(&buffer, format)
+		assert.NoError(t, err, format)
+		assert.Equal(t, records, read, format)
+	}
+}
+
+func Test_ReadRecordsCsv(t *testing.T) {
+	csv := `name,container name,profiles,network devices.default.ipaddr,network devices.default.hwaddr
+n2,rocky,"default,test",10.0.0.2,08:00:27:39:46:71
+n3,,,10.0.0.3,
+`
+	records, err := ReadRecords(strings.NewReader(csv), FormatCsv)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"n2", "n3"}, records.Names())
+	assert.Equal(t, []string{"default", "test"}, records["n2"].Profiles)
+	assert.Equal(t, "08:00:27:39:46:71", records["n2"].NetDevs["default"].Hwaddr)
+	assert.Equal(t, "", records["n3"].ContainerName)
+	assert.Equal(t, "10.0.0.3", records["n3"].NetDevs["default"].Ipaddr)
+
+	csv = `name,raids.md0.level,raids.md0.devices
+n4,1,"/dev/sda1,/dev/sdb1"
+`
+	records, err = ReadRecords(strings.NewReader(csv), FormatCsv)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/dev/sda1", "/dev/sdb1"}, records["n4"].Raids["md0"].Devices)
+
+	_, err = ReadRecords(strings.NewReader("name,unknown field\nn2,foo\n"), FormatCsv)
+	assert.Error(t, err)
+	_, err = ReadRecords(strings.NewReader("node,comment\nn2,foo\n"), FormatCsv)
+	assert.Error(t, err)
+}
+
+func Test_ImportRecords(t *testing.T) {
+	config := recordsTestConfig(t)
+	records := Records{
+		"n1": {Comment: "UNSET", Init: "/bin/init"},
+		"n2": {NetDevs: map[string]*NetDevs{"default": {Ipaddr: "10.0.0.2"}}},
+	}
+
+	_, err := config.ImportRecords(records, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "n1: node exists already")
+	assert.NotContains(t, config.Nodes, "n2")
+
+	results, err := config.ImportRecords(records, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []ImportResult{
+		{Name: "n1", Changes: []FieldChange{
+			{Field: "comment", Old: "first"},
+			{Field: "init", New: "/bin/init"}}},
+		{Name: "n2", Added: true, Changes: []FieldChange{
+			{Field: "network devices.default.ipaddr", New: "10.0.0.2"},
+			{Field: "profiles", New: "default"}}},
+	}, results)
+	assert.Equal(t, "", config.Nodes["n1"].Comment)
+	assert.Equal(t, "rocky", config.Nodes["n1"].ContainerName)
+	assert.Equal(t, []string{"default"}, config.Nodes["n2"].Profiles)
+}
+
+func Test_ImportRecordsValidation(t *testing.T) {
+	config := recordsTestConfig(t)
+	records := Records{
+		"n2": {
+			Profiles: []string{"missing"},
+			NetDevs: map[string]*NetDevs{"default": {
+				Hwaddr: "08:00:27:39:46:70", Ipaddr: "10.0.0.300"}},
+		},
+		"-n3": {},
+		"a0":  {NetDevs: map[string]*NetDevs{"default": {Ipaddr: "10.0.0.1"}}},
+	}
+	_, err := config.ImportRecords(records, true)
+	assert.Error(t, err)
+	for _, msg := range []string{
+		"-n3: invalid node name",
+		"n2: profile does not exist: missing",
+		"n2: invalid ipaddr of default: 10.0.0.300",
+		"n2: 08:00:27:39:46:70 of default is used by n1",
+		"a0: 10.0.0.1 of default is used by n1",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+	assert.NotContains(t, config.Nodes, "n2")
+}
+
+func Test_RecordsDottedKeys(t *testing.T) {
+	records := Records{
+		"n1": {
+			Tags: map[string]string{"a.b": "c"},
+			NetDevs: map[string]*NetDevs{
+				"eth0.100": {Ipaddr: "10.0.0.1", Tags: map[string]string{"x.y": "z"}},
+			},
+		},
+	}
+	var buffer bytes.Buffer
+	assert.NoError(t, records.Write(&buffer, FormatCsv))
+	read, err := ReadRecords(&buffer, FormatCsv)
+	assert.NoError(t, err)
+	assert.Equal(t, records, read)
+
+	// the keys are merged one by one
+	config := recordsTestConfig(t)
+	config.Nodes["n1"].Tags = map[string]string{"a.b": "c", "d": "e"}
+	_, err = config.ImportRecords(Records{
+		"n1": {
+			Tags: map[string]string{"a.b": "UNSET", "f.g": "h"},
+			NetDevs: map[string]*NetDevs{
+				"default":  {Ipaddr: "10.0.0.2"},
+				"eth0.100": {Ipaddr: "10.0.1.2"},
+			},
+		},
+	}, true)
+	assert.NoError(t, err)
+	n := config.Nodes["n1"]
+	assert.Equal(t, map[string]string{"d": "e", "f.g": "h"}, n.Tags)
+	assert.Equal(t, "10.0.0.2", n.NetDevs["default"].Ipaddr)
+	assert.Equal(t, "08:00:27:39:46:70", n.NetDevs["default"].Hwaddr)
+	assert.Equal(t, "10.0.1.2", n.NetDevs["eth0.100"].Ipaddr)
+	assert.Equal(t, "first", n.Comment)
+}
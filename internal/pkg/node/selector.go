@@ -0,0 +1,241 @@
+package node
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+/*
+A single term of a selector like profile=gpu, tag:rack!=12 or
+netdev:ib0.type=infiniband. Values are glob patterns, alternatives are
+separated by '|'.
+*/
+type SelectorTerm struct {
+	Key    string
+	Negate bool
+	Values []string
+}
+
+/*
+A node selector is a comma separated list of terms, a node is selected if
+all terms match
+*/
+type Selector []SelectorTerm
+
+var selectorKeys = map[string]bool{
+	"name":         true,
+	"profile":      true,
+	"container":    true,
+	"kernel":       true,
+	"cluster":      true,
+	"arch":         true,
+	"ipxe":         true,
+	"discoverable": true,
+	"stage":        true,
+}
+
+var selectorNetDevKeys = map[string]bool{
+	"type":    true,
+	"device":  true,
+	"hwaddr":  true,
+	"ipaddr":  true,
+	"ipaddr6": true,
+	"netmask": true,
+	"gateway": true,
+	"network": true,
+	"onboot":  true,
+	"primary": true,
+}
+
+/*
+Parses a selector, e.g. "profile=gpu,tag:rack=12,stage!=RUNTIME_OVERLAY".
+Known keys are name, profile, container, kernel, cluster, arch, ipxe,
+discoverable, stage, tag:KEY, netdev.ATTR (any network device) and
+netdev:NAME.ATTR. A profile matches the nodes which have it directly or
+through an included profile.
+*/
+func ParseSelector(sel string) (Selector, error) {
+	var ret Selector
+	if strings.TrimSpace(sel) == "" {
+		return ret, nil
+	}
+	for _, t := range strings.Split(sel, ",") {
+		var term SelectorTerm
+		var value string
+		t = strings.TrimSpace(t)
+		if i := strings.Index(t, "!="); i > 0 {
+			term.Key, value, term.Negate = t[:i], t[i+2:], true
+		} else if i := strings.Index(t, "="); i > 0 {
+			term.Key, value = t[:i], t[i+1:]
+		} else {
+			return nil, fmt.Errorf("invalid selector term, must be KEY=VALUE or KEY!=VALUE: %s", t)
+		}
+		term.Key = strings.TrimSpace(term.Key)
+		if err := checkSelectorKey(term.Key); err != nil {
+			return nil, err
+		}
+		for _, v := range strings.Split(value, "|") {
+			if _, err := path.Match(v, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern in selector: %s", v)
+			}
+			term.Values = append(term.Values, v)
+		}
+		ret = append(ret, term)
+	}
+	return ret, nil
+}
+
+func checkSelectorKey(key string) error {
+	switch {
+	case selectorKeys[key]:
+		return nil
+	case strings.HasPrefix(key, "tag:") && len(key) > 4:
+		return nil
+	case strings.HasPrefix(key, "netdev."):
+		if selectorNetDevKeys[strings.TrimPrefix(key, "netdev.")] {
+			return nil
+		}
+	case strings.HasPrefix(key, "netdev:"):
+		parts := strings.SplitN(strings.TrimPrefix(key, "netdev:"), ".", 2)
+		if len(parts) == 2 && parts[0] != "" && selectorNetDevKeys[parts[1]] {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown selector key: %s", key)
+}
+
+/*
+Returns true if the selector uses the provisioning stage, which is only
+known to the running warewulfd
+*/
+func (sel Selector) NeedsStage() bool {
+	for _, term := range sel {
+		if term.Key == "stage" {
+			return true
+		}
+	}
+	return false
+}
+
+func netDevValue(netdev *NetDevEntry, attr string) string {
+	switch attr {
+	case "type":
+		return netdev.Type.Get()
+	case "device":
+		return netdev.Device.Get()
+	case "hwaddr":
+		return netdev.Hwaddr.Get()
+	case "ipaddr":
+		return netdev.Ipaddr.Get()
+	case "ipaddr6":
+		return netdev.Ipaddr6.Get()
+	case "netmask":
+		return netdev.Netmask.Get()
+	case "gateway":
+		return netdev.Gateway.Get()
+	case "network":
+		return netdev.Network.Get()
+	case "onboot":
+		return netdev.OnBoot.PrintB()
+	case "primary":
+		return netdev.Primary.PrintB()
+	}
+	return ""
+}
+
+/*
+The values of the node the term is matched against, the term matches if
+any of them matches
+*/
+func (term SelectorTerm) nodeValues(n NodeInfo, stage string) []string {
+	switch {
+	case term.Key == "name":
+		return []string{n.Id.Get()}
+	case term.Key == "profile":
+		// the included profiles match as well
+		if len(n.AllProfiles) > 0 {
+			return n.AllProfiles
+		}
+		if len(n.Profiles) == 0 {
+			return []string{""}
+		}
+		return n.Profiles
+	case term.Key == "container":
+		return []string{n.ContainerName.Get()}
+	case term.Key == "kernel":
+		if n.Kernel == nil {
+			return []string{""}
+		}
+		return []string{n.Kernel.Override.Get()}
+	case term.Key == "cluster":
+		return []string{n.ClusterName.Get()}
+	case term.Key == "arch":
+		return []string{n.Arch.Get()}
+	case term.Key == "ipxe":
+		return []string{n.Ipxe.Get()}
+	case term.Key == "discoverable":
+		return []string{n.Discoverable.PrintB()}
+	case term.Key == "stage":
+		return []string{stage}
+	case strings.HasPrefix(term.Key, "tag:"):
+		if tag, ok := n.Tags[strings.TrimPrefix(term.Key, "tag:")]; ok {
+			return []string{tag.Get()}
+		}
+		return []string{""}
+	case strings.HasPrefix(term.Key, "netdev."):
+		var ret []string
+		for _, netdev := range n.NetDevs {
+			ret = append(ret, netDevValue(netdev, strings.TrimPrefix(term.Key, "netdev.")))
+		}
+		if len(ret) == 0 {
+			return []string{""}
+		}
+		return ret
+	case strings.HasPrefix(term.Key, "netdev:"):
+		parts := strings.SplitN(strings.TrimPrefix(term.Key, "netdev:"), ".", 2)
+		if netdev, ok := n.NetDevs[parts[0]]; ok {
+			return []string{netDevValue(netdev, parts[1])}
+		}
+		return []string{""}
+	}
+	return []string{""}
+}
+
+func (term SelectorTerm) match(n NodeInfo, stage string) bool {
+	for _, value := range term.nodeValues(n, stage) {
+		for _, pattern := range term.Values {
+			if ok, _ := path.Match(pattern, value); ok {
+				return !term.Negate
+			}
+		}
+	}
+	return term.Negate
+}
+
+/*
+Returns true if all terms of the selector match the node, stage is the
+provisioning stage of the node as reported by warewulfd
+*/
+func (sel Selector) Match(n NodeInfo, stage string) bool {
+	for _, term := range sel {
+		if !term.match(n, stage) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Filter a given slice of NodeInfo with a selector, stages maps node IDs to
+their provisioning stage and is only needed if the selector uses it
+*/
+func FilterBySelector(set []NodeInfo, sel Selector, stages map[string]string) []NodeInfo {
+	var ret []NodeInfo
+	for _, n := range set {
+		if sel.Match(n, stages[n.Id.Get()]) {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const selectorConfig = `WW_INTERNAL: 43
+nodeprofiles:
+  default:
+    container name: rocky
+  gpu:
+    tags:
+      accel: nvidia
+  a100:
+    profiles:
+    - gpu
+nodes:
+  n1:
+    profiles:
+    - default
+    - a100
+    tags:
+      rack: "12"
+    network devices:
+      ib0:
+        type: infiniband
+  n2:
+    profiles:
+    - default
+    container name: rocky-new
+    tags:
+      rack: "13"
+  n3:
+    cluster name: cluster2
+    profiles:
+    - default
+    kernel:
+      override: 5.14-custom
+`
+
+func Test_Selector(t *testing.T) {
+	var config nodeYaml
+	assert.NoError(t, yaml.Unmarshal([]byte(selectorConfig), &config))
+	nodes, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	stages := map[string]string{"n1": "RUNTIME_OVERLAY", "n2": "KERNEL"}
+
+	tests := []struct {
+		selector string
+		nodes    []string
+	}{
+		{"", []string{"n1", "n2", "n3"}},
+		{"profile=gpu", []string{"n1"}},
+		{"profile=a100", []string{"n1"}},
+		{"profile!=gpu", []string{"n2", "n3"}},
+		{"container=rocky", []string{"n1", "n3"}},
+		{"container=rocky*", []string{"n1", "n2", "n3"}},
+		{"tag:rack=12|13", []string{"n1", "n2"}},
+		{"tag:rack=", []string{"n3"}},
+		{"tag:accel=nvidia", []string{"n1"}},
+		{"kernel=5.14*", []string{"n3"}},
+		{"cluster=cluster2", []string{"n3"}},
+		{"netdev.type=infiniband", []string{"n1"}},
+		{"netdev:ib0.type!=infiniband", []string{"n2", "n3"}},
+		{"stage!=RUNTIME_OVERLAY", []string{"n2", "n3"}},
+		{"profile=default,stage!=RUNTIME_OVERLAY,container!=rocky", []string{"n2"}},
+		{"name=n[12]", []string{"n1", "n2"}},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.selector)
+		assert.NoError(t, err, tt.selector)
+		var got []string
+		for _, n := range FilterBySelector(nodes, sel, stages) {
+			got = append(got, n.Id.Get())
+		}
+		sort.Strings(got)
+		assert.Equal(t, tt.nodes, got, tt.selector)
+	}
+
+	for _, s := range []string{"profile", "color=red", "netdev.speed=10", "netdev:.type=ib", "name=[", "=x"} {
+		_, err := ParseSelector(s)
+		assert.Error(t, err, s)
+	}
+
+	sel, _ := ParseSelector("profile=gpu,stage=KERNEL")
+	assert.True(t, sel.NeedsStage())
+}
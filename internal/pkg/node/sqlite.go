@@ -0,0 +1,357 @@
+package node
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS objects (
+	kind TEXT NOT NULL,
+	name TEXT NOT NULL,
+	conf TEXT NOT NULL,
+	PRIMARY KEY (kind, name)
+);
+CREATE TABLE IF NOT EXISTS netdevs (
+	node TEXT NOT NULL,
+	netdev TEXT NOT NULL,
+	hwaddr TEXT NOT NULL DEFAULT '',
+	ipaddr TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (node, netdev)
+);
+CREATE INDEX IF NOT EXISTS netdevs_hwaddr ON netdevs (hwaddr);
+CREATE INDEX IF NOT EXISTS netdevs_ipaddr ON netdevs (ipaddr);
+`
+
+/*
+Embedded sqlite database, every node and profile is a row, so that only the
+changed ones are written. The network devices of the nodes are indexed by
+hwaddr and ipaddr.
+*/
+type sqliteBackend struct {
+	fileName string
+	// json of the loaded objects by kind and name, used to find the changed
+	// objects and to detect concurrent modifications on store()
+	loaded map[string]map[string]string
+	// WW_INTERNAL as loaded
+	loadedInternal int
+}
+
+func (db *sqliteBackend) file() string {
+	return db.fileName
+}
+
+func (db *sqliteBackend) open() (*sql.DB, error) {
+	err := os.MkdirAll(path.Dir(db.fileName), 0755)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sql.Open("sqlite3", "file:"+db.fileName+"?_txlock=immediate&_busy_timeout=30000")
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Exec(sqliteSchema)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "could not create schema of %s", db.fileName)
+	}
+	return conn, nil
+}
+
+func (db *sqliteBackend) load() (nodeYaml, error) {
+	var ret nodeYaml
+	ret.Nodes = make(map[string]*NodeConf)
+	ret.NodeProfiles = make(map[string]*NodeConf)
+
+	wwlog.Printf(wwlog.VERBOSE, "Opening node database: %s\n", db.fileName)
+	conn, err := db.open()
+	if err != nil {
+		return ret, err
+	}
+	defer conn.Close()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return ret, err
+	}
+	defer tx.Rollback()
+
+	ret.WWInternal, err = sqliteInternal(tx)
+	if err != nil {
+		return ret, err
+	}
+
+	rows, err := tx.Query("SELECT kind, name, conf FROM objects")
+	if err != nil {
+		return ret, err
+	}
+	defer rows.Close()
+
+	loaded := map[string]map[string]string{"node": {}, "profile": {}}
+	for rows.Next() {
+		var kind, name, conf string
+		err = rows.Scan(&kind, &name, &conf)
+		if err != nil {
+			return ret, err
+		}
+		objects := ret.objects(kind)
+		if objects == nil {
+			wwlog.Warn("Ignoring %s %s of unknown kind in %s", kind, name, db.fileName)
+			continue
+		}
+		var n NodeConf
+		err = json.Unmarshal([]byte(conf), &n)
+		if err != nil {
+			return ret, errors.Wrapf(err, "could not parse %s %s", kind, name)
+		}
+		objects[name] = &n
+		loaded[kind][name] = conf
+	}
+	if err = rows.Err(); err != nil {
+		return ret, err
+	}
+	db.loaded = loaded
+	db.loadedInternal = ret.WWInternal
+
+	return ret, nil
+}
+
+func sqliteInternal(tx *sql.Tx) (int, error) {
+	var value string
+	err := tx.QueryRow("SELECT value FROM meta WHERE key = 'WW_INTERNAL'").Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+/*
+Writes the changed nodes and profiles within a single transaction. A change is
+refused if the node or profile was modified by someone else since it was
+loaded.
+*/
+func (db *sqliteBackend) store(config *nodeYaml) error {
+	// serializes the audit log ids, the database itself is protected by
+	// the transaction
+	lock, err := util.LockFile(db.fileName + ".lock")
+	if err != nil {
+		return errors.Wrap(err, "could not lock node database")
+	}
+	defer lock.Unlock()
+
+	conn, err := db.open()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if db.loaded == nil {
+		db.loaded = map[string]map[string]string{"node": {}, "profile": {}}
+	}
+
+	var objects []ObjectChange
+	var conflicts []string
+	stored := map[string]map[string]string{"node": {}, "profile": {}}
+	for _, kind := range []string{"profile", "node"} {
+		confs := config.objects(kind)
+		names := make(map[string]bool)
+		for name := range confs {
+			names[name] = true
+		}
+		for name := range db.loaded[kind] {
+			names[name] = true
+		}
+		for _, name := range sortedKeys(names) {
+			var conf string
+			if confs[name] != nil {
+				buffer, err := json.Marshal(confs[name])
+				if err != nil {
+					return err
+				}
+				conf = string(buffer)
+			}
+			base := db.loaded[kind][name]
+			if conf == base {
+				continue
+			}
+
+			var current string
+			err = tx.QueryRow("SELECT conf FROM objects WHERE kind = ? AND name = ?", kind, name).Scan(&current)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			if current != base && current != conf {
+				conflicts = append(conflicts, kind+" "+name)
+				continue
+			}
+
+			err = sqliteWrite(tx, kind, name, confs[name], conf)
+			if err != nil {
+				return err
+			}
+			stored[kind][name] = conf
+
+			before, err := jsonToYaml(current)
+			if err != nil {
+				return err
+			}
+			after := confBytes(confs[name])
+			objects = append(objects, ObjectChange{
+				Kind:   kind,
+				Name:   name,
+				Before: string(before),
+				After:  string(after),
+				Fields: diffFields(before, after),
+			})
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("node configuration was modified concurrently, conflicting changes of: %s",
+			strings.Join(conflicts, ", "))
+	}
+
+	if config.WWInternal != db.loadedInternal {
+		_, err = tx.Exec("INSERT OR REPLACE INTO meta (key, value) VALUES ('WW_INTERNAL', ?)",
+			strconv.Itoa(config.WWInternal))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrapf(err, "could not write %s", db.fileName)
+	}
+	for kind := range stored {
+		for name, conf := range stored[kind] {
+			if conf == "" {
+				delete(db.loaded[kind], name)
+			} else {
+				db.loaded[kind][name] = conf
+			}
+		}
+	}
+	db.loadedInternal = config.WWInternal
+
+	err = writeAuditLog(objects)
+	if err != nil {
+		wwlog.Warn("Could not write audit log %s: %s", AuditLogFile, err)
+	}
+
+	return nil
+}
+
+/*
+Writes a single object and the index of its network devices, an empty conf
+deletes the object
+*/
+func sqliteWrite(tx *sql.Tx, kind string, name string, n *NodeConf, conf string) error {
+	if kind == "node" {
+		_, err := tx.Exec("DELETE FROM netdevs WHERE node = ?", name)
+		if err != nil {
+			return err
+		}
+	}
+	if conf == "" {
+		_, err := tx.Exec("DELETE FROM objects WHERE kind = ? AND name = ?", kind, name)
+		return err
+	}
+	_, err := tx.Exec("INSERT OR REPLACE INTO objects (kind, name, conf) VALUES (?, ?, ?)", kind, name, conf)
+	if err != nil {
+		return err
+	}
+	if kind != "node" {
+		return nil
+	}
+	for netdev, dev := range n.NetDevs {
+		if dev == nil {
+			continue
+		}
+		_, err = tx.Exec("INSERT INTO netdevs (node, netdev, hwaddr, ipaddr) VALUES (?, ?, ?, ?)",
+			name, netdev, strings.ToLower(dev.Hwaddr), dev.Ipaddr)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *sqliteBackend) lookup(field string, value string) ([]string, error) {
+	var query string
+	switch field {
+	case "hwaddr":
+		query = "SELECT DISTINCT node FROM netdevs WHERE hwaddr = ?"
+		value = strings.ToLower(value)
+	case "ipaddr":
+		query = "SELECT DISTINCT node FROM netdevs WHERE ipaddr = ?"
+	default:
+		return nil, nil
+	}
+	conn, err := db.open()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(query, value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ret := []string{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, name)
+	}
+	return ret, rows.Err()
+}
+
+func jsonToYaml(conf string) ([]byte, error) {
+	if conf == "" {
+		return nil, nil
+	}
+	var n NodeConf
+	err := json.Unmarshal([]byte(conf), &n)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&n)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var ret []string
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
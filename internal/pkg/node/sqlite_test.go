@@ -0,0 +1,78 @@
+package node
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SqliteBackend(t *testing.T) {
+	defer setupPersistTest(t)()
+	dbFile := path.Join(path.Dir(ConfigFile), "nodes.db")
+
+	// import nodes.conf into the database
+	yamlDb, err := OpenBackend(BackendYaml, "")
+	assert.NoError(t, err)
+	yamlConf, err := NewFromBackend(yamlDb)
+	assert.NoError(t, err)
+	yamlConf.Nodes["n1"].NetDevs = map[string]*NetDevs{
+		"eth0": {Hwaddr: "08:00:27:39:46:70", Ipaddr: "10.0.0.1"},
+	}
+
+	db, err := OpenBackend(BackendSqlite, dbFile)
+	assert.NoError(t, err)
+	config, err := NewFromBackend(db)
+	assert.NoError(t, err)
+	assert.Empty(t, config.Nodes)
+	config.Replace(yamlConf)
+	assert.NoError(t, config.Persist())
+
+	db, err = OpenBackend(BackendSqlite, dbFile)
+	assert.NoError(t, err)
+	a, err := NewFromBackend(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 43, a.WWInternal)
+	assert.Equal(t, "first", a.Nodes["n1"].Comment)
+	assert.Equal(t, "second", a.Nodes["n2"].Comment)
+	assert.Equal(t, "default profile", a.NodeProfiles["default"].Comment)
+
+	names, err := db.lookup("hwaddr", "08:00:27:39:46:70")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"n1"}, names)
+	n, err := a.FindByIpaddr("10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "n1", n.Id.Get())
+
+	db, err = OpenBackend(BackendSqlite, dbFile)
+	assert.NoError(t, err)
+	b, err := NewFromBackend(db)
+	assert.NoError(t, err)
+
+	// changes of different nodes are both kept
+	a.Nodes["n1"].Comment = "changed by a"
+	assert.NoError(t, a.Persist())
+	b.Nodes["n2"].Comment = "changed by b"
+	assert.NoError(t, b.Persist())
+
+	// n1 was changed by a since b read it
+	assert.NoError(t, b.DelNode("n1"))
+	err = b.Persist()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "node n1")
+
+	db, err = OpenBackend(BackendSqlite, dbFile)
+	assert.NoError(t, err)
+	c, err := NewFromBackend(db)
+	assert.NoError(t, err)
+	assert.Equal(t, "changed by a", c.Nodes["n1"].Comment)
+	assert.Equal(t, "changed by b", c.Nodes["n2"].Comment)
+
+	changes, err := ReadAuditLog()
+	assert.NoError(t, err)
+	if assert.Len(t, changes, 3) {
+		assert.True(t, changes[2].Touches("node", "n2"))
+		assert.Equal(t, []FieldChange{{Field: "comment", Old: "second", New: "changed by b"}},
+			changes[2].Objects[0].Fields)
+	}
+}
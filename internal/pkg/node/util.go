@@ -11,19 +11,14 @@ func (config *nodeYaml) FindByHwaddr(hwa string) (NodeInfo, error) {
 		return NodeInfo{}, errors.New("invalid hardware address: " + hwa)
 	}
 
-	var ret NodeInfo
-
-	n, _ := config.FindAllNodes()
-
-	for _, node := range n {
-		for _, dev := range node.NetDevs {
-			if strings.EqualFold(dev.Hwaddr.Get(), hwa) {
-				return node, nil
-			}
-		}
+	n, ok := config.findIndexed("hwaddr", hwa, func(dev *NetDevEntry) bool {
+		return strings.EqualFold(dev.Hwaddr.Get(), hwa)
+	})
+	if ok {
+		return n, nil
 	}
 
-	return ret, errors.New("No nodes found with HW Addr: " + hwa)
+	return n, errors.New("No nodes found with HW Addr: " + hwa)
 }
 
 func (config *nodeYaml) FindByIpaddr(ipaddr string) (NodeInfo, error) {
@@ -31,17 +26,73 @@ func (config *nodeYaml) FindByIpaddr(ipaddr string) (NodeInfo, error) {
 		return NodeInfo{}, errors.New("invalid IP:" + ipaddr)
 	}
 
-	var ret NodeInfo
+	n, ok := config.findIndexed("ipaddr", ipaddr, func(dev *NetDevEntry) bool {
+		return dev.Ipaddr.Get() == ipaddr
+	})
+	if ok {
+		return n, nil
+	}
 
-	n, _ := config.FindAllNodes()
+	return n, errors.New("No nodes found with IP Addr: " + ipaddr)
+}
 
-	for _, node := range n {
-		for _, dev := range node.NetDevs {
-			if dev.Ipaddr.Get() == ipaddr {
-				return node, nil
+/*
+Returns the first node with a network device which matches. The nodes which
+the index of the backend returns are checked first. If none of them matches,
+all nodes are checked, as the value could come from a profile or could have
+been changed since the index was built.
+*/
+func (config *nodeYaml) findIndexed(field string, value string, match func(*NetDevEntry) bool) (NodeInfo, bool) {
+	find := func(nodes []NodeInfo) (NodeInfo, bool) {
+		for _, node := range nodes {
+			for _, dev := range node.NetDevs {
+				if match(dev) {
+					return node, true
+				}
 			}
 		}
+		return NodeInfo{}, false
 	}
 
-	return ret, errors.New("No nodes found with IP Addr: " + ipaddr)
+	if config.db != nil {
+		names, err := config.db.lookup(field, value)
+		if err == nil && len(names) > 0 {
+			candidates, err := config.findNamed(names)
+			if err == nil {
+				if n, ok := find(candidates); ok {
+					return n, true
+				}
+			}
+		}
+	}
+	nodes, err := config.FindAllNodes()
+	if err != nil {
+		return NodeInfo{}, false
+	}
+	return find(nodes)
+}
+
+/*
+Returns the nodes with the given names, from the resolved nodes if they are
+there
+*/
+func (config *nodeYaml) findNamed(names []string) ([]NodeInfo, error) {
+	if config.resolved != nil {
+		var ret []NodeInfo
+		for _, name := range names {
+			for i := range config.resolved {
+				if config.resolved[i].Id.Get() == name {
+					ret = append(ret, config.resolved[i].copy())
+				}
+			}
+		}
+		return ret, nil
+	}
+	nodes := make(map[string]*NodeConf)
+	for _, name := range names {
+		if n, ok := config.Nodes[name]; ok {
+			nodes[name] = n
+		}
+	}
+	return config.findNodes(nodes)
 }
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+/*
+Returns the UUID in its canonical lower case form. The nil UUID and the one
+with all bits set are rejected, firmware reports them if the UUID was never
+set.
+*/
+func NormalizeUUID(value string) (string, error) {
+	id, err := uuid.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("invalid uuid: %s", value)
+	}
+	if id == uuid.Nil || id.String() == "ffffffff-ffff-ffff-ffff-ffffffffffff" {
+		return "", fmt.Errorf("uuid is not set by the firmware: %s", value)
+	}
+	return id.String(), nil
+}
+
+/*
+Returns the node with the given UUID
+*/
+func (config *nodeYaml) FindByUUID(value string) (NodeInfo, error) {
+	var ret NodeInfo
+	id, err := NormalizeUUID(value)
+	if err != nil {
+		return ret, err
+	}
+	for name, n := range config.Nodes {
+		if n.UUID == "" || !strings.EqualFold(n.UUID, id) {
+			continue
+		}
+		nodes, err := config.findNodes(map[string]*NodeConf{name: n})
+		if err != nil {
+			return ret, err
+		}
+		return nodes[0], nil
+	}
+	return ret, fmt.Errorf("no node found with uuid: %s", id)
+}
+
+/*
+Binds the UUID to a node, an empty UUID removes the binding. A UUID can only
+be bound to one node.
+*/
+func (config *nodeYaml) SetUUID(nodeID string, value string) error {
+	n, ok := config.Nodes[nodeID]
+	if !ok {
+		return fmt.Errorf("node does not exist: %s", nodeID)
+	}
+	config.modified()
+	if value == "" {
+		n.UUID = ""
+		return nil
+	}
+	id, err := NormalizeUUID(value)
+	if err != nil {
+		return err
+	}
+	for name, other := range config.Nodes {
+		if name != nodeID && strings.EqualFold(other.UUID, id) {
+			return fmt.Errorf("uuid %s is already bound to node %s", id, name)
+		}
+	}
+	n.UUID = id
+	return nil
+}
+
+/*
+Checks the UUID of a request against the one bound to the node. Nodes
+without a UUID accept any request.
+*/
+func (n *NodeInfo) CheckUUID(value string) bool {
+	if !n.UUID.Defined() {
+		return true
+	}
+	id, err := NormalizeUUID(value)
+	return err == nil && strings.EqualFold(n.UUID.Get(), id)
+}
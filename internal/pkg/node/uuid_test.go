@@ -0,0 +1,81 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const uuidConfig = `WW_INTERNAL: 43
+nodeprofiles:
+  default: {}
+nodes:
+  n0001:
+    profiles:
+    - default
+    uuid: 4c4c4544-0035-5910-8044-b4c04f4a4e32
+  n0002:
+    profiles:
+    - default
+`
+
+func Test_NormalizeUUID(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		err      bool
+	}{
+		{value: "4C4C4544-0035-5910-8044-B4C04F4A4E32", expected: "4c4c4544-0035-5910-8044-b4c04f4a4e32"},
+		{value: " 4c4c4544-0035-5910-8044-b4c04f4a4e32\n", expected: "4c4c4544-0035-5910-8044-b4c04f4a4e32"},
+		{value: "", err: true},
+		{value: "4c4c4544", err: true},
+		{value: "00000000-0000-0000-0000-000000000000", err: true},
+		{value: "ffffffff-ffff-ffff-ffff-ffffffffffff", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			id, err := NormalizeUUID(tt.value)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, id)
+		})
+	}
+}
+
+func Test_SetUUID(t *testing.T) {
+	var config nodeYaml
+	assert.NoError(t, yaml.Unmarshal([]byte(uuidConfig), &config))
+
+	n, err := config.FindByUUID("4C4C4544-0035-5910-8044-B4C04F4A4E32")
+	assert.NoError(t, err)
+	assert.Equal(t, "n0001", n.Id.Get())
+	assert.True(t, n.CheckUUID("4c4c4544-0035-5910-8044-b4c04f4a4e32"))
+	assert.False(t, n.CheckUUID("4c4c4544-0035-5910-8044-b4c04f4a4e33"))
+	assert.False(t, n.CheckUUID(""))
+
+	assert.Error(t, config.SetUUID("n0002", "4c4c4544-0035-5910-8044-b4c04f4a4e32"))
+	assert.Error(t, config.SetUUID("n0002", "invalid"))
+	assert.Error(t, config.SetUUID("n0003", "4c4c4544-0035-5910-8044-b4c04f4a4e33"))
+
+	assert.NoError(t, config.SetUUID("n0001", ""))
+	_, err = config.FindByUUID("4c4c4544-0035-5910-8044-b4c04f4a4e32")
+	assert.Error(t, err)
+
+	assert.NoError(t, config.SetUUID("n0002", "4C4C4544-0035-5910-8044-B4C04F4A4E32"))
+	n, err = config.FindByUUID("4c4c4544-0035-5910-8044-b4c04f4a4e32")
+	assert.NoError(t, err)
+	assert.Equal(t, "n0002", n.Id.Get())
+	assert.Equal(t, "4c4c4544-0035-5910-8044-b4c04f4a4e32", n.UUID.Get())
+
+	nodes, err := config.FindAllNodes()
+	assert.NoError(t, err)
+	for _, n := range nodes {
+		if n.Id.Get() == "n0001" {
+			assert.True(t, n.CheckUUID(""), "node without uuid accepts any request")
+		}
+	}
+}
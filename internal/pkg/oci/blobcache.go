@@ -0,0 +1,486 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	imgSpecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+
+	"github.com/hpcng/warewulf/internal/pkg/util"
+)
+
+const (
+	blobCacheIndex    = "index.json"
+	blobCacheMetadata = "warewulf-cache.json"
+	refNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+/*
+BlobCache manages the oci layout in which the puller caches the blobs of all
+pulled images. Every image is referenced by the id returned by GenerateID.
+*/
+type BlobCache struct {
+	path string
+}
+
+/*
+Information about an image in the cache
+*/
+type CacheEntry struct {
+	Id       string
+	Digest   string
+	Uri      string
+	Size     int64
+	LastUsed time.Time
+}
+
+/*
+Metadata which isn't part of the oci layout and is kept in a separate file
+*/
+type cacheMetadata struct {
+	Images map[string]*cacheImageMeta `json:"images"`
+}
+
+type cacheImageMeta struct {
+	Uri      string    `json:"uri"`
+	Platform string    `json:"platform,omitempty"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+func NewBlobCache(path string) *BlobCache {
+	return &BlobCache{path: path}
+}
+
+func (c *BlobCache) blobPath(digest string) (string, error) {
+	s := strings.SplitN(digest, ":", 2)
+	if len(s) != 2 || s[0] == "" || s[1] == "" || strings.ContainsAny(s[1], "/.") {
+		return "", fmt.Errorf("invalid digest: %q", digest)
+	}
+	return filepath.Join(c.path, "blobs", s[0], s[1]), nil
+}
+
+func (c *BlobCache) readIndex() (imgSpecs.Index, error) {
+	var index imgSpecs.Index
+	buffer, err := ioutil.ReadFile(filepath.Join(c.path, blobCacheIndex))
+	if os.IsNotExist(err) {
+		return index, nil
+	} else if err != nil {
+		return index, err
+	}
+	err = json.Unmarshal(buffer, &index)
+	return index, err
+}
+
+func (c *BlobCache) writeIndex(index imgSpecs.Index) error {
+	buffer, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(filepath.Join(c.path, blobCacheIndex), buffer, 0644)
+}
+
+func (c *BlobCache) readMetadata() (cacheMetadata, error) {
+	meta := cacheMetadata{Images: make(map[string]*cacheImageMeta)}
+	buffer, err := ioutil.ReadFile(filepath.Join(c.path, blobCacheMetadata))
+	if os.IsNotExist(err) {
+		return meta, nil
+	} else if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(buffer, &meta); err != nil {
+		return meta, err
+	}
+	if meta.Images == nil {
+		meta.Images = make(map[string]*cacheImageMeta)
+	}
+	return meta, nil
+}
+
+func (c *BlobCache) writeMetadata(meta cacheMetadata) error {
+	buffer, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(filepath.Join(c.path, blobCacheMetadata), buffer, 0644)
+}
+
+/*
+Records the usage of the image id which was pulled from uri
+*/
+func (c *BlobCache) Touch(id string, uri string, platform string) error {
+	meta, err := c.readMetadata()
+	if err != nil {
+		return err
+	}
+	meta.Images[id] = &cacheImageMeta{
+		Uri:      uri,
+		Platform: platform,
+		LastUsed: time.Now(),
+	}
+	return c.writeMetadata(meta)
+}
+
+/*
+Returns the id of the most recently used image which was pulled from uri for
+the given platform and is still in the cache.
+*/
+func (c *BlobCache) Lookup(uri string, platform string) (string, error) {
+	meta, err := c.readMetadata()
+	if err != nil {
+		return "", err
+	}
+	index, err := c.readIndex()
+	if err != nil {
+		return "", err
+	}
+	var ret string
+	var lastUsed time.Time
+	for _, m := range index.Manifests {
+		id := m.Annotations[refNameAnnotation]
+		im, ok := meta.Images[id]
+		if !ok || im.Uri != uri || im.Platform != platform {
+			continue
+		}
+		if ret == "" || im.LastUsed.After(lastUsed) {
+			ret = id
+			lastUsed = im.LastUsed
+		}
+	}
+	if ret == "" {
+		return "", fmt.Errorf("%s is not in the cache", uri)
+	}
+	return ret, nil
+}
+
+/*
+Returns the digests of all blobs which are referenced by the descriptor,
+including the descriptor itself
+*/
+func (c *BlobCache) references(desc imgSpecs.Descriptor) ([]string, error) {
+	ret := []string{desc.Digest.String()}
+	blob, err := c.blobPath(desc.Digest.String())
+	if err != nil {
+		return ret, err
+	}
+	buffer, err := ioutil.ReadFile(blob)
+	if err != nil {
+		return ret, err
+	}
+	switch desc.MediaType {
+	case imgSpecs.MediaTypeImageIndex:
+		var index imgSpecs.Index
+		if err := json.Unmarshal(buffer, &index); err != nil {
+			return ret, err
+		}
+		for _, m := range index.Manifests {
+			refs, err := c.references(m)
+			ret = append(ret, refs...)
+			if err != nil {
+				return ret, err
+			}
+		}
+	default:
+		var manifest imgSpecs.Manifest
+		if err := json.Unmarshal(buffer, &manifest); err != nil {
+			return ret, err
+		}
+		ret = append(ret, manifest.Config.Digest.String())
+		for _, l := range manifest.Layers {
+			ret = append(ret, l.Digest.String())
+		}
+	}
+	return ret, nil
+}
+
+func (c *BlobCache) blobSize(digest string) int64 {
+	blob, err := c.blobPath(digest)
+	if err != nil {
+		return 0
+	}
+	fi, err := os.Stat(blob)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+/*
+Lists all images in the cache, the least recently used first. The size is
+the size of all blobs of the image, blobs may be shared between images.
+*/
+func (c *BlobCache) List() ([]CacheEntry, error) {
+	var ret []CacheEntry
+	index, err := c.readIndex()
+	if err != nil {
+		return ret, err
+	}
+	meta, err := c.readMetadata()
+	if err != nil {
+		return ret, err
+	}
+	for _, m := range index.Manifests {
+		entry := CacheEntry{
+			Id:     m.Annotations[refNameAnnotation],
+			Digest: m.Digest.String(),
+		}
+		if im, ok := meta.Images[entry.Id]; ok {
+			entry.Uri = im.Uri
+			entry.LastUsed = im.LastUsed
+		}
+		refs, _ := c.references(m)
+		for _, r := range uniq(refs) {
+			entry.Size += c.blobSize(r)
+		}
+		ret = append(ret, entry)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].LastUsed.Before(ret[j].LastUsed)
+	})
+	return ret, nil
+}
+
+/*
+Returns the size of all blobs in the cache
+*/
+func (c *BlobCache) Size() (int64, error) {
+	var size int64
+	err := filepath.Walk(filepath.Join(c.path, "blobs"), func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+/*
+Removes all images which weren't used within maxAge and afterwards the least
+recently used images until the cache is smaller than maxSize. A value of zero
+disables the respective limit. Blobs which aren't referenced anymore are
+deleted. Returns the removed images and the number of freed bytes.
+*/
+func (c *BlobCache) Prune(maxAge time.Duration, maxSize int64, dryRun bool) ([]CacheEntry, int64, error) {
+	var removed []CacheEntry
+	entries, err := c.List()
+	if err != nil {
+		return removed, 0, err
+	}
+	size, err := c.Size()
+	if err != nil {
+		return removed, 0, err
+	}
+
+	remove := make(map[string]bool)
+	for _, e := range entries {
+		if maxAge > 0 && time.Since(e.LastUsed) > maxAge {
+			remove[e.Id] = true
+		}
+	}
+	if maxSize > 0 {
+		// blobs might be shared, so the real size must be calculated
+		for _, e := range entries {
+			if c.keptSize(entries, remove) <= maxSize {
+				break
+			}
+			remove[e.Id] = true
+		}
+	}
+	for _, e := range entries {
+		if remove[e.Id] {
+			removed = append(removed, e)
+		}
+	}
+	if dryRun {
+		return removed, size - c.keptSize(entries, remove), nil
+	}
+	if len(removed) > 0 {
+		if err := c.removeRefs(remove); err != nil {
+			return removed, 0, err
+		}
+	}
+	freed, err := c.GC()
+	return removed, freed, err
+}
+
+func (c *BlobCache) keptSize(entries []CacheEntry, remove map[string]bool) int64 {
+	index, err := c.readIndex()
+	if err != nil {
+		return 0
+	}
+	var size int64
+	seen := make(map[string]bool)
+	for _, m := range index.Manifests {
+		if remove[m.Annotations[refNameAnnotation]] {
+			continue
+		}
+		refs, _ := c.references(m)
+		for _, r := range refs {
+			if !seen[r] {
+				seen[r] = true
+				size += c.blobSize(r)
+			}
+		}
+	}
+	return size
+}
+
+func (c *BlobCache) removeRefs(remove map[string]bool) error {
+	index, err := c.readIndex()
+	if err != nil {
+		return err
+	}
+	var manifests []imgSpecs.Descriptor
+	for _, m := range index.Manifests {
+		if !remove[m.Annotations[refNameAnnotation]] {
+			manifests = append(manifests, m)
+		}
+	}
+	index.Manifests = manifests
+	if err := c.writeIndex(index); err != nil {
+		return err
+	}
+	meta, err := c.readMetadata()
+	if err != nil {
+		return err
+	}
+	for id := range remove {
+		delete(meta.Images, id)
+	}
+	return c.writeMetadata(meta)
+}
+
+/*
+Deletes all blobs which aren't referenced by an image in the cache, returns the
+number of freed bytes
+*/
+func (c *BlobCache) GC() (int64, error) {
+	index, err := c.readIndex()
+	if err != nil {
+		return 0, err
+	}
+	keep := make(map[string]bool)
+	for _, m := range index.Manifests {
+		refs, err := c.references(m)
+		for _, r := range refs {
+			keep[r] = true
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "could not read image %s, not collecting garbage", m.Annotations[refNameAnnotation])
+		}
+	}
+	var freed int64
+	blobs, _ := filepath.Glob(filepath.Join(c.path, "blobs", "*", "*"))
+	for _, blob := range blobs {
+		digest := filepath.Base(filepath.Dir(blob)) + ":" + filepath.Base(blob)
+		if keep[digest] {
+			continue
+		}
+		fi, err := os.Stat(blob)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(blob); err != nil {
+			return freed, err
+		}
+		freed += fi.Size()
+	}
+	return freed, nil
+}
+
+/*
+Checks the digests of all blobs in the cache and returns the digests of the
+corrupted or missing ones. Images which reference a corrupted blob are removed
+from the cache if remove is set.
+*/
+func (c *BlobCache) Verify(remove bool) ([]string, error) {
+	var bad []string
+	index, err := c.readIndex()
+	if err != nil {
+		return bad, err
+	}
+	checked := make(map[string]bool)
+	broken := make(map[string]bool)
+	for _, m := range index.Manifests {
+		id := m.Annotations[refNameAnnotation]
+		refs, err := c.references(m)
+		if err != nil {
+			broken[id] = true
+		}
+		for _, r := range uniq(refs) {
+			ok, done := checked[r]
+			if !done {
+				ok = c.verifyBlob(r) == nil
+				checked[r] = ok
+				if !ok {
+					bad = append(bad, r)
+				}
+			}
+			if !ok {
+				broken[id] = true
+			}
+		}
+	}
+	if remove && len(broken) > 0 {
+		if err := c.removeRefs(broken); err != nil {
+			return bad, err
+		}
+		for _, b := range bad {
+			if blob, err := c.blobPath(b); err == nil {
+				_ = os.Remove(blob)
+			}
+		}
+		if _, err := c.GC(); err != nil {
+			return bad, err
+		}
+	}
+	return bad, nil
+}
+
+func (c *BlobCache) verifyBlob(digest string) error {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return fmt.Errorf("unsupported digest: %s", digest)
+	}
+	blob, err := c.blobPath(digest)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if fmt.Sprintf("sha256:%x", h.Sum(nil)) != digest {
+		return fmt.Errorf("digest mismatch: %s", digest)
+	}
+	return nil
+}
+
+func uniq(list []string) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
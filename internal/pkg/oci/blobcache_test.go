@@ -0,0 +1,112 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	imgSpecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeBlob(t *testing.T, dir string, content []byte) imgSpecs.Descriptor {
+	d := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", d[7:]), content, 0644))
+	return imgSpecs.Descriptor{Digest: digest.Digest(d), Size: int64(len(content))}
+}
+
+/*
+Creates a cache with two images, which share the first layer
+*/
+func newTestCache(t *testing.T) (*BlobCache, string) {
+	dir := t.TempDir()
+	shared := writeBlob(t, dir, make([]byte, 1000))
+	var index imgSpecs.Index
+	for i, id := range []string{"sha256:old", "sha256:new"} {
+		config := writeBlob(t, dir, []byte(fmt.Sprintf("config %d", i)))
+		layer := writeBlob(t, dir, make([]byte, 100*(i+1)))
+		manifest, _ := json.Marshal(imgSpecs.Manifest{Config: config, Layers: []imgSpecs.Descriptor{shared, layer}})
+		desc := writeBlob(t, dir, manifest)
+		desc.MediaType = imgSpecs.MediaTypeImageManifest
+		desc.Annotations = map[string]string{refNameAnnotation: id}
+		index.Manifests = append(index.Manifests, desc)
+	}
+	c := NewBlobCache(dir)
+	assert.NoError(t, c.writeIndex(index))
+	assert.NoError(t, c.Touch("sha256:old", "docker://old", ""))
+	meta, _ := c.readMetadata()
+	meta.Images["sha256:old"].LastUsed = time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, c.writeMetadata(meta))
+	assert.NoError(t, c.Touch("sha256:new", "docker://new", "linux/arm64"))
+	return c, dir
+}
+
+func Test_BlobCache_List(t *testing.T) {
+	c, _ := newTestCache(t)
+	entries, err := c.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "sha256:old", entries[0].Id)
+	assert.Equal(t, "docker://new", entries[1].Uri)
+	assert.Greater(t, entries[1].Size, entries[0].Size)
+
+	id, err := c.Lookup("docker://new", "linux/arm64")
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:new", id)
+	_, err = c.Lookup("docker://new", "")
+	assert.Error(t, err)
+}
+
+func Test_BlobCache_Prune(t *testing.T) {
+	c, _ := newTestCache(t)
+	before, _ := c.Size()
+
+	removed, freed, err := c.Prune(24*time.Hour, 0, true)
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	after, _ := c.Size()
+	assert.Equal(t, before, after, "dry run must not remove anything")
+
+	removed, freed, err = c.Prune(24*time.Hour, 0, false)
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	assert.Equal(t, "sha256:old", removed[0].Id)
+	after, _ = c.Size()
+	assert.Equal(t, before-after, freed)
+	// the shared layer must still be there
+	bad, err := c.Verify(false)
+	assert.NoError(t, err)
+	assert.Empty(t, bad)
+
+	removed, _, err = c.Prune(0, 1, false)
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	after, _ = c.Size()
+	assert.Equal(t, int64(0), after)
+}
+
+func Test_BlobCache_Verify(t *testing.T) {
+	c, dir := newTestCache(t)
+	layer := filepath.Join(dir, "blobs", "sha256", fmt.Sprintf("%x", sha256.Sum256(make([]byte, 200))))
+	assert.NoError(t, ioutil.WriteFile(layer, []byte("corrupted"), 0644))
+
+	bad, err := c.Verify(false)
+	assert.NoError(t, err)
+	assert.Len(t, bad, 1)
+
+	bad, err = c.Verify(true)
+	assert.NoError(t, err)
+	assert.Len(t, bad, 1)
+	entries, _ := c.List()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "sha256:old", entries[0].Id)
+	bad, _ = c.Verify(false)
+	assert.Empty(t, bad)
+}
@@ -0,0 +1,213 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	imgSpecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const whiteoutPrefix = ".wh."
+const whiteoutOpaque = ".wh..wh..opq"
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Extract unpacks only the paths below prefixes (relative to / of the image)
+// into dst, the remaining content of the layers is skipped
+func (p *puller) Extract(ctx context.Context, uri, dst string, prefixes []string) error {
+	_, cacheRef, err := p.cacheImage(ctx, uri)
+	if err != nil {
+		return err
+	}
+
+	src, err := cacheRef.NewImageSource(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	manifestBytes, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var manifest imgSpecs.Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return fmt.Errorf("unable to unmarshall manifest json: %v", err)
+	}
+
+	cache := NewBlobCache(p.blobCachePath)
+	for _, l := range manifest.Layers {
+		blob, err := cache.blobPath(l.Digest.String())
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(blob)
+		if err != nil {
+			return err
+		}
+		err = extractLayer(f, dst, prefixes)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("unable to extract layer %s: %v", l.Digest, err)
+		}
+	}
+
+	return nil
+}
+
+func matchPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.Trim(prefix, "/")
+		if name == prefix || strings.HasPrefix(name, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// securePath returns the path of name within dst, it fails if a parent
+// directory is a symlink, as the entry could be written outside of dst
+func securePath(dst, name string) (string, error) {
+	parts := strings.Split(name, "/")
+	cur := dst
+	for _, part := range parts[:len(parts)-1] {
+		cur = filepath.Join(cur, part)
+		fi, err := os.Lstat(cur)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("parent of %s is a symlink", name)
+		}
+	}
+	return filepath.Join(dst, name), nil
+}
+
+// extractLayer unpacks the entries of the (possibly gzip compressed) layer
+// tar which are below prefixes into dst and applies the whiteouts
+func extractLayer(r io.Reader, dst string, prefixes []string) error {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	var reader io.Reader = br
+	if len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	} else if bytes.Equal(magic, zstdMagic) {
+		return fmt.Errorf("zstd compressed layers are not supported")
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if name == "" {
+			continue
+		}
+		dir, base := path.Split(name)
+
+		if base == whiteoutOpaque {
+			if !matchPrefix(strings.TrimSuffix(dir, "/"), prefixes) {
+				continue
+			}
+			target, err := securePath(dst, strings.TrimSuffix(dir, "/"))
+			if err != nil {
+				return err
+			}
+			entries, _ := os.ReadDir(target)
+			for _, e := range entries {
+				if err := os.RemoveAll(filepath.Join(target, e.Name())); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			name = path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+			if !matchPrefix(name, prefixes) {
+				continue
+			}
+			target, err := securePath(dst, name)
+			if err != nil {
+				return err
+			}
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !matchPrefix(name, prefixes) {
+			continue
+		}
+		target, err := securePath(dst, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		// replace whatever was there in a lower layer, except directories
+		if fi, err := os.Lstat(target); err == nil && !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			linkName := strings.TrimPrefix(path.Clean("/"+hdr.Linkname), "/")
+			if !matchPrefix(linkName, prefixes) {
+				continue
+			}
+			source, err := securePath(dst, linkName)
+			if err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	link     string
+}
+
+func makeLayer(t *testing.T, entries []tarEntry, compress bool) *bytes.Buffer {
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: 0644, Size: int64(len(e.content)), Linkname: e.link}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		assert.NoError(t, tw.WriteHeader(hdr))
+		if e.typeflag == tar.TypeReg {
+			_, err := tw.Write([]byte(e.content))
+			assert.NoError(t, err)
+		}
+	}
+	assert.NoError(t, tw.Close())
+	if gz != nil {
+		assert.NoError(t, gz.Close())
+	}
+	return &buf
+}
+
+func Test_extractLayer(t *testing.T) {
+	dst := t.TempDir()
+	prefixes := []string{"boot", "lib/modules"}
+
+	lower := makeLayer(t, []tarEntry{
+		{"bin/sh", tar.TypeReg, "shell", ""},
+		{"boot/", tar.TypeDir, "", ""},
+		{"boot/vmlinuz-5.15", tar.TypeReg, "kernel", ""},
+		{"boot/System.map-5.15", tar.TypeReg, "map", ""},
+		{"lib/modules/5.15/kernel/a.ko", tar.TypeReg, "a", ""},
+		{"lib/modules/5.15/kernel/b.ko", tar.TypeLink, "", "lib/modules/5.15/kernel/a.ko"},
+		{"../../etc/passwd", tar.TypeReg, "root", ""},
+	}, true)
+	assert.NoError(t, extractLayer(lower, dst, prefixes))
+
+	upper := makeLayer(t, []tarEntry{
+		{"boot/.wh.System.map-5.15", tar.TypeReg, "", ""},
+		{"boot/vmlinuz", tar.TypeSymlink, "", "vmlinuz-5.15"},
+		{"lib/modules/5.15/kernel/.wh..wh..opq", tar.TypeReg, "", ""},
+		{"lib/modules/5.15/kernel/c.ko", tar.TypeReg, "c", ""},
+	}, false)
+	assert.NoError(t, extractLayer(upper, dst, prefixes))
+
+	content, err := ioutil.ReadFile(filepath.Join(dst, "boot/vmlinuz"))
+	assert.NoError(t, err)
+	assert.Equal(t, "kernel", string(content))
+	assert.NoFileExists(t, filepath.Join(dst, "bin/sh"))
+	assert.NoFileExists(t, filepath.Join(dst, "boot/System.map-5.15"))
+	assert.NoFileExists(t, filepath.Join(dst, "lib/modules/5.15/kernel/a.ko"))
+	assert.NoFileExists(t, filepath.Join(dst, "lib/modules/5.15/kernel/b.ko"))
+	assert.FileExists(t, filepath.Join(dst, "lib/modules/5.15/kernel/c.ko"))
+	// the traversal is cleaned to /etc/passwd, which isn't in the prefixes
+	assert.NoFileExists(t, filepath.Join(dst, "etc/passwd"))
+}
+
+func Test_extractLayer_symlinkEscape(t *testing.T) {
+	dst := t.TempDir()
+	outside := t.TempDir()
+
+	layer := makeLayer(t, []tarEntry{
+		{"lib/modules/evil", tar.TypeSymlink, "", outside},
+		{"lib/modules/evil/file", tar.TypeReg, "pwned", ""},
+	}, true)
+	assert.Error(t, extractLayer(layer, dst, []string{"lib/modules"}))
+
+	entries, err := os.ReadDir(outside)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
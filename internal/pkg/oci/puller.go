@@ -14,6 +14,7 @@ import (
 	"github.com/containers/image/v5/docker"
 	dockerarchive "github.com/containers/image/v5/docker/archive"
 	"github.com/containers/image/v5/docker/daemon"
+	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/oci/layout"
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/types"
@@ -46,11 +47,80 @@ func OptSetSystemContext(s *types.SystemContext) pullerOpt {
 	}
 }
 
+// OptSetOffline makes the puller use only images which are already in the
+// blob cache, the source is never contacted
+func OptSetOffline(offline bool) pullerOpt {
+	return func(p *puller) error {
+		p.offline = offline
+		return nil
+	}
+}
+
+// OptSetPlatform selects the platform (os/arch[/variant]) which is pulled from
+// a multi-arch image index
+func OptSetPlatform(platform string) pullerOpt {
+	return func(p *puller) error {
+		if p.sysCtx == nil {
+			p.sysCtx = &types.SystemContext{}
+		}
+		return SetPlatform(p.sysCtx, platform)
+	}
+}
+
+// SetPlatform sets the os, architecture and variant choice of the system
+// context from a platform string like linux/arm64 or aarch64
+func SetPlatform(sCtx *types.SystemContext, platform string) error {
+	if platform == "" {
+		return nil
+	}
+	s := strings.Split(platform, "/")
+	switch len(s) {
+	case 1:
+		sCtx.ArchitectureChoice = util.OciArch(s[0])
+	case 2, 3:
+		sCtx.OSChoice = s[0]
+		sCtx.ArchitectureChoice = util.OciArch(s[1])
+		if len(s) == 3 {
+			sCtx.VariantChoice = s[2]
+		}
+	default:
+		return fmt.Errorf("invalid platform: %q", platform)
+	}
+	return nil
+}
+
+// PlatformArch returns the architecture of a platform string in the uname
+// notation used by warewulf
+func PlatformArch(platform string) string {
+	s := strings.Split(platform, "/")
+	if len(s) == 1 {
+		return util.NormalizeArch(s[0])
+	}
+	return util.NormalizeArch(s[1])
+}
+
+// platform returns the platform selected in the system context, empty for the
+// platform of this host
+func platform(sCtx *types.SystemContext) string {
+	if sCtx == nil || sCtx.ArchitectureChoice == "" {
+		return ""
+	}
+	ret := sCtx.ArchitectureChoice
+	if sCtx.OSChoice != "" {
+		ret = sCtx.OSChoice + "/" + ret
+	}
+	if sCtx.VariantChoice != "" {
+		ret += "/" + sCtx.VariantChoice
+	}
+	return ret
+}
+
 type puller struct {
 	id            string
 	blobCachePath string
 	tmpDirPath    string
 	sysCtx        *types.SystemContext
+	offline       bool
 }
 
 func NewPuller(opts ...pullerOpt) (*puller, error) {
@@ -92,6 +162,15 @@ func getReference(uri string) (types.ImageReference, error) {
 
 // GenerateID stores and returns a unique identifier derived from the sha256sum of the image manifest
 func (p *puller) GenerateID(ctx context.Context, uri string) (string, error) {
+	if p.offline {
+		id, err := NewBlobCache(p.blobCachePath).Lookup(uri, platform(p.sysCtx))
+		if err != nil {
+			return "", err
+		}
+		p.id = id
+		return p.id, nil
+	}
+
 	ref, err := getReference(uri)
 	if err != nil {
 		return "", fmt.Errorf("unable to parse uri: %v", err)
@@ -102,38 +181,71 @@ func (p *puller) GenerateID(ctx context.Context, uri string) (string, error) {
 		return "", err
 	}
 
-	manifestBytes, _, err := src.GetManifest(ctx, nil)
+	defer src.Close()
+
+	manifestBytes, mimeType, err := src.GetManifest(ctx, nil)
 	if err != nil {
 		return "", err
 	}
 
+	// the id must belong to the image of the selected platform, not to the index
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(manifestBytes, mimeType)
+		if err != nil {
+			return "", err
+		}
+		instance, err := list.ChooseInstance(p.sysCtx)
+		if err != nil {
+			return "", err
+		}
+		manifestBytes, _, err = src.GetManifest(ctx, &instance)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	p.id = fmt.Sprintf("sha256:%x", sha256.Sum256(manifestBytes))
 	return p.id, nil
 }
 
-func (p *puller) Pull(ctx context.Context, uri, dst string) (err error) {
+// cacheImage copies the image to the blob cache, unless the puller is offline
+func (p *puller) cacheImage(ctx context.Context, uri string) (*signature.PolicyContext, types.ImageReference, error) {
 	srcRef, err := getReference(uri)
 	if err != nil {
-		return fmt.Errorf("unable to parse uri: %v", err)
+		return nil, nil, fmt.Errorf("unable to parse uri: %v", err)
 	}
 
 	cacheRef, err := layout.ParseReference(p.blobCachePath + ":" + p.id)
 	if err != nil {
-		return fmt.Errorf("unable to generate local oci reference: %v", err)
+		return nil, nil, fmt.Errorf("unable to generate local oci reference: %v", err)
 	}
 
 	// Create a wide open oci image signature policy
 	policy := &signature.Policy{Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()}}
 	policyCtx, err := signature.NewPolicyContext(policy)
 	if err != nil {
-		return fmt.Errorf("unable to create policy context: %v", err)
+		return nil, nil, fmt.Errorf("unable to create policy context: %v", err)
 	}
 
 	// copy to cache location
-	_, err = copy.Image(ctx, policyCtx, cacheRef, srcRef, &copy.Options{
-		ReportWriter: os.Stdout,
-		SourceCtx:    p.sysCtx,
-	})
+	if !p.offline {
+		_, err = copy.Image(ctx, policyCtx, cacheRef, srcRef, &copy.Options{
+			ReportWriter: os.Stdout,
+			SourceCtx:    p.sysCtx,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := NewBlobCache(p.blobCachePath).Touch(p.id, uri, platform(p.sysCtx)); err != nil {
+		return nil, nil, fmt.Errorf("unable to update cache metadata: %v", err)
+	}
+
+	return policyCtx, cacheRef, nil
+}
+
+func (p *puller) Pull(ctx context.Context, uri, dst string) (err error) {
+	policyCtx, cacheRef, err := p.cacheImage(ctx, uri)
 	if err != nil {
 		return err
 	}
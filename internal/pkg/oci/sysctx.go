@@ -0,0 +1,65 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/containers/image/v5/types"
+)
+
+func setOCICredentials(sCtx *types.SystemContext) error {
+	username, userSet := os.LookupEnv("WAREWULF_OCI_USERNAME")
+	password, passSet := os.LookupEnv("WAREWULF_OCI_PASSWORD")
+	if userSet || passSet {
+		if userSet && passSet {
+			sCtx.DockerAuthConfig = &types.DockerAuthConfig{
+				Username: username,
+				Password: password,
+			}
+		} else {
+			return fmt.Errorf("oci username and password env vars must be specified together")
+		}
+	}
+	return nil
+}
+
+func setNoHTTPSOpts(sCtx *types.SystemContext) error {
+	val, ok := os.LookupEnv("WAREWULF_OCI_NOHTTPS")
+	if !ok {
+		return nil
+	}
+
+	noHTTPS, err := strconv.ParseBool(val)
+	if err != nil {
+		return fmt.Errorf("while parsing insecure http option: %v", err)
+	}
+
+	// only set this if we want to disable, otherwise leave as undefined
+	if noHTTPS {
+		sCtx.DockerInsecureSkipTLSVerify = types.NewOptionalBool(true)
+	}
+	sCtx.OCIInsecureSkipTLSVerify = noHTTPS
+
+	return nil
+}
+
+// NewSystemContext returns the system context for pulling images for the
+// given platform, credentials and TLS options are read from the environment
+func NewSystemContext(platform string) (sCtx *types.SystemContext, err error) {
+	sCtx = &types.SystemContext{}
+
+	if err := setOCICredentials(sCtx); err != nil {
+		return nil, err
+	}
+
+	if err := setNoHTTPSOpts(sCtx); err != nil {
+		return nil, err
+	}
+
+	if err := SetPlatform(sCtx, platform); err != nil {
+		return nil, err
+	}
+
+	return sCtx, nil
+}
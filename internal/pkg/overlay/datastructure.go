@@ -10,31 +10,37 @@ struct which contains the variables to which are available in
 the templates.
 */
 type TemplateStruct struct {
-	Id             string
-	Hostname       string
-	ClusterName    string
-	Container      string
-	Kernel         *node.KernelConf
-	Init           string
-	Root           string
-	Ipmi           *node.IpmiConf
-	RuntimeOverlay string
-	SystemOverlay  string
-	NetDevs        map[string]*node.NetDevs
-	Tags           map[string]string
-	Keys           map[string]string
-	AllNodes       []node.NodeInfo
-	BuildHost      string
-	BuildTime      string
-	BuildTimeUnix  string
-	BuildSource    string
-	Ipaddr         string
-	Ipaddr6        string
-	Netmask        string
-	Network        string
-	NetworkCIDR    string
-	Ipv6           bool
-	Dhcp           warewulfconf.DhcpConf
-	Nfs            warewulfconf.NfsConf
-	Warewulf       warewulfconf.WarewulfConf
+	Id              string
+	Hostname        string
+	ClusterName     string
+	Container       string
+	ContainerDigest string
+	Arch            string
+	Kernel          *node.KernelConf
+	Init            string
+	Root            string
+	Ipmi            *node.IpmiConf
+	RuntimeOverlay  string
+	SystemOverlay   string
+	NetDevs         map[string]*node.NetDevs
+	Tags            map[string]string
+	Disks           map[string]*node.Disk
+	Raids           map[string]*node.Raid
+	FileSystems     map[string]*node.FileSystem
+	RootDevice      string
+	Keys            map[string]string
+	AllNodes        []node.NodeInfo
+	BuildHost       string
+	BuildTime       string
+	BuildTimeUnix   string
+	BuildSource     string
+	Ipaddr          string
+	Ipaddr6         string
+	Netmask         string
+	Network         string
+	NetworkCIDR     string
+	Ipv6            bool
+	Dhcp            warewulfconf.DhcpConf
+	Nfs             warewulfconf.NfsConf
+	Warewulf        warewulfconf.WarewulfConf
 }
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 
 	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
@@ -100,3 +101,16 @@ func templateContainerFileInclude(containername string, filepath string) string
 	}
 	return strings.TrimSuffix(string(content), "\n")
 }
+
+var shellSafe = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+/*
+Quotes a string for the shell, strings without special characters are
+returned as they are.
+*/
+func templateShellQuote(str string) string {
+	if shellSafe.MatchString(str) {
+		return str
+	}
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
@@ -0,0 +1,15 @@
+package overlay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_templateShellQuote(t *testing.T) {
+	assert.Equal(t, "/dev/disk/by-partlabel/rootfs", templateShellQuote("/dev/disk/by-partlabel/rootfs"))
+	assert.Equal(t, "''", templateShellQuote(""))
+	assert.Equal(t, "'nvme-*'", templateShellQuote("nvme-*"))
+	assert.Equal(t, "'a b; $(reboot)'", templateShellQuote("a b; $(reboot)"))
+	assert.Equal(t, `'it'\''s'`, templateShellQuote("it's"))
+}
@@ -16,6 +16,7 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/hpcng/warewulf/internal/pkg/container"
 	"github.com/hpcng/warewulf/internal/pkg/node"
 	"github.com/hpcng/warewulf/internal/pkg/util"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
@@ -226,6 +227,8 @@ func BuildOverlayIndir(nodeInfo node.NodeInfo, overlayNames []string, outputDir
 	tstruct.Hostname = nodeInfo.Id.Get()
 	tstruct.ClusterName = nodeInfo.ClusterName.Get()
 	tstruct.Container = nodeInfo.ContainerName.Get()
+	tstruct.ContainerDigest = container.ImageDigest(nodeInfo.ContainerName.Get())
+	tstruct.Arch = nodeInfo.Arch.Get()
 	tstruct.Kernel.Version = nodeInfo.Kernel.Override.Get()
 	tstruct.Kernel.Override = nodeInfo.Kernel.Override.Get()
 	tstruct.Kernel.Args = nodeInfo.Kernel.Args.Get()
@@ -274,6 +277,8 @@ func BuildOverlayIndir(nodeInfo node.NodeInfo, overlayNames []string, outputDir
 	for keyname, key := range nodeInfo.Tags {
 		tstruct.Tags[keyname] = key.Get()
 	}
+	tstruct.Disks, tstruct.Raids, tstruct.FileSystems = nodeInfo.DisksConf()
+	tstruct.RootDevice = nodeInfo.RootDevice()
 	tstruct.AllNodes = allNodes
 	tstruct.Nfs = *controller.Nfs
 	tstruct.Dhcp = *controller.Dhcp
@@ -356,6 +361,7 @@ func BuildOverlayIndir(nodeInfo node.NodeInfo, overlayNames []string, outputDir
 					"split": func(s string, d string) []string {
 						return strings.Split(s, d)
 					},
+					"shellquote": templateShellQuote,
 					// }).ParseGlob(path.Join(OverlayDir, destFile+".ww*"))
 				}).ParseGlob(location)
 				if err != nil {
@@ -0,0 +1,242 @@
+package remoteexec
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+
+	// allowlist of the commands wwclient runs, relative to the root
+	AllowlistFile = "etc/warewulf/wwclient.exec"
+
+	// timeout of commands without one
+	DefaultTimeout = 60
+)
+
+/*
+A command which warewulfd queued for a node, the command is run without a
+shell
+*/
+type Job struct {
+	ID      string   `json:"id"`
+	Command []string `json:"command"`
+	Timeout int      `json:"timeout"`
+}
+
+/*
+A chunk of the output of a command
+*/
+type Output struct {
+	Stream string `json:"stream"`
+	Data   string `json:"data"`
+}
+
+/*
+Sent by wwclient while a command runs, the last report is done and has the
+exit code or the error which prevented the command from running
+*/
+type Report struct {
+	Output   []Output `json:"output,omitempty"`
+	Done     bool     `json:"done,omitempty"`
+	ExitCode int      `json:"exit code"`
+	Error    string   `json:"error,omitempty"`
+}
+
+/*
+A command which wwctl submits to warewulfd
+*/
+type Request struct {
+	Node    string   `json:"node"`
+	Command []string `json:"command"`
+	Timeout int      `json:"timeout"`
+}
+
+/*
+The state of a submitted command, Output starts at the requested offset and
+Next is the offset of the following output
+*/
+type Status struct {
+	ID       string   `json:"id"`
+	Node     string   `json:"node"`
+	Output   []Output `json:"output,omitempty"`
+	Next     int      `json:"next"`
+	Started  bool     `json:"started"`
+	Done     bool     `json:"done"`
+	ExitCode int      `json:"exit code"`
+	Error    string   `json:"error,omitempty"`
+}
+
+/*
+Patterns of the commands a node runs, a command is allowed if it has as many
+arguments as one of the patterns and every argument matches the pattern at
+its position
+*/
+type Allowlist [][]string
+
+/*
+Reads the allowlist, every line is a pattern of the command and its
+arguments separated by spaces. The patterns of the arguments are the ones of
+path.Match, * matches any characters but / and ? a single one. Nothing is
+allowed if the file doesn't exist.
+*/
+func LoadAllowlist(fileName string) (Allowlist, error) {
+	var ret Allowlist
+	file, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return ret, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern := compilePattern(line)
+		for _, arg := range pattern {
+			if _, err := path.Match(arg, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern %q: %s", fileName, line, err)
+			}
+		}
+		ret = append(ret, pattern)
+	}
+	return ret, scanner.Err()
+}
+
+func compilePattern(pattern string) []string {
+	return strings.Fields(pattern)
+}
+
+func (a Allowlist) Allowed(command []string) bool {
+	if len(command) == 0 {
+		return false
+	}
+	for _, pattern := range a {
+		if matchCommand(pattern, command) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchCommand(pattern []string, command []string) bool {
+	if len(pattern) != len(command) {
+		return false
+	}
+	for i, arg := range command {
+		if ok, err := path.Match(pattern[i], arg); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Collects the output of a command, it is taken by the reports
+*/
+type outputBuffer struct {
+	sync.Mutex
+	output []Output
+}
+
+type streamWriter struct {
+	buf    *outputBuffer
+	stream string
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w.buf.Lock()
+	defer w.buf.Unlock()
+	n := len(w.buf.output)
+	if n > 0 && w.buf.output[n-1].Stream == w.stream {
+		w.buf.output[n-1].Data += string(p)
+	} else {
+		w.buf.output = append(w.buf.output, Output{Stream: w.stream, Data: string(p)})
+	}
+	return len(p), nil
+}
+
+func (b *outputBuffer) take() []Output {
+	b.Lock()
+	defer b.Unlock()
+	ret := b.output
+	b.output = nil
+	return ret
+}
+
+/*
+Runs the command of a job if the allowlist allows it. The output is sent
+every interval while the command runs, the last report is done. Commands
+are killed when they exceed their timeout.
+*/
+func Run(job Job, allow Allowlist, interval time.Duration, send func(Report)) {
+	if !allow.Allowed(job.Command) {
+		send(Report{Done: true, ExitCode: 126, Error: "command is not allowed on this node: " + strings.Join(job.Command, " ")})
+		return
+	}
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	var buf outputBuffer
+	cmd := exec.Command(job.Command[0], job.Command[1:]...)
+	cmd.Stdout = streamWriter{buf: &buf, stream: Stdout}
+	cmd.Stderr = streamWriter{buf: &buf, stream: Stderr}
+	cmd.Stdin = bytes.NewReader(nil)
+	// in an own process group, so that the children are killed as well
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err := cmd.Start()
+	if err != nil {
+		send(Report{Done: true, ExitCode: 127, Error: err.Error()})
+		return
+	}
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- cmd.Wait()
+	}()
+	deadline := time.NewTimer(time.Duration(timeout) * time.Second)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	timedOut := false
+	for {
+		select {
+		case <-ticker.C:
+			if output := buf.take(); len(output) > 0 {
+				send(Report{Output: output})
+			}
+		case <-deadline.C:
+			timedOut = true
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case err := <-finished:
+			report := Report{Output: buf.take(), Done: true}
+			var exitErr *exec.ExitError
+			if timedOut {
+				report.ExitCode = -1
+				report.Error = "command timed out"
+			} else if errors.As(err, &exitErr) {
+				report.ExitCode = exitErr.ExitCode()
+			} else if err != nil {
+				report.ExitCode = -1
+				report.Error = err.Error()
+			}
+			send(report)
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package remoteexec
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Allowlist(t *testing.T) {
+	fileName := path.Join(t.TempDir(), "wwclient.exec")
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte(`# diagnostics
+/usr/bin/uptime
+systemctl   status *
+journalctl -u ?sshd* -n [0-9]*
+`), 0644))
+	allow, err := LoadAllowlist(fileName)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		command []string
+		allowed bool
+	}{
+		{command: []string{"/usr/bin/uptime"}, allowed: true},
+		{command: []string{"/usr/bin/uptime", "-p"}},
+		{command: []string{"systemctl", "status", "sshd"}, allowed: true},
+		{command: []string{"systemctl", "status", "sshd", "--kill"}},
+		{command: []string{"systemctl", "status", "../sshd"}},
+		{command: []string{"systemctl status sshd"}},
+		{command: []string{"systemctl", "restart", "sshd"}},
+		{command: []string{"journalctl", "-u", "xsshd", "-n", "10"}, allowed: true},
+		{command: []string{"journalctl", "-u", "xsshd", "-n", "1", "--vacuum-size=1"}},
+		{command: []string{"journalctl", "-u", "xsshd", "-n", "--vacuum-size=1"}},
+		{command: []string{"journalctl", "-u", "sshd", "-n", "10"}},
+		{command: []string{"/usr/bin/uptime; reboot"}},
+		{},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, allow.Allowed(tt.command), tt.command)
+	}
+
+	allow, err = LoadAllowlist(path.Join(t.TempDir(), "missing"))
+	assert.NoError(t, err)
+	assert.False(t, allow.Allowed([]string{"/usr/bin/uptime"}))
+
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte("journalctl -u [sshd\n"), 0644))
+	_, err = LoadAllowlist(fileName)
+	assert.Error(t, err)
+}
+
+func runJob(job Job, allow Allowlist) []Report {
+	var reports []Report
+	Run(job, allow, 10*time.Millisecond, func(r Report) {
+		reports = append(reports, r)
+	})
+	return reports
+}
+
+func output(reports []Report) []Output {
+	var ret []Output
+	for _, r := range reports {
+		for _, o := range r.Output {
+			if n := len(ret); n > 0 && ret[n-1].Stream == o.Stream {
+				ret[n-1].Data += o.Data
+			} else {
+				ret = append(ret, o)
+			}
+		}
+	}
+	return ret
+}
+
+func Test_Run(t *testing.T) {
+	allow := Allowlist{compilePattern("/bin/sh -c *")}
+
+	reports := runJob(Job{Command: []string{"/bin/sh", "-c", "echo out; sleep 0.05; echo err >&2; exit 3"}}, allow)
+	last := reports[len(reports)-1]
+	assert.True(t, last.Done)
+	assert.Equal(t, 3, last.ExitCode)
+	assert.Empty(t, last.Error)
+	assert.Equal(t, []Output{{Stream: Stdout, Data: "out\n"}, {Stream: Stderr, Data: "err\n"}}, output(reports))
+
+	reports = runJob(Job{Command: []string{"/bin/sh", "-c", "sleep 5"}, Timeout: 1}, allow)
+	assert.Equal(t, "command timed out", reports[len(reports)-1].Error)
+
+	reports = runJob(Job{Command: []string{"/bin/true"}}, allow)
+	assert.Equal(t, []Report{{Done: true, ExitCode: 126, Error: "command is not allowed on this node: /bin/true"}}, reports)
+
+	reports = runJob(Job{Command: []string{"/missing/command"}}, Allowlist{compilePattern("/*/*")})
+	assert.Equal(t, 127, reports[0].ExitCode)
+	assert.True(t, reports[0].Done)
+}
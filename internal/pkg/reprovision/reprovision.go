@@ -0,0 +1,190 @@
+package reprovision
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+)
+
+/*
+File with the provisioning state of the nodes which are installed to disk
+*/
+var File string
+
+func init() {
+	if File == "" {
+		File = path.Join(buildconfig.LOCALSTATEDIR(), "warewulf/reprovision.json")
+	}
+}
+
+/*
+The provisioning state of a node with its root on disk. A reprovision is
+requested by wwctl, it is started when warewulfd sends the iPXE script which
+installs the node and finished when the wwinit stage 95-disk reports that it
+installed the node. Times are unix times, zero if not set.
+*/
+type State struct {
+	Requested   int64  `json:"requested,omitempty"`
+	Started     int64  `json:"started,omitempty"`
+	Provisioned int64  `json:"provisioned,omitempty"`
+	Digest      string `json:"digest,omitempty"`
+}
+
+/*
+true if the node has to be installed on its next boot. Nodes which were never
+provisioned boot from their disk as well, the iPXE script installs them if
+the disk doesn't boot.
+*/
+func (s State) Pending() bool {
+	return s.Requested != 0
+}
+
+func load() (map[string]State, error) {
+	ret := make(map[string]State)
+	data, err := ioutil.ReadFile(File)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return ret, err
+	}
+	err = json.Unmarshal(data, &ret)
+	return ret, err
+}
+
+func save(states map[string]State) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(File, data, 0644)
+}
+
+/*
+Runs fn on the states with the file locked, the states are saved if fn
+returns true. warewulfd and wwctl both modify the file.
+*/
+func modify(fn func(states map[string]State) bool) error {
+	err := os.MkdirAll(path.Dir(File), 0755)
+	if err != nil {
+		return err
+	}
+	lock, err := util.LockFile(File + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	states, err := load()
+	if err != nil {
+		return err
+	}
+	if !fn(states) {
+		return nil
+	}
+	return save(states)
+}
+
+/*
+Returns the states of all nodes by their id
+*/
+func List() (map[string]State, error) {
+	return load()
+}
+
+/*
+Returns the state of a node, the zero state if the node was never
+provisioned
+*/
+func Get(nodeID string) (State, error) {
+	states, err := load()
+	return states[nodeID], err
+}
+
+/*
+Requests that the nodes are installed again on their next boot
+*/
+func Request(now int64, nodeIDs ...string) error {
+	return modify(func(states map[string]State) bool {
+		for _, id := range nodeIDs {
+			s := states[id]
+			s.Requested = now
+			states[id] = s
+		}
+		return true
+	})
+}
+
+/*
+Withdraws the requests of the nodes
+*/
+func Cancel(nodeIDs ...string) error {
+	return modify(func(states map[string]State) bool {
+		changed := false
+		for _, id := range nodeIDs {
+			s, ok := states[id]
+			if !ok || (s.Requested == 0 && s.Started == 0) {
+				continue
+			}
+			s.Requested = 0
+			s.Started = 0
+			if s == (State{}) {
+				delete(states, id)
+			} else {
+				states[id] = s
+			}
+			changed = true
+		}
+		return changed
+	})
+}
+
+/*
+Records that the node boots to install itself
+*/
+func Start(nodeID string, now int64) error {
+	return modify(func(states map[string]State) bool {
+		s := states[nodeID]
+		s.Started = now
+		states[nodeID] = s
+		return true
+	})
+}
+
+/*
+Records that the node was installed with the container of the given digest.
+The node may also be installed without a started reprovision, if its disk
+didn't boot. A request made after the install started is kept.
+*/
+func Finish(nodeID string, digest string, now int64) error {
+	return modify(func(states map[string]State) bool {
+		s := states[nodeID]
+		if now < s.Started {
+			return false
+		}
+		finished := State{Provisioned: now, Digest: digest}
+		if s.Requested > s.Started {
+			finished.Requested = s.Requested
+		}
+		states[nodeID] = finished
+		return true
+	})
+}
+
+/*
+Removes the state of nodes, e.g. because they were deleted
+*/
+func Remove(nodeIDs ...string) error {
+	return modify(func(states map[string]State) bool {
+		changed := false
+		for _, id := range nodeIDs {
+			if _, ok := states[id]; ok {
+				delete(states, id)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
@@ -0,0 +1,53 @@
+package reprovision
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_State(t *testing.T) {
+	saved := File
+	File = path.Join(t.TempDir(), "reprovision.json")
+	defer func() { File = saved }()
+
+	// nodes which were never provisioned boot from their disk
+	s, err := Get("n1")
+	assert.NoError(t, err)
+	assert.False(t, s.Pending())
+
+	// an install after the disk didn't boot
+	assert.NoError(t, Finish("n1", "old", 50))
+	states, err := List()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]State{"n1": {Provisioned: 50, Digest: "old"}}, states)
+
+	assert.NoError(t, Request(60, "n1"))
+	assert.NoError(t, Start("n1", 100))
+	assert.NoError(t, Finish("n1", "abc", 99))
+	assert.NoError(t, Finish("n1", "abc", 160))
+	s, _ = Get("n1")
+	assert.Equal(t, State{Provisioned: 160, Digest: "abc"}, s)
+	assert.False(t, s.Pending())
+
+	assert.NoError(t, Request(200, "n1", "n2"))
+	s, _ = Get("n1")
+	assert.True(t, s.Pending())
+	assert.Equal(t, int64(200), s.Requested)
+
+	// a request during the install is kept
+	assert.NoError(t, Start("n1", 210))
+	assert.NoError(t, Request(220, "n1"))
+	assert.NoError(t, Finish("n1", "def", 230))
+	s, _ = Get("n1")
+	assert.Equal(t, State{Requested: 220, Provisioned: 230, Digest: "def"}, s)
+
+	assert.NoError(t, Cancel("n1", "n2", "n3"))
+	states, _ = List()
+	assert.Equal(t, map[string]State{"n1": {Provisioned: 230, Digest: "def"}}, states)
+
+	assert.NoError(t, Remove("n1"))
+	states, _ = List()
+	assert.Empty(t, states)
+}
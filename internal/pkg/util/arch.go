@@ -0,0 +1,117 @@
+package util
+
+import (
+	"debug/elf"
+	"os"
+	"path"
+	"strings"
+)
+
+/*
+Binaries which are checked to find out the architecture of a root file system
+*/
+var archProbeFiles = []string{
+	"/bin/sh",
+	"/usr/bin/sh",
+	"/bin/bash",
+	"/usr/bin/bash",
+	"/sbin/init",
+	"/usr/lib/systemd/systemd",
+}
+
+/*
+Returns the architecture in the uname notation (x86_64, aarch64, ...) which is
+used within warewulf. Go and OCI notations like amd64 or arm64 are translated.
+*/
+func NormalizeArch(arch string) string {
+	arch = strings.TrimSpace(arch)
+	switch strings.ToLower(arch) {
+	case "amd64", "x86-64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386", "i686", "i586":
+		return "i386"
+	case "ppc64el":
+		return "ppc64le"
+	}
+	return arch
+}
+
+/*
+Returns the architecture in the notation of the OCI image spec
+*/
+func OciArch(arch string) string {
+	switch NormalizeArch(arch) {
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "i386":
+		return "386"
+	}
+	return NormalizeArch(arch)
+}
+
+/*
+Returns the architecture of an ELF binary
+*/
+func ElfArch(fileName string) (string, error) {
+	f, err := elf.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return "x86_64", nil
+	case elf.EM_AARCH64:
+		return "aarch64", nil
+	case elf.EM_386:
+		return "i386", nil
+	case elf.EM_ARM:
+		return "armv7l", nil
+	case elf.EM_PPC64:
+		if f.ByteOrder.String() == "LittleEndian" {
+			return "ppc64le", nil
+		}
+		return "ppc64", nil
+	case elf.EM_S390:
+		return "s390x", nil
+	case elf.EM_RISCV:
+		return "riscv64", nil
+	}
+	return strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_")), nil
+}
+
+/*
+Tries to find out the architecture of the root file system under root, returns
+an empty string if nothing was found.
+*/
+func FindArch(root string) string {
+	for _, probe := range archProbeFiles {
+		arch, err := ElfArch(resolveInRoot(root, probe))
+		if err == nil {
+			return arch
+		}
+	}
+	return ""
+}
+
+/*
+Follows symlinks of file, absolute links are resolved relative to root
+*/
+func resolveInRoot(root string, file string) string {
+	for i := 0; i < 16; i++ {
+		target, err := os.Readlink(path.Join(root, file))
+		if err != nil {
+			break
+		}
+		if path.IsAbs(target) {
+			file = target
+		} else {
+			file = path.Join(path.Dir(file), target)
+		}
+	}
+	return path.Join(root, file)
+}
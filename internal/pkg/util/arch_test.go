@@ -0,0 +1,44 @@
+package util
+
+import (
+	"os"
+	"path"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NormalizeArch(t *testing.T) {
+	tests := map[string]string{
+		"amd64":   "x86_64",
+		"x86_64":  "x86_64",
+		"ARM64":   "aarch64",
+		"aarch64": "aarch64",
+		"ppc64el": "ppc64le",
+		"UNSET":   "UNSET",
+		"":        "",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, NormalizeArch(in), in)
+	}
+	assert.Equal(t, "amd64", OciArch("x86_64"))
+	assert.Equal(t, "arm64", OciArch("aarch64"))
+}
+
+func Test_FindArch(t *testing.T) {
+	root := t.TempDir()
+	assert.Equal(t, "", FindArch(root))
+
+	// absolute links must be resolved inside of root, not on the host
+	assert.NoError(t, os.MkdirAll(path.Join(root, "bin"), 0755))
+	assert.NoError(t, os.Symlink("/usr/bin/bash", path.Join(root, "bin/sh")))
+	assert.Equal(t, path.Join(root, "usr/bin/bash"), resolveInRoot(root, "/bin/sh"))
+	assert.Equal(t, "", FindArch(root))
+
+	exe, err := os.Executable()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(path.Join(root, "usr/bin"), 0755))
+	assert.NoError(t, CopyFile(exe, path.Join(root, "usr/bin/bash")))
+	assert.Equal(t, NormalizeArch(runtime.GOARCH), FindArch(root))
+}
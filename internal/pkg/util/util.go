@@ -558,15 +558,34 @@ func CpioCreate(
 	format string,
 	cpio_args ...string ) (err error) {
 
+	return CpioCreateAs(nil, ifiles, ofile, format, cpio_args...)
+}
+
+/*******************************************************************************
+	Create an archive using cpio, which runs with the process attributes attr,
+	e.g. in a user namespace. With attributes the archive is written through
+	the standard output of cpio, as it may not be allowed to create ofile.
+*/
+func CpioCreateAs(
+	attr *syscall.SysProcAttr,
+	ifiles []string,
+	ofile string,
+	format string,
+	cpio_args ...string ) (err error) {
+
 	args := []string{
 		"--quiet",
 		"--create",
-		"-H", format,
-		"--file=" + ofile }
+		"-H", format }
+
+	if attr == nil {
+		args = append(args, "--file=" + ofile)
+	}
 
 	args = append(args, cpio_args...)
 
 	proc := exec.Command("cpio", args...)
+	proc.SysProcAttr = attr
 
 	stdin, err := proc.StdinPipe()
 	if err != nil {
@@ -580,7 +599,23 @@ func CpioCreate(
 		err_in <- err
 	}()
 
-	out, err := proc.CombinedOutput()
+	var out []byte
+	if attr == nil {
+		out, err = proc.CombinedOutput()
+	} else {
+		var file *os.File
+		file, err = os.Create(ofile)
+		if err != nil {
+			<- err_in
+			return err
+		}
+		defer file.Close()
+		var stderr strings.Builder
+		proc.Stdout = file
+		proc.Stderr = &stderr
+		err = proc.Run()
+		out = []byte(stderr.String())
+	}
 	if len(out) > 0 {
 		wwlog.Debug(string(out))
 	}
@@ -638,6 +673,23 @@ func BuildFsImage(
 	format string,
 	cpio_args ...string ) (err error) {
 
+	return BuildFsImageAs(nil, name, rootfsPath, imagePath, include, ignore, ignore_xdev, format, cpio_args...)
+}
+
+/*******************************************************************************
+	Create an archive using cpio, which runs with the process attributes attr
+*/
+func BuildFsImageAs(
+	attr *syscall.SysProcAttr,
+	name string,
+	rootfsPath string,
+	imagePath string,
+	include []string,
+	ignore []string,
+	ignore_xdev bool,
+	format string,
+	cpio_args ...string ) (err error) {
+
 	err = os.MkdirAll(path.Dir(imagePath), 0755)
 	if err != nil {
 		return errors.Wrapf(err, "Failed to create image directory for %s: %s", name, imagePath)
@@ -675,7 +727,8 @@ func BuildFsImage(
 		return errors.Wrapf(err, "Failed discovering files for %s: %s", name, rootfsPath)
 	}
 
-	err = CpioCreate(
+	err = CpioCreateAs(
+		attr,
 		files,
 	 	imagePath,
 		format,
@@ -10,6 +10,7 @@ import (
 	"github.com/brotherpowers/ipsubnet"
 	"github.com/creasty/defaults"
 	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
+	"github.com/hpcng/warewulf/internal/pkg/migrate"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 
 	"gopkg.in/yaml.v2"
@@ -31,10 +32,16 @@ func New() (ControllerConf, error) {
 	var dhpdconf DhcpConf
 	var tftpconf TftpConf
 	var nfsConf NfsConf
+	var containerConf ContainerConf
+	var nodeDBConf NodeDBConf
+	var discoveryConf DiscoveryConf
 	ret.Warewulf = &warewulfconf
 	ret.Dhcp = &dhpdconf
 	ret.Tftp = &tftpconf
 	ret.Nfs = &nfsConf
+	ret.Container = &containerConf
+	ret.NodeDB = &nodeDBConf
+	ret.Discovery = &discoveryConf
 	err := defaults.Set(&ret)
 	if err != nil {
 		wwlog.Printf(wwlog.ERROR, "Coult initialize default variables\n")
@@ -47,6 +54,10 @@ func New() (ControllerConf, error) {
 		if err != nil {
 			wwlog.Printf(wwlog.WARN, "Error reading Warewulf configuration file\n")
 		}
+		data, err = migrate.Warewulf.Load(ConfigFile, data)
+		if err != nil {
+			return ret, err
+		}
 
 		wwlog.Printf(wwlog.DEBUG, "Unmarshaling the Warewulf configuration\n")
 		err = yaml.Unmarshal(data, &ret)
@@ -7,18 +7,22 @@ import (
 )
 
 type ControllerConf struct {
-	WWInternal int           `yaml:"WW_INTERNAL"`
-	Comment    string        `yaml:"comment,omitempty"`
-	Ipaddr     string        `yaml:"ipaddr"`
-	Ipaddr6    string        `yaml:"ipaddr6,omitempty"`
-	Netmask    string        `yaml:"netmask"`
-	Network    string        `yaml:"network,omitempty"`
-	Ipv6net    string        `yaml:"ipv6net,omitempty"`
-	Fqdn       string        `yaml:"fqdn,omitempty"`
-	Warewulf   *WarewulfConf `yaml:"warewulf"`
-	Dhcp       *DhcpConf     `yaml:"dhcp"`
-	Tftp       *TftpConf     `yaml:"tftp"`
-	Nfs        *NfsConf      `yaml:"nfs"`
+	WWInternal int                     `yaml:"WW_INTERNAL"`
+	Comment    string                  `yaml:"comment,omitempty"`
+	Ipaddr     string                  `yaml:"ipaddr"`
+	Ipaddr6    string                  `yaml:"ipaddr6,omitempty"`
+	Netmask    string                  `yaml:"netmask"`
+	Network    string                  `yaml:"network,omitempty"`
+	Ipv6net    string                  `yaml:"ipv6net,omitempty"`
+	Fqdn       string                  `yaml:"fqdn,omitempty"`
+	Warewulf   *WarewulfConf           `yaml:"warewulf"`
+	Dhcp       *DhcpConf               `yaml:"dhcp"`
+	Tftp       *TftpConf               `yaml:"tftp"`
+	Nfs        *NfsConf                `yaml:"nfs"`
+	Container  *ContainerConf          `yaml:"container,omitempty"`
+	NodeDB     *NodeDBConf             `yaml:"nodedb,omitempty"`
+	Networks   map[string]*NetworkConf `yaml:"networks,omitempty"`
+	Discovery  *DiscoveryConf          `yaml:"discovery,omitempty"`
 	current    bool
 }
 
@@ -30,6 +34,9 @@ type WarewulfConf struct {
 	EnableHostOverlay bool   `yaml:"host overlay" default:"true"`
 	Syslog            bool   `yaml:"syslog" default:"false"`
 	DataStore         string `yaml:"datastore" default:"/var/lib/warewulf"`
+	LearnUUID         bool   `yaml:"learn uuid" default:"false"`
+	HeartbeatInterval int    `yaml:"heartbeat interval" default:"60"`
+	DownTimeout       int    `yaml:"down timeout" default:"180"`
 }
 
 type DhcpConf struct {
@@ -59,6 +66,65 @@ type NfsExportConf struct {
 	Mount         bool   `default:"true" yaml:"mount"`
 }
 
+/*
+Delegates maps a container name (or '*' for all containers) to the
+unprivileged users which may modify it. Groups are prefixed with '@'.
+*/
+type ContainerConf struct {
+	Delegates map[string][]string `yaml:"delegates,omitempty"`
+}
+
+/*
+Storage of the nodes and profiles, the backend is either yaml (nodes.conf) or
+sqlite. Path is the sqlite database file.
+*/
+type NodeDBConf struct {
+	Backend string `yaml:"backend" default:"yaml"`
+	Path    string `yaml:"path,omitempty"`
+}
+
+/*
+A named network from which the addresses of the nodes are allocated. Reserved
+holds addresses, ranges (first-last) or subnets which are never assigned.
+Allocation is either next (the lowest free address) or index, where the
+address is base plus the number in the node name (n0042 -> base+42).
+*/
+type NetworkConf struct {
+	Subnet     string   `yaml:"subnet"`
+	Gateway    string   `yaml:"gateway,omitempty"`
+	Reserved   []string `yaml:"reserved,omitempty"`
+	Allocation string   `yaml:"allocation,omitempty"`
+	Base       string   `yaml:"base,omitempty"`
+}
+
+/*
+Discovery of unknown nodes. The rules are tried in their order, the first one
+which matches names the node the hardware address is bound to. If no rule
+matches and Fallback is set, the first discoverable node is used. With
+Approve, unknown nodes are only recorded and bound by wwctl node discover
+accept.
+*/
+type DiscoveryConf struct {
+	Approve  bool             `yaml:"approve" default:"false"`
+	Fallback bool             `yaml:"fallback" default:"true"`
+	Rules    []*DiscoveryRule `yaml:"rules,omitempty"`
+}
+
+/*
+Match maps the properties of a boot request (hwaddr, ipaddr, uuid, serial,
+assettag, circuitid, remoteid) to regular expressions which must match the
+whole value. Node is a template for the node name, which can use the
+properties and the named groups of the expressions, e.g. n{{.port}}. If
+Create is set, a missing node is added with the given profiles.
+*/
+type DiscoveryRule struct {
+	Match    map[string]string `yaml:"match,omitempty"`
+	Node     string            `yaml:"node"`
+	NetDev   string            `yaml:"netdev,omitempty"`
+	Create   bool              `yaml:"create,omitempty"`
+	Profiles []string          `yaml:"profiles,omitempty"`
+}
+
 func (s *NfsConf) Unmarshal(unmarshal func(interface{}) error) error {
 	if err := defaults.Set(s); err != nil {
 		return err
@@ -20,6 +20,8 @@ import (
 const (
 	WAREWULFD_PIDFILE = "/var/run/warewulfd.pid"
 	WAREWULFD_LOGFILE = "/var/log/warewulfd.log"
+	// wwctl authenticates with the token to queue commands for nodes
+	WAREWULFD_TOKENFILE = "/var/run/warewulfd.token"
 )
 
 var loginit bool
@@ -0,0 +1,438 @@
+package warewulfd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/hpcng/warewulf/internal/pkg/remoteexec"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+const (
+	// longest time a request waits for a command or for output
+	maxExecWait = 60
+	// a node which doesn't pick up a command within this time is not
+	// running wwclient
+	execPickupTimeout = 2 * maxExecWait
+	// grace time for the result of a command after its timeout
+	execResultGrace = 30
+	// finished commands are removed after this time
+	execKeep = 600
+	// upper limit of the output of a command which is kept
+	maxExecOutput = 1 << 20
+	// upper limit of the size of a posted report
+	maxExecReport = 2 * maxExecOutput
+)
+
+/*
+A command which was queued for a node
+*/
+type execJob struct {
+	remoteexec.Job
+	node     string
+	created  int64
+	started  int64
+	finished int64
+	output   []remoteexec.Output
+	size     int
+	done     bool
+	exitCode int
+	err      string
+}
+
+var execJobs struct {
+	sync.Mutex
+	jobs    map[string]*execJob
+	changed chan struct{}
+}
+
+/*
+Returns a channel which is closed when a command was queued or changed,
+execJobs must be locked
+*/
+func execChanged() <-chan struct{} {
+	if execJobs.changed == nil {
+		execJobs.changed = make(chan struct{})
+	}
+	return execJobs.changed
+}
+
+/*
+Wakes up the requests waiting for commands, execJobs must be locked
+*/
+func notifyExec() {
+	if execJobs.changed != nil {
+		close(execJobs.changed)
+	}
+	execJobs.changed = make(chan struct{})
+}
+
+func (job *execJob) finish(now int64, exitCode int, err string) {
+	job.done = true
+	job.finished = now
+	job.exitCode = exitCode
+	job.err = err
+}
+
+/*
+Fails commands which were not picked up or have no result in time and
+removes old finished ones, execJobs must be locked
+*/
+func expireExecJobs(now int64) {
+	changed := false
+	for id, job := range execJobs.jobs {
+		if job.done {
+			if now-job.finished > execKeep {
+				delete(execJobs.jobs, id)
+			}
+		} else if job.started == 0 && now-job.created > execPickupTimeout {
+			job.finish(now, -1, "node did not pick up the command, is wwclient running?")
+			changed = true
+		} else if job.started != 0 && now-job.started > int64(job.Timeout+execResultGrace) {
+			job.finish(now, -1, "no result from node")
+			changed = true
+		}
+	}
+	if changed {
+		notifyExec()
+	}
+}
+
+/*
+Queues a command for a node, returns its id
+*/
+func queueExecJob(req remoteexec.Request, now int64) (string, error) {
+	if len(req.Command) == 0 || req.Command[0] == "" {
+		return "", errors.New("no command given")
+	}
+	if req.Timeout <= 0 {
+		req.Timeout = remoteexec.DefaultTimeout
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	job := &execJob{
+		Job:     remoteexec.Job{ID: hex.EncodeToString(buf), Command: req.Command, Timeout: req.Timeout},
+		node:    req.Node,
+		created: now,
+	}
+	execJobs.Lock()
+	defer execJobs.Unlock()
+	if execJobs.jobs == nil {
+		execJobs.jobs = make(map[string]*execJob)
+	}
+	expireExecJobs(now)
+	execJobs.jobs[job.ID] = job
+	notifyExec()
+	return job.ID, nil
+}
+
+/*
+Returns the oldest command which is queued for a node and marks it as
+started, nil if there is none. execJobs must be locked.
+*/
+func nextExecJob(nodeID string, now int64) *remoteexec.Job {
+	expireExecJobs(now)
+	var next *execJob
+	for _, job := range execJobs.jobs {
+		if job.node != nodeID || job.started != 0 || job.done {
+			continue
+		}
+		if next == nil || job.created < next.created || (job.created == next.created && job.ID < next.ID) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil
+	}
+	next.started = now
+	notifyExec()
+	return &next.Job
+}
+
+/*
+Adds a report of a node to its command
+*/
+func reportExecJob(nodeID string, id string, report remoteexec.Report, now int64) error {
+	execJobs.Lock()
+	defer execJobs.Unlock()
+	job, ok := execJobs.jobs[id]
+	if !ok || job.node != nodeID || job.started == 0 {
+		return fmt.Errorf("unknown command: %s", id)
+	}
+	if job.done {
+		return fmt.Errorf("command already finished: %s", id)
+	}
+	for _, o := range report.Output {
+		if job.size+len(o.Data) > maxExecOutput {
+			o.Data = o.Data[:maxExecOutput-job.size]
+		}
+		if o.Data != "" {
+			job.output = append(job.output, o)
+			job.size += len(o.Data)
+		}
+	}
+	if report.Done {
+		job.finish(now, report.ExitCode, report.Error)
+	}
+	notifyExec()
+	return nil
+}
+
+/*
+Returns the state of a command with the output from offset, false if the
+command doesn't exist. execJobs must be locked.
+*/
+func execJobStatus(id string, offset int) (remoteexec.Status, bool) {
+	job, ok := execJobs.jobs[id]
+	if !ok {
+		return remoteexec.Status{}, false
+	}
+	ret := remoteexec.Status{
+		ID:       job.ID,
+		Node:     job.node,
+		Next:     len(job.output),
+		Started:  job.started != 0,
+		Done:     job.done,
+		ExitCode: job.exitCode,
+		Error:    job.err,
+	}
+	if offset >= 0 && offset < len(job.output) {
+		ret.Output = job.output[offset:]
+	}
+	return ret, true
+}
+
+func waitParam(req *http.Request) time.Duration {
+	wait, _ := strconv.Atoi(req.URL.Query().Get("wait"))
+	if wait > maxExecWait {
+		wait = maxExecWait
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return time.Duration(wait) * time.Second
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		wwlog.Warn("Could not send JSON: %s", err)
+	}
+}
+
+/*
+Handles wwclient at /exec/HWADDR: GET waits for the next command of the
+node, POST with the parameter job adds a report to the command
+*/
+func ExecNode(w http.ResponseWriter, req *http.Request) {
+	n, ok := clientNode(w, req, "exec", http.MethodGet, http.MethodPost)
+	if !ok {
+		return
+	}
+	nodeID := n.Id.Get()
+
+	if req.Method == http.MethodPost {
+		var report remoteexec.Report
+		err := json.NewDecoder(io.LimitReader(req.Body, maxExecReport)).Decode(&report)
+		if err != nil {
+			wwlog.Error("Could not decode command report of %s: %s", nodeID, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		err = reportExecJob(nodeID, req.URL.Query().Get("job"), report, time.Now().Unix())
+		if err != nil {
+			wwlog.Warn("%s: %s", nodeID, err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	timeout := time.NewTimer(waitParam(req))
+	defer timeout.Stop()
+	for {
+		execJobs.Lock()
+		changed := execChanged()
+		job := nextExecJob(nodeID, time.Now().Unix())
+		execJobs.Unlock()
+		if job != nil {
+			wwlog.Send("%15s: command %s: %s", nodeID, job.ID, strings.Join(job.Command, " "))
+			writeJSON(w, job)
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+/*
+Only wwctl on the host of warewulfd can read the token and submit commands
+*/
+func checkToken(req *http.Request) bool {
+	token, err := ioutil.ReadFile(WAREWULFD_TOKENFILE)
+	if err != nil || len(token) == 0 {
+		return false
+	}
+	got := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), bytes.TrimSpace(token)) == 1
+}
+
+/*
+Writes a new random token for wwctl
+*/
+func writeToken() error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(WAREWULFD_TOKENFILE, []byte(hex.EncodeToString(buf)), 0600)
+}
+
+/*
+Handles wwctl at /jobs: POST queues a command, GET /jobs/ID waits for output
+of a command after the parameter offset
+*/
+func ExecJobs(w http.ResponseWriter, req *http.Request) {
+	if !checkToken(req) {
+		wwlog.Denied("Invalid token for commands: %s", req.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		var request remoteexec.Request
+		err := json.NewDecoder(io.LimitReader(req.Body, maxExecReport)).Decode(&request)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, err := GetNodeById(request.Node); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		id, err := queueExecJob(request, time.Now().Unix())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		wwlog.Serv("Queued command %s for %s: %s", id, request.Node, strings.Join(request.Command, " "))
+		writeJSON(w, remoteexec.Status{ID: id, Node: request.Node})
+		return
+	} else if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(req.URL.Path, "/jobs/")
+	offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+	timeout := time.NewTimer(waitParam(req))
+	defer timeout.Stop()
+	for {
+		execJobs.Lock()
+		expireExecJobs(time.Now().Unix())
+		changed := execChanged()
+		status, ok := execJobStatus(id, offset)
+		execJobs.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if status.Done || len(status.Output) > 0 {
+			writeJSON(w, status)
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			writeJSON(w, status)
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func execRequest(method string, url string, body io.Reader, v interface{}) error {
+	token, err := ioutil.ReadFile(WAREWULFD_TOKENFILE)
+	if err != nil {
+		return errors.Wrap(err, "could not read token of warewulfd")
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "could not connect to warewulf server")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("warewulf server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func execURL(path string) (string, error) {
+	controller, err := warewulfconf.New()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("http://%s:%d%s", controller.Ipaddr, controller.Warewulf.Port, path), nil
+}
+
+/*
+Queues a command for a node on the running warewulfd, returns its id
+*/
+func SubmitCommand(request remoteexec.Request) (string, error) {
+	url, err := execURL("/jobs")
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	var status remoteexec.Status
+	err = execRequest(http.MethodPost, url, bytes.NewReader(data), &status)
+	return status.ID, err
+}
+
+/*
+Waits up to wait seconds for output of a command after offset or its end
+*/
+func CommandStatus(id string, offset int, wait int) (remoteexec.Status, error) {
+	var status remoteexec.Status
+	url, err := execURL(fmt.Sprintf("/jobs/%s?offset=%d&wait=%d", id, offset, wait))
+	if err != nil {
+		return status, err
+	}
+	err = execRequest(http.MethodGet, url, nil, &status)
+	return status, err
+}
@@ -0,0 +1,96 @@
+package warewulfd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hpcng/warewulf/internal/pkg/remoteexec"
+)
+
+func Test_execJobs(t *testing.T) {
+	execJobs.jobs = nil
+
+	_, err := queueExecJob(remoteexec.Request{Node: "n1"}, 100)
+	assert.Error(t, err)
+
+	first, err := queueExecJob(remoteexec.Request{Node: "n1", Command: []string{"uptime"}}, 100)
+	assert.NoError(t, err)
+	second, err := queueExecJob(remoteexec.Request{Node: "n1", Command: []string{"hostname"}, Timeout: 5}, 101)
+	assert.NoError(t, err)
+
+	execJobs.Lock()
+	assert.Nil(t, nextExecJob("n2", 102))
+	job := nextExecJob("n1", 102)
+	execJobs.Unlock()
+	assert.Equal(t, &remoteexec.Job{ID: first, Command: []string{"uptime"}, Timeout: remoteexec.DefaultTimeout}, job)
+
+	assert.Error(t, reportExecJob("n2", first, remoteexec.Report{}, 103))
+	assert.Error(t, reportExecJob("n1", second, remoteexec.Report{}, 103))
+	assert.NoError(t, reportExecJob("n1", first, remoteexec.Report{Output: []remoteexec.Output{{Stream: remoteexec.Stdout, Data: "up\n"}}}, 103))
+	assert.NoError(t, reportExecJob("n1", first, remoteexec.Report{
+		Output:   []remoteexec.Output{{Stream: remoteexec.Stderr, Data: "warn\n"}},
+		Done:     true,
+		ExitCode: 2,
+	}, 104))
+	assert.Error(t, reportExecJob("n1", first, remoteexec.Report{}, 105))
+
+	execJobs.Lock()
+	status, ok := execJobStatus(first, 1)
+	assert.True(t, ok)
+	assert.Equal(t, remoteexec.Status{
+		ID:       first,
+		Node:     "n1",
+		Output:   []remoteexec.Output{{Stream: remoteexec.Stderr, Data: "warn\n"}},
+		Next:     2,
+		Started:  true,
+		Done:     true,
+		ExitCode: 2,
+	}, status)
+	_, ok = execJobStatus("missing", 0)
+	assert.False(t, ok)
+
+	// the second command is picked up but the node never reports back
+	assert.Equal(t, second, nextExecJob("n1", 110).ID)
+	expireExecJobs(110 + 5 + execResultGrace + 1)
+	status, _ = execJobStatus(second, 0)
+	assert.True(t, status.Done)
+	assert.Equal(t, "no result from node", status.Error)
+
+	// finished commands are removed after a while
+	expireExecJobs(104 + execKeep + 1)
+	_, ok = execJobStatus(first, 0)
+	assert.False(t, ok)
+	execJobs.Unlock()
+
+	// commands which are not picked up fail
+	third, err := queueExecJob(remoteexec.Request{Node: "n3", Command: []string{"uptime"}}, 1000)
+	assert.NoError(t, err)
+	execJobs.Lock()
+	expireExecJobs(1000 + execPickupTimeout + 1)
+	status, _ = execJobStatus(third, 0)
+	execJobs.Unlock()
+	assert.True(t, status.Done)
+	assert.Equal(t, -1, status.ExitCode)
+	assert.Contains(t, status.Error, "did not pick up")
+}
+
+func Test_reportExecJobLimit(t *testing.T) {
+	execJobs.jobs = nil
+	id, err := queueExecJob(remoteexec.Request{Node: "n1", Command: []string{"yes"}}, 100)
+	assert.NoError(t, err)
+	execJobs.Lock()
+	nextExecJob("n1", 100)
+	execJobs.Unlock()
+
+	data := make([]byte, maxExecOutput-10)
+	assert.NoError(t, reportExecJob("n1", id, remoteexec.Report{Output: []remoteexec.Output{{Stream: remoteexec.Stdout, Data: string(data)}}}, 101))
+	assert.NoError(t, reportExecJob("n1", id, remoteexec.Report{Output: []remoteexec.Output{{Stream: remoteexec.Stdout, Data: "0123456789abcdef"}}}, 102))
+	assert.NoError(t, reportExecJob("n1", id, remoteexec.Report{Output: []remoteexec.Output{{Stream: remoteexec.Stdout, Data: "dropped"}}}, 103))
+
+	execJobs.Lock()
+	status, _ := execJobStatus(id, 1)
+	execJobs.Unlock()
+	assert.Equal(t, []remoteexec.Output{{Stream: remoteexec.Stdout, Data: "0123456789"}}, status.Output)
+	assert.Equal(t, 2, status.Next)
+}
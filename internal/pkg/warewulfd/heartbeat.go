@@ -0,0 +1,38 @@
+package warewulfd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hpcng/warewulf/internal/pkg/heartbeat"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+// upper limit of the size of a posted heartbeat
+const maxHeartbeatSize = 1 << 16
+
+/*
+Receives the heartbeat which wwclient posts to /heartbeat/HWADDR and keeps it
+in the status of the node
+*/
+func HeartbeatReceive(w http.ResponseWriter, req *http.Request) {
+	n, ok := clientNode(w, req, "heartbeat", http.MethodPost)
+	if !ok {
+		return
+	}
+
+	var hb heartbeat.Heartbeat
+	err := json.NewDecoder(io.LimitReader(req.Body, maxHeartbeatSize)).Decode(&hb)
+	if err != nil {
+		wwlog.Error("Could not decode heartbeat of %s: %s", n.Id.Get(), err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !hb.Healthy() {
+		wwlog.Verbose("%s: failed units: %s", n.Id.Get(), strings.Join(hb.FailedUnits, ", "))
+	}
+	updateHeartbeat(n.Id.Get(), strings.Split(req.RemoteAddr, ":")[0], hb)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package warewulfd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hpcng/warewulf/internal/pkg/inventory"
+	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+// upper limit of the size of a posted inventory
+const maxInventorySize = 1 << 20
+
+/*
+Checks a request of wwclient to /STAGE/HWADDR with one of the methods and
+returns its node. The response is written if the request is not accepted.
+*/
+func clientNode(w http.ResponseWriter, req *http.Request, stage string, methods ...string) (node.NodeInfo, bool) {
+	var ret node.NodeInfo
+	allowed := false
+	for _, method := range methods {
+		allowed = allowed || req.Method == method
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return ret, false
+	}
+	conf, err := warewulfconf.New()
+	if err != nil {
+		wwlog.Error("Could not open Warewulf configuration: %s", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return ret, false
+	}
+
+	rinfo, err := parseReq(req)
+	if err != nil || rinfo.stage != stage {
+		w.WriteHeader(http.StatusBadRequest)
+		wwlog.Error("Invalid %s request: %s", stage, req.URL.Path)
+		return ret, false
+	}
+	wwlog.Recv("hwaddr: %s, ipaddr: %s, %s", rinfo.hwaddr, req.RemoteAddr, stage)
+
+	if conf.Warewulf.Secure && rinfo.remoteport >= 1024 {
+		wwlog.Denied("Non-privileged port: %s", req.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return ret, false
+	}
+
+	n, err := GetNode(rinfo.hwaddr)
+	if err != nil {
+		wwlog.Error("%s (unknown/unconfigured node)", rinfo.hwaddr)
+		w.WriteHeader(http.StatusNotFound)
+		return ret, false
+	}
+	if n.AssetKey.Defined() && n.AssetKey.Get() != rinfo.assetkey {
+		wwlog.Denied("Incorrect asset key for node: %s", n.Id.Get())
+		w.WriteHeader(http.StatusUnauthorized)
+		return ret, false
+	}
+	if !n.CheckUUID(rinfo.uuid) {
+		wwlog.Denied("Incorrect uuid for node: %s: %s", n.Id.Get(), rinfo.uuid)
+		w.WriteHeader(http.StatusUnauthorized)
+		return ret, false
+	}
+	return n, true
+}
+
+/*
+Receives the hardware inventory which wwclient posts to /inventory/HWADDR and
+stores it for the node, changes to the previous inventory are logged
+*/
+func InventoryReceive(w http.ResponseWriter, req *http.Request) {
+	n, ok := clientNode(w, req, "inventory", http.MethodPost)
+	if !ok {
+		return
+	}
+
+	var inv inventory.Inventory
+	err := json.NewDecoder(io.LimitReader(req.Body, maxInventorySize)).Decode(&inv)
+	if err != nil {
+		wwlog.Error("Could not decode inventory of %s: %s", n.Id.Get(), err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	changes, err := inventory.Save(n.Id.Get(), inv, time.Now().Unix())
+	if err != nil {
+		wwlog.Error("Could not store inventory of %s: %s", n.Id.Get(), err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for _, c := range changes {
+		wwlog.Warn("%s: hardware changed: %s", n.Id.Get(), c)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
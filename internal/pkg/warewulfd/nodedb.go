@@ -3,10 +3,13 @@ package warewulfd
 import (
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/hpcng/warewulf/internal/pkg/discovery"
 	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 )
 
@@ -56,6 +59,21 @@ func GetNode(val string) (node.NodeInfo, error) {
 	return getNode(val)
 }
 
+/*
+Returns the node with the given name
+*/
+func GetNodeById(id string) (node.NodeInfo, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	for _, n := range db.NodeInfo {
+		if n.Id.Get() == id {
+			return n, nil
+		}
+	}
+	var empty node.NodeInfo
+	return empty, errors.Errorf("node not found: %s", id)
+}
+
 func getNode(val string) (node.NodeInfo, error) {
 
 	if _, ok := db.NodeInfo[val]; ok {
@@ -67,18 +85,18 @@ func getNode(val string) (node.NodeInfo, error) {
 	return empty, errors.New("No node found")
 }
 
-func GetNodeOrSetDiscoverable(hwaddr string) (node.NodeInfo, error) {
+func GetNodeOrSetDiscoverable(req node.DiscoveryRequest) (node.NodeInfo, error) {
 	db.lock.Lock()
 	defer db.lock.Unlock()
-	return getNodeOrSetDiscoverable(hwaddr)
+	return getNodeOrSetDiscoverable(req)
 }
 
-func getNodeOrSetDiscoverable(hwaddr string) (node.NodeInfo, error) {
+func getNodeOrSetDiscoverable(req node.DiscoveryRequest) (node.NodeInfo, error) {
 	// NOTE: since discoverable nodes will write an updated DB to file and then
 	// reload, it is not enough to lock individual reads from the DB
 	// to ensure the condition on which the node is updated is still satisfied
 	// after the DB is read back in.
-
+	hwaddr := req.Hwaddr
 
 	n, err := getNode(hwaddr)
 	if err == nil {
@@ -95,16 +113,39 @@ func getNodeOrSetDiscoverable(hwaddr string) (node.NodeInfo, error) {
 		return n, errors.Wrapf(err, "%s (failed to read node configuration file)", hwaddr)
 	}
 
-	_n, netdev, err := config.FindDiscoverableNode()
+	conf, err := warewulfconf.New()
 	if err != nil {
+		return n, errors.Wrapf(err, "%s (failed to read warewulf.conf)", hwaddr)
+	}
+	rules, err := node.CompileDiscoveryRules(conf.Discovery)
+	if err != nil {
+		return n, errors.Wrapf(err, "%s (invalid discovery rules)", hwaddr)
+	}
+
+	_n, netdev, err := config.DiscoverNode(req, rules, conf.Discovery == nil || conf.Discovery.Fallback)
+	if err != nil {
+		wwlog.WarnExc(err, "%s (discovery failed)", hwaddr)
+	}
+
+	// machines which are not bound are recorded, so that they can be
+	// accepted with wwctl node discover
+	pending, _ := discovery.Find(hwaddr)
+	approve := conf.Discovery != nil && conf.Discovery.Approve
+	if !_n.Id.Defined() || approve || pending.Rejected {
+		if pending.Rejected {
+			wwlog.Denied("%s (discovery rejected)", hwaddr)
+		} else if _n.Id.Defined() {
+			wwlog.Serv("%s (waiting for approval as node %s)", hwaddr, _n.Id.Get())
+		}
+		_, err = discovery.Seen(req, _n.Id.Get(), time.Now().Unix())
+		if err != nil {
+			wwlog.ErrorExc(err, "%s (failed to record pending discovery)", hwaddr)
+		}
 		// NOTE: this is taken as there is no discoverable node, so return the
 		// empty one
 		return n, nil
 	}
 
-	_n.NetDevs[netdev].Hwaddr.Set(hwaddr)
-	_n.Discoverable.SetB(false)
-
 	// NOTE: errors here should return the empty node if the state cannot
 	// be saved and re-loaded, since subsequent requests will be made on invalid
 	// assumption that the database is up to date.
@@ -113,6 +154,11 @@ func getNodeOrSetDiscoverable(hwaddr string) (node.NodeInfo, error) {
 		return n, errors.Wrapf(err, "%s (failed to set node configuration)", hwaddr)
 	}
 
+	_, err = config.AllocateIpaddrs([]string{_n.Id.Get()})
+	if err != nil {
+		return n, errors.Wrapf(err, "%s (failed to allocate addresses)", hwaddr)
+	}
+
 	err = config.Persist()
 	if err != nil {
 		return n, errors.Wrapf(err, "%s (failed to persist node configuration)", hwaddr)
@@ -127,8 +173,54 @@ func getNodeOrSetDiscoverable(hwaddr string) (node.NodeInfo, error) {
 	// be done automatically when attempting to serve an overlay that
 	// hasn't been built (without blocking the database).
 
-	wwlog.Serv("%s (node automatically configured)", hwaddr)
+	wwlog.Serv("%s (node %s:%s automatically configured)", hwaddr, _n.Id.Get(), netdev)
 
-	// return the discovered node
-	return _n, nil
+	if pending.Hwaddr != "" {
+		err = discovery.Remove(hwaddr)
+		if err != nil {
+			wwlog.ErrorExc(err, "%s (failed to remove pending discovery)", hwaddr)
+		}
+	}
+
+	// return the discovered node, as read back with the allocated addresses
+	return getNode(hwaddr)
+}
+
+/*
+Binds the UUID of the first request to a node without one (trust on first
+use). Requests without a valid UUID and UUIDs of other nodes are not learned,
+the node is returned unchanged then.
+*/
+func LearnUUID(n node.NodeInfo, uuid string) node.NodeInfo {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	uuid, err := node.NormalizeUUID(uuid)
+	if err != nil {
+		wwlog.Debug("%s: not learning uuid: %s", n.Id.Get(), err)
+		return n
+	}
+	config, err := node.New()
+	if err != nil {
+		wwlog.ErrorExc(err, "%s (failed to read node configuration file)", n.Id.Get())
+		return n
+	}
+	err = config.SetUUID(n.Id.Get(), uuid)
+	if err != nil {
+		wwlog.Warn("%s: not learning uuid: %s", n.Id.Get(), err)
+		return n
+	}
+	err = config.Persist()
+	if err != nil {
+		wwlog.ErrorExc(err, "%s (failed to persist node configuration)", n.Id.Get())
+		return n
+	}
+	err = loadNodeDB()
+	if err != nil {
+		wwlog.ErrorExc(err, "%s (failed to reload configuration)", n.Id.Get())
+		return n
+	}
+	wwlog.Serv("%s (uuid %s learned)", n.Id.Get(), uuid)
+	n.UUID.Set(uuid)
+	return n
 }
@@ -0,0 +1,148 @@
+package warewulfd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/hpcng/warewulf/internal/pkg/cpio"
+	"github.com/hpcng/warewulf/internal/pkg/util"
+)
+
+/*
+Longest time a request for the runtime overlay waits for a change
+*/
+const maxWait = 3600
+
+var overlayChange struct {
+	sync.Mutex
+	ch chan struct{}
+}
+
+/*
+Returns a channel which is closed when overlays changed
+*/
+func overlaysChanged() <-chan struct{} {
+	overlayChange.Lock()
+	defer overlayChange.Unlock()
+	if overlayChange.ch == nil {
+		overlayChange.ch = make(chan struct{})
+	}
+	return overlayChange.ch
+}
+
+/*
+Wakes up the requests which wait for a change of their overlay
+*/
+func notifyOverlaysChanged() {
+	overlayChange.Lock()
+	defer overlayChange.Unlock()
+	if overlayChange.ch != nil {
+		close(overlayChange.ch)
+	}
+	overlayChange.ch = make(chan struct{})
+}
+
+/*
+Tells a running warewulfd that overlay images were built, the nodes waiting
+for a change of their runtime overlay get it immediately
+*/
+func DaemonNotifyOverlays() error {
+	if !util.IsFile(WAREWULFD_PIDFILE) {
+		return errors.New("Warewulf server is not running")
+	}
+	dat, err := ioutil.ReadFile(WAREWULFD_PIDFILE)
+	if err != nil {
+		return errors.Wrap(err, "could not read Warewulfd PID file")
+	}
+	pid, _ := strconv.Atoi(string(dat))
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return errors.Wrap(err, "failed to find running PID")
+	}
+	return process.Signal(syscall.SIGUSR1)
+}
+
+type imageDigest struct {
+	size   int64
+	mtime  int64
+	digest string
+}
+
+var digestCache struct {
+	sync.Mutex
+	images map[string]imageDigest
+}
+
+/*
+Returns the ETag of an overlay image, which is the digest of its content
+without modification times. Nodes which send it in If-None-Match don't get
+an overlay which was only built again. The digests are cached until the
+image changes.
+*/
+func overlayEtag(imageFile string) string {
+	stat, err := os.Stat(imageFile)
+	if err != nil {
+		return ""
+	}
+	digestCache.Lock()
+	defer digestCache.Unlock()
+	if digestCache.images == nil {
+		digestCache.images = make(map[string]imageDigest)
+	}
+	cached, ok := digestCache.images[imageFile]
+	if ok && cached.size == stat.Size() && cached.mtime == stat.ModTime().UnixNano() {
+		return `"` + cached.digest + `"`
+	}
+	digest, err := cpio.DigestFile(imageFile)
+	if err != nil {
+		return ""
+	}
+	digestCache.images[imageFile] = imageDigest{size: stat.Size(), mtime: stat.ModTime().UnixNano(), digest: digest}
+	return `"` + digest + `"`
+}
+
+func etagMatches(header string, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == etag || value == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Waits at most wait seconds until the ETag of the overlay image differs from
+the one in If-None-Match of the request, returns the current ETag. false is
+returned if the request was canceled.
+*/
+func waitOverlayChange(req *http.Request, imageFile string, wait int) (string, bool) {
+	if wait > maxWait {
+		wait = maxWait
+	}
+	timeout := time.NewTimer(time.Duration(wait) * time.Second)
+	defer timeout.Stop()
+	for {
+		// taken before the ETag, so that no change is missed
+		changed := overlaysChanged()
+		etag := overlayEtag(imageFile)
+		if etag == "" || !etagMatches(req.Header.Get("If-None-Match"), etag) {
+			return etag, true
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			return etag, true
+		case <-req.Context().Done():
+			return etag, false
+		}
+	}
+}
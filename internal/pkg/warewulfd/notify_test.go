@@ -0,0 +1,250 @@
+package warewulfd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hpcng/warewulf/internal/pkg/cpio"
+)
+
+// writes an overlay image with one file in the newc format
+func writeImage(t *testing.T, fileName string, data string, mtime int64) {
+	var buf bytes.Buffer
+	write := func(name string, mode uint32, data string) {
+		fmt.Fprintf(&buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			1, mode, 0, 0, 1, mtime, len(data), 0, 0, 0, 0, len(name)+1, 0)
+		buf.WriteString(name + "\x00")
+		buf.Write(make([]byte, (4-(110+len(name)+1)%4)%4))
+		buf.WriteString(data)
+		buf.Write(make([]byte, (4-len(data)%4)%4))
+	}
+	write("etc/motd", cpio.ModeRegular|0644, data)
+	write("TRAILER!!!", 0, "")
+	assert.NoError(t, ioutil.WriteFile(fileName, buf.Bytes(), 0644))
+}
+
+// serves the image as the runtime overlay is served by ProvisionSend
+func overlayHandler(imageFile string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		etag := overlayEtag(imageFile)
+		wait, _ := strconv.Atoi(req.URL.Query().Get("wait"))
+		if wait > 0 && etag != "" {
+			var ok bool
+			etag, ok = waitOverlayChange(req, imageFile, wait)
+			if !ok {
+				return
+			}
+		}
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		_ = sendFile(w, req, imageFile, "n1")
+	})
+}
+
+func Test_overlayEtag(t *testing.T) {
+	imageFile := path.Join(t.TempDir(), "runtime.img")
+	assert.Equal(t, "", overlayEtag(imageFile))
+
+	writeImage(t, imageFile, "hello\n", 100)
+	etag := overlayEtag(imageFile)
+	assert.Regexp(t, `^"[0-9a-f]{64}"$`, etag)
+
+	// built again from the same files
+	writeImage(t, imageFile, "hello\n", 200)
+	assert.Equal(t, etag, overlayEtag(imageFile))
+
+	writeImage(t, imageFile, "world\n", 200)
+	changed := overlayEtag(imageFile)
+	assert.NotEqual(t, etag, changed)
+
+	assert.NoError(t, os.Remove(imageFile))
+	assert.Equal(t, "", overlayEtag(imageFile))
+}
+
+func Test_overlayEtagCache(t *testing.T) {
+	imageFile := path.Join(t.TempDir(), "runtime.img")
+	writeImage(t, imageFile, "hello\n", 100)
+	etag := overlayEtag(imageFile)
+	stat, err := os.Stat(imageFile)
+	assert.NoError(t, err)
+
+	// same size and modification time, the cached digest is used
+	writeImage(t, imageFile, "world\n", 100)
+	assert.NoError(t, os.Chtimes(imageFile, stat.ModTime(), stat.ModTime()))
+	assert.Equal(t, etag, overlayEtag(imageFile))
+
+	mtime := stat.ModTime().Add(time.Second)
+	assert.NoError(t, os.Chtimes(imageFile, mtime, mtime))
+	changed := overlayEtag(imageFile)
+	assert.NotEqual(t, etag, changed)
+
+	// a different size invalidates it as well
+	writeImage(t, imageFile, "hello world\n", 100)
+	assert.NoError(t, os.Chtimes(imageFile, mtime, mtime))
+	assert.NotEqual(t, changed, overlayEtag(imageFile))
+}
+
+func Test_etagMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected bool
+	}{
+		{name: "empty", header: ""},
+		{name: "same", header: `"abc"`, expected: true},
+		{name: "other", header: `"def"`},
+		{name: "weak", header: `W/"abc"`, expected: true},
+		{name: "list", header: `"def", "abc"`, expected: true},
+		{name: "any", header: `*`, expected: true},
+		{name: "unquoted", header: `abc`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, etagMatches(tt.header, `"abc"`))
+		})
+	}
+}
+
+func Test_overlayNotModified(t *testing.T) {
+	imageFile := path.Join(t.TempDir(), "runtime.img")
+	writeImage(t, imageFile, "hello\n", 100)
+	server := httptest.NewServer(overlayHandler(imageFile))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, overlayEtag(imageFile), etag)
+	assert.NotEmpty(t, body)
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Empty(t, body)
+
+	// only built again, the node keeps its overlay
+	writeImage(t, imageFile, "hello\n", 200)
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	writeImage(t, imageFile, "world\n", 200)
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+	assert.Contains(t, string(body), "world")
+}
+
+func Test_waitOverlayChangeTimeout(t *testing.T) {
+	imageFile := path.Join(t.TempDir(), "runtime.img")
+	writeImage(t, imageFile, "hello\n", 100)
+	server := httptest.NewServer(overlayHandler(imageFile))
+	defer server.Close()
+
+	etag := overlayEtag(imageFile)
+	req, _ := http.NewRequest("GET", server.URL+"?wait=1", nil)
+	req.Header.Set("If-None-Match", etag)
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Equal(t, etag, resp.Header.Get("ETag"))
+}
+
+func Test_waitOverlayChangeSignal(t *testing.T) {
+	imageFile := path.Join(t.TempDir(), "runtime.img")
+	writeImage(t, imageFile, "hello\n", 100)
+	server := httptest.NewServer(overlayHandler(imageFile))
+	defer server.Close()
+
+	c := make(chan os.Signal, 1)
+	defer close(c)
+	signal.Notify(c, syscall.SIGUSR1)
+	defer signal.Stop(c)
+	go handleSignals(c)
+
+	etag := overlayEtag(imageFile)
+	req, _ := http.NewRequest("GET", server.URL+"?wait=60", nil)
+	req.Header.Set("If-None-Match", etag)
+	done := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		done <- resp
+	}()
+
+	// the change is only seen when warewulfd is told about it
+	time.Sleep(200 * time.Millisecond)
+	writeImage(t, imageFile, "world\n", 200)
+	select {
+	case <-done:
+		t.Fatal("returned before SIGUSR1")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	select {
+	case resp := <-done:
+		if resp == nil {
+			t.FailNow()
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+		assert.Contains(t, string(body), "world")
+	case <-time.After(10 * time.Second):
+		t.Fatal("not woken up by SIGUSR1")
+	}
+}
+
+func Test_waitOverlayChangeCanceled(t *testing.T) {
+	imageFile := path.Join(t.TempDir(), "runtime.img")
+	writeImage(t, imageFile, "hello\n", 100)
+	etag := overlayEtag(imageFile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	req.Header.Set("If-None-Match", etag)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	current, ok := waitOverlayChange(req, imageFile, 60)
+	assert.False(t, ok)
+	assert.Equal(t, etag, current)
+
+	// a different ETag is returned without waiting
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	current, ok = waitOverlayChange(req, imageFile, 60)
+	assert.True(t, ok)
+	assert.Equal(t, etag, current)
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+
+	"github.com/hpcng/warewulf/internal/pkg/node"
 )
 
 type parserInfo struct {
@@ -14,9 +16,36 @@ type parserInfo struct {
 	remoteport int
 	assetkey   string
 	uuid       string
+	serial     string
+	circuitid  string
+	remoteid   string
 	stage      string
 	overlay    string
 	compress   string
+	wait       int
+	applied    string
+	digest     string
+}
+
+/*
+dhcpd passes the relay agent options as colon separated hex bytes without
+leading zeros, e.g. 45:74:68:31:2f:31 for Eth1/1. They are decoded if the
+result is printable, other values are kept as they are.
+*/
+func decodeHexString(value string) string {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 {
+		return value
+	}
+	var ret []byte
+	for _, part := range parts {
+		b, err := strconv.ParseUint(part, 16, 8)
+		if err != nil || len(part) > 2 || b < 0x20 || b > 0x7e {
+			return value
+		}
+		ret = append(ret, byte(b))
+	}
+	return string(ret)
 }
 
 func parseReq(req *http.Request) (parserInfo, error) {
@@ -47,6 +76,18 @@ func parseReq(req *http.Request) (parserInfo, error) {
 		ret.uuid = req.URL.Query()["uuid"][0]
 	}
 
+	if len(req.URL.Query()["serial"]) > 0 {
+		ret.serial = req.URL.Query()["serial"][0]
+	}
+
+	if len(req.URL.Query()["circuitid"]) > 0 {
+		ret.circuitid = decodeHexString(req.URL.Query()["circuitid"][0])
+	}
+
+	if len(req.URL.Query()["remoteid"]) > 0 {
+		ret.remoteid = decodeHexString(req.URL.Query()["remoteid"][0])
+	}
+
 	if len(req.URL.Query()["stage"]) > 0 {
 		ret.stage = req.URL.Query()["stage"][0]
 	}else{
@@ -63,6 +104,12 @@ func parseReq(req *http.Request) (parserInfo, error) {
 			ret.stage = "system"
 		}else if stage == "overlay-runtime" {
 			ret.stage = "runtime"
+		}else if stage == "inventory" {
+			ret.stage = "inventory"
+		}else if stage == "heartbeat" {
+			ret.stage = "heartbeat"
+		}else if stage == "exec" {
+			ret.stage = "exec"
 		}
 	}
 
@@ -72,6 +119,15 @@ func parseReq(req *http.Request) (parserInfo, error) {
 	if len(req.URL.Query()["compress"]) > 0 {
 		ret.compress = req.URL.Query()["compress"][0]
 	}
+	if len(req.URL.Query()["wait"]) > 0 {
+		ret.wait, _ = strconv.Atoi(req.URL.Query()["wait"][0])
+	}
+	if len(req.URL.Query()["applied"]) > 0 {
+		ret.applied = req.URL.Query()["applied"][0]
+	}
+	if len(req.URL.Query()["digest"]) > 0 {
+		ret.digest = req.URL.Query()["digest"][0]
+	}
 	if ret.stage == "" {
 		return ret, errors.New("no stage encoded in GET")
 	}
@@ -87,3 +143,20 @@ func parseReq(req *http.Request) (parserInfo, error) {
 
 	return ret, nil
 }
+
+
+/*
+The properties of the request which are matched by the discovery rules, the
+asset key is passed as asset tag by the iPXE configuration of dhcpd
+*/
+func (rinfo parserInfo) discoveryRequest() node.DiscoveryRequest {
+	return node.DiscoveryRequest{
+		Hwaddr:    rinfo.hwaddr,
+		Ipaddr:    rinfo.ipaddr,
+		UUID:      strings.ToLower(rinfo.uuid),
+		Serial:    rinfo.serial,
+		AssetTag:  rinfo.assetkey,
+		CircuitId: rinfo.circuitid,
+		RemoteId:  rinfo.remoteid,
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/hpcng/warewulf/internal/pkg/buildconfig"
 	"github.com/hpcng/warewulf/internal/pkg/container"
 	"github.com/hpcng/warewulf/internal/pkg/kernel"
+	"github.com/hpcng/warewulf/internal/pkg/node"
 	"github.com/hpcng/warewulf/internal/pkg/util"
 	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
@@ -23,11 +24,13 @@ type iPxeTemplate struct {
 	Id             string
 	Cluster        string
 	ContainerName  string
+	Arch           string
 	Hwaddr         string
 	Ipaddr         string
 	Port           string
 	KernelArgs     string
 	KernelOverride string
+	BootLocal      bool
 }
 
 func ProvisionSend(w http.ResponseWriter, req *http.Request) {
@@ -68,7 +71,7 @@ func ProvisionSend(w http.ResponseWriter, req *http.Request) {
 	// TODO: when module version is upgraded to go1.18, should be 'any' type
 	var tmpl_data interface{}
 
-	node, err := GetNodeOrSetDiscoverable(rinfo.hwaddr)
+	node, err := GetNodeOrSetDiscoverable(rinfo.discoveryRequest())
 	if err != nil {
 		wwlog.ErrorExc(err, "")
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -82,6 +85,17 @@ func ProvisionSend(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if !node.CheckUUID(rinfo.uuid) {
+		w.WriteHeader(http.StatusUnauthorized)
+		wwlog.Denied("Incorrect uuid for node: %s: %s", node.Id.Get(), rinfo.uuid)
+		updateStatus(node.Id.Get(), status_stage, "BAD_UUID", rinfo.ipaddr)
+		return
+	}
+
+	if node.Id.Defined() && !node.UUID.Defined() && conf.Warewulf.LearnUUID {
+		node = LearnUUID(node, rinfo.uuid)
+	}
+
 	if !node.Id.Defined() {
 		wwlog.Error("%s (unknown/unconfigured node)", rinfo.hwaddr)
 		if rinfo.stage == "ipxe" {
@@ -92,7 +106,7 @@ func ProvisionSend(w http.ResponseWriter, req *http.Request) {
 
 	}else if rinfo.stage == "ipxe" {
 		stage_file = path.Join(buildconfig.SYSCONFDIR(), "warewulf/ipxe/"+node.Ipxe.Get()+".ipxe")
-		tmpl_data = iPxeTemplate{
+		ipxe := iPxeTemplate{
 			Id : node.Id.Get(),
 			Cluster : node.ClusterName.Get(),
 			Fqdn : node.Id.Get(),
@@ -101,8 +115,21 @@ func ProvisionSend(w http.ResponseWriter, req *http.Request) {
 			Hostname : node.Id.Get(),
 			Hwaddr : rinfo.hwaddr,
 			ContainerName : node.ContainerName.Get(),
+			Arch : nodeArch(node),
 			KernelArgs : node.Kernel.Args.Get(),
 			KernelOverride : node.Kernel.Override.Get() }
+		if node.Root.Get() == "disk" {
+			ipxe.BootLocal, ipxe.KernelArgs = diskBoot(node.Id.Get(), ipxe.KernelArgs)
+		}
+		tmpl_data = ipxe
+
+	}else if rinfo.stage == "provisioned" {
+		// 95-disk reports that it installed the node to disk
+		if node.Root.Get() == "disk" {
+			finishProvision(node.Id.Get(), rinfo.digest)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
 
 	}else if rinfo.stage == "kernel" {
 		if node.Kernel.Override.Defined() {
@@ -139,6 +166,9 @@ func ProvisionSend(w http.ResponseWriter, req *http.Request) {
 		}
 
 	}else if rinfo.stage == "runtime" {
+		if rinfo.applied != "" {
+			updateApplied(node.Id.Get(), rinfo.applied)
+		}
 		if rinfo.overlay != "" {
 			stage_overlays = []string{rinfo.overlay}
 		} else if len(node.RuntimeOverlay.GetSlice()) != 0 {
@@ -153,6 +183,7 @@ func ProvisionSend(w http.ResponseWriter, req *http.Request) {
 		stage_file, err = getOverlayFile(
 			node.Id.Get(),
 			stage_overlays,
+			node.ContainerName.Get(),
 			conf.Warewulf.AutobuildOverlays )
 
 		if err != nil {
@@ -162,6 +193,19 @@ func ProvisionSend(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	var etag string
+	if len(stage_overlays) > 0 {
+		etag = overlayEtag(stage_file)
+		// long poll of wwclient, which gets the overlay as soon as it changes
+		if rinfo.stage == "runtime" && rinfo.wait > 0 && etag != "" {
+			var ok bool
+			etag, ok = waitOverlayChange(req, stage_file, rinfo.wait)
+			if !ok {
+				return
+			}
+		}
+	}
+
 	wwlog.Serv("stage_file '%s'", stage_file )
 
 	if util.IsFile(stage_file) {
@@ -216,6 +260,9 @@ func ProvisionSend(w http.ResponseWriter, req *http.Request) {
 				w.WriteHeader(http.StatusNotFound)
 			}
 
+			if etag != "" {
+				w.Header().Set("ETag", etag)
+			}
 			err = sendFile(w, req, stage_file, node.Id.Get())
 			if err != nil {
 				wwlog.ErrorExc(err, "")
@@ -237,3 +284,16 @@ func ProvisionSend(w http.ResponseWriter, req *http.Request) {
 	}
 
 }
+
+/*
+The architecture of the node, if not set the one of its container
+*/
+func nodeArch(n node.NodeInfo) string {
+	if n.Arch.Defined() {
+		return util.NormalizeArch(n.Arch.Get())
+	}
+	if n.ContainerName.Defined() {
+		return container.Arch(n.ContainerName.Get())
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package warewulfd
+
+import (
+	"time"
+
+	"github.com/hpcng/warewulf/internal/pkg/reprovision"
+	"github.com/hpcng/warewulf/internal/pkg/wwlog"
+)
+
+/*
+Decides how a node with its root on disk boots. It boots from its disk
+unless a reprovision is pending, then the flag which makes wwinit install
+the node is added to the kernel arguments.
+*/
+func diskBoot(nodeID string, kernelArgs string) (bool, string) {
+	state, err := reprovision.Get(nodeID)
+	if err != nil {
+		// the iPXE script falls back to install the node if the disk
+		// doesn't boot
+		wwlog.Error("Could not read the provisioning state of %s: %s", nodeID, err)
+		return true, kernelArgs
+	}
+	if !state.Pending() {
+		return true, kernelArgs
+	}
+	wwlog.Info("Installing node %s to disk", nodeID)
+	err = reprovision.Start(nodeID, time.Now().Unix())
+	if err != nil {
+		wwlog.Error("Could not record the provisioning of %s: %s", nodeID, err)
+	}
+	return false, kernelArgs + " wwprovision=1"
+}
+
+/*
+Clears the reprovision request of a node when the wwinit stage 95-disk
+reports that it installed the node
+*/
+func finishProvision(nodeID string, digest string) {
+	wwlog.Info("Node %s is provisioned", nodeID)
+	err := reprovision.Finish(nodeID, digest, time.Now().Unix())
+	if err != nil {
+		wwlog.Error("Could not record the provisioning of %s: %s", nodeID, err)
+	}
+}
@@ -2,10 +2,14 @@ package warewulfd
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/hpcng/warewulf/internal/pkg/heartbeat"
 	"github.com/hpcng/warewulf/internal/pkg/node"
+	"github.com/hpcng/warewulf/internal/pkg/warewulfconf"
 	"github.com/hpcng/warewulf/internal/pkg/wwlog"
 	"github.com/pkg/errors"
 )
@@ -20,10 +24,24 @@ type NodeStatus struct {
 	Sent     string `json:"sent"`
 	Ipaddr   string `json:"ipaddr"`
 	Lastseen int64  `json:"last seen"`
+	// digest of the runtime overlay which wwclient applied last
+	Applied       string               `json:"applied overlay,omitempty"`
+	Heartbeat     *heartbeat.Heartbeat `json:"heartbeat,omitempty"`
+	LastHeartbeat int64                `json:"last heartbeat,omitempty"`
+	State         string               `json:"state,omitempty"`
 }
 
+const (
+	StateUp        = "UP"
+	StateUnhealthy = "UNHEALTHY"
+	StateDown      = "DOWN"
+)
+
 var statusDB allStatus
 
+// the status is updated by concurrent requests
+var statusLock sync.Mutex
+
 func init() {
 	statusDB.Nodes = make(map[string]*NodeStatus)
 }
@@ -42,6 +60,8 @@ func LoadNodeStatus() error {
 		return err
 	}
 
+	statusLock.Lock()
+	defer statusLock.Unlock()
 	for _, n := range nodes {
 		if _, ok := statusDB.Nodes[n.Id.Get()]; !ok {
 			newDB.Nodes[n.Id.Get()] = &NodeStatus{}
@@ -66,13 +86,90 @@ func updateStatus(nodeID, stage, sent, ipaddr string) {
 	n.Lastseen = rightnow
 	n.Sent = sent
 	n.Ipaddr = ipaddr
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	if old, ok := statusDB.Nodes[nodeID]; ok {
+		n.Applied = old.Applied
+		// a node which boots has no heartbeat yet
+		if stage != "IPXE" {
+			n.Heartbeat = old.Heartbeat
+			n.LastHeartbeat = old.LastHeartbeat
+		}
+	}
 	statusDB.Nodes[nodeID] = &n
 }
 
+/*
+Returns the status of a node to be modified, statusLock must be held
+*/
+func nodeStatus(nodeID string) *NodeStatus {
+	n, ok := statusDB.Nodes[nodeID]
+	if !ok {
+		n = &NodeStatus{NodeName: nodeID}
+		statusDB.Nodes[nodeID] = n
+	}
+	return n
+}
+
+/*
+Records the digest of the runtime overlay which wwclient reported as applied
+*/
+func updateApplied(nodeID, digest string) {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	nodeStatus(nodeID).Applied = digest
+}
+
+/*
+Records the heartbeat of a node, which is also seen by it
+*/
+func updateHeartbeat(nodeID, ipaddr string, hb heartbeat.Heartbeat) {
+	rightnow := time.Now().Unix()
+
+	wwlog.Debug("Updating node heartbeat: %s", nodeID)
+
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	n := nodeStatus(nodeID)
+	n.Heartbeat = &hb
+	n.LastHeartbeat = rightnow
+	n.Lastseen = rightnow
+	n.Ipaddr = ipaddr
+}
+
+/*
+State of a node from its heartbeats: DOWN if none was received within timeout
+seconds, UNHEALTHY if systemd units failed, else UP. Nodes which never sent a
+heartbeat have no state. A timeout of 0 never marks nodes as down.
+*/
+func nodeState(n *NodeStatus, now int64, timeout int) string {
+	if n.LastHeartbeat == 0 || n.Heartbeat == nil {
+		return ""
+	}
+	if timeout > 0 && now-n.LastHeartbeat > int64(timeout) {
+		return StateDown
+	}
+	if !n.Heartbeat.Healthy() {
+		return StateUnhealthy
+	}
+	return StateUp
+}
+
 func statusJSON() ([]byte, error) {
 
 	wwlog.Debug("Request for node status data...")
 
+	timeout := 0
+	if conf, err := warewulfconf.New(); err == nil {
+		timeout = conf.Warewulf.DownTimeout
+	}
+	rightnow := time.Now().Unix()
+
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	for _, n := range statusDB.Nodes {
+		n.State = nodeState(n, rightnow, timeout)
+	}
 	ret, err := json.MarshalIndent(statusDB, "", "  ")
 	if err != nil {
 		return ret, errors.Wrap(err, "could not marshal JSON data from sstatus structure")
@@ -94,3 +191,52 @@ func StatusSend(w http.ResponseWriter, req *http.Request) {
 		wwlog.Warn("Could not send status JSON: %s", err)
 	}
 }
+
+/*
+Fetches the status of all nodes from the running warewulfd
+*/
+func GetNodeStatus() (map[string]*NodeStatus, error) {
+	controller, err := warewulfconf.New()
+	if err != nil {
+		return nil, err
+	}
+	statusURL := fmt.Sprintf("http://%s:%d/status", controller.Ipaddr, controller.Warewulf.Port)
+	wwlog.Verbose("Connecting to: %s", statusURL)
+	resp, err := http.Get(statusURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to warewulf server")
+	}
+	defer resp.Body.Close()
+
+	var status allStatus
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode node status")
+	}
+	return status.Nodes, nil
+}
+
+/*
+Filters nodes with a selector (see node.ParseSelector). The provisioning
+stages are only fetched from warewulfd if the selector uses them.
+*/
+func SelectNodes(nodes []node.NodeInfo, selector string) ([]node.NodeInfo, error) {
+	sel, err := node.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(sel) == 0 {
+		return nodes, nil
+	}
+	stages := make(map[string]string)
+	if sel.NeedsStage() {
+		status, err := GetNodeStatus()
+		if err != nil {
+			return nil, err
+		}
+		for name, s := range status {
+			stages[name] = s.Stage
+		}
+	}
+	return node.FilterBySelector(nodes, sel, stages), nil
+}
@@ -0,0 +1,47 @@
+package warewulfd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hpcng/warewulf/internal/pkg/heartbeat"
+)
+
+func Test_nodeState(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   NodeStatus
+		timeout  int
+		expected string
+	}{
+		{name: "no heartbeat", status: NodeStatus{Lastseen: 100}, timeout: 180},
+		{name: "up", status: NodeStatus{Heartbeat: &heartbeat.Heartbeat{}, LastHeartbeat: 900}, timeout: 180, expected: StateUp},
+		{name: "down", status: NodeStatus{Heartbeat: &heartbeat.Heartbeat{}, LastHeartbeat: 800}, timeout: 180, expected: StateDown},
+		{name: "no timeout", status: NodeStatus{Heartbeat: &heartbeat.Heartbeat{}, LastHeartbeat: 800}, expected: StateUp},
+		{
+			name:     "unhealthy",
+			status:   NodeStatus{Heartbeat: &heartbeat.Heartbeat{FailedUnits: []string{"munge.service"}}, LastHeartbeat: 900},
+			timeout:  180,
+			expected: StateUnhealthy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, nodeState(&tt.status, 1000, tt.timeout))
+		})
+	}
+}
+
+func Test_updateStatus(t *testing.T) {
+	updateHeartbeat("n1", "10.0.0.1", heartbeat.Heartbeat{Uptime: 10})
+	updateApplied("n1", "abc")
+	updateStatus("n1", "RUNTIME_OVERLAY", "generic.img.gz", "10.0.0.1")
+	assert.Equal(t, "abc", statusDB.Nodes["n1"].Applied)
+	assert.Equal(t, int64(10), statusDB.Nodes["n1"].Heartbeat.Uptime)
+
+	// the heartbeat of the last boot is dropped
+	updateStatus("n1", "IPXE", "default.ipxe", "10.0.0.1")
+	assert.Nil(t, statusDB.Nodes["n1"].Heartbeat)
+	assert.Equal(t, int64(0), statusDB.Nodes["n1"].LastHeartbeat)
+}
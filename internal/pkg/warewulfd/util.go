@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/hpcng/warewulf/internal/pkg/container"
 	nodepkg "github.com/hpcng/warewulf/internal/pkg/node"
 	"github.com/hpcng/warewulf/internal/pkg/overlay"
 	"github.com/hpcng/warewulf/internal/pkg/util"
@@ -44,6 +45,7 @@ func sendFile(
 func getOverlayFile(
 	nodeId string,
 	stage_overlays []string,
+	container_name string,
 	autobuild bool ) (stage_file string, err error) {
 
 	stage_file = overlay.OverlayImage(nodeId, stage_overlays)
@@ -52,11 +54,17 @@ func getOverlayFile(
 	build := !util.IsFile(stage_file)
 
 	if !build && autobuild {
-		build = util.PathIsNewer(stage_file, nodepkg.ConfigFile)
+		build = util.PathIsNewer(stage_file, nodepkg.DatabaseFile())
 
 		for _, overlayname := range stage_overlays {
 			build = build || util.PathIsNewer(stage_file, overlay.OverlaySourceDir(overlayname))
 		}
+
+		// overlays contain the digest of the container and may include
+		// files from it
+		if container_name != "" {
+			build = build || util.PathIsNewer(stage_file, container.ImageDigestFile(container_name))
+		}
 	}
 
 	if build {
@@ -15,6 +15,31 @@ import (
 // TODO: https://github.com/danderson/netboot/blob/master/pixiecore/dhcp.go
 // TODO: https://github.com/pin/tftp
 
+/*
+Handles the signals of warewulfd until c is closed: SIGHUP reloads the
+databases, SIGUSR1 tells that overlays were built
+*/
+func handleSignals(c <-chan os.Signal) {
+	for sig := range c {
+		if sig == syscall.SIGUSR1 {
+			wwlog.Verbose("Received SIGUSR1, overlays changed")
+			notifyOverlaysChanged()
+			continue
+		}
+		wwlog.Warn("Received SIGHUP, reloading...")
+		err := LoadNodeDB()
+		if err != nil {
+			wwlog.Error("Could not load node DB: %s", err)
+		}
+
+		err = LoadNodeStatus()
+		if err != nil {
+			wwlog.Error("Could not prepopulate node status DB: %s", err)
+		}
+		notifyOverlaysChanged()
+	}
+}
+
 func RunServer() error {
 	err := DaemonInitLogging()
 	if err != nil {
@@ -22,22 +47,9 @@ func RunServer() error {
 	}
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGHUP)
-
-	go func() {
-		for range c {
-			wwlog.Warn("Received SIGHUP, reloading...")
-			err := LoadNodeDB()
-			if err != nil {
-				wwlog.Error("Could not load node DB: %s", err)
-			}
-
-			err = LoadNodeStatus()
-			if err != nil {
-				wwlog.Error("Could not prepopulate node status DB: %s", err)
-			}
-		}
-	}()
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGUSR1)
+
+	go handleSignals(c)
 
 	err = LoadNodeDB()
 	if err != nil {
@@ -56,8 +68,18 @@ func RunServer() error {
 	http.HandleFunc("/container/", ProvisionSend)
 	http.HandleFunc("/overlay-system/", ProvisionSend)
 	http.HandleFunc("/overlay-runtime/", ProvisionSend)
+	http.HandleFunc("/inventory/", InventoryReceive)
+	http.HandleFunc("/heartbeat/", HeartbeatReceive)
+	http.HandleFunc("/exec/", ExecNode)
+	http.HandleFunc("/jobs", ExecJobs)
+	http.HandleFunc("/jobs/", ExecJobs)
 	http.HandleFunc("/status", StatusSend)
 
+	err = writeToken()
+	if err != nil {
+		wwlog.Error("Could not write token, commands can't be run on nodes: %s", err)
+	}
+
 	conf, err := warewulfconf.New()
 	if err != nil {
 		return errors.Wrap(err, "could not get Warewulf configuration")